@@ -0,0 +1,88 @@
+// MCP服务器入口：通过Model Context Protocol向外部LLM智能体暴露图片库
+//
+// 用法:
+//
+//	mcp -transport stdio -user alice                由MCP客户端作为子进程启动，以alice的身份访问其个人工作区
+//	mcp -transport stdio -user alice -workspace 3   以alice的身份访问ID为3的团队工作区
+//	mcp -transport http -addr :8090                 Streamable HTTP，客户端使用登录获得的JWT认证，X-Workspace-ID请求头选择工作区
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"image-manager/internal/config"
+	"image-manager/internal/database"
+	"image-manager/internal/mcp"
+	"image-manager/internal/services"
+
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	cfg := config.Load()
+
+	transport := flag.String("transport", "stdio", "传输方式：stdio 或 http")
+	addr := flag.String("addr", cfg.MCPAddr, "http传输的监听地址")
+	username := flag.String("user", cfg.MCPUsername, "stdio传输使用的用户名或邮箱")
+	workspaceID := flag.Uint("workspace", 0, "stdio传输访问的工作区ID，0表示用户的个人工作区")
+	flag.Parse()
+
+	// stdout专用于协议消息，所有日志都写到stderr
+	log.SetOutput(os.Stderr)
+	db := database.Open(cfg, logger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      logger.Warn,
+	}))
+
+	library := services.NewLibrary(db, cfg)
+	authService := services.NewAuthService(db, cfg.JWTSecret)
+	workspaceService := services.NewWorkspaceService(db, authService)
+	albumService := services.NewAlbumService(db, library.Images)
+	server := mcp.NewServer(library.Images, library.Tags, albumService, library.AI)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch *transport {
+	case "stdio":
+		if *username == "" {
+			log.Fatal("stdio传输需要通过 -user 或 MCP_USERNAME 指定用户")
+		}
+		user, err := authService.FindUser(*username)
+		if err != nil {
+			log.Fatalf("查找用户失败: %v", err)
+		}
+		member, err := workspaceService.Resolve(user.ID, *workspaceID)
+		if err != nil {
+			log.Fatalf("进入工作区失败: %v", err)
+		}
+		if err := mcp.ServeStdio(ctx, server, member, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+			log.Fatalf("mcp stdio failed: %v", err)
+		}
+	case "http":
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", mcp.NewHTTPHandler(server, cfg.JWTSecret, cfg.CORSOrigins, workspaceService.Resolve))
+		httpServer := &http.Server{Addr: *addr, Handler: mux}
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = httpServer.Shutdown(shutdownCtx)
+		}()
+
+		log.Printf("MCP服务器监听 %s/mcp", *addr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("mcp http failed: %v", err)
+		}
+	default:
+		log.Fatalf("未知的传输方式: %s", *transport)
+	}
+}
@@ -0,0 +1,264 @@
+// Package animation 实现动图（GIF、WebP）的逐帧解码、处理和编码
+// 解码时按各帧的处置方式合成为完整画布，每一帧都是与画布等大的图片，
+// 因此裁剪、缩放、调色等编辑操作可以逐帧独立执行后再编码回动图
+package animation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// MaxPixels 解码动图时所有帧像素总数的上限，超过时返回ErrTooLarge，调用方退化为只处理第一帧
+const MaxPixels = 64 << 20
+
+// ErrTooLarge 动图帧数或尺寸过大
+var ErrTooLarge = errors.New("动图过大")
+
+// defaultDelay 帧延迟为0或过短时使用的延迟，与主流浏览器的处理一致
+const defaultDelay = 100 * time.Millisecond
+
+// Animation 解码后的动图
+type Animation struct {
+	Frames    []image.Image   // 与画布等大的完整帧
+	Delays    []time.Duration // 每帧的显示时长
+	LoopCount int             // 播放次数，0表示无限循环
+}
+
+// Duration 一次播放的总时长
+func (a *Animation) Duration() time.Duration {
+	var total time.Duration
+	for _, d := range a.Delays {
+		total += d
+	}
+	return total
+}
+
+// Map 对每一帧执行同一个处理函数，返回新的动图
+func (a *Animation) Map(fn func(image.Image) (image.Image, error)) (*Animation, error) {
+	out := &Animation{
+		Frames:    make([]image.Image, len(a.Frames)),
+		Delays:    append([]time.Duration(nil), a.Delays...),
+		LoopCount: a.LoopCount,
+	}
+	for i, frame := range a.Frames {
+		processed, err := fn(frame)
+		if err != nil {
+			return nil, fmt.Errorf("第%d帧: %v", i+1, err)
+		}
+		out.Frames[i] = processed
+	}
+	return out, nil
+}
+
+// Sample 均匀抽取不超过n帧，被跳过的帧的时长累加到前一个保留帧上，总时长不变
+func (a *Animation) Sample(n int) *Animation {
+	if n <= 0 || len(a.Frames) <= n {
+		return a
+	}
+	out := &Animation{LoopCount: a.LoopCount}
+	for i := 0; i < n; i++ {
+		start, end := i*len(a.Frames)/n, (i+1)*len(a.Frames)/n
+		var delay time.Duration
+		for _, d := range a.Delays[start:end] {
+			delay += d
+		}
+		out.Frames = append(out.Frames, a.Frames[start])
+		out.Delays = append(out.Delays, delay)
+	}
+	return out
+}
+
+// Info 动图的帧数和时长
+type Info struct {
+	Frames   int
+	Duration time.Duration
+}
+
+// Probe 获取GIF或WebP的帧数和总时长，不合成画布；静态图片返回1帧
+// WebP只读取帧头，GIF需要解压全部帧数据；format为image.DecodeConfig返回的格式名，其他格式返回nil
+func Probe(data []byte, format string) (*Info, error) {
+	switch format {
+	case "gif":
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		info := &Info{Frames: len(g.Image)}
+		for _, d := range g.Delay {
+			info.Duration += gifDelay(d)
+		}
+		return info, nil
+	case "webp":
+		w, err := parseWebP(data)
+		if err != nil {
+			return nil, err
+		}
+		if !w.animated {
+			return &Info{Frames: 1}, nil
+		}
+		info := &Info{Frames: len(w.frames)}
+		for _, f := range w.frames {
+			info.Duration += f.delay
+		}
+		return info, nil
+	}
+	return nil, nil
+}
+
+// IsAnimated 判断图片数据是否为多帧动图
+func IsAnimated(data []byte, format string) bool {
+	info, err := Probe(data, format)
+	return err == nil && info != nil && info.Frames > 1
+}
+
+// Decode 解码GIF或WebP动图，静态图片返回nil
+func Decode(data []byte, format string) (*Animation, error) {
+	switch format {
+	case "gif":
+		return decodeGIF(data)
+	case "webp":
+		return decodeWebP(data)
+	}
+	return nil, nil
+}
+
+// Encode 把动图编码为GIF或WebP（无损）
+func Encode(w io.Writer, a *Animation, format string) error {
+	if len(a.Frames) == 0 {
+		return errors.New("动图没有帧")
+	}
+	switch format {
+	case "gif":
+		return encodeGIF(w, a)
+	case "webp":
+		return encodeWebP(w, a)
+	}
+	return fmt.Errorf("不支持的动图格式: %s", format)
+}
+
+// gifDelay 把GIF的帧延迟（1/100秒）换算为时长
+func gifDelay(centiseconds int) time.Duration {
+	d := time.Duration(centiseconds) * 10 * time.Millisecond
+	if d <= 10*time.Millisecond {
+		return defaultDelay
+	}
+	return d
+}
+
+// decodeGIF 解码GIF并按处置方式合成完整帧
+func decodeGIF(data []byte) (*Animation, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) <= 1 {
+		return nil, nil
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	if len(g.Image)*bounds.Dx()*bounds.Dy() > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	a := &Animation{LoopCount: fromGIFLoopCount(g.LoopCount)}
+	canvas := image.NewNRGBA(bounds)
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		a.Frames = append(a.Frames, cloneNRGBA(canvas))
+		a.Delays = append(a.Delays, gifDelay(g.Delay[i]))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return a, nil
+}
+
+// fromGIFLoopCount 把GIF的循环次数换算为播放次数：GIF中0为无限循环，-1为只播放一次，n为重复n次（共播放n+1次）
+func fromGIFLoopCount(n int) int {
+	switch {
+	case n == 0:
+		return 0
+	case n < 0:
+		return 1
+	}
+	return n + 1
+}
+
+// toGIFLoopCount fromGIFLoopCount的逆运算
+func toGIFLoopCount(n int) int {
+	switch {
+	case n == 0:
+		return 0
+	case n == 1:
+		return -1
+	}
+	return n - 1
+}
+
+// encodeGIF 编码GIF，每帧用Plan9调色板加一个透明色做误差扩散量化
+func encodeGIF(w io.Writer, a *Animation) error {
+	pal := append(color.Palette{color.Transparent}, palette.Plan9[:255]...)
+	g := &gif.GIF{LoopCount: toGIFLoopCount(a.LoopCount)}
+	for i, frame := range a.Frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), pal)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, bounds.Min)
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, int(a.Delays[i]/(10*time.Millisecond)))
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, g)
+}
+
+// encodeWebP 编码无损WebP动图
+func encodeWebP(w io.Writer, a *Animation) error {
+	ani := &nativewebp.Animation{
+		Images:    make([]image.Image, len(a.Frames)),
+		Durations: make([]uint, len(a.Frames)),
+		Disposals: make([]uint, len(a.Frames)),
+		LoopCount: uint16(a.LoopCount),
+	}
+	for i, frame := range a.Frames {
+		// 每帧都是完整画布，从原点开始，显示前清空到背景，避免半透明像素叠加
+		bounds := frame.Bounds()
+		if bounds.Min != (image.Point{}) {
+			frame = cloneNRGBA(frame)
+		}
+		ani.Images[i] = frame
+		ani.Durations[i] = uint(a.Delays[i] / time.Millisecond)
+		ani.Disposals[i] = 1
+	}
+	return nativewebp.EncodeAll(w, ani, nil)
+}
+
+// cloneNRGBA 复制图片为坐标从原点开始的NRGBA
+func cloneNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
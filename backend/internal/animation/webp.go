@@ -0,0 +1,182 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+// errInvalidWebP WebP文件结构无效
+var errInvalidWebP = errors.New("无效的WebP文件")
+
+// webpFile 解析后的WebP容器结构
+type webpFile struct {
+	animated  bool
+	width     int // 画布宽度
+	height    int // 画布高度
+	loopCount int
+	frames    []webpFrame
+}
+
+// webpFrame ANMF帧
+type webpFrame struct {
+	x, y          int           // 帧在画布中的偏移
+	width, height int           // 帧尺寸
+	delay         time.Duration // 显示时长
+	noBlend       bool          // true时直接覆盖画布，否则按透明度混合
+	dispose       bool          // true时显示后把帧区域清空为透明
+	data          []byte        // 帧的ALPH、VP8、VP8L子块
+}
+
+// parseWebP 解析WebP的RIFF容器，只读取块头，不解码像素
+func parseWebP(data []byte) (*webpFile, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebP
+	}
+	w := &webpFile{}
+	err := eachChunk(data[12:], func(id string, payload []byte) error {
+		switch id {
+		case "VP8X":
+			if len(payload) < 10 {
+				return errInvalidWebP
+			}
+			w.animated = payload[0]&(1<<1) != 0
+			w.width = int(uint24(payload[4:])) + 1
+			w.height = int(uint24(payload[7:])) + 1
+		case "ANIM":
+			if len(payload) < 6 {
+				return errInvalidWebP
+			}
+			w.loopCount = int(binary.LittleEndian.Uint16(payload[4:]))
+		case "ANMF":
+			if len(payload) < 16 {
+				return errInvalidWebP
+			}
+			delay := time.Duration(uint24(payload[12:])) * time.Millisecond
+			if delay <= 10*time.Millisecond {
+				delay = defaultDelay
+			}
+			w.frames = append(w.frames, webpFrame{
+				x:       int(uint24(payload[0:])) * 2,
+				y:       int(uint24(payload[3:])) * 2,
+				width:   int(uint24(payload[6:])) + 1,
+				height:  int(uint24(payload[9:])) + 1,
+				delay:   delay,
+				noBlend: payload[15]&(1<<1) != 0,
+				dispose: payload[15]&1 != 0,
+				data:    payload[16:],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// decodeWebP 解码WebP动图并按混合和处置方式合成完整帧
+func decodeWebP(data []byte) (*Animation, error) {
+	w, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+	if !w.animated || len(w.frames) <= 1 {
+		return nil, nil
+	}
+	if len(w.frames)*w.width*w.height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	a := &Animation{LoopCount: w.loopCount}
+	canvas := image.NewNRGBA(image.Rect(0, 0, w.width, w.height))
+	for _, f := range w.frames {
+		img, err := webp.Decode(bytes.NewReader(frameFile(f)))
+		if err != nil {
+			return nil, err
+		}
+		rect := image.Rect(f.x, f.y, f.x+f.width, f.y+f.height)
+		op := draw.Over
+		if f.noBlend {
+			op = draw.Src
+		}
+		draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+		a.Frames = append(a.Frames, cloneNRGBA(canvas))
+		a.Delays = append(a.Delays, f.delay)
+		if f.dispose {
+			draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+	return a, nil
+}
+
+// frameFile 把ANMF帧的子块包装为独立的WebP文件，交给静态WebP解码器
+// 有ALPH块的有损帧需要VP8X容器并设置透明标志；无损帧自带透明通道，直接包装
+func frameFile(f webpFrame) []byte {
+	body := &bytes.Buffer{}
+	body.WriteString("WEBP")
+	hasAlpha := false
+	eachChunk(f.data, func(id string, _ []byte) error {
+		hasAlpha = hasAlpha || id == "ALPH"
+		return nil
+	})
+	if hasAlpha {
+		header := make([]byte, 10)
+		header[0] = 1 << 4
+		putUint24(header[4:], uint32(f.width-1))
+		putUint24(header[7:], uint32(f.height-1))
+		writeChunk(body, "VP8X", header)
+	}
+	body.Write(f.data)
+
+	file := &bytes.Buffer{}
+	file.WriteString("RIFF")
+	binary.Write(file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+// eachChunk 遍历RIFF块，块数据按偶数字节对齐
+func eachChunk(data []byte, fn func(id string, payload []byte) error) error {
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size < 0 || 8+size > len(data) {
+			return errInvalidWebP
+		}
+		if err := fn(id, data[8:8+size]); err != nil {
+			return err
+		}
+		next := 8 + size + size%2
+		if next > len(data) {
+			break
+		}
+		data = data[next:]
+	}
+	return nil
+}
+
+// writeChunk 写入一个RIFF块
+func writeChunk(buf *bytes.Buffer, id string, payload []byte) {
+	buf.WriteString(id)
+	binary.Write(buf, binary.LittleEndian, uint32(len(payload)))
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// uint24 读取小端24位整数
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// putUint24 写入小端24位整数
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -18,6 +18,7 @@ type Config struct {
 	StorageDir      string
 	ThumbnailWidth  int
 	ThumbnailHeight int
+	AnimatedThumbs  bool // 动图是否生成GIF动态缩略图，关闭时取第一帧生成静态缩略图
 	MaxUploadSize   int64
 	CORSOrigins     []string
 	// AI相关配置（使用智谱AI GLM-4 Vision，国内可用）
@@ -25,6 +26,43 @@ type Config struct {
 	AIApiURL        string  // 智谱AI API的URL
 	AIModel         string  // 使用的AI模型名称，默认为glm-4v（支持图片分析）
 	AIEnabled       bool    // 是否启用AI功能
+	// AI调用的容错配置
+	AITimeoutSeconds         int     // 单次AI请求超时时间（秒）
+	AIMaxRetries             int     // 网络错误、429或5xx时的最大重试次数
+	AIRetryBaseMillis        int     // 重试退避的基础间隔（毫秒），每次重试翻倍
+	AIRateLimit              float64 // 每秒允许的AI请求数（令牌桶补充速率），<=0 表示不限流
+	AIRateBurst              int     // 令牌桶容量，即允许的突发请求数
+	AIBreakerThreshold       int     // 连续失败多少次后打开熔断器，<=0 表示禁用熔断
+	AIBreakerCooldownSeconds int     // 熔断器打开后的冷却时间（秒）
+	AIQueryCacheTTLSeconds   int     // 自然语言查询转换结果的缓存时间（秒），<=0 表示禁用缓存
+	AIQueryCacheSize         int     // 查询转换缓存的最大条目数
+	// 人脸检测与人物聚类
+	FaceDetectionEnabled bool    // 是否在上传后检测人脸并聚类为人物
+	FaceMinScore         float64 // 人脸检测置信度阈值，低于该值的候选框被丢弃
+	FaceMatchThreshold   float64 // 人脸与人物聚类中心的相似度阈值（0-1），达到该值归入该人物，否则新建人物
+	// 画质检测
+	QualityBlurThreshold  float64 // 清晰度（拉普拉斯方差）低于该值的图片视为模糊
+	QualityNoiseThreshold float64 // 噪点估计高于该值的图片视为噪点过多
+	// OCR文字识别
+	OCREngine         string  // 识别引擎：tesseract（本地命令行）、ai（视觉大模型）或 none（禁用）
+	OCRTesseractPath  string  // tesseract可执行文件名或路径
+	OCRLanguages      string  // tesseract语言包，多个用+连接
+	OCRMinConfidence  float64 // tesseract词的最低置信度（0-100），低于该值的词被丢弃
+	OCRTimeoutSeconds int     // 单张图片识别的超时时间（秒）
+	// 水印
+	WatermarkFontPath string // 文字水印字体文件（TTF/OTF/TTC），为空时使用不含中文字形的内置字体
+	// 相机格式转换
+	HEIFConverterPath string // heif-convert（libheif）可执行文件名或路径，找不到时无法上传HEIC/HEIF
+	RAWConverterPath  string // dcraw_emu（LibRaw）可执行文件名或路径，找不到时RAW只使用内嵌预览图
+	// 视频
+	FFmpegPath         string // ffmpeg可执行文件名或路径，找不到时无法上传视频
+	FFprobePath        string // ffprobe可执行文件名或路径
+	MaxVideoUploadSize int64  // 视频（包括Live Photo的动态部分）的最大上传大小（字节）
+	// MCP服务器配置（cmd/mcp）
+	MCPAddr     string // Streamable HTTP传输的监听地址
+	MCPUsername string // stdio传输以哪个用户（用户名或邮箱）身份访问图片库
+	// 系统管理员
+	AdminUsers []string // 系统管理员的用户名或邮箱，可以查看AI调用统计等全局运行状态；为空表示没有管理员
 }
 
 func Load() Config {
@@ -39,6 +77,7 @@ func Load() Config {
 		StorageDir:      getEnv("STORAGE_DIR", "./storage"),
 		ThumbnailWidth:  getEnvAsInt("THUMBNAIL_WIDTH", 300),
 		ThumbnailHeight: getEnvAsInt("THUMBNAIL_HEIGHT", 300),
+		AnimatedThumbs:  getEnvAsBool("THUMBNAIL_ANIMATED", true),
 		MaxUploadSize:   getEnvAsInt64("MAX_UPLOAD_SIZE", 10*1024*1024),
 		CORSOrigins:     getEnvAsSlice("CORS_ALLOWED_ORIGINS", []string{"http://localhost:5173"}),
 		// AI配置，使用智谱AI GLM-4 Vision（国内可用）
@@ -46,6 +85,34 @@ func Load() Config {
 		AIApiURL:        getEnv("AI_API_URL", "https://open.bigmodel.cn/api/paas/v4/chat/completions"),
 		AIModel:         getEnv("AI_MODEL", "glm-4v"),
 		AIEnabled:       getEnvAsBool("AI_ENABLED", true),  // 默认不启用，需要显式设置
+		AITimeoutSeconds:         getEnvAsInt("AI_TIMEOUT_SECONDS", 30),
+		AIMaxRetries:             getEnvAsInt("AI_MAX_RETRIES", 2),
+		AIRetryBaseMillis:        getEnvAsInt("AI_RETRY_BASE_MS", 500),
+		AIRateLimit:              getEnvAsFloat("AI_RATE_LIMIT", 2),
+		AIRateBurst:              getEnvAsInt("AI_RATE_BURST", 5),
+		AIBreakerThreshold:       getEnvAsInt("AI_BREAKER_THRESHOLD", 5),
+		AIBreakerCooldownSeconds: getEnvAsInt("AI_BREAKER_COOLDOWN_SECONDS", 30),
+		AIQueryCacheTTLSeconds:   getEnvAsInt("AI_QUERY_CACHE_TTL_SECONDS", 600),
+		AIQueryCacheSize:         getEnvAsInt("AI_QUERY_CACHE_SIZE", 1000),
+		FaceDetectionEnabled:     getEnvAsBool("FACE_DETECTION_ENABLED", true),
+		FaceMinScore:             getEnvAsFloat("FACE_MIN_SCORE", 20),
+		FaceMatchThreshold:       getEnvAsFloat("FACE_MATCH_THRESHOLD", 0.9),
+		QualityBlurThreshold:     getEnvAsFloat("QUALITY_BLUR_THRESHOLD", 100),
+		QualityNoiseThreshold:    getEnvAsFloat("QUALITY_NOISE_THRESHOLD", 5),
+		OCREngine:                getEnv("OCR_ENGINE", "tesseract"),
+		OCRTesseractPath:         getEnv("OCR_TESSERACT_PATH", "tesseract"),
+		OCRLanguages:             getEnv("OCR_LANGUAGES", "chi_sim+eng"),
+		OCRMinConfidence:         getEnvAsFloat("OCR_MIN_CONFIDENCE", 60),
+		OCRTimeoutSeconds:        getEnvAsInt("OCR_TIMEOUT_SECONDS", 30),
+		WatermarkFontPath:        getEnv("WATERMARK_FONT_PATH", ""),
+		HEIFConverterPath:        getEnv("HEIF_CONVERTER_PATH", "heif-convert"),
+		RAWConverterPath:         getEnv("RAW_CONVERTER_PATH", "dcraw_emu"),
+		FFmpegPath:               getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:              getEnv("FFPROBE_PATH", "ffprobe"),
+		MaxVideoUploadSize:       getEnvAsInt64("MAX_VIDEO_UPLOAD_SIZE", 500*1024*1024),
+		MCPAddr:                  getEnv("MCP_ADDR", ":8090"),
+		MCPUsername:              getEnv("MCP_USERNAME", ""),
+		AdminUsers:               getEnvAsSlice("ADMIN_USERS", nil),
 	}
 }
 
@@ -76,6 +143,16 @@ func getEnvAsInt64(key string, fallback int64) int64 {
 	return fallback
 }
 
+func getEnvAsFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+		log.Printf("invalid value for %s, using fallback %v", key, fallback)
+	}
+	return fallback
+}
+
 func getEnvAsBool(key string, fallback bool) bool {
 	if value, ok := os.LookupEnv(key); ok {
 		if parsed, err := strconv.ParseBool(value); err == nil {
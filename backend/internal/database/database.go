@@ -13,6 +13,12 @@ import (
 )
 
 func New(cfg config.Config) *gorm.DB {
+	return Open(cfg, logger.Default.LogMode(logger.Info))
+}
+
+// Open 使用指定的SQL日志记录器连接数据库并执行迁移
+// 以stdio方式运行的MCP服务器需要把日志写到stderr，避免污染stdout上的协议消息
+func Open(cfg config.Config, sqlLogger logger.Interface) *gorm.DB {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		cfg.DBUser,
 		cfg.DBPassword,
@@ -22,22 +28,136 @@ func New(cfg config.Config) *gorm.DB {
 	)
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: sqlLogger,
 	})
 	if err != nil {
 		log.Fatalf("failed to connect database: %v", err)
 	}
 
-	if err := db.AutoMigrate(
+	if err := Migrate(db); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return db
+}
+
+// Migrate 创建或升级全部数据表
+func Migrate(db *gorm.DB) error {
+	// 工作区需要先于其他表迁移：升级时已有数据要归入各用户的个人工作区
+	if err := db.AutoMigrate(&models.User{}, &models.Workspace{}, &models.WorkspaceMember{}); err != nil {
+		return err
+	}
+	if err := migrateWorkspaces(db); err != nil {
+		return fmt.Errorf("failed to migrate workspaces: %w", err)
+	}
+
+	return db.AutoMigrate(
 		&models.User{},
+		&models.Workspace{},
+		&models.WorkspaceMember{},
 		&models.Image{},
 		&models.ImageEXIF{},
 		&models.Tag{},
 		&models.ImageTag{},
 		&models.Thumbnail{},
-	); err != nil {
-		log.Fatalf("failed to migrate database: %v", err)
+		&models.SearchSession{},
+		&models.SearchMessage{},
+		&models.Person{},
+		&models.Face{},
+		&models.ImageColor{},
+		&models.ImageQuality{},
+		&models.ImageText{},
+		&models.ImageVersion{},
+		&models.Watermark{},
+		&models.Album{},
+		&models.AlbumItem{},
+		&models.Slideshow{},
+		&models.SlideshowItem{},
+		&models.RenderJob{},
+		&models.ShareLink{},
+		&models.ShareGrant{},
+		&models.ShareGrantImage{},
+		&models.ImageComment{},
+		&models.ImageAnnotation{},
+		&models.ImageFavorite{},
+	)
+}
+
+// workspaceTables 按工作区划分的表，升级前这些数据按user_id归属于各个用户
+var workspaceTables = []interface{}{
+	&models.Image{},
+	&models.Tag{},
+	&models.Album{},
+	&models.Person{},
+	&models.Face{},
+	&models.Slideshow{},
+	&models.RenderJob{},
+	&models.ShareLink{},
+	&models.SearchSession{},
+}
+
+// migrateWorkspaces 为每个还没有个人工作区的用户创建个人工作区，并把没有工作区的已有数据归入创建者的个人工作区
+// 标签名改为在工作区内唯一，需要在创建新的唯一索引之前完成归属，所以在AutoMigrate之前执行
+func migrateWorkspaces(db *gorm.DB) error {
+	var users []models.User
+	personal := db.Model(&models.Workspace{}).Select("owner_id").Where("personal = ?", true)
+	if err := db.Where("id NOT IN (?)", personal).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			workspace := models.Workspace{Name: user.Username, Personal: true, OwnerID: user.ID}
+			if err := tx.Create(&workspace).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: models.RoleOwner}).Error
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return db
+	migrator := db.Migrator()
+	for _, model := range workspaceTables {
+		if !migrator.HasTable(model) {
+			continue
+		}
+		if !migrator.HasColumn(model, "WorkspaceID") {
+			if err := migrator.AddColumn(model, "WorkspaceID"); err != nil {
+				return err
+			}
+		}
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if err := assignPersonalWorkspace(db, stmt.Schema.Table, "user_id"); err != nil {
+			return err
+		}
+	}
+	if migrator.HasTable(&models.ShareGrant{}) {
+		if !migrator.HasColumn(&models.ShareGrant{}, "WorkspaceID") {
+			if err := migrator.AddColumn(&models.ShareGrant{}, "WorkspaceID"); err != nil {
+				return err
+			}
+		}
+		if err := assignPersonalWorkspace(db, "share_grants", "owner_id"); err != nil {
+			return err
+		}
+	}
+
+	// 标签原来按用户唯一
+	if migrator.HasTable(&models.Tag{}) && migrator.HasIndex(&models.Tag{}, "idx_user_tag") {
+		return migrator.DropIndex(&models.Tag{}, "idx_user_tag")
+	}
+	return nil
+}
+
+// assignPersonalWorkspace 把表中还没有工作区的行归入ownerColumn所指用户的个人工作区
+// 新加的workspace_id列在已有行上为NULL（没有默认值），所以同时匹配NULL和0
+func assignPersonalWorkspace(db *gorm.DB, table, ownerColumn string) error {
+	return db.Exec(fmt.Sprintf(
+		"UPDATE %[1]s SET workspace_id = (SELECT w.id FROM workspaces w WHERE w.owner_id = %[1]s.%[2]s AND w.personal = ?) "+
+			"WHERE (workspace_id IS NULL OR workspace_id = 0) AND %[2]s IN (SELECT owner_id FROM workspaces WHERE personal = ?)",
+		table, ownerColumn), true, true).Error
 }
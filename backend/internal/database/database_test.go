@@ -0,0 +1,117 @@
+package database
+
+import (
+	"testing"
+
+	"image-manager/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// preWorkspaceSchema 引入工作区之前的表结构：数据按user_id（授权按owner_id）归属于用户，没有workspace_id列
+var preWorkspaceSchema = []string{
+	"CREATE TABLE images (id INTEGER PRIMARY KEY, user_id INTEGER, original_filename TEXT, created_at DATETIME, updated_at DATETIME)",
+	"CREATE TABLE tags (id INTEGER PRIMARY KEY, user_id INTEGER, name TEXT, color TEXT)",
+	"CREATE UNIQUE INDEX idx_user_tag ON tags (user_id, name)",
+	"CREATE TABLE albums (id INTEGER PRIMARY KEY, user_id INTEGER, name TEXT, created_at DATETIME, updated_at DATETIME)",
+	"CREATE TABLE share_grants (id INTEGER PRIMARY KEY, owner_id INTEGER, recipient_id INTEGER, kind TEXT, access TEXT, status TEXT, created_at DATETIME)",
+}
+
+func TestMigrateWorkspacesAssignsExistingRows(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range preWorkspaceSchema {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	alice := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	bob := models.User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	if err := db.Create(&alice).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&bob).Error; err != nil {
+		t.Fatal(err)
+	}
+	seed := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"INSERT INTO images (id, user_id, original_filename) VALUES (?, ?, ?)", []interface{}{1, alice.ID, "a.jpg"}},
+		{"INSERT INTO images (id, user_id, original_filename) VALUES (?, ?, ?)", []interface{}{2, bob.ID, "b.jpg"}},
+		// 升级前标签名按用户唯一，两个用户可以有同名标签
+		{"INSERT INTO tags (id, user_id, name) VALUES (?, ?, ?)", []interface{}{1, alice.ID, "旅行"}},
+		{"INSERT INTO tags (id, user_id, name) VALUES (?, ?, ?)", []interface{}{2, bob.ID, "旅行"}},
+		{"INSERT INTO albums (id, user_id, name) VALUES (?, ?, ?)", []interface{}{1, bob.ID, "夏天"}},
+		{"INSERT INTO share_grants (id, owner_id, recipient_id, kind, access, status) VALUES (?, ?, ?, ?, ?, ?)", []interface{}{1, alice.ID, bob.ID, "images", "read", "accepted"}},
+	}
+	for _, row := range seed {
+		if err := db.Exec(row.sql, row.args...).Error; err != nil {
+			t.Fatalf("%s: %v", row.sql, err)
+		}
+	}
+
+	// 只执行工作区迁移：其余表的迁移用到了MySQL专有的全文索引
+	migrate := func() error {
+		if err := db.AutoMigrate(&models.User{}, &models.Workspace{}, &models.WorkspaceMember{}); err != nil {
+			return err
+		}
+		return migrateWorkspaces(db)
+	}
+	if err := migrate(); err != nil {
+		t.Fatalf("migrateWorkspaces: %v", err)
+	}
+
+	personal := map[uint]uint{}
+	for _, user := range []models.User{alice, bob} {
+		var workspace models.Workspace
+		if err := db.Where("owner_id = ? AND personal = ?", user.ID, true).First(&workspace).Error; err != nil {
+			t.Fatalf("personal workspace of %s: %v", user.Username, err)
+		}
+		var member models.WorkspaceMember
+		if err := db.Where("workspace_id = ? AND user_id = ?", workspace.ID, user.ID).First(&member).Error; err != nil || member.Role != models.RoleOwner {
+			t.Fatalf("%s should own their personal workspace, got %+v, %v", user.Username, member, err)
+		}
+		personal[user.ID] = workspace.ID
+	}
+
+	tests := []struct {
+		table string
+		id    uint
+		owner uint
+	}{
+		{"images", 1, alice.ID},
+		{"images", 2, bob.ID},
+		{"tags", 1, alice.ID},
+		{"tags", 2, bob.ID},
+		{"albums", 1, bob.ID},
+		{"share_grants", 1, alice.ID},
+	}
+	for _, tt := range tests {
+		var workspaceID *uint
+		if err := db.Table(tt.table).Where("id = ?", tt.id).Select("workspace_id").Row().Scan(&workspaceID); err != nil {
+			t.Fatalf("%s %d: %v", tt.table, tt.id, err)
+		}
+		if workspaceID == nil || *workspaceID != personal[tt.owner] {
+			t.Errorf("%s %d: workspace_id = %v, want %d", tt.table, tt.id, workspaceID, personal[tt.owner])
+		}
+	}
+
+	// 再次迁移不会重复创建个人工作区，也不会改变已有归属
+	if err := migrate(); err != nil {
+		t.Fatalf("second migrateWorkspaces: %v", err)
+	}
+	var count int64
+	db.Model(&models.Workspace{}).Where("personal = ?", true).Count(&count)
+	if count != 2 {
+		t.Errorf("personal workspaces = %d, want 2", count)
+	}
+}
@@ -1,6 +1,11 @@
 package dto
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+	"time"
+
+	"image-manager/internal/editor"
+)
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=6,max=50"`
@@ -41,26 +46,160 @@ type AddImageTagRequest struct {
 }
 
 type CropRequest struct {
-	X      int `json:"x" binding:"required"`
-	Y      int `json:"y" binding:"required"`
-	Width  int `json:"width" binding:"required,gt=0"`
-	Height int `json:"height" binding:"required,gt=0"`
+	X      int    `json:"x" binding:"gte=0"`
+	Y      int    `json:"y" binding:"gte=0"`
+	Width  int    `json:"width" binding:"required,gt=0"`
+	Height int    `json:"height" binding:"required,gt=0"`
+	Mode   string `json:"mode" binding:"omitempty,oneof=replace copy"` // replace（默认）在原图上生成新版本，copy另存为新图片
 }
 
 type AdjustRequest struct {
-	Brightness int `json:"brightness" binding:"gte=-100,lte=100"`
-	Contrast   int `json:"contrast" binding:"gte=-100,lte=100"`
-	Saturation int `json:"saturation" binding:"gte=-100,lte=100"`
-	Hue        int `json:"hue" binding:"gte=-180,lte=180"`
+	Brightness int    `json:"brightness" binding:"gte=-100,lte=100"`
+	Contrast   int    `json:"contrast" binding:"gte=-100,lte=100"`
+	Saturation int    `json:"saturation" binding:"gte=-100,lte=100"`
+	Hue        int    `json:"hue" binding:"gte=-180,lte=180"`
+	Mode       string `json:"mode" binding:"omitempty,oneof=replace copy"` // replace（默认）在原图上生成新版本，copy另存为新图片
 }
 
-type ImportVerifyRequest struct {
-	Username string `json:"username" binding:"required"` // 用户名或邮箱
-	Password string `json:"password" binding:"required"`
+// GrantRequest 把图片或相册授权给另一个用户，imageIds和albumId二选一
+type GrantRequest struct {
+	Recipient string `json:"recipient" binding:"required"`               // 接收者的用户名或邮箱
+	ImageIDs  []uint `json:"imageIds" binding:"max=500"`                 // 选定的图片
+	AlbumID   uint   `json:"albumId"`                                    // 相册，接收者看到的内容随相册变化
+	Access    string `json:"access" binding:"omitempty,oneof=read copy"` // read只能浏览，copy还可以导入，默认read
+	Message   string `json:"message" binding:"max=500"`
+}
+
+// GrantImportRequest 通过授权导入图片
+type GrantImportRequest struct {
+	ImageIDs []uint `json:"imageIds" binding:"required,min=1,max=500"` // 要导入的图片ID列表，必须在授权范围内
+}
+
+type RenamePersonRequest struct {
+	Name string `json:"name" binding:"max=100"` // 人物名称，为空表示取消命名
+}
+
+type MergePeopleRequest struct {
+	SourceID uint `json:"sourceId" binding:"required"` // 被合并（删除）的人物ID
+}
+
+type BatchDeleteRequest struct {
+	ImageIDs []uint `json:"imageIds" binding:"required,min=1,max=500"` // 要删除的图片ID列表
+}
+
+type EditRequest struct {
+	Operations []editor.Operation `json:"operations" binding:"required,min=1,max=50"`  // 按顺序执行的编辑操作
+	Label      string             `json:"label" binding:"max=100"`                     // 版本说明，为空时按操作类型生成
+	Mode       string             `json:"mode" binding:"omitempty,oneof=replace copy"` // replace（默认）在原图上生成新版本，copy另存为新图片
+}
+
+type EditPreviewRequest struct {
+	Operations []editor.Operation `json:"operations" binding:"max=50"` // 在当前版本上预览的编辑操作，为空时预览当前版本
+	Size       int                `json:"size" binding:"gte=0"`        // 预览图最长边，默认1024
+}
+
+// WatermarkRequest 创建或修改水印预设，以multipart/form-data提交，图片水印的标志文件字段名为logo
+type WatermarkRequest struct {
+	Name     string  `form:"name" binding:"required,max=100"`
+	Type     string  `form:"type" binding:"required,oneof=text image"`
+	Text     string  `form:"text" binding:"max=100"`
+	Color    string  `form:"color" binding:"omitempty,hexcolor"`
+	Position string  `form:"position" binding:"omitempty,oneof=top-left top top-right left center right bottom-left bottom bottom-right tile"`
+	Opacity  float64 `form:"opacity" binding:"gt=0,lte=1"`
+	Scale    float64 `form:"scale" binding:"gt=0,lte=1"`
+	Margin   float64 `form:"margin" binding:"gte=0,lte=0.5"`
+}
+
+// AlbumRequest 创建或修改相册
+// 创建时提供filters或query即为智能相册；修改智能相册时提供其一则替换原筛选条件
+type AlbumRequest struct {
+	Name         string            `json:"name" binding:"required,max=100"`
+	Description  string            `json:"description" binding:"max=1000"`
+	CoverImageID *uint             `json:"coverImageId"` // 封面图片ID，必须是相册中的图片；为空时使用第一张图片
+	Filters      map[string]string `json:"filters"`      // 智能相册的筛选条件，键与图片列表的查询参数相同
+	Query        string            `json:"query"`        // 智能相册的筛选条件，以URL查询字符串表示，如 tags=海边&media=video
+}
+
+// AlbumImagesRequest 向相册添加或从相册移除图片
+type AlbumImagesRequest struct {
+	ImageIDs []uint `json:"imageIds" binding:"required,min=1,max=500"` // 添加时按此顺序追加到相册末尾
 }
 
-type ImportRequest struct {
-	Username string `json:"username" binding:"required"` // 用户名或邮箱
+// ReorderAlbumRequest 调整相册中图片的顺序
+type ReorderAlbumRequest struct {
+	ImageIDs []uint `json:"imageIds" binding:"required,min=1"` // 相册中全部图片的ID，按新的顺序排列
+}
+
+// AlbumCaptionRequest 设置图片在相册中的说明
+type AlbumCaptionRequest struct {
+	Caption string `json:"caption" binding:"max=500"` // 为空表示清除说明
+}
+
+// SlideshowRequest 创建或修改幻灯片，修改时items整体替换
+type SlideshowRequest struct {
+	Name       string                 `json:"name" binding:"required,max=100"`
+	Transition string                 `json:"transition" binding:"omitempty,oneof=none fade slide zoom"` // 默认转场效果，默认fade
+	Items      []SlideshowItemRequest `json:"items" binding:"max=500,dive"`                              // 按播放顺序排列
+}
+
+// SlideshowItemRequest 幻灯片中的一张图片
+type SlideshowItemRequest struct {
+	ImageID    uint    `json:"imageId" binding:"required"`
+	Duration   float64 `json:"duration" binding:"gte=0,lte=600"`                          // 停留秒数，为0时图片默认5秒、视频播放完整
+	Transition string  `json:"transition" binding:"omitempty,oneof=none fade slide zoom"` // 为空时使用幻灯片的默认效果
+	Caption    string  `json:"caption" binding:"max=500"`
+}
+
+// RenderRequest 创建视频导出任务，albumId和slideshowId二选一
+// 可以用multipart/form-data随请求上传音轨（字段名music），相册只能用这种方式添加音轨
+type RenderRequest struct {
+	AlbumID       uint    `form:"albumId" json:"albumId"`
+	SlideshowID   uint    `form:"slideshowId" json:"slideshowId"`
+	Resolution    int     `form:"resolution" json:"resolution" binding:"omitempty,oneof=720 1080 2160"` // 输出高度，默认1080
+	SlideDuration float64 `form:"slideDuration" json:"slideDuration" binding:"gte=0,lte=600"`           // 相册中每张图片的停留秒数，默认5；幻灯片使用各自的停留时间
+	KenBurns      *bool   `form:"kenBurns" json:"kenBurns"`                                             // 是否添加平移缩放效果，默认开启
+	Audio         *bool   `form:"audio" json:"audio"`                                                   // 是否带音轨，默认开启；没有上传音轨时使用幻灯片的背景音乐
+}
+
+// ShareRequest 创建公开分享链接，imageId、tags和albumId三选一
+type ShareRequest struct {
+	ImageID       uint       `json:"imageId"`
+	Tags          []string   `json:"tags" binding:"max=20"` // 分享同时带有这些标签的图片
+	AlbumID       uint       `json:"albumId"`
+	Title         string     `json:"title" binding:"max=100"`    // 为空时使用图片文件名、标签或相册名称
+	Password      string     `json:"password" binding:"max=100"` // 为空表示不需要密码
+	AllowDownload bool       `json:"allowDownload"`
+	WatermarkID   uint       `json:"watermarkId"` // 访客浏览和下载时叠加的水印预设，0表示不加水印
+	ExpiresAt     *time.Time `json:"expiresAt"`   // 为空表示永不过期
+}
+
+// ShareUnlockRequest 访客输入分享的访问密码
+type ShareUnlockRequest struct {
 	Password string `json:"password" binding:"required"`
-	ImageIDs []uint `json:"imageIds" binding:"required,min=1"` // 要导入的图片ID列表
+}
+
+// WorkspaceRequest 创建或重命名工作区
+type WorkspaceRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// WorkspaceMemberRequest 添加成员或修改成员角色；修改角色时User被忽略
+type WorkspaceMemberRequest struct {
+	User string `json:"user" binding:"max=100"` // 用户名或邮箱
+	Role string `json:"role" binding:"required,oneof=admin editor viewer"`
+}
+
+// CommentRequest 发表或修改评论；修改时ParentID被忽略
+type CommentRequest struct {
+	Body     string `json:"body" binding:"required,max=2000"`
+	ParentID *uint  `json:"parentId"` // 回复的评论ID，为空表示顶层评论
+}
+
+// AnnotationRequest 添加或修改区域标注，坐标和尺寸以原图像素为单位
+type AnnotationRequest struct {
+	X      int    `json:"x" binding:"min=0"`
+	Y      int    `json:"y" binding:"min=0"`
+	Width  int    `json:"width" binding:"required,min=1"`
+	Height int    `json:"height" binding:"required,min=1"`
+	Note   string `json:"note" binding:"max=500"`
 }
@@ -0,0 +1,398 @@
+// Package editor 实现非破坏性编辑的操作定义与渲染
+// 一次编辑由有序的操作列表描述，渲染时依次作用在原图上，原图文件始终保持不变
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+
+	"image-manager/internal/watermark"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// 操作类型
+const (
+	OpCrop      = "crop"      // 裁剪
+	OpAdjust    = "adjust"    // 亮度/对比度/饱和度/色相调整
+	OpRotate    = "rotate"    // 旋转
+	OpFlip      = "flip"      // 翻转
+	OpResize    = "resize"    // 缩放
+	OpSharpen   = "sharpen"   // 锐化
+	OpBlur      = "blur"      // 模糊
+	OpGrayscale = "grayscale" // 灰度
+	OpSepia     = "sepia"     // 复古（棕褐色）
+	OpWatermark = "watermark" // 叠加水印
+	OpFormat    = "format"    // 输出格式转换，不改变像素，只影响渲染结果的编码
+)
+
+// 缩放模式
+const (
+	ResizeFit     = "fit"     // 等比缩放到不超过目标尺寸（默认）
+	ResizeFill    = "fill"    // 等比缩放后居中裁剪到目标尺寸
+	ResizeStretch = "stretch" // 拉伸到目标尺寸，宽或高为0时按比例计算
+)
+
+// 输出格式
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatGIF  = "gif"
+	FormatBMP  = "bmp"
+	FormatTIFF = "tiff"
+)
+
+// maxDimension 缩放目标的最大边长
+const maxDimension = 10000
+
+// Operation 单个编辑操作
+// Type决定使用哪些参数，其余参数被忽略；坐标均相对于前一步操作的输出图片
+type Operation struct {
+	Type string `json:"type"` // 操作类型
+
+	// crop、resize
+	X      int    `json:"x,omitempty"`      // 裁剪区域左上角X
+	Y      int    `json:"y,omitempty"`      // 裁剪区域左上角Y
+	Width  int    `json:"width,omitempty"`  // 裁剪宽度；缩放的目标宽度
+	Height int    `json:"height,omitempty"` // 裁剪高度；缩放的目标高度
+	Mode   string `json:"mode,omitempty"`   // 缩放模式：fit、fill、stretch
+
+	// adjust，取值范围与dto.AdjustRequest一致
+	Brightness int `json:"brightness,omitempty"` // 亮度（-100到100）
+	Contrast   int `json:"contrast,omitempty"`   // 对比度（-100到100）
+	Saturation int `json:"saturation,omitempty"` // 饱和度（-100到100）
+	Hue        int `json:"hue,omitempty"`        // 色相偏移（-180到180度）
+
+	// rotate
+	Angle      float64 `json:"angle,omitempty"`      // 顺时针旋转角度，90的整数倍时无损旋转
+	Background string  `json:"background,omitempty"` // 任意角度旋转时空白区域的颜色（#RRGGBB），默认白色
+
+	// flip
+	Direction string `json:"direction,omitempty"` // horizontal（水平翻转）或 vertical（垂直翻转）
+
+	// sharpen、blur
+	Sigma float64 `json:"sigma,omitempty"` // 强度（高斯核标准差，0到50）
+
+	// watermark：请求中只需指定预设ID，提交时服务端把预设参数快照写入Watermark，之后修改或删除预设不影响该版本
+	WatermarkID uint            `json:"watermarkId,omitempty"` // 水印预设ID
+	Watermark   *watermark.Spec `json:"watermark,omitempty"`   // 水印参数快照
+
+	// format
+	Format  string `json:"format,omitempty"`  // 输出格式：jpeg、png、webp
+	Quality int    `json:"quality,omitempty"` // JPEG质量（1-100），默认95；WebP为无损编码，忽略该参数
+}
+
+// Validate 校验操作参数，不依赖图片尺寸的检查在这里完成
+func (op Operation) Validate() error {
+	switch op.Type {
+	case OpCrop:
+		if op.X < 0 || op.Y < 0 || op.Width <= 0 || op.Height <= 0 {
+			return errors.New("裁剪区域无效")
+		}
+	case OpAdjust:
+		for _, v := range []int{op.Brightness, op.Contrast, op.Saturation} {
+			if v < -100 || v > 100 {
+				return errors.New("亮度、对比度、饱和度应在-100到100之间")
+			}
+		}
+		if op.Hue < -180 || op.Hue > 180 {
+			return errors.New("色相应在-180到180之间")
+		}
+	case OpRotate:
+		if math.IsNaN(op.Angle) || math.IsInf(op.Angle, 0) {
+			return errors.New("旋转角度无效")
+		}
+		if op.Background != "" {
+			if _, err := colorful.Hex(op.Background); err != nil {
+				return errors.New("背景颜色应为#RRGGBB格式")
+			}
+		}
+	case OpFlip:
+		if op.Direction != "horizontal" && op.Direction != "vertical" {
+			return errors.New("翻转方向应为horizontal或vertical")
+		}
+	case OpResize:
+		if op.Width < 0 || op.Height < 0 || op.Width > maxDimension || op.Height > maxDimension {
+			return fmt.Errorf("缩放尺寸应在0到%d之间", maxDimension)
+		}
+		switch op.Mode {
+		case "", ResizeFit, ResizeFill:
+			if op.Width == 0 || op.Height == 0 {
+				return errors.New("fit和fill模式需要同时指定宽度和高度")
+			}
+		case ResizeStretch:
+			if op.Width == 0 && op.Height == 0 {
+				return errors.New("缩放至少需要指定宽度或高度")
+			}
+		default:
+			return errors.New("缩放模式应为fit、fill或stretch")
+		}
+	case OpSharpen, OpBlur:
+		if op.Sigma <= 0 || op.Sigma > 50 {
+			return errors.New("强度应在0到50之间")
+		}
+	case OpGrayscale, OpSepia:
+	case OpWatermark:
+		if op.Watermark == nil {
+			return errors.New("缺少水印")
+		}
+		if err := op.Watermark.Validate(); err != nil {
+			return err
+		}
+	case OpFormat:
+		switch op.Format {
+		case FormatJPEG, FormatPNG, FormatWebP:
+		default:
+			return errors.New("输出格式应为jpeg、png或webp")
+		}
+		if op.Quality < 0 || op.Quality > 100 {
+			return errors.New("JPEG质量应在1到100之间")
+		}
+	case "":
+		return errors.New("缺少操作类型")
+	default:
+		return fmt.Errorf("不支持的编辑操作: %s", op.Type)
+	}
+	return nil
+}
+
+// Validate 校验操作列表中的每个操作
+func Validate(ops []Operation) error {
+	for i, op := range ops {
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("第%d个操作: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Apply 依次对图片执行操作列表，返回渲染结果
+func Apply(img image.Image, ops []Operation) (image.Image, error) {
+	if err := Validate(ops); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		if img, err = apply(img, op); err != nil {
+			return nil, fmt.Errorf("第%d个操作: %v", i+1, err)
+		}
+	}
+	return img, nil
+}
+
+// apply 执行单个操作
+func apply(img image.Image, op Operation) (image.Image, error) {
+	switch op.Type {
+	case OpCrop:
+		bounds := img.Bounds()
+		rect := image.Rect(op.X, op.Y, op.X+op.Width, op.Y+op.Height).Add(bounds.Min)
+		if !rect.In(bounds) {
+			return nil, fmt.Errorf("裁剪区域超出图片范围（%dx%d）", bounds.Dx(), bounds.Dy())
+		}
+		return imaging.Crop(img, rect), nil
+	case OpAdjust:
+		var adjusted image.Image = imaging.AdjustBrightness(img, float64(op.Brightness)/100)
+		adjusted = imaging.AdjustContrast(adjusted, float64(op.Contrast)/100)
+		adjusted = imaging.AdjustSaturation(adjusted, float64(op.Saturation)/100)
+		return adjustHue(adjusted, float64(op.Hue)), nil
+	case OpRotate:
+		return rotate(img, op), nil
+	case OpFlip:
+		if op.Direction == "vertical" {
+			return imaging.FlipV(img), nil
+		}
+		return imaging.FlipH(img), nil
+	case OpResize:
+		switch op.Mode {
+		case ResizeFill:
+			return imaging.Fill(img, op.Width, op.Height, imaging.Center, imaging.Lanczos), nil
+		case ResizeStretch:
+			return imaging.Resize(img, op.Width, op.Height, imaging.Lanczos), nil
+		default:
+			return imaging.Fit(img, op.Width, op.Height, imaging.Lanczos), nil
+		}
+	case OpSharpen:
+		return imaging.Sharpen(img, op.Sigma), nil
+	case OpBlur:
+		return imaging.Blur(img, op.Sigma), nil
+	case OpGrayscale:
+		return imaging.Grayscale(img), nil
+	case OpSepia:
+		return sepia(img), nil
+	case OpWatermark:
+		return watermark.Apply(img, *op.Watermark)
+	}
+	// format不改变像素
+	return img, nil
+}
+
+// rotate 顺时针旋转，90度的整数倍使用无损旋转，其余角度扩展画布并用背景色填充空白
+func rotate(img image.Image, op Operation) image.Image {
+	angle := math.Mod(op.Angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	switch angle {
+	case 0:
+		return img
+	case 90:
+		return imaging.Rotate270(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate90(img)
+	}
+	var bg color.Color = color.White
+	if op.Background != "" {
+		if c, err := colorful.Hex(op.Background); err == nil {
+			bg = c
+		}
+	}
+	// imaging.Rotate按逆时针方向旋转
+	return imaging.Rotate(img, -angle, bg)
+}
+
+// sepia 棕褐色滤镜，使用常见的sepia色彩矩阵
+func sepia(img image.Image) image.Image {
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return color.NRGBA{
+			R: clampUint8(0.393*r + 0.769*g + 0.189*b),
+			G: clampUint8(0.349*r + 0.686*g + 0.168*b),
+			B: clampUint8(0.272*r + 0.534*g + 0.131*b),
+			A: c.A,
+		}
+	})
+}
+
+// clampUint8 将浮点数截断到0-255并取整
+func clampUint8(v float64) uint8 {
+	if v > 255 {
+		return 255
+	}
+	if v < 0 {
+		return 0
+	}
+	return uint8(v + 0.5)
+}
+
+// ScaleOps 按比例缩放操作中与尺寸相关的参数，用于在缩小的图片上预览针对原尺寸编写的操作
+// 裁剪区域、缩放目标尺寸和锐化/模糊强度随比例缩放，其余参数不变
+func ScaleOps(ops []Operation, factor float64) []Operation {
+	scaled := make([]Operation, len(ops))
+	scale := func(v int, min int) int {
+		if v == 0 {
+			return 0
+		}
+		if r := int(math.Round(float64(v) * factor)); r > min {
+			return r
+		}
+		return min
+	}
+	for i, op := range ops {
+		switch op.Type {
+		case OpCrop:
+			// 按两端坐标取整，保证缩放后的裁剪区域不会因舍入超出缩小后的图片
+			x2, y2 := scale(op.X+op.Width, 1), scale(op.Y+op.Height, 1)
+			op.X, op.Y = scale(op.X, 0), scale(op.Y, 0)
+			op.Width, op.Height = max(x2-op.X, 1), max(y2-op.Y, 1)
+		case OpResize:
+			op.Width, op.Height = scale(op.Width, 1), scale(op.Height, 1)
+		case OpSharpen, OpBlur:
+			op.Sigma = math.Max(op.Sigma*factor, 0.1)
+		}
+		scaled[i] = op
+	}
+	return scaled
+}
+
+// Output 渲染结果的编码方式
+type Output struct {
+	Format  string // 编码格式
+	Quality int    // JPEG质量
+}
+
+// OutputOf 确定渲染结果的编码方式：使用操作列表中最后一个format操作，
+// 没有时沿用原图格式（由原图文件名判断），原图格式未知时使用JPEG
+func OutputOf(ops []Operation, originalFilename string) Output {
+	out := Output{Format: FormatFromFilename(originalFilename), Quality: 95}
+	for _, op := range ops {
+		if op.Type == OpFormat {
+			out.Format = op.Format
+			if op.Quality > 0 {
+				out.Quality = op.Quality
+			}
+		}
+	}
+	return out
+}
+
+// FormatFromFilename 根据文件扩展名判断格式，无法识别时返回jpeg
+func FormatFromFilename(filename string) string {
+	if strings.HasSuffix(strings.ToLower(filename), ".webp") {
+		return FormatWebP
+	}
+	format, err := imaging.FormatFromFilename(filename)
+	if err != nil {
+		return FormatJPEG
+	}
+	return strings.ToLower(format.String())
+}
+
+// Encode 按指定格式编码图片
+func Encode(w io.Writer, img image.Image, out Output) error {
+	if out.Format == FormatWebP {
+		return nativewebp.Encode(w, img, nil)
+	}
+	format, err := imaging.FormatFromExtension(out.Format)
+	if err != nil {
+		format = imaging.JPEG
+	}
+	quality := out.Quality
+	if quality <= 0 {
+		quality = 95
+	}
+	return imaging.Encode(w, img, format, imaging.JPEGQuality(quality))
+}
+
+// adjustHue 在HSL空间中旋转色相
+func adjustHue(img image.Image, degrees float64) image.Image {
+	if degrees == 0 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			colorVal, ok := colorful.MakeColor(img.At(x, y))
+			if !ok {
+				continue
+			}
+			h, s, l := colorVal.Hsl()
+			h = math.Mod(h+degrees, 360)
+			if h < 0 {
+				h += 360
+			}
+			newColor := colorful.Hsl(h, s, l)
+			_, _, _, alpha := img.At(x, y).RGBA()
+			dst.Set(x, y, color.NRGBA{
+				R: uint8(newColor.R * 255),
+				G: uint8(newColor.G * 255),
+				B: uint8(newColor.B * 255),
+				A: uint8(alpha >> 8),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,113 @@
+// Package faces 提供本地（纯CPU）人脸检测和人脸特征提取
+// 检测使用pigo（Pixel Intensity Comparison-based Object detection，纯Go实现），
+// 级联模型文件 cascade/facefinder 随程序一起编译进二进制（MIT许可，来自 github.com/esimov/pigo）
+package faces
+
+import (
+	_ "embed"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+	pigo "github.com/esimov/pigo/core"
+)
+
+//go:embed cascade/facefinder
+var faceFinderCascade []byte
+
+// 检测参数
+const (
+	detectMaxSide = 1024 // 检测前将图片长边缩放到不超过该值，控制耗时
+	minFaceSize   = 24   // 缩放后图片中的最小人脸边长（像素）
+	shiftFactor   = 0.1  // 滑动窗口步长（相对窗口大小）
+	scaleFactor   = 1.1  // 窗口尺寸的缩放倍数
+	iouThreshold  = 0.2  // 合并重叠检测框的IoU阈值
+)
+
+// Detection 检测到的人脸，坐标为原图像素坐标
+type Detection struct {
+	X     int     // 人脸框左上角X
+	Y     int     // 人脸框左上角Y
+	Size  int     // 人脸框边长（正方形）
+	Score float32 // 检测置信度
+}
+
+// Detector 人脸检测器，创建后可以被多个goroutine并发使用
+type Detector struct {
+	classifier *pigo.Pigo
+	minScore   float32
+}
+
+// NewDetector 加载内置级联模型并创建检测器
+// 参数:
+//   - minScore: 检测置信度阈值，低于该值的候选框被丢弃（pigo的经验值约为5）
+//
+// 返回: Detector指针和错误信息
+func NewDetector(minScore float32) (*Detector, error) {
+	classifier, err := pigo.NewPigo().Unpack(faceFinderCascade)
+	if err != nil {
+		return nil, err
+	}
+	return &Detector{classifier: classifier, minScore: minScore}, nil
+}
+
+// Detect 检测图片中的人脸，按置信度从高到低返回
+func (d *Detector) Detect(img image.Image) []Detection {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil
+	}
+
+	// 大图先缩小再检测，结果按比例映射回原图坐标
+	scale := 1.0
+	src := img
+	if longest := max(width, height); longest > detectMaxSide {
+		scale = float64(longest) / detectMaxSide
+		src = imaging.Resize(img, int(math.Round(float64(width)/scale)), int(math.Round(float64(height)/scale)), imaging.Box)
+	}
+	srcBounds := src.Bounds()
+	cols, rows := srcBounds.Dx(), srcBounds.Dy()
+
+	params := pigo.CascadeParams{
+		MinSize:     minFaceSize,
+		MaxSize:     min(cols, rows),
+		ShiftFactor: shiftFactor,
+		ScaleFactor: scaleFactor,
+		ImageParams: pigo.ImageParams{
+			Pixels: pigo.RgbToGrayscale(src),
+			Rows:   rows,
+			Cols:   cols,
+			Dim:    cols,
+		},
+	}
+	if params.MaxSize < params.MinSize {
+		return nil
+	}
+
+	candidates := d.classifier.ClusterDetections(d.classifier.RunCascade(params, 0), iouThreshold)
+
+	detections := []Detection{}
+	for _, c := range candidates {
+		if c.Q < d.minScore {
+			continue
+		}
+		size := int(math.Round(float64(c.Scale) * scale))
+		x := int(math.Round(float64(c.Col)*scale)) - size/2
+		y := int(math.Round(float64(c.Row)*scale)) - size/2
+		detections = append(detections, Detection{
+			X:     bounds.Min.X + max(x, 0),
+			Y:     bounds.Min.Y + max(y, 0),
+			Size:  size,
+			Score: c.Q,
+		})
+	}
+	sort.Slice(detections, func(i, j int) bool { return detections[i].Score > detections[j].Score })
+	return detections
+}
+
+// Rect 返回人脸框在原图中的矩形（裁剪到图片范围内）
+func (d Detection) Rect(bounds image.Rectangle) image.Rectangle {
+	return image.Rect(d.X, d.Y, d.X+d.Size, d.Y+d.Size).Intersect(bounds)
+}
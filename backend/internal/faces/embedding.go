@@ -0,0 +1,155 @@
+// Package faces 提供本地（纯CPU）人脸检测和人脸特征提取
+// embedding.go 实现人脸特征向量：在对齐缩放后的人脸灰度图上计算分块的均匀LBP（局部二值模式）直方图，
+// 经Hellinger变换和L2归一化后，两个向量的点积即为相似度（越接近1越相似）
+package faces
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// 特征参数
+const (
+	faceSize     = 64 // 人脸缩放后的边长（像素）
+	gridCells    = 4  // 每个方向的分块数
+	lbpBins      = 59 // 均匀LBP的直方图bin数（58种均匀模式 + 1个非均匀模式）
+	faceMargin   = 0.1
+	EmbeddingLen = gridCells * gridCells * lbpBins // 特征向量维度
+)
+
+// uniformLBP 8邻域LBP码到均匀模式bin的映射表
+var uniformLBP = buildUniformLBP()
+
+func buildUniformLBP() [256]uint8 {
+	var table [256]uint8
+	next := uint8(0)
+	for code := 0; code < 256; code++ {
+		// 统计循环二进制串中0/1跳变的次数，不超过2次的为均匀模式
+		transitions := 0
+		for bit := 0; bit < 8; bit++ {
+			if (code>>bit)&1 != (code>>((bit+1)%8))&1 {
+				transitions++
+			}
+		}
+		if transitions <= 2 {
+			table[code] = next
+			next++
+		} else {
+			table[code] = lbpBins - 1
+		}
+	}
+	return table
+}
+
+// Embed 计算人脸区域的特征向量
+// 参数:
+//   - img: 原图
+//   - face: 人脸框（原图坐标），会向内收缩少量边距以去除背景和头发
+//
+// 返回: 长度为EmbeddingLen的单位向量
+func Embed(img image.Image, face image.Rectangle) []float32 {
+	margin := int(float64(face.Dx()) * faceMargin)
+	inner := face.Inset(margin)
+	if inner.Empty() {
+		inner = face
+	}
+
+	gray := imaging.Grayscale(imaging.Crop(img, inner))
+	gray = imaging.Resize(gray, faceSize, faceSize, imaging.Lanczos)
+
+	pixels := make([]uint8, faceSize*faceSize)
+	for y := 0; y < faceSize; y++ {
+		for x := 0; x < faceSize; x++ {
+			pixels[y*faceSize+x] = gray.Pix[y*gray.Stride+x*4]
+		}
+	}
+
+	// 8邻域的偏移（顺时针）
+	offsets := [8][2]int{{-1, -1}, {0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}}
+	cellSize := float64(faceSize-2) / gridCells
+
+	vec := make([]float32, EmbeddingLen)
+	for y := 1; y < faceSize-1; y++ {
+		for x := 1; x < faceSize-1; x++ {
+			center := pixels[y*faceSize+x]
+			code := 0
+			for i, off := range offsets {
+				if pixels[(y+off[1])*faceSize+x+off[0]] >= center {
+					code |= 1 << i
+				}
+			}
+			cx := min(int(float64(x-1)/cellSize), gridCells-1)
+			cy := min(int(float64(y-1)/cellSize), gridCells-1)
+			vec[(cy*gridCells+cx)*lbpBins+int(uniformLBP[code])]++
+		}
+	}
+
+	// 每个分块内归一化为概率分布后取平方根（Hellinger），再整体L2归一化
+	for cell := 0; cell < gridCells*gridCells; cell++ {
+		hist := vec[cell*lbpBins : (cell+1)*lbpBins]
+		var sum float32
+		for _, v := range hist {
+			sum += v
+		}
+		if sum == 0 {
+			continue
+		}
+		for i, v := range hist {
+			hist[i] = float32(math.Sqrt(float64(v / sum)))
+		}
+	}
+	return Normalize(vec)
+}
+
+// Normalize 将向量L2归一化（原地修改并返回）
+func Normalize(vec []float32) []float32 {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= inv
+	}
+	return vec
+}
+
+// Similarity 计算两个单位向量的余弦相似度
+func Similarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// Encode 将特征向量编码为二进制（小端float32），用于数据库存储
+func Encode(vec []float32) []byte {
+	buf := make([]byte, len(vec)*4)
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// Decode 解码Encode生成的二进制特征向量
+func Decode(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, errors.New("特征向量数据长度无效")
+	}
+	vec := make([]float32, len(data)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vec, nil
+}
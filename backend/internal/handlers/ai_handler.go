@@ -0,0 +1,32 @@
+// Package handlers 提供HTTP请求处理器
+// ai_handler.go 实现了AI服务运行状态相关的HTTP处理器
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AIHandler AI处理器结构体
+// 提供AI调用统计等运行状态查询
+type AIHandler struct {
+	aiService *services.AIService
+}
+
+// NewAIHandler 创建AI处理器实例
+// 参数:
+//   - aiService: AI服务实例
+// 返回: AIHandler指针
+func NewAIHandler(aiService *services.AIService) *AIHandler {
+	return &AIHandler{aiService: aiService}
+}
+
+// Metrics 获取AI调用统计
+// 返回各AI操作的调用次数、成功/失败次数、重试次数、耗时以及熔断器状态
+// 路由: GET /api/v1/ai/metrics
+func (h *AIHandler) Metrics(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.aiService.Metrics())
+}
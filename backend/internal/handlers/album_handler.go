@@ -0,0 +1,191 @@
+// Package handlers 提供HTTP请求处理器
+// album_handler.go 实现了相册的增删改查、图片的添加移除、排序和说明，以及智能相册的图片计算
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlbumHandler 相册处理器结构体
+type AlbumHandler struct {
+	albumService *services.AlbumService
+}
+
+// NewAlbumHandler 创建相册处理器实例
+func NewAlbumHandler(albumService *services.AlbumService) *AlbumHandler {
+	return &AlbumHandler{albumService: albumService}
+}
+
+// List 获取相册列表
+// 路由: GET /api/v1/albums
+func (h *AlbumHandler) List(ctx *gin.Context) {
+	m := member(ctx)
+	albums, err := h.albumService.List(m)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, albums)
+}
+
+// Detail 获取相册详情，items按顺序列出图片ID和说明
+// 路由: GET /api/v1/albums/:id
+func (h *AlbumHandler) Detail(ctx *gin.Context) {
+	m := member(ctx)
+	album, err := h.albumService.Get(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, album)
+}
+
+// Images 分页获取相册中的图片，返回格式与图片列表相同
+// 普通相册按相册中的顺序排列，智能相册按保存的筛选条件实时计算
+// 路由: GET /api/v1/albums/:id/images?page=1&pageSize=20
+func (h *AlbumHandler) Images(ctx *gin.Context) {
+	m := member(ctx)
+	page := parseInt(ctx.DefaultQuery("page", "1"))
+	pageSize := parseInt(ctx.DefaultQuery("pageSize", "20"))
+	images, total, err := h.albumService.Images(m, parseUint(ctx.Param("id")), page, pageSize)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+		"items":    images,
+	})
+}
+
+// Refresh 重新计算智能相册的图片数量和封面
+// 路由: POST /api/v1/albums/:id/refresh
+func (h *AlbumHandler) Refresh(ctx *gin.Context) {
+	m := member(ctx)
+	album, err := h.albumService.Refresh(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, album)
+}
+
+// Create 创建相册，请求中带filters或query时创建智能相册
+// 路由: POST /api/v1/albums
+func (h *AlbumHandler) Create(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.AlbumRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	album, err := h.albumService.Create(m, req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, album)
+}
+
+// Update 修改相册的名称、描述和封面
+// 路由: PUT /api/v1/albums/:id
+func (h *AlbumHandler) Update(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.AlbumRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	album, err := h.albumService.Update(m, parseUint(ctx.Param("id")), req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, album)
+}
+
+// Delete 删除相册，图片本身保留
+// 路由: DELETE /api/v1/albums/:id
+func (h *AlbumHandler) Delete(ctx *gin.Context) {
+	m := member(ctx)
+	if err := h.albumService.Delete(m, parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// AddImages 把图片追加到相册末尾
+// 路由: POST /api/v1/albums/:id/images
+func (h *AlbumHandler) AddImages(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.AlbumImagesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请选择要添加的图片"})
+		return
+	}
+	added, err := h.albumService.AddImages(m, parseUint(ctx.Param("id")), req.ImageIDs)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"added": added})
+}
+
+// RemoveImages 从相册移除图片
+// 路由: POST /api/v1/albums/:id/images/remove
+func (h *AlbumHandler) RemoveImages(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.AlbumImagesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请选择要移除的图片"})
+		return
+	}
+	removed, err := h.albumService.RemoveImages(m, parseUint(ctx.Param("id")), req.ImageIDs)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+// Reorder 调整相册中图片的顺序
+// 路由: PUT /api/v1/albums/:id/order
+func (h *AlbumHandler) Reorder(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.ReorderAlbumRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	album, err := h.albumService.Reorder(m, parseUint(ctx.Param("id")), req.ImageIDs)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, album)
+}
+
+// SetCaption 设置图片在相册中的说明
+// 路由: PUT /api/v1/albums/:id/images/:imageId/caption
+func (h *AlbumHandler) SetCaption(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.AlbumCaptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	item, err := h.albumService.SetCaption(m, parseUint(ctx.Param("id")), parseUint(ctx.Param("imageId")), req.Caption)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
@@ -0,0 +1,76 @@
+// Package handlers 提供HTTP请求处理器
+// annotation_handler.go 实现了图片区域标注的查看、添加、修改和删除
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnnotationHandler 区域标注处理器结构体
+type AnnotationHandler struct {
+	annotationService *services.AnnotationService
+}
+
+// NewAnnotationHandler 创建区域标注处理器实例
+func NewAnnotationHandler(annotationService *services.AnnotationService) *AnnotationHandler {
+	return &AnnotationHandler{annotationService: annotationService}
+}
+
+// List 获取图片的全部区域标注，坐标以原图像素为单位
+// 路由: GET /api/v1/images/:id/annotations
+func (h *AnnotationHandler) List(ctx *gin.Context) {
+	annotations, err := h.annotationService.List(member(ctx), parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, annotations)
+}
+
+// Create 在图片上添加区域标注
+// 路由: POST /api/v1/images/:id/annotations
+// 请求体: {"x": 120, "y": 80, "width": 300, "height": 200, "note": "这里有划痕"}
+func (h *AnnotationHandler) Create(ctx *gin.Context) {
+	var req dto.AnnotationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	annotation, err := h.annotationService.Create(member(ctx), parseUint(ctx.Param("id")), req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, annotation)
+}
+
+// Update 修改区域标注的矩形和说明
+// 路由: PUT /api/v1/annotations/:id
+func (h *AnnotationHandler) Update(ctx *gin.Context) {
+	var req dto.AnnotationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	annotation, err := h.annotationService.Update(member(ctx), parseUint(ctx.Param("id")), req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, annotation)
+}
+
+// Delete 删除区域标注
+// 路由: DELETE /api/v1/annotations/:id
+func (h *AnnotationHandler) Delete(ctx *gin.Context) {
+	if err := h.annotationService.Delete(member(ctx), parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -51,3 +51,14 @@ func (h *AuthHandler) Login(ctx *gin.Context) {
 		"user":  user,
 	})
 }
+
+// MediaToken 获取当前工作区的媒体令牌，<img>、<video>等标签通过查询参数mt携带它访问图片文件
+// 路由: GET /api/v1/media-token
+func (h *AuthHandler) MediaToken(ctx *gin.Context) {
+	token, expiresAt, err := h.authService.MediaToken(member(ctx))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": expiresAt})
+}
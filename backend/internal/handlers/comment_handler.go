@@ -0,0 +1,76 @@
+// Package handlers 提供HTTP请求处理器
+// comment_handler.go 实现了图片评论的查看、发表、修改和删除
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CommentHandler 评论处理器结构体
+type CommentHandler struct {
+	commentService *services.CommentService
+}
+
+// NewCommentHandler 创建评论处理器实例
+func NewCommentHandler(commentService *services.CommentService) *CommentHandler {
+	return &CommentHandler{commentService: commentService}
+}
+
+// List 获取图片的全部评论，按发表时间排序
+// 路由: GET /api/v1/images/:id/comments
+func (h *CommentHandler) List(ctx *gin.Context) {
+	comments, err := h.commentService.List(member(ctx), parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, comments)
+}
+
+// Create 发表评论或回复
+// 路由: POST /api/v1/images/:id/comments
+// 请求体: {"body": "拍得真好", "parentId": 3}，parentId为空表示顶层评论
+func (h *CommentHandler) Create(ctx *gin.Context) {
+	var req dto.CommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	comment, err := h.commentService.Create(member(ctx), parseUint(ctx.Param("id")), req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// Update 修改自己的评论
+// 路由: PUT /api/v1/comments/:id
+func (h *CommentHandler) Update(ctx *gin.Context) {
+	var req dto.CommentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	comment, err := h.commentService.Update(member(ctx), parseUint(ctx.Param("id")), req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// Delete 删除评论及其所有回复
+// 路由: DELETE /api/v1/comments/:id
+func (h *CommentHandler) Delete(ctx *gin.Context) {
+	if err := h.commentService.Delete(member(ctx), parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,217 @@
+// Package handlers 提供HTTP请求处理器
+// edit_handler.go 实现了非破坏性编辑相关的HTTP处理器：追加编辑操作、撤销/重做、版本历史与渲染结果
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/editor"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EditHandler 编辑处理器结构体
+type EditHandler struct {
+	editService  *services.EditService
+	imageService *services.ImageService
+}
+
+// NewEditHandler 创建编辑处理器实例
+// 参数:
+//   - editService: 编辑服务实例
+//   - imageService: 图片服务实例，用于返回编辑后的图片信息
+// 返回: EditHandler指针
+func NewEditHandler(editService *services.EditService, imageService *services.ImageService) *EditHandler {
+	return &EditHandler{editService: editService, imageService: imageService}
+}
+
+// Preview 预览编辑效果：在缩小的当前版本上执行操作列表，直接返回JPEG图片，不保存任何内容
+// 编辑器拖动滑块时反复调用该接口，确定后再调用Commit提交
+// 路由: POST /api/v1/images/:id/edits/preview
+func (h *EditHandler) Preview(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+
+	var req dto.EditPreviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	preview, err := h.editService.Preview(m, imageID, req.Operations, req.Size)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Preview-Width", strconv.Itoa(preview.Width))
+	ctx.Header("X-Preview-Height", strconv.Itoa(preview.Height))
+	ctx.Header("X-Preview-Scale", strconv.FormatFloat(preview.Scale, 'f', -1, 64))
+	ctx.Data(http.StatusOK, "image/jpeg", preview.Data)
+}
+
+// Commit 提交编辑：在当前版本上追加一组编辑操作，生成新版本；mode为copy时另存为新图片
+// 路由: POST /api/v1/images/:id/edits/commit
+func (h *EditHandler) Commit(ctx *gin.Context) {
+	var req dto.EditRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	h.apply(ctx, req.Operations, req.Label, req.Mode)
+}
+
+// Crop 裁剪，等价于只包含一个crop操作的编辑
+// 路由: POST /api/v1/images/:id/crop
+func (h *EditHandler) Crop(ctx *gin.Context) {
+	var req dto.CropRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	h.apply(ctx, []editor.Operation{{
+		Type:   editor.OpCrop,
+		X:      req.X,
+		Y:      req.Y,
+		Width:  req.Width,
+		Height: req.Height,
+	}}, "", req.Mode)
+}
+
+// Adjust 调整亮度、对比度、饱和度和色相，等价于只包含一个adjust操作的编辑
+// 路由: POST /api/v1/images/:id/adjust
+func (h *EditHandler) Adjust(ctx *gin.Context) {
+	var req dto.AdjustRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	h.apply(ctx, []editor.Operation{{
+		Type:       editor.OpAdjust,
+		Brightness: req.Brightness,
+		Contrast:   req.Contrast,
+		Saturation: req.Saturation,
+		Hue:        req.Hue,
+	}}, "", req.Mode)
+}
+
+// apply 追加编辑操作并返回图片和新版本
+// mode为copy时把编辑结果另存为新图片，只返回新图片
+func (h *EditHandler) apply(ctx *gin.Context, ops []editor.Operation, label, mode string) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+
+	if mode == "copy" {
+		image, err := h.editService.Copy(m, imageID, ops)
+		if err != nil {
+			ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{"image": image})
+		return
+	}
+
+	version, err := h.editService.Apply(m, imageID, ops, label)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	image, err := h.imageService.Get(m, imageID)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"image": image, "version": version})
+}
+
+// History 获取图片的编辑版本历史
+// 路由: GET /api/v1/images/:id/versions
+func (h *EditHandler) History(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+
+	history, err := h.editService.History(m, imageID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": "图片不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, history)
+}
+
+// Undo 撤销最近一次编辑
+// 路由: POST /api/v1/images/:id/undo
+func (h *EditHandler) Undo(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+	h.respondMove(ctx, func() (interface{}, error) { return h.editService.Undo(m, imageID) })
+}
+
+// Redo 重做被撤销的编辑
+// 路由: POST /api/v1/images/:id/redo
+func (h *EditHandler) Redo(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+	h.respondMove(ctx, func() (interface{}, error) { return h.editService.Redo(m, imageID) })
+}
+
+// Revert 恢复原图
+// 路由: POST /api/v1/images/:id/revert
+func (h *EditHandler) Revert(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+	h.respondMove(ctx, func() (interface{}, error) { return h.editService.Revert(m, imageID) })
+}
+
+// Checkout 切换到指定的历史版本
+// 路由: POST /api/v1/images/:id/versions/:versionId/checkout
+func (h *EditHandler) Checkout(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+	versionID := parseUint(ctx.Param("versionId"))
+	h.respondMove(ctx, func() (interface{}, error) { return h.editService.Checkout(m, imageID, versionID) })
+}
+
+// respondMove 执行版本切换并返回切换后的图片
+func (h *EditHandler) respondMove(ctx *gin.Context, move func() (interface{}, error)) {
+	image, err := move()
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, image)
+}
+
+// Export 下载图片当前版本，可临时叠加水印预设、转换格式，不影响编辑历史
+// 查询参数: watermark（水印预设ID，可选）、format（jpeg/png/webp，可选）
+// 路由: GET /api/v1/images/:id/export
+func (h *EditHandler) Export(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+	watermarkID := parseUint(ctx.Query("watermark"))
+
+	data, mimeType, filename, err := h.editService.Export(m, imageID, watermarkID, ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	ctx.Data(http.StatusOK, mimeType, data)
+}
+
+// Rendered 返回图片当前版本的渲染结果，未编辑时返回原图
+// 路由: GET /api/v1/images/:id/rendered（与原图接口一样，<img>可以用媒体令牌引用）
+func (h *EditHandler) Rendered(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
+
+	data, mimeType, err := h.editService.Render(m, imageID)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": "图片不存在"})
+		return
+	}
+	ctx.Data(http.StatusOK, mimeType, data)
+}
@@ -0,0 +1,60 @@
+// Package handlers 提供HTTP请求处理器
+// favorite_handler.go 实现了图片收藏的添加、取消和当前用户收藏列表
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FavoriteHandler 收藏处理器结构体
+type FavoriteHandler struct {
+	favoriteService *services.FavoriteService
+	imageService    *services.ImageService
+}
+
+// NewFavoriteHandler 创建收藏处理器实例
+func NewFavoriteHandler(favoriteService *services.FavoriteService, imageService *services.ImageService) *FavoriteHandler {
+	return &FavoriteHandler{favoriteService: favoriteService, imageService: imageService}
+}
+
+// List 分页获取当前用户在当前工作区中收藏的图片，等价于图片列表的favorited=true筛选
+// 路由: GET /api/v1/favorites
+func (h *FavoriteHandler) List(ctx *gin.Context) {
+	page := parseInt(ctx.DefaultQuery("page", "1"))
+	pageSize := parseInt(ctx.DefaultQuery("pageSize", "20"))
+	images, total, err := h.imageService.List(member(ctx), map[string]string{"favorited": "true"}, page, pageSize)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+		"items":    images,
+	})
+}
+
+// Add 收藏图片
+// 路由: PUT /api/v1/images/:id/favorite
+func (h *FavoriteHandler) Add(ctx *gin.Context) {
+	if err := h.favoriteService.Add(member(ctx), parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"favorited": true})
+}
+
+// Remove 取消收藏
+// 路由: DELETE /api/v1/images/:id/favorite
+func (h *FavoriteHandler) Remove(ctx *gin.Context) {
+	if err := h.favoriteService.Remove(member(ctx), parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"favorited": false})
+}
@@ -0,0 +1,152 @@
+// Package handlers 提供HTTP请求处理器
+// grant_handler.go 实现了用户之间的分享授权：创建、接受或拒绝、撤销，浏览授权中的图片以及通过授权导入图片
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GrantHandler 分享授权处理器结构体
+type GrantHandler struct {
+	grantService *services.GrantService
+}
+
+// NewGrantHandler 创建分享授权处理器实例
+func NewGrantHandler(grantService *services.GrantService) *GrantHandler {
+	return &GrantHandler{grantService: grantService}
+}
+
+// Outgoing 获取当前用户授权给他人的记录
+// 路由: GET /api/v1/grants/outgoing
+func (h *GrantHandler) Outgoing(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	grants, err := h.grantService.Outgoing(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, grants)
+}
+
+// Incoming 获取他人授权给当前用户的记录
+// 路由: GET /api/v1/grants/incoming
+func (h *GrantHandler) Incoming(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	grants, err := h.grantService.Incoming(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, grants)
+}
+
+// Create 把当前工作区中选定的图片或相册授权给另一个用户，等待对方接受
+// 路由: POST /api/v1/grants
+func (h *GrantHandler) Create(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.GrantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	grant, err := h.grantService.Create(m, req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, grant)
+}
+
+// Accept 接收者接受授权
+// 路由: POST /api/v1/grants/:id/accept
+func (h *GrantHandler) Accept(ctx *gin.Context) {
+	h.respond(ctx, true)
+}
+
+// Decline 接收者拒绝授权
+// 路由: POST /api/v1/grants/:id/decline
+func (h *GrantHandler) Decline(ctx *gin.Context) {
+	h.respond(ctx, false)
+}
+
+func (h *GrantHandler) respond(ctx *gin.Context, accept bool) {
+	userID := ctx.GetUint("user_id")
+	grant, err := h.grantService.Respond(userID, parseUint(ctx.Param("id")), accept)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, grant)
+}
+
+// Delete 所有者撤销授权，或接收者移除授权
+// 路由: DELETE /api/v1/grants/:id
+func (h *GrantHandler) Delete(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	if err := h.grantService.Delete(userID, parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// Images 分页浏览授权中的图片，返回格式与图片列表相同
+// 路由: GET /api/v1/grants/:id/images?page=1&pageSize=20
+func (h *GrantHandler) Images(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	page := parseInt(ctx.DefaultQuery("page", "1"))
+	pageSize := parseInt(ctx.DefaultQuery("pageSize", "20"))
+	images, total, err := h.grantService.Images(userID, parseUint(ctx.Param("id")), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+		"items":    images,
+	})
+}
+
+// Thumbnail 授权中图片的缩略图
+// 路由: GET /api/v1/grants/:id/images/:imageId/thumbnail
+func (h *GrantHandler) Thumbnail(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	thumb, err := h.grantService.Thumbnail(userID, parseUint(ctx.Param("id")), parseUint(ctx.Param("imageId")))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	mimeType := thumb.MimeType
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+	ctx.Data(http.StatusOK, mimeType, thumb.Data)
+}
+
+// Import 把授权中的图片导入到当前工作区，授权需要为copy
+// 路由: POST /api/v1/grants/:id/import
+func (h *GrantHandler) Import(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.GrantImportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请至少选择一张图片"})
+		return
+	}
+	importedImages, err := h.grantService.Import(m, parseUint(ctx.Param("id")), req.ImageIDs)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        fmt.Sprintf("成功导入 %d 张图片", len(importedImages)),
+		"importedImages": importedImages,
+	})
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"mime"
 	"net/http"
 	"strconv"
 
@@ -14,19 +15,17 @@ import (
 type ImageHandler struct {
 	imageService *services.ImageService
 	tagService   *services.TagService
-	authService  *services.AuthService
 }
 
-func NewImageHandler(imageService *services.ImageService, tagService *services.TagService, authService *services.AuthService) *ImageHandler {
+func NewImageHandler(imageService *services.ImageService, tagService *services.TagService) *ImageHandler {
 	return &ImageHandler{
 		imageService: imageService,
 		tagService:   tagService,
-		authService:  authService,
 	}
 }
 
 func (h *ImageHandler) Upload(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	file, err := ctx.FormFile("file")
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请选择要上传的图片"})
@@ -39,9 +38,14 @@ func (h *ImageHandler) Upload(ctx *gin.Context) {
 	if useAIStr := ctx.PostForm("use_ai"); useAIStr != "" {
 		useAI = useAIStr == "true"
 	}
-	image, err := h.imageService.Upload(userID, file, tags, useAI)
+	// Live Photo的动态部分（MOV），与静态图片合并为一项
+	motion, err := ctx.FormFile("motion")
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		motion = nil
+	}
+	image, err := h.imageService.Upload(ctx.Request.Context(), m, file, motion, tags, useAI)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -49,29 +53,45 @@ func (h *ImageHandler) Upload(ctx *gin.Context) {
 }
 
 func (h *ImageHandler) List(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	page := parseInt(ctx.DefaultQuery("page", "1"))
 	pageSize := parseInt(ctx.DefaultQuery("pageSize", "20"))
 	filters := map[string]string{
-		"keyword":      ctx.Query("keyword"),
-		"start":        ctx.Query("start_date"),
-		"end":          ctx.Query("end_date"),
-		"taken_start":  ctx.Query("taken_start"),
-		"taken_end":    ctx.Query("taken_end"),
-		"width_min":    ctx.Query("width_min"),
-		"width_max":    ctx.Query("width_max"),
-		"height_min":   ctx.Query("height_min"),
-		"height_max":   ctx.Query("height_max"),
-		"size_min":     ctx.Query("size_min"),
-		"size_max":     ctx.Query("size_max"),
-		"tags":         ctx.Query("tags"),
-		"keyword_mode": ctx.Query("keyword_mode"),  // "and" 或 "or"，表示关键词和其他条件的关系
-		"tag_mode":     ctx.Query("tag_mode"),      // "and" 或 "or"，表示标签之间的关系
+		"keyword":         ctx.Query("keyword"),
+		"start":           ctx.Query("start_date"),
+		"end":             ctx.Query("end_date"),
+		"taken_start":     ctx.Query("taken_start"),
+		"taken_end":       ctx.Query("taken_end"),
+		"width_min":       ctx.Query("width_min"),
+		"width_max":       ctx.Query("width_max"),
+		"height_min":      ctx.Query("height_min"),
+		"height_max":      ctx.Query("height_max"),
+		"size_min":        ctx.Query("size_min"),
+		"size_max":        ctx.Query("size_max"),
+		"tags":            ctx.Query("tags"),
+		"person":          ctx.Query("person"),          // 人物名称或ID，逗号分隔时要求同时包含
+		"media":           ctx.Query("media"),           // 媒体类型：image、video、live，逗号分隔表示任一
+		"album":           ctx.Query("album"),           // 相册ID，未指定sort时按相册中的顺序排列
+		"has_comments":    ctx.Query("has_comments"),    // true/false：是否有评论
+		"has_annotations": ctx.Query("has_annotations"), // true/false：是否有区域标注
+		"favorited":       ctx.Query("favorited"),       // true/false：是否被当前用户收藏
+		"color":           ctx.Query("color"),           // 颜色：十六进制（#3366CC）或名称（blue、蓝色），前缀"mostly "表示占大部分
+		"color_tolerance": ctx.Query("color_tolerance"), // 十六进制颜色的Lab容差（ΔE），默认15
+		"color_ratio":     ctx.Query("color_ratio"),     // 匹配颜色的最小总占比（0-1）
+		"quality":         ctx.Query("quality"),         // 画质标记：blurry、sharp、underexposed、overexposed、well_exposed、noisy、clean，逗号分隔时同时满足
+		"sharpness_min":   ctx.Query("sharpness_min"),   // 清晰度（拉普拉斯方差）范围
+		"sharpness_max":   ctx.Query("sharpness_max"),
+		"noise_min":       ctx.Query("noise_min"), // 噪点估计范围
+		"noise_max":       ctx.Query("noise_max"),
+		"sort":            ctx.Query("sort"),         // 排序字段：created_at（默认）、sharpness、noise、brightness
+		"order":           ctx.Query("order"),        // 排序方向：desc（默认）或 asc
+		"keyword_mode":    ctx.Query("keyword_mode"), // "and" 或 "or"，表示关键词和其他条件的关系
+		"tag_mode":        ctx.Query("tag_mode"),     // "and" 或 "or"，表示标签之间的关系
 	}
 
-	images, total, err := h.imageService.List(userID, filters, page, pageSize)
+	images, total, err := h.imageService.List(m, filters, page, pageSize)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -84,10 +104,10 @@ func (h *ImageHandler) List(ctx *gin.Context) {
 }
 
 func (h *ImageHandler) Detail(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 
-	image, err := h.imageService.Get(userID, imageID)
+	image, err := h.imageService.Get(m, imageID)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"message": "图片不存在"})
 		return
@@ -97,7 +117,7 @@ func (h *ImageHandler) Detail(ctx *gin.Context) {
 }
 
 func (h *ImageHandler) Update(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 	file, err := ctx.FormFile("file")
 	if err != nil {
@@ -105,9 +125,9 @@ func (h *ImageHandler) Update(ctx *gin.Context) {
 		return
 	}
 
-	image, err := h.imageService.Update(userID, imageID, file)
+	image, err := h.imageService.Update(m, imageID, file)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -115,148 +135,101 @@ func (h *ImageHandler) Update(ctx *gin.Context) {
 }
 
 func (h *ImageHandler) Delete(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 
-	if err := h.imageService.Delete(userID, imageID); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+	if err := h.imageService.Delete(m, imageID); err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
 }
 
-func (h *ImageHandler) Thumbnail(ctx *gin.Context) {
-	imageID := parseUint(ctx.Param("id"))
-
-	thumb, err := h.imageService.GetThumbnail(imageID)
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "缩略图不存在"})
+// BatchDelete 批量删除图片，配合画质筛选可一次删除所有模糊照片
+func (h *ImageHandler) BatchDelete(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.BatchDeleteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请选择要删除的图片"})
 		return
 	}
 
-	ctx.Data(http.StatusOK, "image/jpeg", thumb.Data)
-}
-
-func (h *ImageHandler) Original(ctx *gin.Context) {
-	imageID := parseUint(ctx.Param("id"))
-
-	imageModel, data, err := h.imageService.GetFile(imageID)
+	deleted, err := h.imageService.DeleteBatch(m, req.ImageIDs)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "图片不存在"})
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error(), "deleted": deleted})
 		return
 	}
 
-	ctx.Data(http.StatusOK, "image/"+imageModel.MimeType, data)
+	ctx.JSON(http.StatusOK, gin.H{"deleted": deleted})
 }
 
-func (h *ImageHandler) Crop(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+func (h *ImageHandler) Thumbnail(ctx *gin.Context) {
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 
-	var req dto.CropRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
-	}
-
-	newImage, err := h.imageService.Crop(userID, imageID, req)
+	thumb, err := h.imageService.GetThumbnail(m, imageID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": "缩略图不存在"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, newImage)
+	mimeType := thumb.MimeType
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+	ctx.Data(http.StatusOK, mimeType, thumb.Data)
 }
 
-func (h *ImageHandler) Adjust(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+func (h *ImageHandler) Original(ctx *gin.Context) {
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 
-	var req dto.AdjustRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
-	}
-
-	newImage, err := h.imageService.Adjust(userID, imageID, req)
+	imageModel, data, err := h.imageService.GetFile(m, imageID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": "图片不存在"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, newImage)
+	ctx.Data(http.StatusOK, "image/"+imageModel.MimeType, data)
 }
 
-// ImportVerify 验证其他用户的凭据并获取其图片列表
-func (h *ImageHandler) ImportVerify(ctx *gin.Context) {
-	var req dto.ImportVerifyRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
-	}
-
-	// 验证用户凭据
-	loginReq := dto.LoginRequest{
-		Username: req.Username,
-		Password: req.Password,
-	}
-	_, user, err := h.authService.Login(loginReq)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"message": "用户名或密码错误"})
-		return
-	}
+// Source 下载HEIC/HEIF或相机RAW图片的原始文件，/original返回的是其JPEG工作副本
+// 路由: GET /api/v1/images/:id/source
+func (h *ImageHandler) Source(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
 
-	// 获取该用户的所有图片
-	images, err := h.imageService.GetOtherUserImages(user.ID)
+	imageModel, data, err := h.imageService.GetSource(m, imageID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "获取图片列表失败"})
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"images": images,
-		"userId": user.ID,
-	})
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": imageModel.OriginalFilename}))
+	ctx.Data(http.StatusOK, imageModel.SourceMimeType, data)
 }
 
-// Import 导入其他用户的图片
-func (h *ImageHandler) Import(ctx *gin.Context) {
-	currentUserID := ctx.GetUint("user_id")
-	var req dto.ImportRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
-	}
+// Video 播放视频或Live Photo的动态部分，支持Range请求，浏览器可以拖动进度条和边下边播
+// 路由: GET /api/v1/images/:id/video
+func (h *ImageHandler) Video(ctx *gin.Context) {
+	m := member(ctx)
+	imageID := parseUint(ctx.Param("id"))
 
-	// 验证源用户凭据
-	loginReq := dto.LoginRequest{
-		Username: req.Username,
-		Password: req.Password,
-	}
-	_, sourceUser, err := h.authService.Login(loginReq)
+	imageModel, file, err := h.imageService.OpenVideo(m, imageID)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"message": "用户名或密码错误"})
-		return
-	}
-
-	// 防止用户导入自己的图片
-	if sourceUser.ID == currentUserID {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "不能导入自己的图片"})
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
 		return
 	}
-
-	// 执行导入
-	importedImages, err := h.imageService.ImportImages(currentUserID, sourceUser.ID, req.ImageIDs, h.tagService)
+	defer file.Close()
+	stat, err := file.Stat()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":        fmt.Sprintf("成功导入 %d 张图片", len(importedImages)),
-		"importedImages": importedImages,
-	})
+	ctx.Header("Content-Type", imageModel.VideoMimeType)
+	http.ServeContent(ctx.Writer, ctx.Request, "", stat.ModTime(), file)
 }
 
 func parseInt(value string) int {
@@ -274,3 +247,23 @@ func parseUint(value string) uint {
 	}
 	return uint(i)
 }
+
+// member 当前用户及其在当前工作区中的角色，由AuthMiddleware和WorkspaceMiddleware写入
+func member(ctx *gin.Context) services.Member {
+	return services.Member{
+		UserID:      ctx.GetUint("user_id"),
+		WorkspaceID: ctx.GetUint("workspace_id"),
+		Role:        ctx.GetString("workspace_role"),
+	}
+}
+
+// statusOf 权限不足时返回403，其他错误返回fallback
+func statusOf(err error, fallback int) int {
+	if errors.Is(err, services.ErrForbidden) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, services.ErrEditConflict) {
+		return http.StatusConflict
+	}
+	return fallback
+}
@@ -4,7 +4,10 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
 	"image-manager/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -13,9 +16,11 @@ import (
 // MCPHandler MCP处理器结构体
 // 处理对话式图片检索的HTTP请求
 type MCPHandler struct {
-	imageService *services.ImageService
-	aiService    *services.AIService
-	tagService   *services.TagService
+	imageService   *services.ImageService
+	aiService      *services.AIService
+	tagService     *services.TagService
+	sessionService *services.SearchSessionService
+	albumService   *services.AlbumService
 }
 
 // NewMCPHandler 创建MCP处理器实例
@@ -23,29 +28,42 @@ type MCPHandler struct {
 //   - imageService: 图片服务实例
 //   - aiService: AI服务实例
 //   - tagService: 标签服务实例
+//   - sessionService: 对话式检索会话服务实例
+//   - albumService: 相册服务实例，用于把检索条件保存为智能相册
 // 返回: MCPHandler指针
-func NewMCPHandler(imageService *services.ImageService, aiService *services.AIService, tagService *services.TagService) *MCPHandler {
+func NewMCPHandler(imageService *services.ImageService, aiService *services.AIService, tagService *services.TagService, sessionService *services.SearchSessionService, albumService *services.AlbumService) *MCPHandler {
 	return &MCPHandler{
-		imageService: imageService,
-		aiService:    aiService,
-		tagService:   tagService,
+		imageService:   imageService,
+		aiService:      aiService,
+		tagService:     tagService,
+		sessionService: sessionService,
+		albumService:   albumService,
 	}
 }
 
+// sessionHistoryLimit 细化检索条件时传给AI的最近消息条数
+const sessionHistoryLimit = 10
+
 // SearchRequest 对话式搜索请求结构
 type SearchRequest struct {
 	Query string `json:"query" binding:"required"`  // 自然语言查询字符串
 	Page  int    `json:"page"`                      // 页码，默认为1
 	PageSize int `json:"pageSize"`                  // 每页数量，默认为20
+	SessionID uint `json:"sessionId"`               // 会话ID，传入时在该会话上一轮的基础上细化；为0时开启新会话
+	KeywordMode string `json:"keywordMode"`          // 关键词与其他条件的关系：and 或 or，默认为or
+	TagMode     string `json:"tagMode"`              // 多个标签之间的关系：and 或 or，默认为or
 }
 
 // Search 对话式图片搜索
 // 接收自然语言查询，使用AI转换为搜索条件，然后搜索图片
+// 支持多轮对话：传入sessionId时，新查询会在该会话上一轮的过滤条件和结果集基础上细化
+// 翻页（page>1且查询与会话最近一轮相同）时直接复用上一轮的过滤条件，不新增对话轮次
+// keywordMode/tagMode 由调用方选择AND或OR语义，未传时默认为OR（放宽检索条件）
 // 路由: POST /api/v1/mcp/search
-// 请求体: {"query": "找一些风景照片", "page": 1, "pageSize": 20}
-// 返回: 搜索到的图片列表和总数
+// 请求体: {"query": "找一些风景照片", "page": 1, "pageSize": 20, "sessionId": 0, "keywordMode": "or", "tagMode": "or"}
+// 返回: 搜索到的图片列表、总数、会话ID和转换诊断信息（是否使用AI、降级原因、模型原始输出、被丢弃的字段）
 func (h *MCPHandler) Search(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 
 	var req SearchRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -60,9 +78,102 @@ func (h *MCPHandler) Search(ctx *gin.Context) {
 	if req.PageSize <= 0 {
 		req.PageSize = 20
 	}
+	keywordMode, ok := normalizeMode(req.KeywordMode)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "keywordMode只能为and或or"})
+		return
+	}
+	tagMode, ok := normalizeMode(req.TagMode)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "tagMode只能为and或or"})
+		return
+	}
+
+	var session *models.SearchSession
+	if req.SessionID != 0 {
+		existing, err := h.sessionService.Get(m, req.SessionID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"message": "会话不存在"})
+			return
+		}
+		session = existing
+	}
+
+	var filters map[string]string
+	var diagnostics services.FilterDiagnostics
+	if session != nil && req.Page > 1 && lastUserQuery(session) == req.Query {
+		// 翻页：复用会话最近一轮的过滤条件
+		filters = services.SessionFilters(session)
+		filters["keyword_mode"] = keywordMode
+		filters["tag_mode"] = tagMode
+		diagnostics = services.FilterDiagnostics{Source: services.FilterSourceSession}
+	} else {
+		resolved, diag, err := h.resolveFilters(ctx, m, session, req.Query, keywordMode, tagMode)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"message": "AI查询转换失败: " + err.Error(),
+			})
+			return
+		}
+		filters = resolved
+		diagnostics = diag
+
+		// 第一页的新查询开启新会话；未携带会话的翻页请求保持无状态
+		if session == nil && req.Page == 1 {
+			created, err := h.sessionService.Create(m, req.Query)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"message": "创建会话失败: " + err.Error()})
+				return
+			}
+			session = created
+		}
+
+		if session != nil {
+			resultIDs, err := h.imageService.ListIDs(m, filters)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"message": "搜索失败: " + err.Error()})
+				return
+			}
+			if err := h.sessionService.RecordTurn(session, req.Query, filters, resultIDs); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"message": "保存会话失败: " + err.Error()})
+				return
+			}
+		}
+	}
+
+	// 调用图片服务的List方法进行搜索
+	images, total, err := h.imageService.List(m, filters, req.Page, req.PageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "搜索失败: " + err.Error(),
+		})
+		return
+	}
+
+	var sessionID uint
+	if session != nil {
+		sessionID = session.ID
+	}
 
+	// 返回搜索结果
+	ctx.JSON(http.StatusOK, gin.H{
+		"query":     req.Query,     // 原始查询
+		"filters":   filters,       // 转换后的过滤器
+		"total":     total,         // 总数量
+		"page":      req.Page,      // 当前页码
+		"pageSize":  req.PageSize,  // 每页数量
+		"items":     images,        // 图片列表
+		"sessionId": sessionID,     // 会话ID，后续追问时传回
+		"diagnostics": diagnostics, // 转换诊断信息
+	})
+}
+
+// resolveFilters 将查询转换为检索过滤条件
+// 没有会话历史时独立转换（可命中缓存）；有会话历史时结合上一轮条件和对话历史进行细化
+// 返回: 过滤条件、转换诊断信息和错误信息
+func (h *MCPHandler) resolveFilters(ctx *gin.Context, m services.Member, session *models.SearchSession, query, keywordMode, tagMode string) (map[string]string, services.FilterDiagnostics, error) {
 	// 先获取用户已有的标签库，让AI优先从中选择标签
-	existingTags, err := h.tagService.List(userID)
+	existingTags, err := h.tagService.List(m)
 	existingTagNames := []string{}
 	if err == nil {
 		for _, tag := range existingTags {
@@ -70,38 +181,166 @@ func (h *MCPHandler) Search(ctx *gin.Context) {
 		}
 	}
 
-	// 使用AI将自然语言查询转换为搜索过滤器，传入已有标签库
-	filters, err := h.aiService.ConvertQueryToFilters(req.Query, existingTagNames)
+	var filters map[string]string
+	var diag services.FilterDiagnostics
+	if session == nil || len(session.Messages) == 0 {
+		// 使用AI将自然语言查询转换为搜索过滤器，传入已有标签库
+		filters, diag, err = h.aiService.ConvertQueryToFilters(ctx.Request.Context(), m.WorkspaceID, query, existingTagNames)
+		if err != nil {
+			return nil, diag, err
+		}
+	} else {
+		// 去掉内部使用的字段，只把语义条件交给AI细化
+		previous := services.SessionFilters(session)
+		delete(previous, "ids")
+		delete(previous, "keyword_mode")
+		delete(previous, "tag_mode")
+
+		refined, refineDiag, err := h.aiService.RefineQueryFilters(ctx.Request.Context(), query, previous,
+			services.SessionHistory(session, sessionHistoryLimit), existingTagNames)
+		if err != nil {
+			return nil, refineDiag, err
+		}
+		diag = refineDiag
+		if !diag.AIUsed {
+			refined = map[string]string{"keyword": query} // 降级为关键词搜索
+		}
+
+		// scope=previous 时限定在上一轮结果集中检索
+		scope := refined["scope"]
+		delete(refined, "scope")
+		if scope == "previous" {
+			refined["ids"] = services.JoinIDs(services.SessionResultIDs(session))
+		}
+		filters = refined
+	}
+
+	// keyword_mode: 关键词和其他条件之间的关系
+	// tag_mode: 标签之间的关系
+	filters["keyword_mode"] = keywordMode
+	filters["tag_mode"] = tagMode
+	return filters, diag, nil
+}
+
+// normalizeMode 校验AND/OR模式参数，空值默认为or（放宽检索条件）
+func normalizeMode(mode string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "":
+		return "or", true
+	case "and":
+		return "and", true
+	case "or":
+		return "or", true
+	}
+	return "", false
+}
+
+// ListSessions 获取当前用户的对话式检索会话列表
+// 路由: GET /api/v1/mcp/sessions
+func (h *MCPHandler) ListSessions(ctx *gin.Context) {
+	m := member(ctx)
+	sessions, err := h.sessionService.List(m)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "AI查询转换失败: " + err.Error(),
-		})
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
 		return
 	}
+	ctx.JSON(http.StatusOK, sessions)
+}
 
-	// AI搜索时，可选部分始终选择OR逻辑
-	// keyword_mode: 关键词和其他条件使用OR关系
-	// tag_mode: 标签之间使用OR关系
-	filters["keyword_mode"] = "or"
-	filters["tag_mode"] = "or"
+// GetSession 获取会话详情，包括完整消息历史和最近一轮的结果集
+// 路由: GET /api/v1/mcp/sessions/:id
+func (h *MCPHandler) GetSession(ctx *gin.Context) {
+	m := member(ctx)
+	sessionID := parseUint(ctx.Param("id"))
 
-	// 调用图片服务的List方法进行搜索
-	images, total, err := h.imageService.List(userID, filters, req.Page, req.PageSize)
+	session, err := h.sessionService.Get(m, sessionID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "搜索失败: " + err.Error(),
-		})
+		ctx.JSON(http.StatusNotFound, gin.H{"message": "会话不存在"})
 		return
 	}
 
-	// 返回搜索结果
 	ctx.JSON(http.StatusOK, gin.H{
-		"query":   req.Query,           // 原始查询
-		"filters": filters,              // 转换后的过滤器
-		"total":   total,                // 总数量
-		"page":    req.Page,             // 当前页码
-		"pageSize": req.PageSize,        // 每页数量
-		"items":   images,               // 图片列表
+		"session":   session,
+		"filters":   services.SessionFilters(session),
+		"resultIds": services.SessionResultIDs(session),
 	})
 }
 
+// DeleteSession 删除会话
+// 路由: DELETE /api/v1/mcp/sessions/:id
+func (h *MCPHandler) DeleteSession(ctx *gin.Context) {
+	m := member(ctx)
+	sessionID := parseUint(ctx.Param("id"))
+
+	if err := h.sessionService.Delete(m, sessionID); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": "会话不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// SaveAlbumRequest 把检索会话保存为智能相册的请求结构
+type SaveAlbumRequest struct {
+	Name        string `json:"name" binding:"max=100"`         // 相册名称，为空时使用会话标题
+	Description string `json:"description" binding:"max=1000"` // 相册描述
+}
+
+// SaveAlbum 把会话最近一轮检索使用的过滤条件保存为智能相册
+// 保存的是过滤条件而不是结果集，之后上传的图片符合条件时也会出现在相册中；
+// 最近一轮限定在上一轮结果集中（scope=previous）时条件包含固定的图片列表，不能保存为智能相册，
+// 返回400和该轮结果集的imageIds，由调用方改为创建普通相册
+// 路由: POST /api/v1/mcp/sessions/:id/album
+// 请求体: {"name": "海边的照片", "description": ""}
+func (h *MCPHandler) SaveAlbum(ctx *gin.Context) {
+	m := member(ctx)
+	sessionID := parseUint(ctx.Param("id"))
+
+	var req SaveAlbumRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误: " + err.Error()})
+		return
+	}
+	session, err := h.sessionService.Get(m, sessionID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": "会话不存在"})
+		return
+	}
+
+	filters := services.SessionFilters(session)
+	if _, ok := filters["ids"]; ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message":  "本轮检索限定在上一轮的结果中，不能保存为智能相册，请改为创建普通相册",
+			"imageIds": services.SessionResultIDs(session),
+		})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = session.Title
+		if runes := []rune(name); len(runes) > 100 {
+			name = string(runes[:100])
+		}
+	}
+	album, err := h.albumService.Create(m, dto.AlbumRequest{
+		Name:        name,
+		Description: req.Description,
+		Filters:     filters,
+	})
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, album)
+}
+
+// lastUserQuery 返回会话中最近一条用户查询
+func lastUserQuery(session *models.SearchSession) string {
+	for i := len(session.Messages) - 1; i >= 0; i-- {
+		if session.Messages[i].Role == "user" {
+			return session.Messages[i].Content
+		}
+	}
+	return ""
+}
+
@@ -0,0 +1,110 @@
+// Package handlers 提供HTTP请求处理器
+// people_handler.go 实现了人物（人脸聚类）相关的HTTP处理器
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PeopleHandler 人物处理器结构体
+type PeopleHandler struct {
+	peopleService *services.PeopleService
+}
+
+// NewPeopleHandler 创建人物处理器实例
+// 参数:
+//   - peopleService: 人物服务实例
+// 返回: PeopleHandler指针
+func NewPeopleHandler(peopleService *services.PeopleService) *PeopleHandler {
+	return &PeopleHandler{peopleService: peopleService}
+}
+
+// List 获取当前用户的人物列表
+// 路由: GET /api/v1/people
+func (h *PeopleHandler) List(ctx *gin.Context) {
+	m := member(ctx)
+	people, err := h.peopleService.List(m)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, people)
+}
+
+// Detail 获取人物详情及其全部人脸位置
+// 路由: GET /api/v1/people/:id
+func (h *PeopleHandler) Detail(ctx *gin.Context) {
+	m := member(ctx)
+	personID := parseUint(ctx.Param("id"))
+
+	person, faces, err := h.peopleService.Get(m, personID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": "人物不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"person": person,
+		"faces":  faces,
+	})
+}
+
+// Rename 为人物命名
+// 路由: PUT /api/v1/people/:id/name
+// 请求体: {"name": "小明"}，name为空表示取消命名
+func (h *PeopleHandler) Rename(ctx *gin.Context) {
+	m := member(ctx)
+	personID := parseUint(ctx.Param("id"))
+
+	var req dto.RenamePersonRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	person, err := h.peopleService.Rename(m, personID, req.Name)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, person)
+}
+
+// Merge 将另一个人物合并到当前人物
+// 路由: POST /api/v1/people/:id/merge
+// 请求体: {"sourceId": 12}
+func (h *PeopleHandler) Merge(ctx *gin.Context) {
+	m := member(ctx)
+	personID := parseUint(ctx.Param("id"))
+
+	var req dto.MergePeopleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	person, err := h.peopleService.Merge(m, personID, req.SourceID)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, person)
+}
+
+// Cover 获取人物封面（裁剪的人脸图）
+// 路由: GET /api/v1/people/:id/cover
+func (h *PeopleHandler) Cover(ctx *gin.Context) {
+	m := member(ctx)
+	personID := parseUint(ctx.Param("id"))
+
+	data, err := h.peopleService.Cover(m, personID)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": "人物封面不存在"})
+		return
+	}
+	ctx.Data(http.StatusOK, "image/jpeg", data)
+}
@@ -0,0 +1,99 @@
+// Package handlers 提供HTTP请求处理器
+// render_handler.go 实现了相册和幻灯片的MP4导出：创建任务、查询进度、下载和删除生成的视频
+package handlers
+
+import (
+	"mime"
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenderHandler 视频导出处理器结构体
+type RenderHandler struct {
+	renderService *services.RenderService
+}
+
+// NewRenderHandler 创建视频导出处理器实例
+func NewRenderHandler(renderService *services.RenderService) *RenderHandler {
+	return &RenderHandler{renderService: renderService}
+}
+
+// List 获取视频导出任务列表
+// 路由: GET /api/v1/renders
+func (h *RenderHandler) List(ctx *gin.Context) {
+	m := member(ctx)
+	jobs, err := h.renderService.List(m)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, jobs)
+}
+
+// Detail 获取视频导出任务的状态和进度
+// 路由: GET /api/v1/renders/:id
+func (h *RenderHandler) Detail(ctx *gin.Context) {
+	m := member(ctx)
+	job, err := h.renderService.Get(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// Create 创建视频导出任务，任务在后台渲染，通过Detail查询进度
+// 请求可以是JSON，也可以是multipart/form-data并附带音轨文件（字段名music）
+// 路由: POST /api/v1/renders
+func (h *RenderHandler) Create(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.RenderRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	// 音轨是可选的，JSON请求没有文件
+	audio, _ := ctx.FormFile("music")
+	job, err := h.renderService.Create(m, req, audio)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// Download 下载导出完成的视频，支持Range请求
+// 路由: GET /api/v1/renders/:id/download
+func (h *RenderHandler) Download(ctx *gin.Context) {
+	m := member(ctx)
+	job, file, err := h.renderService.Open(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Type", "video/mp4")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": job.Name + ".mp4"}))
+	http.ServeContent(ctx.Writer, ctx.Request, "", stat.ModTime(), file)
+}
+
+// Delete 删除视频导出任务和生成的视频，正在渲染的任务会被中止
+// 路由: DELETE /api/v1/renders/:id
+func (h *RenderHandler) Delete(ctx *gin.Context) {
+	m := member(ctx)
+	if err := h.renderService.Delete(m, parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
+}
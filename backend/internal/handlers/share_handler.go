@@ -0,0 +1,186 @@
+// Package handlers 提供HTTP请求处理器
+// share_handler.go 实现了公开分享链接的创建、列表和撤销，以及访客无需登录访问分享内容的接口
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShareHandler 分享处理器结构体
+type ShareHandler struct {
+	shareService *services.ShareService
+}
+
+// NewShareHandler 创建分享处理器实例
+func NewShareHandler(shareService *services.ShareService) *ShareHandler {
+	return &ShareHandler{shareService: shareService}
+}
+
+// List 获取当前用户创建的分享链接，包括访问次数
+// 路由: GET /api/v1/shares
+func (h *ShareHandler) List(ctx *gin.Context) {
+	m := member(ctx)
+	links, err := h.shareService.List(m)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, links)
+}
+
+// Create 创建分享链接
+// 路由: POST /api/v1/shares
+func (h *ShareHandler) Create(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.ShareRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	link, err := h.shareService.Create(m, req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, link)
+}
+
+// Revoke 撤销分享链接
+// 路由: DELETE /api/v1/shares/:id
+func (h *ShareHandler) Revoke(ctx *gin.Context) {
+	m := member(ctx)
+	if err := h.shareService.Revoke(m, parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"revoked": true})
+}
+
+// Unlock 访客输入访问密码，返回之后请求使用的访问凭证（请求头X-Share-Key或查询参数key）
+// 路由: POST /api/v1/share/:token/unlock
+func (h *ShareHandler) Unlock(ctx *gin.Context) {
+	var req dto.ShareUnlockRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请输入访问密码"})
+		return
+	}
+	key, err := h.shareService.Unlock(ctx.Param("token"), req.Password)
+	if err != nil {
+		status := shareStatus(err)
+		if status == http.StatusNotFound && !errors.Is(err, services.ErrShareNotFound) {
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"key": key})
+}
+
+// View 访客分页查看分享中的图片
+// 需要密码时返回401和passwordRequired，先调用Unlock
+// 路由: GET /api/v1/share/:token?page=1&pageSize=50
+func (h *ShareHandler) View(ctx *gin.Context) {
+	page := parseInt(ctx.DefaultQuery("page", "1"))
+	pageSize := parseInt(ctx.DefaultQuery("pageSize", "50"))
+	view, err := h.shareService.View(ctx.Param("token"), shareKey(ctx), page, pageSize)
+	if err != nil {
+		ctx.JSON(shareStatus(err), gin.H{
+			"message":          err.Error(),
+			"passwordRequired": errors.Is(err, services.ErrSharePassword),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, view)
+}
+
+// Thumbnail 分享中图片的缩略图
+// 路由: GET /api/v1/share/:token/images/:itemId/thumbnail
+func (h *ShareHandler) Thumbnail(ctx *gin.Context) {
+	thumb, err := h.shareService.Thumbnail(ctx.Param("token"), shareKey(ctx), ctx.Param("itemId"))
+	if err != nil {
+		ctx.JSON(shareStatus(err), gin.H{"message": err.Error()})
+		return
+	}
+	mimeType := thumb.MimeType
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+	ctx.Data(http.StatusOK, mimeType, thumb.Data)
+}
+
+// Image 分享中图片当前版本缩小后的JPEG，用于浏览大图；不允许下载的分享也可以查看
+// 路由: GET /api/v1/share/:token/images/:itemId?size=1920
+func (h *ShareHandler) Image(ctx *gin.Context) {
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "1920"))
+	if !slices.Contains(services.RenditionSizes, size) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "不支持的尺寸"})
+		return
+	}
+	data, err := h.shareService.Rendition(ctx.Param("token"), shareKey(ctx), ctx.Param("itemId"), size)
+	if err != nil {
+		ctx.JSON(shareStatus(err), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.Data(http.StatusOK, "image/jpeg", data)
+}
+
+// Video 分享中的视频或Live Photo的动态部分，支持Range请求
+// 路由: GET /api/v1/share/:token/images/:itemId/video
+func (h *ShareHandler) Video(ctx *gin.Context) {
+	imageModel, file, err := h.shareService.Video(ctx.Param("token"), shareKey(ctx), ctx.Param("itemId"))
+	if err != nil {
+		ctx.JSON(shareStatus(err), gin.H{"message": err.Error()})
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Type", imageModel.VideoMimeType)
+	http.ServeContent(ctx.Writer, ctx.Request, "", stat.ModTime(), file)
+}
+
+// Download 下载分享中图片的当前版本，分享需要允许下载
+// 路由: GET /api/v1/share/:token/images/:itemId/download
+func (h *ShareHandler) Download(ctx *gin.Context) {
+	img, data, mimeType, err := h.shareService.Download(ctx.Param("token"), shareKey(ctx), ctx.Param("itemId"))
+	if err != nil {
+		ctx.JSON(shareStatus(err), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": img.OriginalFilename}))
+	ctx.Data(http.StatusOK, mimeType, data)
+}
+
+// shareKey 输入密码后获得的访问凭证；<img>等无法设置请求头的场景使用查询参数
+func shareKey(ctx *gin.Context) string {
+	if key := ctx.GetHeader("X-Share-Key"); key != "" {
+		return key
+	}
+	return ctx.Query("key")
+}
+
+// shareStatus 分享访问错误对应的HTTP状态码
+func shareStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrShareExpired):
+		return http.StatusGone
+	case errors.Is(err, services.ErrSharePassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrShareDownload), errors.Is(err, services.ErrShareVideo):
+		return http.StatusForbidden
+	}
+	return http.StatusNotFound
+}
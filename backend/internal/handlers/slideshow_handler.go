@@ -0,0 +1,195 @@
+// Package handlers 提供HTTP请求处理器
+// slideshow_handler.go 实现了幻灯片的增删改查、背景音乐上传，以及无需登录的公开播放接口
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SlideshowHandler 幻灯片处理器结构体
+type SlideshowHandler struct {
+	slideshowService *services.SlideshowService
+}
+
+// NewSlideshowHandler 创建幻灯片处理器实例
+func NewSlideshowHandler(slideshowService *services.SlideshowService) *SlideshowHandler {
+	return &SlideshowHandler{slideshowService: slideshowService}
+}
+
+// List 获取幻灯片列表
+// 路由: GET /api/v1/slideshows
+func (h *SlideshowHandler) List(ctx *gin.Context) {
+	m := member(ctx)
+	shows, err := h.slideshowService.List(m)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, shows)
+}
+
+// Detail 获取幻灯片详情
+// 路由: GET /api/v1/slideshows/:id
+func (h *SlideshowHandler) Detail(ctx *gin.Context) {
+	m := member(ctx)
+	show, err := h.slideshowService.Get(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, show)
+}
+
+// Create 创建幻灯片
+// 路由: POST /api/v1/slideshows
+func (h *SlideshowHandler) Create(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.SlideshowRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	show, err := h.slideshowService.Create(m, req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, show)
+}
+
+// Update 修改幻灯片，图片列表整体替换
+// 路由: PUT /api/v1/slideshows/:id
+func (h *SlideshowHandler) Update(ctx *gin.Context) {
+	m := member(ctx)
+	var req dto.SlideshowRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	show, err := h.slideshowService.Update(m, parseUint(ctx.Param("id")), req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, show)
+}
+
+// Delete 删除幻灯片
+// 路由: DELETE /api/v1/slideshows/:id
+func (h *SlideshowHandler) Delete(ctx *gin.Context) {
+	m := member(ctx)
+	if err := h.slideshowService.Delete(m, parseUint(ctx.Param("id"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// SetMusic 上传背景音乐，multipart/form-data，字段名music
+// 路由: PUT /api/v1/slideshows/:id/music
+func (h *SlideshowHandler) SetMusic(ctx *gin.Context) {
+	m := member(ctx)
+	file, err := ctx.FormFile("music")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "请选择背景音乐文件"})
+		return
+	}
+	show, err := h.slideshowService.SetMusic(m, parseUint(ctx.Param("id")), file)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, show)
+}
+
+// RemoveMusic 删除背景音乐
+// 路由: DELETE /api/v1/slideshows/:id/music
+func (h *SlideshowHandler) RemoveMusic(ctx *gin.Context) {
+	m := member(ctx)
+	show, err := h.slideshowService.RemoveMusic(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, show)
+}
+
+// ResetToken 重新生成公开播放令牌，旧的播放链接失效
+// 路由: POST /api/v1/slideshows/:id/token
+func (h *SlideshowHandler) ResetToken(ctx *gin.Context) {
+	m := member(ctx)
+	show, err := h.slideshowService.ResetToken(m, parseUint(ctx.Param("id")))
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusNotFound), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, show)
+}
+
+// Manifest 公开播放清单，无需登录
+// 路由: GET /api/v1/play/:token
+func (h *SlideshowHandler) Manifest(ctx *gin.Context) {
+	manifest, err := h.slideshowService.Manifest(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, manifest)
+}
+
+// Rendition 公开播放时按屏幕尺寸缩小的图片，无需登录
+// 路由: GET /api/v1/play/:token/images/:itemId?size=1920
+func (h *SlideshowHandler) Rendition(ctx *gin.Context) {
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "1920"))
+	data, err := h.slideshowService.Rendition(ctx.Param("token"), ctx.Param("itemId"), size)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.Header("Cache-Control", "public, max-age=3600")
+	ctx.Data(http.StatusOK, "image/jpeg", data)
+}
+
+// Video 公开播放时幻灯片中的视频，支持Range请求，无需登录
+// 路由: GET /api/v1/play/:token/images/:itemId/video
+func (h *SlideshowHandler) Video(ctx *gin.Context) {
+	imageModel, file, err := h.slideshowService.OpenVideo(ctx.Param("token"), ctx.Param("itemId"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Type", imageModel.VideoMimeType)
+	http.ServeContent(ctx.Writer, ctx.Request, "", stat.ModTime(), file)
+}
+
+// Music 公开播放时的背景音乐，支持Range请求，无需登录
+// 路由: GET /api/v1/play/:token/music
+func (h *SlideshowHandler) Music(ctx *gin.Context) {
+	show, file, err := h.slideshowService.OpenMusic(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Type", show.MusicMimeType)
+	http.ServeContent(ctx.Writer, ctx.Request, "", stat.ModTime(), file)
+}
@@ -18,16 +18,16 @@ func NewTagHandler(tagService *services.TagService) *TagHandler {
 }
 
 func (h *TagHandler) Create(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	var req dto.CreateTagRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	tag, err := h.tagService.Create(userID, req)
+	tag, err := h.tagService.Create(m, req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -35,17 +35,17 @@ func (h *TagHandler) Create(ctx *gin.Context) {
 }
 
 func (h *TagHandler) List(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
-	tags, err := h.tagService.List(userID)
+	m := member(ctx)
+	tags, err := h.tagService.List(m)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, tags)
 }
 
 func (h *TagHandler) Assign(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 
 	var req dto.AssignTagsRequest
@@ -54,8 +54,8 @@ func (h *TagHandler) Assign(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.tagService.AssignBulk(userID, imageID, req.TagIDs); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := h.tagService.AssignBulk(m, imageID, req.TagIDs); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -63,12 +63,12 @@ func (h *TagHandler) Assign(ctx *gin.Context) {
 }
 
 func (h *TagHandler) Remove(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 	tagID := parseUint(ctx.Param("tagId"))
 
-	if err := h.tagService.Remove(imageID, tagID, userID); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := h.tagService.Remove(imageID, tagID, m); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -76,7 +76,7 @@ func (h *TagHandler) Remove(ctx *gin.Context) {
 }
 
 func (h *TagHandler) UpdateColor(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	tagID := parseUint(ctx.Param("id"))
 	
 	var req dto.UpdateTagColorRequest
@@ -85,9 +85,9 @@ func (h *TagHandler) UpdateColor(ctx *gin.Context) {
 		return
 	}
 
-	tag, err := h.tagService.UpdateColor(userID, tagID, req.Color)
+	tag, err := h.tagService.UpdateColor(m, tagID, req.Color)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -95,7 +95,7 @@ func (h *TagHandler) UpdateColor(ctx *gin.Context) {
 }
 
 func (h *TagHandler) UpdateImageTag(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 	
 	var req dto.UpdateImageTagRequest
@@ -104,8 +104,8 @@ func (h *TagHandler) UpdateImageTag(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.tagService.UpdateImageTag(userID, imageID, req.OldTagID, req.NewTagName); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := h.tagService.UpdateImageTag(m, imageID, req.OldTagID, req.NewTagName); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -113,7 +113,7 @@ func (h *TagHandler) UpdateImageTag(ctx *gin.Context) {
 }
 
 func (h *TagHandler) AddImageTag(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	imageID := parseUint(ctx.Param("id"))
 	
 	var req dto.AddImageTagRequest
@@ -122,8 +122,8 @@ func (h *TagHandler) AddImageTag(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.tagService.AddImageTagByName(userID, imageID, req.TagName); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := h.tagService.AddImageTagByName(m, imageID, req.TagName); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
@@ -134,11 +134,11 @@ func (h *TagHandler) AddImageTag(ctx *gin.Context) {
 // 删除标签时，同时删除所有图片与该标签的关联
 // 路由: DELETE /api/v1/tags/:id
 func (h *TagHandler) Delete(ctx *gin.Context) {
-	userID := ctx.GetUint("user_id")
+	m := member(ctx)
 	tagID := parseUint(ctx.Param("id"))
 
-	if err := h.tagService.Delete(userID, tagID); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := h.tagService.Delete(m, tagID); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
 		return
 	}
 
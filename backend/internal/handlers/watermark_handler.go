@@ -0,0 +1,107 @@
+// Package handlers 提供HTTP请求处理器
+// watermark_handler.go 实现了水印预设的增删改查和标志图片读取
+package handlers
+
+import (
+	"mime/multipart"
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WatermarkHandler 水印处理器结构体
+type WatermarkHandler struct {
+	watermarkService *services.WatermarkService
+}
+
+// NewWatermarkHandler 创建水印处理器实例
+func NewWatermarkHandler(watermarkService *services.WatermarkService) *WatermarkHandler {
+	return &WatermarkHandler{watermarkService: watermarkService}
+}
+
+// List 获取水印预设列表
+// 路由: GET /api/v1/watermarks
+func (h *WatermarkHandler) List(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	marks, err := h.watermarkService.List(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, marks)
+}
+
+// Create 创建水印预设，multipart/form-data，图片水印需上传PNG标志（字段名logo）
+// 路由: POST /api/v1/watermarks
+func (h *WatermarkHandler) Create(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	req, logo, ok := bindWatermark(ctx)
+	if !ok {
+		return
+	}
+	mark, err := h.watermarkService.Create(userID, req, logo)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, mark)
+}
+
+// Update 修改水印预设，图片水印不上传logo时沿用原标志
+// 路由: PUT /api/v1/watermarks/:id
+func (h *WatermarkHandler) Update(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	id := parseUint(ctx.Param("id"))
+	req, logo, ok := bindWatermark(ctx)
+	if !ok {
+		return
+	}
+	mark, err := h.watermarkService.Update(userID, id, req, logo)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, mark)
+}
+
+// Delete 删除水印预设，已叠加该水印的编辑版本不受影响
+// 路由: DELETE /api/v1/watermarks/:id
+func (h *WatermarkHandler) Delete(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	id := parseUint(ctx.Param("id"))
+	if err := h.watermarkService.Delete(userID, id); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+// Logo 返回图片水印的标志文件
+// 路由: GET /api/v1/watermarks/:id/logo
+func (h *WatermarkHandler) Logo(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	id := parseUint(ctx.Param("id"))
+	data, err := h.watermarkService.Logo(userID, id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.Data(http.StatusOK, "image/png", data)
+}
+
+// bindWatermark 解析水印表单和可选的标志文件，失败时已写入错误响应
+func bindWatermark(ctx *gin.Context) (dto.WatermarkRequest, *multipart.FileHeader, bool) {
+	var req dto.WatermarkRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return req, nil, false
+	}
+	logo, err := ctx.FormFile("logo")
+	if err != nil {
+		logo = nil
+	}
+	return req, logo, true
+}
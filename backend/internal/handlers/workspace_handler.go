@@ -0,0 +1,169 @@
+// Package handlers 提供HTTP请求处理器
+// workspace_handler.go 实现了工作区的创建、重命名、删除，以及成员的添加、角色修改和移除
+package handlers
+
+import (
+	"net/http"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHandler 工作区处理器结构体
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+}
+
+// NewWorkspaceHandler 创建工作区处理器实例
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaceService: workspaceService}
+}
+
+// List 获取当前用户所在的工作区，包含个人工作区和当前用户的角色
+// 路由: GET /api/v1/workspaces
+func (h *WorkspaceHandler) List(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	workspaces, err := h.workspaceService.List(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, workspaces)
+}
+
+// Create 创建团队工作区，当前用户成为所有者
+// 路由: POST /api/v1/workspaces
+func (h *WorkspaceHandler) Create(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+	var req dto.WorkspaceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	workspace, err := h.workspaceService.Create(userID, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, workspace)
+}
+
+// Update 重命名工作区
+// 路由: PUT /api/v1/workspaces/:id
+func (h *WorkspaceHandler) Update(ctx *gin.Context) {
+	m, ok := h.resolve(ctx)
+	if !ok {
+		return
+	}
+	var req dto.WorkspaceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	workspace, err := h.workspaceService.Update(m, req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, workspace)
+}
+
+// Delete 删除团队工作区，工作区中不能还有图片
+// 路由: DELETE /api/v1/workspaces/:id
+func (h *WorkspaceHandler) Delete(ctx *gin.Context) {
+	m, ok := h.resolve(ctx)
+	if !ok {
+		return
+	}
+	if err := h.workspaceService.Delete(m); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// Members 获取工作区的成员列表
+// 路由: GET /api/v1/workspaces/:id/members
+func (h *WorkspaceHandler) Members(ctx *gin.Context) {
+	m, ok := h.resolve(ctx)
+	if !ok {
+		return
+	}
+	members, err := h.workspaceService.Members(m)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, members)
+}
+
+// AddMember 按用户名或邮箱添加成员
+// 路由: POST /api/v1/workspaces/:id/members
+func (h *WorkspaceHandler) AddMember(ctx *gin.Context) {
+	m, ok := h.resolve(ctx)
+	if !ok {
+		return
+	}
+	var req dto.WorkspaceMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	member, err := h.workspaceService.AddMember(m, req)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, member)
+}
+
+// UpdateMember 修改成员的角色
+// 路由: PUT /api/v1/workspaces/:id/members/:userId
+func (h *WorkspaceHandler) UpdateMember(ctx *gin.Context) {
+	m, ok := h.resolve(ctx)
+	if !ok {
+		return
+	}
+	var req dto.WorkspaceMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	member, err := h.workspaceService.UpdateMember(m, parseUint(ctx.Param("userId")), req.Role)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, member)
+}
+
+// RemoveMember 移除成员；userId为当前用户时表示退出工作区
+// 路由: DELETE /api/v1/workspaces/:id/members/:userId
+func (h *WorkspaceHandler) RemoveMember(ctx *gin.Context) {
+	m, ok := h.resolve(ctx)
+	if !ok {
+		return
+	}
+	if err := h.workspaceService.RemoveMember(m, parseUint(ctx.Param("userId"))); err != nil {
+		ctx.JSON(statusOf(err, http.StatusBadRequest), gin.H{"message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "移除成功"})
+}
+
+// resolve 解析当前用户在路径中工作区的身份，不是成员时写入403响应并返回false
+func (h *WorkspaceHandler) resolve(ctx *gin.Context) (services.Member, bool) {
+	workspaceID := parseUint(ctx.Param("id"))
+	if workspaceID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "工作区ID格式错误"})
+		return services.Member{}, false
+	}
+	m, err := h.workspaceService.Resolve(ctx.GetUint("user_id"), workspaceID)
+	if err != nil {
+		ctx.JSON(statusOf(err, http.StatusInternalServerError), gin.H{"message": err.Error()})
+		return services.Member{}, false
+	}
+	return m, true
+}
@@ -0,0 +1,257 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// heifBrands HEIF容器的兼容品牌，对应的格式名；AVIF（avif、avis）不在此列
+var heifBrands = map[string]string{
+	"heic": "heic", "heix": "heic", "heim": "heic", "heis": "heic",
+	"hevc": "heic", "hevx": "heic",
+	"mif1": "heif", "msf1": "heif",
+}
+
+// heifBrand 根据ftyp盒子判断是否为HEIC/HEIF，返回格式名，不是时返回空字符串
+func heifBrand(data []byte) string {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return ""
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > len(data) {
+		return ""
+	}
+	// 主品牌在前，兼容品牌从第16字节开始；任一品牌是AVIF时交给AVIF处理而不是HEIC
+	brands := []string{string(data[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(data[i:i+4]))
+	}
+	result := ""
+	for _, brand := range brands {
+		switch {
+		case brand == "avif" || brand == "avis":
+			return ""
+		case heifBrands[brand] == "heic":
+			result = "heic"
+		case heifBrands[brand] == "heif" && result == "":
+			result = "heif"
+		}
+	}
+	return result
+}
+
+// box ISO BMFF盒子
+type box struct {
+	typ     string
+	payload []byte
+}
+
+// readBoxes 解析连续的盒子
+func readBoxes(data []byte) []box {
+	var boxes []box
+	for pos := 0; pos+8 <= len(data); {
+		size := int64(binary.BigEndian.Uint32(data[pos:]))
+		header := 8
+		switch size {
+		case 0:
+			size = int64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return boxes
+			}
+			size = int64(binary.BigEndian.Uint64(data[pos+8:]))
+			header = 16
+		}
+		if size < int64(header) || int64(pos)+size > int64(len(data)) {
+			return boxes
+		}
+		boxes = append(boxes, box{
+			typ:     string(data[pos+4 : pos+8]),
+			payload: data[pos+header : pos+int(size)],
+		})
+		pos += int(size)
+	}
+	return boxes
+}
+
+// findBox 返回第一个指定类型的盒子
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// heifExif 从HEIF中提取Exif条目，返回以TIFF文件头开始的EXIF数据，没有时返回nil
+// 在meta盒子的iinf中找到类型为Exif的条目ID，再从iloc中读取它在文件或idat中的位置
+func heifExif(data []byte) []byte {
+	meta, ok := findBox(readBoxes(data), "meta")
+	if !ok || len(meta.payload) < 4 {
+		return nil
+	}
+	children := readBoxes(meta.payload[4:]) // meta是FullBox，跳过version和flags
+
+	iinf, ok := findBox(children, "iinf")
+	if !ok {
+		return nil
+	}
+	itemID, ok := exifItemID(iinf.payload)
+	if !ok {
+		return nil
+	}
+	iloc, ok := findBox(children, "iloc")
+	if !ok {
+		return nil
+	}
+	var idat []byte
+	if b, ok := findBox(children, "idat"); ok {
+		idat = b.payload
+	}
+	item := readItem(iloc.payload, itemID, data, idat)
+
+	// Exif条目以4字节的TIFF文件头偏移开始，偏移之后通常是 "Exif\0\0" 和TIFF数据
+	if len(item) < 4 {
+		return nil
+	}
+	skip := int(binary.BigEndian.Uint32(item))
+	if skip < 0 || 4+skip > len(item) {
+		return nil
+	}
+	exif := item[4+skip:]
+	if isTIFF(exif) {
+		return exif
+	}
+	if i := bytes.Index(item, []byte("Exif\x00\x00")); i >= 0 && isTIFF(item[i+6:]) {
+		return item[i+6:]
+	}
+	return nil
+}
+
+// exifItemID 在iinf盒子中查找类型为Exif的条目ID
+func exifItemID(payload []byte) (uint32, bool) {
+	if len(payload) < 6 {
+		return 0, false
+	}
+	start := 6 // version、flags和2字节的条目数
+	if payload[0] != 0 {
+		start = 8
+	}
+	if start > len(payload) {
+		return 0, false
+	}
+	for _, infe := range readBoxes(payload[start:]) {
+		p := infe.payload
+		if infe.typ != "infe" || len(p) < 4 {
+			continue
+		}
+		// infe版本2用2字节条目ID，版本3用4字节；之后是2字节保护索引和4字节条目类型
+		var id uint32
+		var rest []byte
+		switch p[0] {
+		case 2:
+			if len(p) < 12 {
+				continue
+			}
+			id, rest = uint32(binary.BigEndian.Uint16(p[4:])), p[6:]
+		case 3:
+			if len(p) < 14 {
+				continue
+			}
+			id, rest = binary.BigEndian.Uint32(p[4:]), p[8:]
+		default:
+			continue
+		}
+		if string(rest[2:6]) == "Exif" {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// readItem 按iloc盒子的记录读取条目数据，支持文件偏移（construction_method 0）和idat偏移（1）
+func readItem(payload []byte, itemID uint32, file, idat []byte) []byte {
+	if len(payload) < 8 {
+		return nil
+	}
+	version := payload[0]
+	offsetSize, lengthSize := int(payload[4]>>4), int(payload[4]&0x0f)
+	baseOffsetSize, indexSize := int(payload[5]>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(payload[5] & 0x0f)
+	}
+
+	r := &byteReader{data: payload, pos: 6, ok: true}
+	var count uint64
+	if version < 2 {
+		count = r.read(2)
+	} else {
+		count = r.read(4)
+	}
+	for i := uint64(0); i < count && r.ok; i++ {
+		var id uint64
+		if version < 2 {
+			id = r.read(2)
+		} else {
+			id = r.read(4)
+		}
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = r.read(2) & 0x0f
+		}
+		r.read(2) // data_reference_index
+		base := r.read(baseOffsetSize)
+		extents := r.read(2)
+
+		var out []byte
+		for j := uint64(0); j < extents && r.ok; j++ {
+			r.read(indexSize)
+			offset, length := base+r.read(offsetSize), r.read(lengthSize)
+			if uint32(id) != itemID {
+				continue
+			}
+			src := file
+			if method == 1 {
+				src = idat
+			} else if method != 0 {
+				return nil
+			}
+			if offset > uint64(len(src)) {
+				return nil
+			}
+			if length == 0 {
+				length = uint64(len(src)) - offset
+			}
+			if offset+length > uint64(len(src)) {
+				return nil
+			}
+			out = append(out, src[offset:offset+length]...)
+		}
+		if uint32(id) == itemID && r.ok {
+			return out
+		}
+	}
+	return nil
+}
+
+// byteReader 读取变长大端整数，越界后ok为false并一直返回0
+type byteReader struct {
+	data []byte
+	pos  int
+	ok   bool
+}
+
+// read 读取n字节的大端整数，n为0时返回0
+func (r *byteReader) read(n int) uint64 {
+	if !r.ok || r.pos+n > len(r.data) {
+		r.ok = false
+		return 0
+	}
+	var v uint64
+	for _, b := range r.data[r.pos : r.pos+n] {
+		v = v<<8 | uint64(b)
+	}
+	r.pos += n
+	return v
+}
@@ -0,0 +1,261 @@
+// Package ingest 把image包和浏览器都无法直接解码的相机格式（HEIC/HEIF、相机RAW）转换为JPEG工作副本
+// 原始文件由调用方原样保留；缩略图、编辑、画质检测等后续处理都使用工作副本，EXIF则从原始文件中提取
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/tiff" // 注册 TIFF 解码器，用于解码dcraw_emu输出的TIFF
+)
+
+// jpegQuality 工作副本的JPEG质量
+const jpegQuality = 92
+
+// convertTimeout 单个文件调用外部解码器的超时时间
+const convertTimeout = 2 * time.Minute
+
+// minPreviewSize RAW内嵌预览图长边达到该值时直接作为工作副本，否则优先使用RAW解码器
+const minPreviewSize = 1600
+
+// Format 需要转换的相机格式
+type Format struct {
+	Name     string // heic、heif、cr2、nef、arw、dng
+	MimeType string // 原始文件的MIME类型
+	raw      bool
+}
+
+// rawFormats 支持的相机RAW格式，按扩展名识别；它们都是TIFF结构，只靠文件头无法区分
+var rawFormats = map[string]Format{
+	".cr2": {Name: "cr2", MimeType: "image/x-canon-cr2", raw: true},
+	".nef": {Name: "nef", MimeType: "image/x-nikon-nef", raw: true},
+	".arw": {Name: "arw", MimeType: "image/x-sony-arw", raw: true},
+	".dng": {Name: "dng", MimeType: "image/x-adobe-dng", raw: true},
+}
+
+// Detect 识别需要转换的相机格式，普通图片返回nil
+// HEIC/HEIF按文件头识别；RAW按扩展名识别并校验TIFF文件头
+func Detect(data []byte, filename string) *Format {
+	if name := heifBrand(data); name != "" {
+		return &Format{Name: name, MimeType: "image/" + name}
+	}
+	if f, ok := rawFormats[strings.ToLower(filepath.Ext(filename))]; ok && isTIFF(data) {
+		return &f
+	}
+	return nil
+}
+
+// Result 转换结果
+type Result struct {
+	JPEG   []byte       // 已按方向摆正的JPEG工作副本，不含EXIF
+	Config image.Config // 工作副本的尺寸
+	Exif   []byte       // 原始文件中的EXIF（以TIFF文件头开始），没有时为空
+	Source string       // 工作副本的来源：preview（RAW内嵌预览图）或解码器名称
+}
+
+// Converter 相机格式转换器
+// HEIC/HEIF通过heif-convert（libheif）解码；RAW优先使用内嵌的JPEG预览图，预览图过小或缺失时调用dcraw_emu（LibRaw）
+type Converter struct {
+	heifPath string // heif-convert可执行文件路径，为空表示不可用
+	rawPath  string // dcraw_emu可执行文件路径，为空表示不可用
+	tempDir  string // 调用外部解码器时存放临时文件的目录
+}
+
+// NewConverter 创建转换器，找不到的外部解码器视为不可用
+// 参数:
+//   - heifPath: heif-convert可执行文件名或路径
+//   - rawPath: dcraw_emu可执行文件名或路径
+//   - tempDir: 临时文件目录
+func NewConverter(heifPath, rawPath, tempDir string) *Converter {
+	c := &Converter{tempDir: tempDir}
+	if resolved, err := exec.LookPath(heifPath); err == nil && heifPath != "" {
+		c.heifPath = resolved
+	}
+	if resolved, err := exec.LookPath(rawPath); err == nil && rawPath != "" {
+		c.rawPath = resolved
+	}
+	return c
+}
+
+// HEIFSupported 是否能转换HEIC/HEIF
+func (c *Converter) HEIFSupported() bool {
+	return c.heifPath != ""
+}
+
+// RAWDecoderAvailable 是否能完整解码RAW；不可用时RAW只能使用内嵌预览图
+func (c *Converter) RAWDecoderAvailable() bool {
+	return c.rawPath != ""
+}
+
+// Convert 把相机格式转换为JPEG工作副本并提取EXIF
+func (c *Converter) Convert(ctx context.Context, data []byte, f *Format) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, convertTimeout)
+	defer cancel()
+
+	var res *Result
+	var err error
+	if f.raw {
+		res, err = c.convertRAW(ctx, data)
+	} else {
+		res, err = c.convertHEIF(ctx, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.Config, err = jpeg.DecodeConfig(bytes.NewReader(res.JPEG)); err != nil {
+		return nil, fmt.Errorf("工作副本无效: %v", err)
+	}
+	return res, nil
+}
+
+// convertHEIF 调用heif-convert解码主图像，libheif会按irot/imir属性摆正方向
+func (c *Converter) convertHEIF(ctx context.Context, data []byte) (*Result, error) {
+	if c.heifPath == "" {
+		return nil, errors.New("服务器未安装heif-convert，无法处理HEIC/HEIF图片")
+	}
+	// 先输出无损的PNG再统一编码为JPEG，避免二次压缩，也不会带上原文件中的EXIF方向
+	pngData, err := c.runTool(ctx, c.heifPath, data, "input.heic", func(input, dir string) ([]string, string) {
+		output := filepath.Join(dir, "output.png")
+		return []string{input, output}, output
+	})
+	if err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return nil, err
+	}
+	jpegData, err := encodeJPEG(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{JPEG: jpegData, Exif: heifExif(data), Source: "heif-convert"}, nil
+}
+
+// convertRAW 从RAW中取出最大的内嵌JPEG预览图并按方向摆正；预览图过小或缺失时调用dcraw_emu
+func (c *Converter) convertRAW(ctx context.Context, data []byte) (*Result, error) {
+	t, err := newTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+	preview, size := t.largestPreview()
+	orientation := t.orientation()
+
+	if (preview == nil || size < minPreviewSize) && c.rawPath != "" {
+		// -w 使用相机白平衡，-T 输出TIFF，-Z - 写到标准输出；dcraw_emu默认按方向旋转
+		tiffData, err := c.runTool(ctx, c.rawPath, data, "input.raw", func(input, _ string) ([]string, string) {
+			return []string{"-w", "-T", "-Z", "-", input}, ""
+		})
+		if err == nil {
+			img, _, decodeErr := image.Decode(bytes.NewReader(tiffData))
+			if decodeErr == nil {
+				jpegData, err := encodeJPEG(img)
+				if err != nil {
+					return nil, err
+				}
+				return &Result{JPEG: jpegData, Exif: data, Source: "dcraw_emu"}, nil
+			}
+			err = decodeErr
+		}
+		if preview == nil {
+			return nil, fmt.Errorf("RAW解码失败: %v", err)
+		}
+	}
+	if preview == nil {
+		return nil, errors.New("RAW文件中没有可用的预览图，且服务器未安装dcraw_emu")
+	}
+
+	if orientation > 1 {
+		img, err := imaging.Decode(bytes.NewReader(preview))
+		if err != nil {
+			return nil, err
+		}
+		if preview, err = encodeJPEG(orient(img, orientation)); err != nil {
+			return nil, err
+		}
+	}
+	// RAW本身是TIFF结构，可直接作为EXIF数据解析
+	return &Result{JPEG: preview, Exif: data, Source: "preview"}, nil
+}
+
+// runTool 把数据写入临时文件后调用外部解码器
+// args根据输入文件路径和临时目录返回命令行参数和输出文件路径，输出文件路径为空时读取标准输出
+func (c *Converter) runTool(ctx context.Context, path string, data []byte, inputName string, args func(input, dir string) ([]string, string)) ([]byte, error) {
+	if err := os.MkdirAll(c.tempDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(c.tempDir, "ingest-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, inputName)
+	if err := os.WriteFile(input, data, 0o644); err != nil {
+		return nil, err
+	}
+	argv, output := args(input, dir)
+
+	cmd := exec.CommandContext(ctx, path, argv...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s执行失败: %v: %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+	if output == "" {
+		return stdout.Bytes(), nil
+	}
+	if _, err := os.Stat(output); os.IsNotExist(err) {
+		// 文件中有多张顶层图像时heif-convert输出 output-1.png、output-2.png……，取第一张
+		ext := filepath.Ext(output)
+		matches, _ := filepath.Glob(strings.TrimSuffix(output, ext) + "-*" + ext)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s没有输出图片", filepath.Base(path))
+		}
+		sort.Strings(matches)
+		output = matches[0]
+	}
+	return os.ReadFile(output)
+}
+
+// encodeJPEG 把图片编码为工作副本使用的JPEG
+func encodeJPEG(img image.Image) ([]byte, error) {
+	buff := &bytes.Buffer{}
+	if err := jpeg.Encode(buff, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// orient 按EXIF方向值（1-8）把图片摆正
+func orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
@@ -0,0 +1,203 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+)
+
+// 用到的TIFF标签
+const (
+	tagCompression     = 0x0103
+	tagStripOffsets    = 0x0111
+	tagOrientation     = 0x0112
+	tagStripByteCounts = 0x0117
+	tagSubIFDs         = 0x014a
+	tagJPEGOffset      = 0x0201 // JPEGInterchangeFormat
+	tagJPEGLength      = 0x0202 // JPEGInterchangeFormatLength
+)
+
+// maxIFDs 遍历的IFD数量上限，防止损坏的文件中IFD链成环
+const maxIFDs = 32
+
+// tiffFile RAW文件的TIFF结构
+// CR2、NEF、ARW、DNG都在IFD0链或SubIFDs中内嵌一到多张JPEG预览图
+type tiffFile struct {
+	data  []byte
+	order binary.ByteOrder
+	ifds  []map[uint16]tiffEntry // 按遍历顺序，ifds[0]为IFD0
+}
+
+// tiffEntry IFD中的一项
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // 值的原始字节
+}
+
+// isTIFF 判断数据是否以TIFF文件头开始
+func isTIFF(data []byte) bool {
+	return len(data) >= 8 && (string(data[:4]) == "II*\x00" || string(data[:4]) == "MM\x00*")
+}
+
+// newTIFF 解析TIFF文件头并遍历IFD0链及其SubIFDs
+func newTIFF(data []byte) (*tiffFile, error) {
+	if !isTIFF(data) {
+		return nil, errors.New("不是有效的RAW文件")
+	}
+	t := &tiffFile{data: data, order: binary.LittleEndian}
+	if data[0] == 'M' {
+		t.order = binary.BigEndian
+	}
+
+	queue := []uint32{t.order.Uint32(data[4:8])}
+	seen := map[uint32]bool{}
+	for len(queue) > 0 && len(t.ifds) < maxIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || seen[offset] {
+			continue
+		}
+		seen[offset] = true
+		entries, next, ok := t.readIFD(offset)
+		if !ok {
+			continue
+		}
+		t.ifds = append(t.ifds, entries)
+		queue = append(queue, next)
+		if sub, ok := entries[tagSubIFDs]; ok {
+			queue = append(queue, t.uints(sub)...)
+		}
+	}
+	if len(t.ifds) == 0 {
+		return nil, errors.New("RAW文件结构损坏")
+	}
+	return t, nil
+}
+
+// readIFD 读取一个IFD，返回各项和下一个IFD的偏移
+func (t *tiffFile) readIFD(offset uint32) (map[uint16]tiffEntry, uint32, bool) {
+	if int64(offset)+2 > int64(len(t.data)) {
+		return nil, 0, false
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	end := int64(offset) + 2 + int64(n)*12
+	if end+4 > int64(len(t.data)) {
+		return nil, 0, false
+	}
+
+	entries := make(map[uint16]tiffEntry, n)
+	for i := 0; i < n; i++ {
+		raw := t.data[int64(offset)+2+int64(i)*12:]
+		e := tiffEntry{typ: t.order.Uint16(raw[2:]), count: t.order.Uint32(raw[4:])}
+		size := int64(typeSize(e.typ)) * int64(e.count)
+		if size <= 4 {
+			e.value = raw[8 : 8+size]
+		} else {
+			start := int64(t.order.Uint32(raw[8:]))
+			if start+size > int64(len(t.data)) {
+				continue
+			}
+			e.value = t.data[start : start+size]
+		}
+		entries[t.order.Uint16(raw)] = e
+	}
+	return entries, t.order.Uint32(t.data[end:]), true
+}
+
+// typeSize TIFF数据类型的字节数，只关心整数类型，其他类型按1字节计
+func typeSize(typ uint16) int {
+	switch typ {
+	case 3: // SHORT
+		return 2
+	case 4, 13: // LONG、IFD
+		return 4
+	}
+	return 1
+}
+
+// uints 读取SHORT、LONG或IFD类型的值
+func (t *tiffFile) uints(e tiffEntry) []uint32 {
+	var out []uint32
+	switch e.typ {
+	case 3:
+		for i := 0; i+2 <= len(e.value); i += 2 {
+			out = append(out, uint32(t.order.Uint16(e.value[i:])))
+		}
+	case 4, 13:
+		for i := 0; i+4 <= len(e.value); i += 4 {
+			out = append(out, t.order.Uint32(e.value[i:]))
+		}
+	}
+	return out
+}
+
+// uint 读取IFD中某一项的第一个整数值
+func (t *tiffFile) uint(ifd map[uint16]tiffEntry, tag uint16) (uint32, bool) {
+	e, ok := ifd[tag]
+	if !ok {
+		return 0, false
+	}
+	values := t.uints(e)
+	if len(values) == 0 {
+		return 0, false
+	}
+	return values[0], true
+}
+
+// orientation IFD0中记录的方向，缺失时为1
+func (t *tiffFile) orientation() int {
+	if o, ok := t.uint(t.ifds[0], tagOrientation); ok && o >= 1 && o <= 8 {
+		return int(o)
+	}
+	return 1
+}
+
+// largestPreview 返回能被image/jpeg解码的最大内嵌预览图及其长边
+// 预览图可能以JPEGInterchangeFormat标签记录，也可能是压缩方式为JPEG的单条带图像；
+// RAW数据本身（无损JPEG等）无法被image/jpeg解码，自然被排除
+func (t *tiffFile) largestPreview() ([]byte, int) {
+	var best []byte
+	bestArea, bestSize := 0, 0
+	for _, ifd := range t.ifds {
+		for _, candidate := range t.previewCandidates(ifd) {
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(candidate))
+			if err != nil {
+				continue
+			}
+			if area := cfg.Width * cfg.Height; area > bestArea {
+				best, bestArea = candidate, area
+				bestSize = max(cfg.Width, cfg.Height)
+			}
+		}
+	}
+	return best, bestSize
+}
+
+// previewCandidates 一个IFD中可能是JPEG预览图的数据段
+func (t *tiffFile) previewCandidates(ifd map[uint16]tiffEntry) [][]byte {
+	var out [][]byte
+	add := func(offset, length uint32) {
+		end := int64(offset) + int64(length)
+		if length < 4 || end > int64(len(t.data)) {
+			return
+		}
+		if segment := t.data[offset:end]; segment[0] == 0xFF && segment[1] == 0xD8 {
+			out = append(out, segment)
+		}
+	}
+
+	if offset, ok := t.uint(ifd, tagJPEGOffset); ok {
+		if length, ok := t.uint(ifd, tagJPEGLength); ok {
+			add(offset, length)
+		}
+	}
+	if compression, ok := t.uint(ifd, tagCompression); ok && (compression == 6 || compression == 7) {
+		offsets, counts := t.uints(ifd[tagStripOffsets]), t.uints(ifd[tagStripByteCounts])
+		if len(offsets) == 1 && len(counts) == 1 {
+			add(offsets[0], counts[0])
+		}
+	}
+	return out
+}
@@ -0,0 +1,237 @@
+// Package mcp 实现了Model Context Protocol（MCP）服务器
+// http.go 实现了Streamable HTTP传输：客户端通过单一端点POST JSON-RPC消息，服务器以JSON响应
+// 认证使用与REST API相同的Bearer JWT，并通过X-Workspace-ID请求头选择工作区；本服务器不主动推送消息，因此不提供GET的SSE流
+package mcp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"image-manager/internal/middleware"
+	"image-manager/internal/services"
+)
+
+// 会话相关常量
+const (
+	sessionHeader  = "Mcp-Session-Id"
+	sessionIdleTTL = 24 * time.Hour
+	maxBodyBytes   = 4 << 20
+)
+
+// httpSession Streamable HTTP会话
+type httpSession struct {
+	userID   uint
+	lastSeen time.Time
+}
+
+// HTTPHandler Streamable HTTP传输处理器
+type HTTPHandler struct {
+	server         *Server
+	jwtSecret      string
+	allowedOrigins []string
+	resolve        func(userID, workspaceID uint) (services.Member, error)
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// NewHTTPHandler 创建Streamable HTTP处理器
+// 参数:
+//   - server: MCP服务器
+//   - jwtSecret: JWT密钥，用于校验Bearer Token
+//   - allowedOrigins: 允许的Origin列表（包含"*"表示不限制），用于防御DNS重绑定攻击
+//   - resolve: 解析用户在请求的工作区中的角色，通常为WorkspaceService.Resolve
+//
+// 返回: HTTPHandler指针
+func NewHTTPHandler(server *Server, jwtSecret string, allowedOrigins []string, resolve func(userID, workspaceID uint) (services.Member, error)) *HTTPHandler {
+	return &HTTPHandler{
+		server:         server,
+		jwtSecret:      jwtSecret,
+		allowedOrigins: allowedOrigins,
+		resolve:        resolve,
+		sessions:       make(map[string]*httpSession),
+	}
+}
+
+// ServeHTTP 处理MCP端点的请求
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.originAllowed(r.Header.Get("Origin")) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	userID, ok := h.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="image-manager"`)
+		writeJSONError(w, http.StatusUnauthorized, "缺少或无效的认证信息")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		workspaceID, err := middleware.ParseWorkspaceID(r.Header.Get(middleware.WorkspaceHeader))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		member, err := h.resolve(userID, workspaceID)
+		if err != nil {
+			if errors.Is(err, services.ErrForbidden) {
+				writeJSONError(w, http.StatusForbidden, "不是该工作区的成员")
+				return
+			}
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		h.handlePost(w, r, member)
+	case http.MethodDelete:
+		h.handleDelete(w, r, userID)
+	default:
+		// 服务器不会主动发起消息，不提供GET的SSE流
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost 处理客户端发送的JSON-RPC消息
+func (h *HTTPHandler) handlePost(w http.ResponseWriter, r *http.Request, m services.Member) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "读取请求失败")
+		return
+	}
+	if len(body) > maxBodyBytes {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "请求体过大")
+		return
+	}
+
+	if isInitialize(body) {
+		// 初始化请求创建新会话
+		sessionID, err := h.createSession(m.UserID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "创建会话失败")
+			return
+		}
+		w.Header().Set(sessionHeader, sessionID)
+	} else if sessionID := r.Header.Get(sessionHeader); sessionID != "" {
+		// 携带会话ID时校验会话存在且属于当前用户；未携带时按无状态请求处理
+		status := h.touchSession(sessionID, m.UserID)
+		if status != http.StatusOK {
+			writeJSONError(w, status, "会话不存在或已过期")
+			return
+		}
+	}
+
+	resp := h.server.HandleMessage(r.Context(), m, body)
+	if resp == nil {
+		// 只有通知或响应时返回202，无响应体
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp)
+}
+
+// handleDelete 客户端显式结束会话
+func (h *HTTPHandler) handleDelete(w http.ResponseWriter, r *http.Request, userID uint) {
+	sessionID := r.Header.Get(sessionHeader)
+	if sessionID == "" {
+		writeJSONError(w, http.StatusBadRequest, "缺少会话ID")
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session, ok := h.sessions[sessionID]
+	if !ok || session.userID != userID {
+		writeJSONError(w, http.StatusNotFound, "会话不存在")
+		return
+	}
+	delete(h.sessions, sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticate 从Authorization头解析用户ID
+func (h *HTTPHandler) authenticate(r *http.Request) (uint, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return 0, false
+	}
+	userID, err := middleware.ParseUserID(h.jwtSecret, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+// originAllowed 校验Origin头；没有Origin（非浏览器客户端）时放行
+func (h *HTTPHandler) originAllowed(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// createSession 创建会话并顺带清理过期会话
+func (h *HTTPHandler) createSession(userID uint) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	sessionID := hex.EncodeToString(buf)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for id, session := range h.sessions {
+		if now.Sub(session.lastSeen) > sessionIdleTTL {
+			delete(h.sessions, id)
+		}
+	}
+	h.sessions[sessionID] = &httpSession{userID: userID, lastSeen: now}
+	return sessionID, nil
+}
+
+// touchSession 校验会话并刷新活跃时间，返回HTTP状态码
+func (h *HTTPHandler) touchSession(sessionID string, userID uint) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session, ok := h.sessions[sessionID]
+	if !ok || time.Since(session.lastSeen) > sessionIdleTTL {
+		delete(h.sessions, sessionID)
+		return http.StatusNotFound
+	}
+	if session.userID != userID {
+		return http.StatusForbidden
+	}
+	session.lastSeen = time.Now()
+	return http.StatusOK
+}
+
+// isInitialize 判断消息是否为initialize请求
+func isInitialize(body []byte) bool {
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	return req.Method == "initialize"
+}
+
+// writeJSONError 写入HTTP层错误
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
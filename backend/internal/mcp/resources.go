@@ -0,0 +1,165 @@
+// Package mcp 实现了Model Context Protocol（MCP）服务器
+// resources.go 将图片库暴露为MCP资源：
+//   - image://{id}            图片元数据（JSON）
+//   - image://{id}/thumbnail  缩略图（JPEG）
+//   - image://{id}/original   原图
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"image-manager/internal/services"
+)
+
+// resourcePageSize resources/list 每页返回的图片数量
+const resourcePageSize = 50
+
+// imageURI 生成图片资源URI
+func imageURI(id uint) string {
+	return fmt.Sprintf("image://%d", id)
+}
+
+// listResources 处理 resources/list，按上传时间倒序分页列出用户的图片
+// cursor 为下一页的页码
+func (s *Server) listResources(m services.Member, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "resources/list参数错误"}
+		}
+	}
+	page := 1
+	if p.Cursor != "" {
+		parsed, err := strconv.Atoi(p.Cursor)
+		if err != nil || parsed < 1 {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "无效的cursor"}
+		}
+		page = parsed
+	}
+
+	images, total, err := s.images.List(m, map[string]string{}, page, resourcePageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]map[string]interface{}, 0, len(images))
+	for _, img := range images {
+		resources = append(resources, map[string]interface{}{
+			"uri":         imageURI(img.ID),
+			"name":        img.OriginalFilename,
+			"description": fmt.Sprintf("%dx%d %s", img.Width, img.Height, img.MimeType),
+			"mimeType":    "application/json",
+		})
+	}
+
+	result := map[string]interface{}{"resources": resources}
+	if int64(page*resourcePageSize) < total {
+		result["nextCursor"] = strconv.Itoa(page + 1)
+	}
+	return result, nil
+}
+
+// listResourceTemplates 处理 resources/templates/list
+func (s *Server) listResourceTemplates() interface{} {
+	return map[string]interface{}{
+		"resourceTemplates": []map[string]interface{}{
+			{
+				"uriTemplate": "image://{id}",
+				"name":        "图片元数据",
+				"description": "图片的文件信息、标签和EXIF（JSON）",
+				"mimeType":    "application/json",
+			},
+			{
+				"uriTemplate": "image://{id}/thumbnail",
+				"name":        "图片缩略图",
+				"description": "图片的JPEG缩略图",
+				"mimeType":    "image/jpeg",
+			},
+			{
+				"uriTemplate": "image://{id}/original",
+				"name":        "图片原图",
+				"description": "上传的原始图片文件",
+			},
+		},
+	}
+}
+
+// readResource 处理 resources/read
+func (s *Server) readResource(m services.Member, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &RPCError{Code: codeInvalidParams, Message: "resources/read参数错误"}
+	}
+
+	imageID, kind, ok := parseImageURI(p.URI)
+	if !ok {
+		return nil, &RPCError{Code: codeInvalidParams, Message: fmt.Sprintf("无效的资源URI: %s", p.URI)}
+	}
+
+	// 先校验图片属于当前用户
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, &RPCError{Code: codeInvalidParams, Message: fmt.Sprintf("资源不存在: %s", p.URI)}
+	}
+
+	var content map[string]interface{}
+	switch kind {
+	case "":
+		text, err := json.MarshalIndent(imageDetail(*img), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		content = map[string]interface{}{
+			"uri":      p.URI,
+			"mimeType": "application/json",
+			"text":     string(text),
+		}
+	case "thumbnail":
+		thumb, err := s.images.GetThumbnail(m, imageID)
+		if err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "缩略图不存在"}
+		}
+		content = map[string]interface{}{
+			"uri":      p.URI,
+			"mimeType": "image/jpeg",
+			"blob":     base64.StdEncoding.EncodeToString(thumb.Data),
+		}
+	case "original":
+		_, data, err := s.images.GetFile(m, imageID)
+		if err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "原图不存在"}
+		}
+		content = map[string]interface{}{
+			"uri":      p.URI,
+			"mimeType": img.MimeType,
+			"blob":     base64.StdEncoding.EncodeToString(data),
+		}
+	}
+
+	return map[string]interface{}{"contents": []map[string]interface{}{content}}, nil
+}
+
+// parseImageURI 解析 image://{id}[/thumbnail|/original]
+func parseImageURI(uri string) (uint, string, bool) {
+	rest, ok := strings.CutPrefix(uri, "image://")
+	if !ok {
+		return 0, "", false
+	}
+	idPart, kind, _ := strings.Cut(rest, "/")
+	if kind != "" && kind != "thumbnail" && kind != "original" {
+		return 0, "", false
+	}
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil || id == 0 {
+		return 0, "", false
+	}
+	return uint(id), kind, true
+}
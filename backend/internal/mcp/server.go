@@ -0,0 +1,248 @@
+// Package mcp 实现了Model Context Protocol（MCP）服务器
+// 通过JSON-RPC 2.0向外部LLM智能体暴露图片库的工具（tools）和资源（resources），
+// 支持stdio和Streamable HTTP两种传输方式
+// server.go 实现了协议核心：消息结构、初始化握手和方法分发
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"image-manager/internal/services"
+)
+
+// 服务器信息
+const (
+	serverName    = "image-manager"
+	serverVersion = "1.0.0"
+)
+
+// supportedProtocolVersions 支持的MCP协议版本，第一个为首选版本
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC 标准错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// Request JSON-RPC请求或通知（没有id的是通知，不需要响应）
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response JSON-RPC响应
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError JSON-RPC错误对象
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// isNotification 判断消息是否为通知（或客户端发来的响应），这两类消息都不需要回复
+func (r *Request) isNotification() bool {
+	return len(r.ID) == 0 || string(r.ID) == "null"
+}
+
+// Server MCP服务器
+// 协议层与传输层解耦：HandleMessage处理消息，stdio和HTTP传输负责读写和认证
+type Server struct {
+	images    *services.ImageService
+	tags      *services.TagService
+	albums    *services.AlbumService
+	ai        *services.AIService
+	tools     []tool
+	toolIndex map[string]tool
+}
+
+// NewServer 创建MCP服务器实例
+// 参数:
+//   - images: 图片服务实例
+//   - tags: 标签服务实例
+//   - albums: 相册服务实例（create_album使用）
+//   - ai: AI服务实例（search_images的自然语言查询使用）
+//
+// 返回: Server指针
+func NewServer(images *services.ImageService, tags *services.TagService, albums *services.AlbumService, ai *services.AIService) *Server {
+	s := &Server{
+		images:    images,
+		tags:      tags,
+		albums:    albums,
+		ai:        ai,
+		toolIndex: make(map[string]tool),
+	}
+	s.registerTools()
+	return s
+}
+
+// HandleMessage 处理一条原始JSON消息（单条请求或批量请求数组）
+// 参数:
+//   - ctx: 请求上下文
+//   - m: 已认证的用户及其当前工作区，所有工具和资源都限定在该工作区内，并按用户的角色校验权限
+//   - raw: 原始JSON消息
+//
+// 返回: 需要写回的JSON响应，消息全部为通知时返回nil
+func (s *Server) HandleMessage(ctx context.Context, m services.Member, raw []byte) []byte {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return nil
+	}
+
+	// 批量请求
+	if trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return mustMarshal(errorResponse(nil, codeParseError, "解析JSON失败"))
+		}
+		if len(batch) == 0 {
+			return mustMarshal(errorResponse(nil, codeInvalidRequest, "批量请求不能为空"))
+		}
+		responses := []*Response{}
+		for _, item := range batch {
+			if resp := s.handleOne(ctx, m, item); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return mustMarshal(responses)
+	}
+
+	resp := s.handleOne(ctx, m, trimmed)
+	if resp == nil {
+		return nil
+	}
+	return mustMarshal(resp)
+}
+
+// handleOne 处理单条JSON-RPC消息
+func (s *Server) handleOne(ctx context.Context, m services.Member, raw json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, codeParseError, "解析JSON失败")
+	}
+	if req.JSONRPC != "2.0" {
+		return errorResponse(req.ID, codeInvalidRequest, "jsonrpc必须为2.0")
+	}
+	// 客户端发来的响应（没有method）直接忽略
+	if req.Method == "" {
+		if req.isNotification() {
+			return nil
+		}
+		return errorResponse(req.ID, codeInvalidRequest, "缺少method")
+	}
+
+	result, err := s.dispatch(ctx, m, &req)
+	if req.isNotification() {
+		if err != nil {
+			log.Printf("MCP通知处理失败 %s: %v", req.Method, err)
+		}
+		return nil
+	}
+	if err != nil {
+		if rpcErr, ok := err.(*RPCError); ok {
+			return errorResponse(req.ID, rpcErr.Code, rpcErr.Message)
+		}
+		return errorResponse(req.ID, codeInternalError, err.Error())
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// dispatch 按方法名分发请求
+func (s *Server) dispatch(ctx context.Context, m services.Member, req *Request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return s.listTools(), nil
+	case "tools/call":
+		return s.callTool(ctx, m, req.Params)
+	case "resources/list":
+		return s.listResources(m, req.Params)
+	case "resources/templates/list":
+		return s.listResourceTemplates(), nil
+	case "resources/read":
+		return s.readResource(m, req.Params)
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: fmt.Sprintf("不支持的方法: %s", req.Method)}
+	}
+}
+
+// initialize 处理初始化握手，协商协议版本并声明服务器能力
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "initialize参数错误"}
+		}
+	}
+
+	// 客户端请求的版本受支持时使用该版本，否则返回服务器首选版本，由客户端决定是否继续
+	version := supportedProtocolVersions[0]
+	for _, v := range supportedProtocolVersions {
+		if v == p.ProtocolVersion {
+			version = v
+			break
+		}
+	}
+
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{"listChanged": false},
+			"resources": map[string]interface{}{"listChanged": false, "subscribe": false},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    serverName,
+			"version": serverVersion,
+		},
+		"instructions": "图片库服务器：使用search_images检索图片（支持自然语言查询），get_image查看详情，tag_image增删标签，list_tags查看标签库，create_album把图片保存为相册；图片本身可通过image://{id}/thumbnail和image://{id}/original资源读取。",
+	}, nil
+}
+
+// errorResponse 构建错误响应
+func errorResponse(id json.RawMessage, code int, message string) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &RPCError{Code: code, Message: message},
+	}
+}
+
+// mustMarshal 序列化响应，失败时返回内部错误响应
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse(nil, codeInternalError, "序列化响应失败"))
+	}
+	return data
+}
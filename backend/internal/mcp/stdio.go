@@ -0,0 +1,51 @@
+// Package mcp 实现了Model Context Protocol（MCP）服务器
+// stdio.go 实现了stdio传输：每行一条JSON-RPC消息，从stdin读取，响应写入stdout
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+
+	"image-manager/internal/services"
+)
+
+// ServeStdio 以stdio方式运行MCP服务器，直到输入结束或ctx被取消
+// stdio传输由本地进程启动，调用方需要预先确定以哪个用户身份访问哪个工作区
+// 参数:
+//   - ctx: 运行上下文
+//   - server: MCP服务器
+//   - m: 访问图片库使用的用户及工作区
+//   - in: 输入流（通常为os.Stdin）
+//   - out: 输出流（通常为os.Stdout），只能写入协议消息
+//
+// 返回: 读写错误（输入正常结束时返回nil）
+func ServeStdio(ctx context.Context, server *Server, m services.Member, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReaderSize(in, 1<<20)
+	writer := bufio.NewWriter(out)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if resp := server.HandleMessage(ctx, m, line); resp != nil {
+				if _, werr := writer.Write(append(resp, '\n')); werr != nil {
+					return werr
+				}
+				if werr := writer.Flush(); werr != nil {
+					return werr
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
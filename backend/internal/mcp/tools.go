@@ -0,0 +1,475 @@
+// Package mcp 实现了Model Context Protocol（MCP）服务器
+// tools.go 定义了暴露给LLM智能体的工具：search_images、get_image、tag_image、list_tags、create_album
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+	"image-manager/internal/services"
+)
+
+// tool MCP工具定义
+type tool struct {
+	Name        string                 `json:"name"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	handler     func(ctx context.Context, m services.Member, args json.RawMessage) (interface{}, error)
+}
+
+// registerTools 注册所有工具
+func (s *Server) registerTools() {
+	s.addTool(tool{
+		Name:  "search_images",
+		Title: "检索图片",
+		Description: "在当前用户的图片库中检索图片。可以传入自然语言查询query（由AI转换为检索条件），" +
+			"也可以直接传入结构化条件；两者同时提供时结构化条件覆盖AI转换结果。自然语言查询默认使用OR逻辑，可通过tag_mode/keyword_mode改为AND。" +
+			"返回分页的图片摘要列表，以及说明检索条件来源的diagnostics（是否使用AI、降级原因、模型原始输出、被丢弃的字段）。",
+		InputSchema: objectSchema(map[string]interface{}{
+			"query":           stringProp("自然语言查询，如“去年夏天在海边拍的照片”"),
+			"keyword":         stringProp("关键词，匹配文件名和图片中识别出的文字（截图、文档）"),
+			"tags":            stringProp("标签，多个用逗号分隔"),
+			"person":          stringProp("人物名称或ID，多个用逗号分隔时要求图片同时包含这些人物"),
+			"media":           stringProp("媒体类型：image（图片）、video（视频）、live（Live Photo），多个用逗号分隔表示任一"),
+			"album":           stringProp("相册ID，只在该相册中检索，未指定sort时按相册中的顺序排列"),
+			"has_comments":    enumProp("true只检索有评论的图片，false只检索没有评论的图片", "true", "false"),
+			"has_annotations": enumProp("true只检索有区域标注的图片，false只检索没有区域标注的图片", "true", "false"),
+			"favorited":       enumProp("true只检索当前用户收藏的图片，false只检索未收藏的图片", "true", "false"),
+			"color":           stringProp("主色调：十六进制颜色（#3366CC）或颜色名称（blue、蓝色），前缀\"mostly \"表示该颜色占大部分"),
+			"color_tolerance": numberProp("十六进制颜色的Lab容差（ΔE），默认15"),
+			"color_ratio":     numberProp("匹配颜色的最小总占比（0-1）"),
+			"quality":         stringProp("画质标记，多个用逗号分隔时同时满足：blurry、sharp、underexposed、overexposed、well_exposed、noisy、clean"),
+			"sharpness_max":   numberProp("最大清晰度（拉普拉斯方差），越小越模糊"),
+			"sort":            enumProp("排序字段，默认created_at（指定album时为position）", "created_at", "sharpness", "noise", "brightness", "position"),
+			"order":           enumProp("排序方向，默认desc", "desc", "asc"),
+			"tag_mode":        enumProp("多个标签之间的关系", "and", "or"),
+			"keyword_mode":    enumProp("关键词与其他条件之间的关系", "and", "or"),
+			"start_date":      stringProp("上传开始日期，YYYY-MM-DD"),
+			"end_date":        stringProp("上传结束日期，YYYY-MM-DD"),
+			"taken_start":     stringProp("拍摄开始时间，YYYY-MM-DD HH:MM"),
+			"taken_end":       stringProp("拍摄结束时间，YYYY-MM-DD HH:MM"),
+			"width_min":       intProp("最小宽度（像素）"),
+			"width_max":       intProp("最大宽度（像素）"),
+			"height_min":      intProp("最小高度（像素）"),
+			"height_max":      intProp("最大高度（像素）"),
+			"size_min":        numberProp("最小文件大小（MB）"),
+			"size_max":        numberProp("最大文件大小（MB）"),
+			"page":            intProp("页码，默认1"),
+			"page_size":       intProp("每页数量，默认20，最大100"),
+		}),
+		handler: s.toolSearchImages,
+	})
+
+	s.addTool(tool{
+		Name:        "get_image",
+		Title:       "查看图片详情",
+		Description: "获取单张图片的详细信息，包括尺寸、文件大小、标签和EXIF（相机、拍摄时间、GPS等）。",
+		InputSchema: objectSchema(map[string]interface{}{
+			"id": intProp("图片ID"),
+		}, "id"),
+		handler: s.toolGetImage,
+	})
+
+	s.addTool(tool{
+		Name:        "tag_image",
+		Title:       "修改图片标签",
+		Description: "为图片添加和/或移除标签。添加时标签不存在会自动创建；移除时只移除该图片与标签的关联，不删除标签本身。",
+		InputSchema: objectSchema(map[string]interface{}{
+			"id":     intProp("图片ID"),
+			"add":    stringArrayProp("要添加的标签名称列表"),
+			"remove": stringArrayProp("要移除的标签名称列表"),
+		}, "id"),
+		handler: s.toolTagImage,
+	})
+
+	s.addTool(tool{
+		Name:        "list_tags",
+		Title:       "查看标签库",
+		Description: "列出当前用户标签库中的所有标签（名称和颜色）。",
+		InputSchema: objectSchema(map[string]interface{}{}),
+		handler:     s.toolListTags,
+	})
+
+	s.addTool(tool{
+		Name:        "create_album",
+		Title:       "创建相册",
+		Description: "在当前工作区中创建普通相册，可以同时按顺序加入图片（图片ID通常来自search_images的结果）。需要editor及以上角色。",
+		InputSchema: objectSchema(map[string]interface{}{
+			"name":        stringProp("相册名称，最长100个字符"),
+			"description": stringProp("相册描述"),
+			"image_ids":   intArrayProp("要加入相册的图片ID列表，按此顺序排列"),
+		}, "name"),
+		handler: s.toolCreateAlbum,
+	})
+}
+
+// addTool 注册单个工具
+func (s *Server) addTool(t tool) {
+	s.tools = append(s.tools, t)
+	s.toolIndex[t.Name] = t
+}
+
+// listTools 处理 tools/list
+func (s *Server) listTools() interface{} {
+	return map[string]interface{}{"tools": s.tools}
+}
+
+// callTool 处理 tools/call
+// 工具执行中的业务错误以 isError=true 的结果返回，让模型能看到错误并自行调整；只有未知工具等协议错误才返回JSON-RPC错误
+func (s *Server) callTool(ctx context.Context, m services.Member, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: codeInvalidParams, Message: "tools/call参数错误"}
+	}
+	t, ok := s.toolIndex[p.Name]
+	if !ok {
+		return nil, &RPCError{Code: codeInvalidParams, Message: fmt.Sprintf("未知工具: %s", p.Name)}
+	}
+	if len(p.Arguments) == 0 || string(p.Arguments) == "null" {
+		p.Arguments = json.RawMessage("{}")
+	}
+
+	result, err := t.handler(ctx, m, p.Arguments)
+	if err != nil {
+		return map[string]interface{}{
+			"content": []map[string]interface{}{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}, nil
+	}
+
+	text, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"content":           []map[string]interface{}{{"type": "text", "text": string(text)}},
+		"structuredContent": result,
+		"isError":           false,
+	}, nil
+}
+
+// toolSearchImages search_images 工具
+func (s *Server) toolSearchImages(ctx context.Context, m services.Member, args json.RawMessage) (interface{}, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(args, &raw); err != nil {
+		return nil, fmt.Errorf("参数格式错误: %v", err)
+	}
+
+	page := intArg(raw, "page", 1)
+	pageSize := intArg(raw, "page_size", 20)
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	filters := map[string]string{}
+	var diagnostics *services.FilterDiagnostics
+	if query := stringArg(raw, "query"); query != "" {
+		tagNames, err := s.tagNames(m)
+		if err != nil {
+			return nil, err
+		}
+		converted, diag, err := s.ai.ConvertQueryToFilters(ctx, m.WorkspaceID, query, tagNames)
+		if err != nil {
+			return nil, fmt.Errorf("自然语言查询转换失败: %v", err)
+		}
+		filters = converted
+		diagnostics = &diag
+		// 未显式指定时默认使用OR逻辑放宽检索条件，下面的结构化条件可以覆盖
+		filters["keyword_mode"] = "or"
+		filters["tag_mode"] = "or"
+	}
+
+	// 结构化条件覆盖AI转换结果
+	for _, key := range []string{"keyword", "tags", "person", "media", "album", "has_comments", "has_annotations", "favorited", "color", "color_tolerance", "color_ratio", "quality", "sharpness_max", "sort", "order", "tag_mode", "keyword_mode", "start_date", "end_date",
+		"taken_start", "taken_end", "width_min", "width_max", "height_min", "height_max", "size_min", "size_max"} {
+		if value := stringArg(raw, key); value != "" {
+			filters[key] = value
+		}
+	}
+	images, total, err := s.images.List(m, filters, page, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("检索失败: %v", err)
+	}
+
+	items := make([]map[string]interface{}, 0, len(images))
+	for _, img := range images {
+		items = append(items, imageSummary(img))
+	}
+	return map[string]interface{}{
+		"total":       total,
+		"page":        page,
+		"pageSize":    pageSize,
+		"filters":     filters,
+		"diagnostics": diagnostics,
+		"items":       items,
+	}, nil
+}
+
+// toolGetImage get_image 工具
+func (s *Server) toolGetImage(ctx context.Context, m services.Member, args json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.ID == 0 {
+		return nil, fmt.Errorf("参数id无效")
+	}
+	img, err := s.images.Get(m, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("图片不存在: %d", p.ID)
+	}
+	return imageDetail(*img), nil
+}
+
+// toolTagImage tag_image 工具
+func (s *Server) toolTagImage(ctx context.Context, m services.Member, args json.RawMessage) (interface{}, error) {
+	var p struct {
+		ID     uint     `json:"id"`
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.ID == 0 {
+		return nil, fmt.Errorf("参数id无效")
+	}
+	if len(p.Add) == 0 && len(p.Remove) == 0 {
+		return nil, fmt.Errorf("add和remove至少需要提供一个")
+	}
+	if _, err := s.images.Get(m, p.ID); err != nil {
+		return nil, fmt.Errorf("图片不存在: %d", p.ID)
+	}
+
+	for _, name := range p.Add {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if len([]rune(name)) > 50 {
+			return nil, fmt.Errorf("标签名称过长: %s", name)
+		}
+		if err := s.tags.AddImageTagByName(m, p.ID, name); err != nil {
+			return nil, fmt.Errorf("添加标签失败 %s: %v", name, err)
+		}
+	}
+
+	if len(p.Remove) > 0 {
+		userTags, err := s.tags.List(m)
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string]uint, len(userTags))
+		for _, tag := range userTags {
+			byName[tag.Name] = tag.ID
+		}
+		for _, name := range p.Remove {
+			if tagID, ok := byName[strings.TrimSpace(name)]; ok {
+				if err := s.tags.Remove(p.ID, tagID, m); err != nil {
+					return nil, fmt.Errorf("移除标签失败 %s: %v", name, err)
+				}
+			}
+		}
+	}
+
+	img, err := s.images.Get(m, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	return imageSummary(*img), nil
+}
+
+// toolListTags list_tags 工具
+func (s *Server) toolListTags(ctx context.Context, m services.Member, args json.RawMessage) (interface{}, error) {
+	tags, err := s.tags.List(m)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]map[string]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		items = append(items, map[string]interface{}{
+			"id":    tag.ID,
+			"name":  tag.Name,
+			"color": tag.Color,
+		})
+	}
+	return map[string]interface{}{"tags": items}, nil
+}
+
+// toolCreateAlbum create_album 工具
+// 图片加入失败时删除刚创建的相册，不留下空相册
+func (s *Server) toolCreateAlbum(ctx context.Context, m services.Member, args json.RawMessage) (interface{}, error) {
+	var p struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		ImageIDs    []uint `json:"image_ids"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("参数错误: %v", err)
+	}
+	if len([]rune(strings.TrimSpace(p.Name))) > 100 {
+		return nil, fmt.Errorf("相册名称过长")
+	}
+	album, err := s.albums.Create(m, dto.AlbumRequest{Name: p.Name, Description: p.Description})
+	if err != nil {
+		return nil, err
+	}
+	added := 0
+	if len(p.ImageIDs) > 0 {
+		if added, err = s.albums.AddImages(m, album.ID, p.ImageIDs); err != nil {
+			if deleteErr := s.albums.Delete(m, album.ID); deleteErr != nil {
+				return nil, fmt.Errorf("%v（删除相册失败: %v）", err, deleteErr)
+			}
+			return nil, err
+		}
+	}
+	return map[string]interface{}{
+		"id":          album.ID,
+		"name":        album.Name,
+		"description": album.Description,
+		"image_count": added,
+	}, nil
+}
+
+// tagNames 获取用户标签库中的标签名称
+func (s *Server) tagNames(m services.Member) ([]string, error) {
+	tags, err := s.tags.List(m)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, nil
+}
+
+// imageSummary 图片摘要，用于列表类结果
+func imageSummary(img models.Image) map[string]interface{} {
+	tagNames := make([]string, 0, len(img.Tags))
+	for _, tag := range img.Tags {
+		tagNames = append(tagNames, tag.Name)
+	}
+	summary := map[string]interface{}{
+		"id":               img.ID,
+		"originalFilename": img.OriginalFilename,
+		"mimeType":         img.MimeType,
+		"width":            img.Width,
+		"height":           img.Height,
+		"fileSize":         img.FileSize,
+		"createdAt":        img.CreatedAt,
+		"tags":             tagNames,
+		"uri":              imageURI(img.ID),
+		"thumbnailUri":     imageURI(img.ID) + "/thumbnail",
+	}
+	if img.Exif.TakenAt != nil {
+		summary["takenAt"] = img.Exif.TakenAt
+	}
+	if img.MediaType != "" && img.MediaType != models.MediaImage {
+		summary["mediaType"] = img.MediaType
+		summary["durationMs"] = img.DurationMs
+	}
+	if img.CommentCount > 0 {
+		summary["commentCount"] = img.CommentCount
+	}
+	if img.Favorited {
+		summary["favorited"] = true
+	}
+	return summary
+}
+
+// imageDetail 图片详情，在摘要基础上附加EXIF信息
+func imageDetail(img models.Image) map[string]interface{} {
+	detail := imageSummary(img)
+	if img.Exif.ID != 0 {
+		detail["exif"] = img.Exif
+	}
+	if len(img.Colors) > 0 {
+		detail["colors"] = img.Colors
+	}
+	if img.Quality != nil {
+		detail["quality"] = img.Quality
+	}
+	detail["originalUri"] = imageURI(img.ID) + "/original"
+	return detail
+}
+
+// 以下为JSON Schema构造辅助函数
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func intProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "description": description}
+}
+
+func numberProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "number", "description": description}
+}
+
+func enumProp(description string, values ...string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description, "enum": values}
+}
+
+func stringArrayProp(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"description": description,
+		"items":       map[string]interface{}{"type": "string"},
+	}
+}
+
+func intArrayProp(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"description": description,
+		"items":       map[string]interface{}{"type": "integer"},
+	}
+}
+
+// stringArg 读取字符串参数，数字参数会被格式化为字符串
+func stringArg(args map[string]interface{}, key string) string {
+	switch v := args[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// intArg 读取整数参数
+func intArg(args map[string]interface{}, key string, fallback int) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
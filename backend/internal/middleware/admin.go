@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware 只允许系统管理员访问，用于全局运行状态等不属于任何工作区的接口
+// 需要放在AuthMiddleware之后
+func AdminMiddleware(isAdmin func(userID uint) bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !isAdmin(ctx.GetUint("user_id")) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "需要系统管理员权限"})
+			return
+		}
+		ctx.Next()
+	}
+}
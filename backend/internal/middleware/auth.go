@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -22,27 +23,36 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "无效的Token"})
+		userID, err := ParseUserID(secret, parts[1])
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "无法解析Token"})
-			return
-		}
+		ctx.Set("user_id", userID)
+		ctx.Next()
+	}
+}
 
-		userIDValue, ok := claims["user_id"].(float64)
-		if !ok {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Token缺少用户信息"})
-			return
-		}
+// ParseUserID 校验JWT并从中解析用户ID
+// 供HTTP中间件以及其他需要Bearer Token认证的入口（如MCP服务器）共用
+func ParseUserID(secret, tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, errors.New("无效的Token")
+	}
 
-		ctx.Set("user_id", uint(userIDValue))
-		ctx.Next()
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("无法解析Token")
 	}
+
+	userIDValue, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("Token缺少用户信息")
+	}
+
+	return uint(userIDValue), nil
 }
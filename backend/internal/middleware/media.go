@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaTokenParam 媒体令牌的查询参数名
+const MediaTokenParam = "mt"
+
+// mediaWorkspaceKey 媒体令牌中的工作区，WorkspaceMiddleware用它代替X-Workspace-ID请求头
+const mediaWorkspaceKey = "media_workspace_id"
+
+// MediaAuthMiddleware 图片文件、视频等媒体接口的认证
+// 带Authorization请求头时与AuthMiddleware相同；否则使用查询参数中的媒体令牌（供<img>、<video>标签使用），
+// 令牌只能访问签发时的工作区。需要放在WorkspaceMiddleware之前
+func MediaAuthMiddleware(secret string) gin.HandlerFunc {
+	auth := AuthMiddleware(secret)
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader("Authorization") != "" {
+			auth(ctx)
+			return
+		}
+
+		token := ctx.Query(MediaTokenParam)
+		if token == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "缺少认证信息"})
+			return
+		}
+		userID, workspaceID, err := services.ParseMediaToken(secret, token)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+			return
+		}
+
+		ctx.Set("user_id", userID)
+		ctx.Set(mediaWorkspaceKey, workspaceID)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"image-manager/internal/models"
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMediaAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "test-secret"
+	auth := services.NewAuthService(nil, secret)
+	token, _, err := auth.MediaToken(services.Member{UserID: 7, WorkspaceID: 3, Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := services.NewAuthService(nil, "other-secret").MediaToken(services.Member{UserID: 7, WorkspaceID: 3, Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resolved [2]uint
+	resolve := func(userID, workspaceID uint) (services.Member, error) {
+		resolved = [2]uint{userID, workspaceID}
+		return services.Member{UserID: userID, WorkspaceID: workspaceID, Role: models.RoleViewer}, nil
+	}
+	engine := gin.New()
+	engine.GET("/media", MediaAuthMiddleware(secret), WorkspaceMiddleware(resolve), func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+	engine.GET("/api", AuthMiddleware(secret), func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		url    string
+		header map[string]string
+		want   int
+	}{
+		{"no credentials", "/media", nil, http.StatusUnauthorized},
+		{"media token", "/media?mt=" + token, nil, http.StatusNoContent},
+		// 令牌中的工作区优先于请求头，不能借媒体令牌访问其他工作区
+		{"media token ignores workspace header", "/media?mt=" + token, map[string]string{WorkspaceHeader: "9"}, http.StatusNoContent},
+		{"wrong secret", "/media?mt=" + other, nil, http.StatusUnauthorized},
+		{"media token is not a login token", "/api", map[string]string{"Authorization": "Bearer " + token}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved = [2]uint{}
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusNoContent && resolved != [2]uint{7, 3} {
+				t.Errorf("resolved user/workspace = %v, want [7 3]", resolved)
+			}
+		})
+	}
+}
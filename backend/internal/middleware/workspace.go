@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"image-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHeader 选择当前工作区的请求头，缺省时使用用户的个人工作区
+const WorkspaceHeader = "X-Workspace-ID"
+
+// WorkspaceMiddleware 解析当前工作区和用户在其中的角色，写入workspace_id和workspace_role
+// 需要放在AuthMiddleware之后；用户不是该工作区的成员时返回403
+// 通过媒体令牌认证的请求使用令牌中的工作区，忽略请求头
+func WorkspaceMiddleware(resolve func(userID, workspaceID uint) (services.Member, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		workspaceID, err := ParseWorkspaceID(ctx.GetHeader(WorkspaceHeader))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if id, ok := ctx.Get(mediaWorkspaceKey); ok {
+			workspaceID = id.(uint)
+		}
+
+		member, err := resolve(ctx.GetUint("user_id"), workspaceID)
+		if err != nil {
+			if errors.Is(err, services.ErrForbidden) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "不是该工作区的成员"})
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+
+		ctx.Set("workspace_id", member.WorkspaceID)
+		ctx.Set("workspace_role", member.Role)
+		ctx.Next()
+	}
+}
+
+// ParseWorkspaceID 解析工作区请求头，为空时返回0（个人工作区）
+func ParseWorkspaceID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("工作区ID格式错误")
+	}
+	return uint(id), nil
+}
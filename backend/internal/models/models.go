@@ -3,7 +3,10 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // User 用户模型
@@ -18,25 +21,79 @@ type User struct {
 	Images    []Image   `json:"images,omitempty"`                             // 关联的图片列表，一对多关系
 }
 
+// Workspace 工作区模型
+// 图片、标签和相册都属于某个工作区；每个用户注册时自动创建一个个人工作区，
+// 团队工作区可以邀请多个成员，成员按角色获得不同权限
+type Workspace struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`    // 工作区ID，主键
+	Name      string    `gorm:"size:100" json:"name"`    // 工作区名称
+	Personal  bool      `gorm:"index" json:"personal"`   // 是否为个人工作区，个人工作区只有创建者一个成员且不能删除
+	OwnerID   uint      `gorm:"index" json:"ownerId"`    // 创建者的用户ID
+	Role      string    `gorm:"-" json:"role,omitempty"` // 当前用户在该工作区中的角色
+	Members   int       `gorm:"-" json:"memberCount"`    // 成员数量
+	CreatedAt time.Time `json:"createdAt"`               // 创建时间
+	UpdatedAt time.Time `json:"updatedAt"`               // 更新时间
+}
+
+// WorkspaceMember 工作区成员
+type WorkspaceMember struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`                                // 主键
+	WorkspaceID uint      `gorm:"uniqueIndex:uk_workspace_member" json:"workspaceId"`  // 工作区ID
+	UserID      uint      `gorm:"uniqueIndex:uk_workspace_member;index" json:"userId"` // 成员的用户ID
+	Username    string    `gorm:"-" json:"username"`                                   // 成员的用户名
+	Role        string    `gorm:"size:10" json:"role"`                                 // 角色，见RoleOwner等常量
+	CreatedAt   time.Time `json:"createdAt"`                                           // 加入时间
+}
+
+// 工作区成员角色，权限依次递增
+const (
+	RoleViewer = "viewer" // 只能浏览、评论和收藏
+	RoleEditor = "editor" // 上传、编辑和整理图片，只能删除自己上传的图片
+	RoleAdmin  = "admin"  // 可以删除任何图片和标签，管理成员
+	RoleOwner  = "owner"  // 工作区创建者，可以删除工作区，每个工作区只有一个
+)
+
 // Image 图片模型
 // 存储图片的基本信息，包括文件名、路径、尺寸、文件大小等
 type Image struct {
-	ID               uint      `gorm:"primaryKey" json:"id"`                  // 图片ID，主键
-	UserID           uint      `json:"userId"`                                // 所属用户ID
-	OriginalFilename string    `gorm:"size:255" json:"originalFilename"`      // 原始文件名，最大255字符
-	StoredFilename   string    `gorm:"size:255" json:"storedFilename"`        // 存储文件名（经过处理的唯一文件名）
-	FilePath         string    `gorm:"size:500" json:"filePath"`              // 文件存储路径，最大500字符
-	MimeType         string    `gorm:"size:50" json:"mimeType"`               // MIME类型，如image/jpeg
-	FileSize         int64     `json:"fileSize"`                              // 文件大小（字节）
-	Width            int       `json:"width"`                                 // 图片宽度（像素）
-	Height           int       `json:"height"`                                // 图片高度（像素）
-	CreatedAt        time.Time `json:"createdAt"`                             // 创建时间
-	UpdatedAt        time.Time `json:"updatedAt"`                             // 更新时间
-	Exif             ImageEXIF `json:"exif"`                                  // 关联的EXIF数据，一对一关系
-	Tags             []Tag     `gorm:"many2many:image_tags;" json:"tags"`     // 关联的标签列表，多对多关系
-	Thumbnail        Thumbnail `json:"thumbnail"`                             // 关联的缩略图，一对一关系
+	ID               uint          `gorm:"primaryKey" json:"id"`                  // 图片ID，主键
+	UserID           uint          `json:"userId"`                                // 上传者的用户ID
+	WorkspaceID      uint          `gorm:"index" json:"workspaceId"`              // 所属工作区ID，图片库按工作区划分
+	OriginalFilename string        `gorm:"size:255" json:"originalFilename"`      // 原始文件名，最大255字符
+	StoredFilename   string        `gorm:"size:255" json:"storedFilename"`        // 存储文件名（经过处理的唯一文件名）
+	FilePath         string        `gorm:"size:500" json:"filePath"`              // 文件存储路径，最大500字符
+	MimeType         string        `gorm:"size:50" json:"mimeType"`               // MIME类型，如image/jpeg
+	SourceFilePath   string        `gorm:"size:500" json:"-"`                     // 相机原始文件（HEIC/HEIF、RAW）的存储路径，FilePath为其JPEG工作副本；普通图片为空
+	SourceMimeType   string        `gorm:"size:50" json:"sourceMimeType"`         // 相机原始文件的MIME类型，如image/heic、image/x-canon-cr2
+	FileSize         int64         `json:"fileSize"`                              // 文件大小（字节）
+	Width            int           `json:"width"`                                 // 图片宽度（像素）
+	Height           int           `json:"height"`                                // 图片高度（像素）
+	FrameCount       int           `gorm:"default:1" json:"frameCount"`           // 帧数，GIF/WebP动图大于1
+	DurationMs       int           `json:"durationMs"`                            // 动图一次播放的时长或视频时长（毫秒），静态图片为0
+	MediaType        string        `gorm:"size:8;default:image" json:"mediaType"` // 媒体类型：image、video或live（Live Photo），见MediaImage等常量
+	VideoFilePath    string        `gorm:"size:500" json:"-"`                     // 视频或Live Photo动态部分的存储路径；视频的FilePath为其封面帧
+	VideoMimeType    string        `gorm:"size:50" json:"videoMimeType"`          // 视频的MIME类型，如video/mp4、video/quicktime
+	CreatedAt        time.Time     `json:"createdAt"`                             // 创建时间
+	UpdatedAt        time.Time     `json:"updatedAt"`                             // 更新时间
+	EditVersionID    *uint         `json:"editVersionId"`                         // 当前编辑版本ID，为空表示未编辑（显示原图）
+	DerivedFromID    *uint         `gorm:"index" json:"derivedFromId"`            // 以副本方式保存编辑结果时的源图片ID，源图片删除后置空
+	Exif             ImageEXIF     `json:"exif"`                                  // 关联的EXIF数据，一对一关系
+	Tags             []Tag         `gorm:"many2many:image_tags;" json:"tags"`     // 关联的标签列表，多对多关系
+	Thumbnail        Thumbnail     `json:"thumbnail"`                             // 关联的缩略图，一对一关系
+	Colors           []ImageColor  `json:"colors"`                                // 主色调调色板，按占比从高到低排列
+	Quality          *ImageQuality `json:"quality,omitempty"`                     // 画质指标（清晰度、曝光、噪点），尚未计算时为空
+	Text             *ImageText    `json:"text,omitempty"`                        // OCR识别出的文字，只在详情中加载
+	CommentCount     int           `gorm:"-" json:"commentCount"`                 // 评论数量
+	Favorited        bool          `gorm:"-" json:"favorited"`                    // 当前用户是否已收藏
 }
 
+// 图片的媒体类型
+const (
+	MediaImage = "image" // 静态图片或动图
+	MediaVideo = "video" // 视频，FilePath保存封面帧，缩略图、人脸检测等都基于封面帧
+	MediaLive  = "live"  // Live Photo：静态图片附带一段短视频
+)
+
 // ImageEXIF 图片EXIF数据模型
 // 存储图片的EXIF元数据信息，包括相机信息、拍摄时间、地理位置等
 type ImageEXIF struct {
@@ -60,12 +117,13 @@ type ImageEXIF struct {
 // Tag 标签模型
 // 用户自定义的标签，用于分类和管理图片
 type Tag struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`                         // 标签ID，主键
-	UserID    uint      `gorm:"uniqueIndex:idx_user_tag" json:"userId"`       // 所属用户ID，联合唯一索引的一部分
-	Name      string    `gorm:"size:50;uniqueIndex:idx_user_tag" json:"name"` // 标签名称，最大50字符，联合唯一索引的一部分
-	Color     string    `gorm:"size:7" json:"color"`                          // 标签颜色（十六进制颜色码，如#FF0000），最大7字符
-	CreatedAt time.Time `json:"createdAt"`                                    // 创建时间
-	Images    []Image   `gorm:"many2many:image_tags;" json:"-"`               // 关联的图片列表，多对多关系，JSON序列化时排除
+	ID          uint      `gorm:"primaryKey" json:"id"`                              // 标签ID，主键
+	UserID      uint      `gorm:"index" json:"userId"`                               // 创建者的用户ID
+	WorkspaceID uint      `gorm:"uniqueIndex:idx_workspace_tag" json:"workspaceId"`  // 所属工作区ID，联合唯一索引的一部分
+	Name        string    `gorm:"size:50;uniqueIndex:idx_workspace_tag" json:"name"` // 标签名称，最大50字符，联合唯一索引的一部分
+	Color       string    `gorm:"size:7" json:"color"`                               // 标签颜色（十六进制颜色码，如#FF0000），最大7字符
+	CreatedAt   time.Time `json:"createdAt"`                                         // 创建时间
+	Images      []Image   `gorm:"many2many:image_tags;" json:"-"`                    // 关联的图片列表，多对多关系，JSON序列化时排除
 }
 
 // ImageTag 图片标签关联表
@@ -85,5 +143,358 @@ type Thumbnail struct {
 	Width     int       `json:"width"`                          // 缩略图宽度（像素）
 	Height    int       `json:"height"`                         // 缩略图高度（像素）
 	Size      int       `json:"size"`                           // 缩略图文件大小（字节）
+	MimeType  string    `gorm:"size:50" json:"mimeType"`        // 缩略图格式：image/jpeg，动图为image/gif；旧数据为空时按JPEG处理
 	CreatedAt time.Time `json:"createdAt"`                      // 创建时间
 }
+
+// SearchSession 对话式检索会话模型
+// 保存多轮对话式检索的上下文：最近一轮生效的过滤条件和结果集图片ID，用于后续追问时在此基础上细化
+type SearchSession struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`                           // 会话ID，主键
+	UserID      uint            `gorm:"index" json:"userId"`                            // 所属用户ID
+	WorkspaceID uint            `gorm:"index" json:"workspaceId"`                       // 检索的工作区ID
+	Title       string          `gorm:"size:200" json:"title"`                          // 会话标题（取首轮查询内容）
+	Filters     string          `gorm:"type:text" json:"filters"`                       // 最近一轮实际用于检索的过滤条件（JSON格式）
+	ResultIDs   string          `gorm:"type:longtext" json:"-"`                         // 最近一轮结果集的图片ID列表（JSON数组）
+	CreatedAt   time.Time       `json:"createdAt"`                                      // 创建时间
+	UpdatedAt   time.Time       `json:"updatedAt"`                                      // 更新时间
+	Messages    []SearchMessage `gorm:"foreignKey:SessionID" json:"messages,omitempty"` // 会话消息历史
+}
+
+// SearchMessage 对话式检索消息模型
+// 记录会话中的每条消息：用户的查询，以及助手返回的过滤条件和结果数量
+type SearchMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`   // 消息ID，主键
+	SessionID uint      `gorm:"index" json:"sessionId"` // 所属会话ID
+	Role      string    `gorm:"size:20" json:"role"`    // 消息角色：user 或 assistant
+	Content   string    `gorm:"type:text" json:"content"` // 消息内容（用户查询或助手的结果说明）
+	Filters   string    `gorm:"type:text" json:"filters,omitempty"` // 助手消息对应的过滤条件（JSON格式）
+	Total     int64     `json:"total"`                  // 助手消息对应的结果数量
+	CreatedAt time.Time `json:"createdAt"`              // 创建时间
+}
+
+// Person 人物模型
+// 人脸按特征相似度自动聚类得到的人物，初始未命名，用户可以为其命名后按人物检索图片
+type Person struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`            // 人物ID，主键
+	UserID      uint      `gorm:"index" json:"userId"`             // 首次检测到该人物时的上传者ID
+	WorkspaceID uint      `gorm:"index" json:"workspaceId"`        // 所属工作区ID，聚类只在同一工作区的人脸之间进行
+	Name        string    `gorm:"size:100;index" json:"name"`      // 人物名称，未命名时为空
+	FaceCount   int       `json:"faceCount"`                       // 归属该人物的人脸数量
+	CoverFaceID uint      `json:"coverFaceId"`                     // 封面人脸ID（置信度最高的人脸）
+	Centroid    []byte    `gorm:"type:blob" json:"-"`              // 聚类中心特征向量（小端float32）
+	CreatedAt   time.Time `json:"createdAt"`                       // 创建时间
+	UpdatedAt   time.Time `json:"updatedAt"`                       // 更新时间
+}
+
+// Face 人脸模型
+// 上传后处理时在图片中检测到的人脸，记录位置和特征向量
+type Face struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`     // 人脸ID，主键
+	ImageID     uint      `gorm:"index" json:"imageId"`     // 所在图片ID
+	UserID      uint      `gorm:"index" json:"userId"`      // 图片上传者的用户ID
+	WorkspaceID uint      `gorm:"index" json:"workspaceId"` // 所属工作区ID
+	PersonID    uint      `gorm:"index" json:"personId"`    // 所属人物ID
+	X           int       `json:"x"`                        // 人脸框左上角X（原图像素）
+	Y           int       `json:"y"`                        // 人脸框左上角Y（原图像素）
+	Width       int       `json:"width"`                    // 人脸框宽度
+	Height      int       `json:"height"`                   // 人脸框高度
+	Score       float32   `json:"score"`                    // 检测置信度
+	Embedding   []byte    `gorm:"type:blob" json:"-"`       // 人脸特征向量（小端float32）
+	CreatedAt   time.Time `json:"createdAt"`                // 创建时间
+}
+
+// ImageColor 图片主色调模型
+// 上传后处理时通过聚类提取的调色板，每张图片若干条，按占比从高到低排列；
+// 同时保存CIE Lab和LCh坐标，用于按颜色检索（Lab距离或色相范围）
+type ImageColor struct {
+	ID      uint    `gorm:"primaryKey" json:"-"`    // 记录ID，主键
+	ImageID uint    `gorm:"index" json:"-"`         // 所属图片ID
+	Rank    int     `json:"rank"`                   // 排名，0为占比最高的主色
+	Hex     string  `gorm:"size:7" json:"hex"`      // 颜色的十六进制表示，如#3366CC
+	L       float64 `json:"l"`                      // Lab明度（0-100）
+	A       float64 `json:"a"`                      // Lab a分量（绿-红）
+	B       float64 `json:"b"`                      // Lab b分量（蓝-黄）
+	Chroma  float64 `json:"chroma"`                 // LCh彩度
+	Hue     float64 `json:"hue"`                    // LCh色相角（0-360度）
+	Ratio   float64 `json:"ratio"`                  // 该颜色在图片中的像素占比（0-1）
+}
+
+// ImageQuality 图片画质指标模型
+// 上传后处理时计算，每张图片一条；模糊和噪点按配置中的阈值在查询时判断，曝光分类在计算时确定
+type ImageQuality struct {
+	ImageID       uint      `gorm:"primaryKey" json:"-"`            // 所属图片ID，主键
+	Sharpness     float64   `gorm:"index" json:"sharpness"`         // 清晰度：拉普拉斯算子响应的方差，越小越模糊
+	Noise         float64   `gorm:"index" json:"noise"`             // 噪点估计：噪声标准差（0-255灰度）
+	Brightness    float64   `gorm:"index" json:"brightness"`        // 平均亮度（0-255灰度）
+	ShadowClip    float64   `json:"shadowClip"`                     // 暗部溢出（接近纯黑）的像素占比（0-1）
+	HighlightClip float64   `json:"highlightClip"`                  // 高光溢出（接近纯白）的像素占比（0-1）
+	Exposure      string    `gorm:"size:10;index" json:"exposure"`  // 曝光分类：under（欠曝）、over（过曝）、normal（正常）
+	CreatedAt     time.Time `json:"createdAt"`                      // 计算时间
+}
+
+// ImageText 图片文字模型
+// 上传后处理时由OCR引擎识别，每张图片一条；内容建立ngram全文索引，关键词检索时与文件名一起匹配
+type ImageText struct {
+	ImageID   uint      `gorm:"primaryKey" json:"-"`                                                                            // 所属图片ID，主键
+	Engine    string    `gorm:"size:20" json:"engine"`                                                                          // 识别引擎：tesseract 或 ai
+	Content   string    `gorm:"type:text;index:idx_image_texts_content,class:FULLTEXT,option:WITH PARSER ngram" json:"content"` // 识别出的文字，按行分隔
+	CreatedAt time.Time `json:"createdAt"`                                                                                      // 识别时间
+}
+
+// ImageVersion 图片编辑版本模型
+// 非破坏性编辑：每个版本保存相对原图的完整操作列表，渲染时依次作用在原图上；
+// 版本通过ParentID组成树，撤销回到父版本，重做进入最近创建的子版本
+type ImageVersion struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`        // 版本ID，主键
+	ImageID    uint            `gorm:"index" json:"imageId"`        // 所属图片ID
+	ParentID   *uint           `gorm:"index" json:"parentId"`       // 父版本ID，为空表示直接基于原图
+	Label      string          `gorm:"size:100" json:"label"`       // 版本说明，如本次新增的操作类型
+	Operations json.RawMessage `gorm:"type:text" json:"operations"` // 相对原图的完整操作列表（JSON数组）
+	Width      int             `json:"width"`                       // 渲染结果宽度
+	Height     int             `json:"height"`                      // 渲染结果高度
+	CreatedAt  time.Time       `json:"createdAt"`                   // 创建时间
+}
+
+// Watermark 水印预设模型
+// 用户保存的文字或PNG标志水印，可以作为编辑操作永久叠加到图片上，也可以在导出时临时叠加
+// 软删除：已提交的编辑版本中保存了水印参数快照，删除预设后标志文件仍需保留以便重新渲染
+
+type Watermark struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`    // 水印ID，主键
+	UserID    uint           `gorm:"index" json:"userId"`     // 所属用户ID
+	Name      string         `gorm:"size:100" json:"name"`    // 预设名称
+	Type      string         `gorm:"size:10" json:"type"`     // 水印类型：text 或 image
+	Text      string         `gorm:"size:200" json:"text"`    // 文字内容（text）
+	Color     string         `gorm:"size:7" json:"color"`     // 文字颜色（#RRGGBB）
+	LogoPath  string         `gorm:"size:500" json:"-"`       // 标志图片存储路径（image）
+	Position  string         `gorm:"size:20" json:"position"` // 位置，如bottom-right、center、tile
+	Opacity   float64        `json:"opacity"`                 // 不透明度（0到1）
+	Scale     float64        `json:"scale"`                   // 水印宽度占图片宽度的比例（0到1）
+	Margin    float64        `json:"margin"`                  // 与边缘的距离占图片短边的比例
+	CreatedAt time.Time      `json:"createdAt"`               // 创建时间
+	UpdatedAt time.Time      `json:"updatedAt"`               // 更新时间
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`          // 删除时间（软删除）
+}
+
+// Album 相册模型
+// 服务端保存的图片集合，跨设备和浏览器可见
+// 普通相册的图片按AlbumItem.Position排序；智能相册保存筛选条件，每次查看时通过图片列表实时计算，
+// 只缓存最近一次计算的数量和封面
+type Album struct {
+	ID           uint        `gorm:"primaryKey" json:"id"`               // 相册ID，主键
+	UserID       uint        `gorm:"index" json:"userId"`                // 创建者的用户ID
+	WorkspaceID  uint        `gorm:"index" json:"workspaceId"`           // 所属工作区ID
+	Name         string      `gorm:"size:100" json:"name"`               // 相册名称
+	Description  string      `gorm:"size:1000" json:"description"`       // 相册描述
+	Kind         string      `gorm:"size:10;default:manual" json:"kind"` // 相册类型，见AlbumManual、AlbumSmart
+	Filters      string      `gorm:"type:text" json:"filters,omitempty"` // 智能相册的筛选条件（JSON格式），与图片列表的查询参数相同
+	CachedCount  int         `json:"-"`                                  // 智能相册最近一次计算的图片数量
+	CachedCover  uint        `json:"-"`                                  // 智能相册最近一次计算的封面（第一张图片）
+	CachedAt     *time.Time  `json:"cachedAt,omitempty"`                 // 智能相册数量和封面的计算时间
+	CoverImageID *uint       `json:"coverImageId"`                       // 指定的封面图片ID，为空时使用第一张图片
+	CoverID      uint        `gorm:"-" json:"coverId"`                   // 实际显示的封面图片ID，相册为空时为0
+	ImageCount   int         `gorm:"-" json:"imageCount"`                // 图片数量
+	Items        []AlbumItem `json:"items,omitempty"`                    // 相册中的图片，按Position排序
+	CreatedAt    time.Time   `json:"createdAt"`                          // 创建时间
+	UpdatedAt    time.Time   `json:"updatedAt"`                          // 更新时间
+}
+
+// 相册类型
+const (
+	AlbumManual = "manual" // 普通相册，手动添加图片并排序
+	AlbumSmart  = "smart"  // 智能相册，图片由保存的筛选条件决定
+)
+
+// AlbumItem 相册图片关联表
+// 同一张图片可以属于多个相册，在每个相册中有各自的位置和说明
+type AlbumItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`                     // 主键
+	AlbumID   uint      `gorm:"uniqueIndex:uk_item" json:"albumId"`       // 相册ID
+	ImageID   uint      `gorm:"uniqueIndex:uk_item;index" json:"imageId"` // 图片ID，同一相册中不重复
+	Position  int       `json:"position"`                                 // 在相册中的位置，从0开始
+	Caption   string    `gorm:"size:500" json:"caption"`                  // 图片在该相册中的说明
+	CreatedAt time.Time `json:"createdAt"`                                // 加入相册的时间
+}
+
+// Slideshow 幻灯片模型
+// 服务端保存的幻灯片，按SlideshowItem.Position排序播放；Token用于未登录设备（如电视浏览器）公开播放
+type Slideshow struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`             // 幻灯片ID，主键
+	UserID        uint            `gorm:"index" json:"userId"`              // 创建者的用户ID
+	WorkspaceID   uint            `gorm:"index" json:"workspaceId"`         // 所属工作区ID
+	Name          string          `gorm:"size:100" json:"name"`             // 名称
+	Transition    string          `gorm:"size:20" json:"transition"`        // 默认转场效果，见SlideshowTransitions
+	MusicPath     string          `gorm:"size:500" json:"-"`                // 背景音乐文件存储路径，为空表示没有背景音乐
+	MusicMimeType string          `gorm:"size:50" json:"musicMimeType"`     // 背景音乐的MIME类型
+	MusicName     string          `gorm:"size:255" json:"musicName"`        // 背景音乐的原始文件名
+	Token         string          `gorm:"size:32;uniqueIndex" json:"token"` // 公开播放令牌，重新生成后旧链接失效
+	Items         []SlideshowItem `json:"items,omitempty"`                  // 幻灯片中的图片，按Position排序
+	CreatedAt     time.Time       `json:"createdAt"`                        // 创建时间
+	UpdatedAt     time.Time       `json:"updatedAt"`                        // 更新时间
+}
+
+// SlideshowItem 幻灯片中的一张图片
+type SlideshowItem struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`      // 主键
+	SlideshowID uint    `gorm:"index" json:"slideshowId"`  // 幻灯片ID
+	ImageID     uint    `gorm:"index" json:"imageId"`      // 图片ID
+	Position    int     `json:"position"`                  // 播放顺序，从0开始
+	Duration    float64 `json:"duration"`                  // 停留时间（秒）；视频为0时播放完整视频
+	Transition  string  `gorm:"size:20" json:"transition"` // 切换到这张图片时的转场效果，为空时使用幻灯片的默认效果
+	Caption     string  `gorm:"size:500" json:"caption"`   // 说明文字
+}
+
+// SlideshowTransitions 支持的转场效果
+var SlideshowTransitions = []string{"none", "fade", "slide", "zoom"}
+
+// RenderJob 视频导出任务
+// 把相册或幻灯片渲染成MP4，由后台任务队列依次执行；完成后OutputPath保存生成的视频文件
+type RenderJob struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`             // 任务ID，主键
+	UserID        uint       `gorm:"index" json:"userId"`              // 所属用户ID
+	WorkspaceID   uint       `json:"workspaceId"`                      // 来源所在的工作区ID
+	SourceType    string     `gorm:"size:10" json:"sourceType"`        // 来源类型：album或slideshow
+	SourceID      uint       `json:"sourceId"`                         // 相册或幻灯片ID
+	Name          string     `gorm:"size:100" json:"name"`             // 来源的名称，同时用作下载文件名
+	Resolution    int        `json:"resolution"`                       // 输出高度：720、1080或2160
+	SlideDuration float64    `json:"slideDuration"`                    // 相册中每张图片的停留秒数，为0时默认5秒；幻灯片使用各自的停留时间
+	KenBurns      bool       `json:"kenBurns"`                         // 是否添加缓慢平移缩放效果
+	Audio         bool       `json:"audio"`                            // 是否带音轨
+	AudioPath     string     `gorm:"size:500" json:"-"`                // 随任务上传的音轨文件，为空时幻灯片使用其背景音乐；任务结束后删除
+	Status        string     `gorm:"size:10;index" json:"status"`      // 任务状态，见RenderPending等常量
+	Progress      float64    `json:"progress"`                         // 进度，0到1
+	Error         string     `gorm:"type:text" json:"error,omitempty"` // 失败原因
+	OutputPath    string     `gorm:"size:500" json:"-"`                // 生成的MP4文件路径
+	Size          int64      `json:"size"`                             // 生成的文件大小（字节）
+	Duration      float64    `json:"duration"`                         // 视频时长（秒）
+	StartedAt     *time.Time `json:"startedAt,omitempty"`              // 开始渲染时间
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`             // 完成或失败时间
+	CreatedAt     time.Time  `json:"createdAt"`                        // 创建时间
+	UpdatedAt     time.Time  `json:"updatedAt"`                        // 更新时间
+}
+
+// 视频导出任务状态
+const (
+	RenderPending = "pending" // 排队中
+	RenderRunning = "running" // 渲染中
+	RenderDone    = "done"    // 已完成，可以下载
+	RenderFailed  = "failed"  // 失败
+)
+
+// 视频导出的来源类型
+const (
+	RenderSourceAlbum     = "album"
+	RenderSourceSlideshow = "slideshow"
+)
+
+// ShareLink 公开分享链接
+// 未登录的访客凭Token查看分享的图片：单张图片、带有指定标签的图片或一个相册；标签和智能相册的内容随图片库变化
+type ShareLink struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`             // 分享ID，主键
+	UserID        uint       `gorm:"index" json:"userId"`              // 分享者的用户ID
+	WorkspaceID   uint       `gorm:"index" json:"workspaceId"`         // 分享的图片所在的工作区ID
+	Token         string     `gorm:"size:32;uniqueIndex" json:"token"` // 公开访问令牌
+	Kind          string     `gorm:"size:10" json:"kind"`              // 分享类型，见ShareImage等常量
+	TargetID      uint       `json:"targetId,omitempty"`               // 图片ID或相册ID
+	Tags          string     `gorm:"size:500" json:"tags,omitempty"`   // 标签分享的标签名，逗号分隔，要求同时包含
+	Title         string     `gorm:"size:100" json:"title"`            // 访客看到的标题
+	PasswordHash  string     `gorm:"size:100" json:"-"`                // 访问密码的bcrypt哈希，为空表示不需要密码
+	HasPassword   bool       `gorm:"-" json:"hasPassword"`             // 是否需要密码
+	AllowDownload bool       `json:"allowDownload"`                    // 是否允许下载原图
+	WatermarkID   uint       `json:"watermarkId,omitempty"`            // 访客浏览和下载时叠加的水印预设ID，0表示不加水印
+	ExpiresAt     *time.Time `json:"expiresAt"`                        // 过期时间，为空表示永不过期
+	ViewCount     int        `json:"viewCount"`                        // 访问次数
+	CreatedAt     time.Time  `json:"createdAt"`                        // 创建时间
+}
+
+// 分享类型
+const (
+	ShareImage = "image" // 单张图片
+	ShareTags  = "tags"  // 带有指定标签的图片
+	ShareAlbum = "album" // 相册
+)
+
+// ShareGrant 用户之间的分享授权
+// 图片所有者把选定的图片或一个相册授权给另一个用户；对方接受后可以浏览，授权为copy时还可以导入到自己的图片库
+type ShareGrant struct {
+	ID            uint              `gorm:"primaryKey" json:"id"`                       // 授权ID，主键
+	OwnerID       uint              `gorm:"index" json:"ownerId"`                       // 授权者的用户ID
+	WorkspaceID   uint              `gorm:"index" json:"workspaceId"`                   // 授权的图片所在的工作区ID
+	RecipientID   uint              `gorm:"index" json:"recipientId"`                   // 接收者的用户ID
+	OwnerName     string            `gorm:"-" json:"ownerName"`                         // 所有者的用户名
+	RecipientName string            `gorm:"-" json:"recipientName"`                     // 接收者的用户名
+	Kind          string            `gorm:"size:10" json:"kind"`                        // 授权范围：images为选定的图片，album为相册（内容随相册变化）
+	AlbumID       uint              `json:"albumId,omitempty"`                          // 相册授权的相册ID
+	Access        string            `gorm:"size:10" json:"access"`                      // 权限，见GrantRead、GrantCopy
+	Status        string            `gorm:"size:10;index" json:"status"`                // 状态，见GrantPending等常量
+	Message       string            `gorm:"size:500" json:"message"`                    // 所有者附带的说明
+	Images        []ShareGrantImage `gorm:"foreignKey:GrantID" json:"images,omitempty"` // 选定的图片
+	ImageCount    int               `gorm:"-" json:"imageCount"`                        // 授权范围内的图片数量
+	RespondedAt   *time.Time        `json:"respondedAt,omitempty"`                      // 接收者接受或拒绝的时间
+	CreatedAt     time.Time         `json:"createdAt"`                                  // 创建时间
+}
+
+// ShareGrantImage 授权中选定的一张图片
+type ShareGrantImage struct {
+	ID      uint `gorm:"primaryKey" json:"id"` // 主键
+	GrantID uint `gorm:"index" json:"grantId"` // 授权ID
+	ImageID uint `gorm:"index" json:"imageId"` // 图片ID
+}
+
+// 授权范围
+const (
+	GrantImages = "images" // 选定的图片
+	GrantAlbum  = "album"  // 相册
+)
+
+// 授权权限
+const (
+	GrantRead = "read" // 只能浏览
+	GrantCopy = "copy" // 可以浏览并导入到自己的图片库
+)
+
+// 授权状态
+const (
+	GrantPending  = "pending"  // 等待接收者接受
+	GrantAccepted = "accepted" // 已接受
+	GrantDeclined = "declined" // 已拒绝
+)
+
+// ImageComment 图片评论，ParentID不为空时是对另一条评论的回复
+type ImageComment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`     // 主键
+	ImageID     uint      `gorm:"index" json:"imageId"`     // 图片ID
+	WorkspaceID uint      `gorm:"index" json:"workspaceId"` // 图片所在的工作区
+	UserID      uint      `gorm:"index" json:"userId"`      // 评论者的用户ID
+	ParentID    *uint     `gorm:"index" json:"parentId"`    // 回复的评论ID，为空表示顶层评论
+	Username    string    `gorm:"-" json:"username"`        // 评论者的用户名
+	Body        string    `gorm:"type:text" json:"body"`    // 评论内容
+	CreatedAt   time.Time `json:"createdAt"`                // 创建时间
+	UpdatedAt   time.Time `json:"updatedAt"`                // 修改时间
+}
+
+// ImageAnnotation 图片区域标注：一个矩形和一段说明
+// 坐标和尺寸以原图像素为单位，在缩略图或缩放后的预览上显示时按比例换算
+type ImageAnnotation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`     // 主键
+	ImageID     uint      `gorm:"index" json:"imageId"`     // 图片ID
+	WorkspaceID uint      `gorm:"index" json:"workspaceId"` // 图片所在的工作区
+	UserID      uint      `gorm:"index" json:"userId"`      // 标注者的用户ID
+	Username    string    `gorm:"-" json:"username"`        // 标注者的用户名
+	X           int       `json:"x"`                        // 矩形左上角横坐标（原图像素）
+	Y           int       `json:"y"`                        // 矩形左上角纵坐标（原图像素）
+	Width       int       `json:"width"`                    // 矩形宽度（原图像素）
+	Height      int       `json:"height"`                   // 矩形高度（原图像素）
+	Note        string    `gorm:"size:500" json:"note"`     // 说明
+	CreatedAt   time.Time `json:"createdAt"`                // 创建时间
+	UpdatedAt   time.Time `json:"updatedAt"`                // 修改时间
+}
+
+// ImageFavorite 用户收藏的图片，收藏只对本人可见
+type ImageFavorite struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`                               // 主键
+	ImageID   uint      `gorm:"uniqueIndex:uk_image_favorite;index" json:"imageId"` // 图片ID
+	UserID    uint      `gorm:"uniqueIndex:uk_image_favorite" json:"userId"`        // 收藏者的用户ID
+	CreatedAt time.Time `json:"createdAt"`                                          // 收藏时间
+}
@@ -0,0 +1,52 @@
+// Package ocr 提供可替换的文字识别（OCR）引擎
+// 内置本地tesseract命令行引擎；视觉大模型引擎由services包基于AIService实现同一接口
+package ocr
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// Engine 文字识别引擎
+type Engine interface {
+	// Name 引擎名称，随识别结果一起保存
+	Name() string
+	// Recognize 识别图片中的文字，返回按行拼接的文本；没有文字时返回空字符串
+	Recognize(ctx context.Context, data []byte, mimeType string) (string, error)
+}
+
+// CleanText 规范化识别结果：去掉每行首尾空白和空行，连续空白合并为一个空格
+func CleanText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	cleaned := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			cleaned = append(cleaned, line)
+		}
+	}
+	return strings.Join(cleaned, "\n")
+}
+
+// joinWords 将同一行的单词拼接为文本
+// 相邻两侧都是中日韩文字时不加空格，避免"你 好"这种无法按关键词检索的结果
+func joinWords(words []string) string {
+	var b strings.Builder
+	for i, word := range words {
+		if i > 0 {
+			prev := []rune(words[i-1])
+			next := []rune(word)
+			if !(isCJK(prev[len(prev)-1]) && isCJK(next[0])) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
+
+// isCJK 判断字符是否为中日韩文字或全角标点
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
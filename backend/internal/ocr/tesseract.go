@@ -0,0 +1,92 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Tesseract 调用本地tesseract命令行进行识别
+// 以TSV格式输出逐词结果，丢弃置信度过低的词，避免把照片中的纹理识别成乱码
+type Tesseract struct {
+	path          string  // tesseract可执行文件路径
+	languages     string  // 语言包，如 chi_sim+eng
+	minConfidence float64 // 词的最低置信度（0-100）
+}
+
+// NewTesseract 创建tesseract引擎，可执行文件不存在时返回错误
+// 参数:
+//   - path: tesseract可执行文件名或路径
+//   - languages: 语言包，多个用+连接
+//   - minConfidence: 词的最低置信度（0-100）
+func NewTesseract(path, languages string, minConfidence float64) (*Tesseract, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("找不到tesseract: %v", err)
+	}
+	return &Tesseract{path: resolved, languages: languages, minConfidence: minConfidence}, nil
+}
+
+// Name 引擎名称
+func (t *Tesseract) Name() string {
+	return "tesseract"
+}
+
+// Recognize 通过标准输入把图片交给tesseract，解析标准输出中的TSV结果
+func (t *Tesseract) Recognize(ctx context.Context, data []byte, mimeType string) (string, error) {
+	args := []string{"stdin", "stdout"}
+	if t.languages != "" {
+		args = append(args, "-l", t.languages)
+	}
+	args = append(args, "tsv")
+
+	cmd := exec.CommandContext(ctx, t.path, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract执行失败: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTSV(stdout.String(), t.minConfidence), nil
+}
+
+// parseTSV 解析tesseract的TSV输出，按行拼接置信度达标的词
+// 列依次为 level page_num block_num par_num line_num word_num left top width height conf text，level为5的是单词
+func parseTSV(tsv string, minConfidence float64) string {
+	var lines []string
+	var words []string
+	lineKey := ""
+	flush := func() {
+		if len(words) > 0 {
+			lines = append(lines, joinWords(words))
+			words = words[:0]
+		}
+	}
+
+	for i, row := range strings.Split(tsv, "\n") {
+		if i == 0 {
+			continue // 表头
+		}
+		cols := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(cols) < 12 || cols[0] != "5" {
+			continue
+		}
+		key := strings.Join(cols[1:5], "-")
+		if key != lineKey {
+			flush()
+			lineKey = key
+		}
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		text := strings.TrimSpace(cols[11])
+		if err != nil || conf < minConfidence || text == "" {
+			continue
+		}
+		words = append(words, text)
+	}
+	flush()
+	return CleanText(strings.Join(lines, "\n"))
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -16,27 +17,63 @@ import (
 )
 
 type Server struct {
-	cfg          config.Config
-	engine       *gin.Engine
-	authHandler  *handlers.AuthHandler
-	imageHandler *handlers.ImageHandler
-	tagHandler   *handlers.TagHandler
-	mcpHandler   *handlers.MCPHandler
+	cfg               config.Config
+	engine            *gin.Engine
+	authHandler       *handlers.AuthHandler
+	imageHandler      *handlers.ImageHandler
+	tagHandler        *handlers.TagHandler
+	mcpHandler        *handlers.MCPHandler
+	aiHandler         *handlers.AIHandler
+	peopleHandler     *handlers.PeopleHandler
+	editHandler       *handlers.EditHandler
+	watermarkHandler  *handlers.WatermarkHandler
+	albumHandler      *handlers.AlbumHandler
+	slideshowHandler  *handlers.SlideshowHandler
+	renderHandler     *handlers.RenderHandler
+	shareHandler      *handlers.ShareHandler
+	grantHandler      *handlers.GrantHandler
+	workspaceHandler  *handlers.WorkspaceHandler
+	commentHandler    *handlers.CommentHandler
+	annotationHandler *handlers.AnnotationHandler
+	favoriteHandler   *handlers.FavoriteHandler
+	workspaceService  *services.WorkspaceService
+	authService       *services.AuthService
 }
 
 func New(db *gorm.DB, cfg config.Config) *Server {
-	tagService := services.NewTagService(db)
-	aiService := services.NewAIService(cfg)
-	imageService := services.NewImageService(db, cfg, tagService, aiService)
+	library := services.NewLibrary(db, cfg)
+	tagService, aiService, imageService := library.Tags, library.AI, library.Images
+	peopleService, watermarkService, editService := library.People, library.Watermarks, library.Edits
 	authService := services.NewAuthService(db, cfg.JWTSecret)
+	workspaceService := services.NewWorkspaceService(db, authService)
+	sessionService := services.NewSearchSessionService(db)
+	albumService := services.NewAlbumService(db, imageService)
+	slideshowService := services.NewSlideshowService(db, cfg, editService)
+	renderService := services.NewRenderService(db, cfg, editService, albumService, slideshowService)
+	renderService.Start(context.Background())
 
 	s := &Server{
-		cfg:          cfg,
-		engine:       gin.New(),
-		authHandler:  handlers.NewAuthHandler(authService),
-		imageHandler: handlers.NewImageHandler(imageService, tagService, authService),
-		tagHandler:   handlers.NewTagHandler(tagService),
-		mcpHandler:   handlers.NewMCPHandler(imageService, aiService, tagService),
+		cfg:               cfg,
+		engine:            gin.New(),
+		authHandler:       handlers.NewAuthHandler(authService),
+		imageHandler:      handlers.NewImageHandler(imageService, tagService),
+		tagHandler:        handlers.NewTagHandler(tagService),
+		mcpHandler:        handlers.NewMCPHandler(imageService, aiService, tagService, sessionService, albumService),
+		aiHandler:         handlers.NewAIHandler(aiService),
+		peopleHandler:     handlers.NewPeopleHandler(peopleService),
+		editHandler:       handlers.NewEditHandler(editService, imageService),
+		watermarkHandler:  handlers.NewWatermarkHandler(watermarkService),
+		albumHandler:      handlers.NewAlbumHandler(albumService),
+		slideshowHandler:  handlers.NewSlideshowHandler(slideshowService),
+		renderHandler:     handlers.NewRenderHandler(renderService),
+		grantHandler:      handlers.NewGrantHandler(services.NewGrantService(db, authService, imageService, albumService, tagService)),
+		shareHandler:      handlers.NewShareHandler(services.NewShareService(db, cfg, imageService, albumService, editService)),
+		workspaceHandler:  handlers.NewWorkspaceHandler(workspaceService),
+		commentHandler:    handlers.NewCommentHandler(services.NewCommentService(db)),
+		annotationHandler: handlers.NewAnnotationHandler(services.NewAnnotationService(db)),
+		favoriteHandler:   handlers.NewFavoriteHandler(services.NewFavoriteService(db), imageService),
+		workspaceService:  workspaceService,
+		authService:       authService,
 	}
 
 	s.setupMiddleware()
@@ -52,8 +89,8 @@ func (s *Server) setupMiddleware() {
 	corsCfg := cors.Config{
 		AllowOrigins:     []string{"*"},  // 允许所有来源
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"},
-		AllowHeaders:     []string{"Authorization", "Content-Type", "Content-Length", "X-Requested-With", "Accept", "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", "Content-Length", "X-Requested-With", "Accept", "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", middleware.WorkspaceHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Authorization", "X-Preview-Width", "X-Preview-Height", "X-Preview-Scale", "Content-Disposition"},
 		AllowCredentials: false,  // 当AllowOrigins为"*"时，必须设置为false
 		MaxAge:           12 * time.Hour,
 	}
@@ -71,26 +108,132 @@ func (s *Server) setupRoutes() {
 	api.POST("/auth/register", s.authHandler.Register)
 	api.POST("/auth/login", s.authHandler.Login)
 
+	// 工作区管理按路径中的工作区ID鉴权，不经过工作区中间件
+	workspaces := api.Group("/workspaces")
+	workspaces.Use(middleware.AuthMiddleware(s.cfg.JWTSecret))
+	workspaces.GET("", s.workspaceHandler.List)
+	workspaces.POST("", s.workspaceHandler.Create)
+	workspaces.PUT("/:id", s.workspaceHandler.Update)
+	workspaces.DELETE("/:id", s.workspaceHandler.Delete)
+	workspaces.GET("/:id/members", s.workspaceHandler.Members)
+	workspaces.POST("/:id/members", s.workspaceHandler.AddMember)
+	workspaces.PUT("/:id/members/:userId", s.workspaceHandler.UpdateMember)
+	workspaces.DELETE("/:id/members/:userId", s.workspaceHandler.RemoveMember)
+
+	// 其余接口都在X-Workspace-ID请求头选择的工作区（缺省为个人工作区）中执行
 	protected := api.Group("/")
-	protected.Use(middleware.AuthMiddleware(s.cfg.JWTSecret))
+	protected.Use(middleware.AuthMiddleware(s.cfg.JWTSecret), middleware.WorkspaceMiddleware(s.workspaceService.Resolve))
 
 	protected.GET("/images", s.imageHandler.List)
 	protected.POST("/images/upload", s.imageHandler.Upload)
 	protected.GET("/images/:id", s.imageHandler.Detail)
 	protected.PUT("/images/:id", s.imageHandler.Update)
 	protected.DELETE("/images/:id", s.imageHandler.Delete)
-	protected.POST("/images/:id/crop", s.imageHandler.Crop)
-	protected.POST("/images/:id/adjust", s.imageHandler.Adjust)
-	protected.POST("/images/import/verify", s.imageHandler.ImportVerify)
-	protected.POST("/images/import", s.imageHandler.Import)
+	protected.POST("/images/batch-delete", s.imageHandler.BatchDelete)
+	protected.POST("/images/:id/crop", s.editHandler.Crop)
+	protected.POST("/images/:id/adjust", s.editHandler.Adjust)
+
+	protected.GET("/media-token", s.authHandler.MediaToken)
+
+	// 图片文件和视频：<img>、<video>等标签无法携带请求头，可以改用查询参数mt中的媒体令牌认证
+	media := api.Group("/")
+	media.Use(middleware.MediaAuthMiddleware(s.cfg.JWTSecret), middleware.WorkspaceMiddleware(s.workspaceService.Resolve))
+	media.GET("/images/:id/thumbnail", s.imageHandler.Thumbnail)
+	media.GET("/images/:id/original", s.imageHandler.Original)
+	media.GET("/images/:id/source", s.imageHandler.Source)
+	media.GET("/images/:id/video", s.imageHandler.Video)
+	media.GET("/images/:id/rendered", s.editHandler.Rendered)
+
+	// 非破坏性编辑：操作列表、版本历史、撤销/重做
+	protected.POST("/images/:id/edits/preview", s.editHandler.Preview)
+	protected.POST("/images/:id/edits/commit", s.editHandler.Commit)
+	protected.GET("/images/:id/versions", s.editHandler.History)
+	protected.POST("/images/:id/versions/:versionId/checkout", s.editHandler.Checkout)
+	protected.POST("/images/:id/undo", s.editHandler.Undo)
+	protected.POST("/images/:id/redo", s.editHandler.Redo)
+	protected.POST("/images/:id/revert", s.editHandler.Revert)
+	protected.GET("/images/:id/export", s.editHandler.Export)
+
+	// 水印预设
+	protected.GET("/watermarks", s.watermarkHandler.List)
+	protected.POST("/watermarks", s.watermarkHandler.Create)
+	protected.PUT("/watermarks/:id", s.watermarkHandler.Update)
+	protected.DELETE("/watermarks/:id", s.watermarkHandler.Delete)
+	protected.GET("/watermarks/:id/logo", s.watermarkHandler.Logo)
+
+	// 相册和智能相册，普通相册内的图片也可以通过 GET /images?album=ID 获取
+	protected.GET("/albums", s.albumHandler.List)
+	protected.POST("/albums", s.albumHandler.Create)
+	protected.GET("/albums/:id", s.albumHandler.Detail)
+	protected.PUT("/albums/:id", s.albumHandler.Update)
+	protected.DELETE("/albums/:id", s.albumHandler.Delete)
+	protected.GET("/albums/:id/images", s.albumHandler.Images)
+	protected.POST("/albums/:id/refresh", s.albumHandler.Refresh)
+	protected.POST("/albums/:id/images", s.albumHandler.AddImages)
+	protected.POST("/albums/:id/images/remove", s.albumHandler.RemoveImages)
+	protected.PUT("/albums/:id/order", s.albumHandler.Reorder)
+	protected.PUT("/albums/:id/images/:imageId/caption", s.albumHandler.SetCaption)
+
+	// 幻灯片；/play/:token 下是无需登录的公开播放接口
+	protected.GET("/slideshows", s.slideshowHandler.List)
+	protected.POST("/slideshows", s.slideshowHandler.Create)
+	protected.GET("/slideshows/:id", s.slideshowHandler.Detail)
+	protected.PUT("/slideshows/:id", s.slideshowHandler.Update)
+	protected.DELETE("/slideshows/:id", s.slideshowHandler.Delete)
+	protected.PUT("/slideshows/:id/music", s.slideshowHandler.SetMusic)
+	protected.DELETE("/slideshows/:id/music", s.slideshowHandler.RemoveMusic)
+	protected.POST("/slideshows/:id/token", s.slideshowHandler.ResetToken)
+	api.GET("/play/:token", s.slideshowHandler.Manifest)
+	api.GET("/play/:token/images/:itemId", s.slideshowHandler.Rendition)
+	api.GET("/play/:token/images/:itemId/video", s.slideshowHandler.Video)
+	api.GET("/play/:token/music", s.slideshowHandler.Music)
 
-	api.GET("/images/:id/thumbnail", s.imageHandler.Thumbnail)
-	api.GET("/images/:id/original", s.imageHandler.Original)
+	// 相册和幻灯片导出为MP4，在后台渲染
+	protected.GET("/renders", s.renderHandler.List)
+	protected.POST("/renders", s.renderHandler.Create)
+	protected.GET("/renders/:id", s.renderHandler.Detail)
+	protected.GET("/renders/:id/download", s.renderHandler.Download)
+	protected.DELETE("/renders/:id", s.renderHandler.Delete)
+
+	// 公开分享链接；/share/:token 下是无需登录的访客接口，只能访问分享范围内的图片
+	protected.GET("/shares", s.shareHandler.List)
+	protected.POST("/shares", s.shareHandler.Create)
+	protected.DELETE("/shares/:id", s.shareHandler.Revoke)
+	api.GET("/share/:token", s.shareHandler.View)
+	api.POST("/share/:token/unlock", s.shareHandler.Unlock)
+	api.GET("/share/:token/images/:itemId", s.shareHandler.Image)
+	api.GET("/share/:token/images/:itemId/thumbnail", s.shareHandler.Thumbnail)
+	api.GET("/share/:token/images/:itemId/video", s.shareHandler.Video)
+	api.GET("/share/:token/images/:itemId/download", s.shareHandler.Download)
+
+	// 用户之间的分享授权，接受后可以浏览，copy授权可以导入
+	protected.GET("/grants/outgoing", s.grantHandler.Outgoing)
+	protected.GET("/grants/incoming", s.grantHandler.Incoming)
+	protected.POST("/grants", s.grantHandler.Create)
+	protected.POST("/grants/:id/accept", s.grantHandler.Accept)
+	protected.POST("/grants/:id/decline", s.grantHandler.Decline)
+	protected.DELETE("/grants/:id", s.grantHandler.Delete)
+	protected.GET("/grants/:id/images", s.grantHandler.Images)
+	media.GET("/grants/:id/images/:imageId/thumbnail", s.grantHandler.Thumbnail)
+	protected.POST("/grants/:id/import", s.grantHandler.Import)
 
 	protected.POST("/images/:id/tags", s.tagHandler.Assign)
 	protected.DELETE("/images/:id/tags/:tagId", s.tagHandler.Remove)
 	protected.POST("/images/:id/tags/add", s.tagHandler.AddImageTag)
 	protected.PUT("/images/:id/tags/update", s.tagHandler.UpdateImageTag)
+	// 评论、区域标注和收藏；评论和收藏所有成员都可以使用，标注需要editor及以上角色
+	protected.GET("/images/:id/comments", s.commentHandler.List)
+	protected.POST("/images/:id/comments", s.commentHandler.Create)
+	protected.PUT("/comments/:id", s.commentHandler.Update)
+	protected.DELETE("/comments/:id", s.commentHandler.Delete)
+	protected.GET("/images/:id/annotations", s.annotationHandler.List)
+	protected.POST("/images/:id/annotations", s.annotationHandler.Create)
+	protected.PUT("/annotations/:id", s.annotationHandler.Update)
+	protected.DELETE("/annotations/:id", s.annotationHandler.Delete)
+	protected.PUT("/images/:id/favorite", s.favoriteHandler.Add)
+	protected.DELETE("/images/:id/favorite", s.favoriteHandler.Remove)
+	protected.GET("/favorites", s.favoriteHandler.List)
+
 	protected.GET("/tags", s.tagHandler.List)
 	protected.POST("/tags", s.tagHandler.Create)
 	protected.PUT("/tags/:id/color", s.tagHandler.UpdateColor)
@@ -98,6 +241,25 @@ func (s *Server) setupRoutes() {
 
 	// MCP对话式图片检索接口
 	protected.POST("/mcp/search", s.mcpHandler.Search)
+	protected.GET("/mcp/sessions", s.mcpHandler.ListSessions)
+	protected.GET("/mcp/sessions/:id", s.mcpHandler.GetSession)
+	protected.DELETE("/mcp/sessions/:id", s.mcpHandler.DeleteSession)
+	protected.POST("/mcp/sessions/:id/album", s.mcpHandler.SaveAlbum)
+
+	// AI调用统计（调用次数、耗时、失败、熔断器状态），统计是全局的，只有系统管理员可以查看
+	protected.GET("/ai/metrics", middleware.AdminMiddleware(s.isAdmin), s.aiHandler.Metrics)
+
+	// 人物（人脸聚类）相关路由
+	protected.GET("/people", s.peopleHandler.List)
+	protected.GET("/people/:id", s.peopleHandler.Detail)
+	protected.PUT("/people/:id/name", s.peopleHandler.Rename)
+	protected.POST("/people/:id/merge", s.peopleHandler.Merge)
+	media.GET("/people/:id/cover", s.peopleHandler.Cover)
+}
+
+// isAdmin 判断用户是否为配置中的系统管理员
+func (s *Server) isAdmin(userID uint) bool {
+	return s.authService.IsAdmin(userID, s.cfg.AdminUsers)
 }
 
 func (s *Server) Run() error {
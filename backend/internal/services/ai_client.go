@@ -0,0 +1,431 @@
+// Package services 提供业务逻辑层的服务实现
+// ai_client.go 实现了AI API调用共用的HTTP客户端，提供请求取消、指数退避重试、令牌桶限流、熔断器和调用统计
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"image-manager/internal/config"
+)
+
+// ErrAICircuitOpen 熔断器处于打开状态时返回的错误，调用方应直接降级而不是等待
+var ErrAICircuitOpen = errors.New("AI服务暂时不可用（熔断中）")
+
+// aiClient AI API客户端
+// 所有AI调用共享同一个http.Client（复用连接），并统一经过限流、熔断和重试逻辑
+type aiClient struct {
+	cfg     config.Config
+	http    *http.Client
+	limiter *tokenBucket
+	breaker *circuitBreaker
+	metrics *aiMetrics
+}
+
+// newAIClient 根据配置创建AI客户端
+func newAIClient(cfg config.Config) *aiClient {
+	return &aiClient{
+		cfg: cfg,
+		http: &http.Client{
+			Timeout: time.Duration(cfg.AITimeoutSeconds) * time.Second,
+		},
+		limiter: newTokenBucket(cfg.AIRateLimit, cfg.AIRateBurst),
+		breaker: newCircuitBreaker(cfg.AIBreakerThreshold, time.Duration(cfg.AIBreakerCooldownSeconds)*time.Second),
+		metrics: newAIMetrics(),
+	}
+}
+
+// do 发送一次AI API请求（POST JSON）
+// 对网络错误、429和5xx状态码进行指数退避重试；ctx被取消时立即返回
+// 参数:
+//   - ctx: 请求上下文，通常来自发起调用的HTTP请求
+//   - operation: 操作名称，用于按操作分别统计
+//   - payload: 已序列化的JSON请求体
+// 返回: 响应体、HTTP状态码和错误信息（非200但不可重试的响应不视为错误，由调用方处理）
+func (c *aiClient) do(ctx context.Context, operation string, payload []byte) ([]byte, int, error) {
+	start := time.Now()
+	body, status, err := c.doWithRetry(ctx, operation, payload)
+	c.metrics.record(operation, time.Since(start), status, err)
+	return body, status, err
+}
+
+func (c *aiClient) doWithRetry(ctx context.Context, operation string, payload []byte) ([]byte, int, error) {
+	var (
+		last    aiResponse
+		lastErr error
+	)
+
+	for attempt := 0; attempt <= c.cfg.AIMaxRetries; attempt++ {
+		if attempt > 0 {
+			c.metrics.recordRetry(operation)
+			if err := sleepContext(ctx, c.backoff(attempt, last)); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		// 熔断器打开时不再发起请求
+		if err := c.breaker.allow(); err != nil {
+			c.metrics.recordRejected(operation)
+			return nil, 0, err
+		}
+
+		// 限流：等待令牌，等待期间请求被取消则直接返回
+		if err := c.limiter.wait(ctx); err != nil {
+			c.breaker.release()
+			return nil, 0, err
+		}
+
+		resp, err := c.send(ctx, payload)
+		if err != nil {
+			// 调用方主动取消不算作AI服务故障
+			if ctx.Err() != nil {
+				c.breaker.release()
+				return nil, 0, ctx.Err()
+			}
+			c.breaker.failure()
+			last, lastErr = aiResponse{}, err
+			log.Printf("AI API请求失败（第%d次尝试）: %v", attempt+1, err)
+			continue
+		}
+
+		if isRetryableStatus(resp.status) {
+			c.breaker.failure()
+			last, lastErr = resp, nil
+			log.Printf("AI API返回可重试状态码 %d（第%d次尝试）", resp.status, attempt+1)
+			continue
+		}
+
+		c.breaker.success()
+		return resp.body, resp.status, nil
+	}
+
+	if lastErr != nil {
+		return nil, 0, fmt.Errorf("请求AI API失败: %v", lastErr)
+	}
+	return last.body, last.status, nil
+}
+
+// aiResponse 单次HTTP请求的结果
+type aiResponse struct {
+	body       []byte
+	status     int
+	retryAfter time.Duration // 429响应中Retry-After头给出的等待时间，没有则为0
+}
+
+// send 发送单次HTTP请求并读取完整响应体
+func (c *aiClient) send(ctx context.Context, payload []byte) (aiResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.AIApiURL, bytes.NewReader(payload))
+	if err != nil {
+		return aiResponse{}, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.cfg.AIApiKey))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return aiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return aiResponse{}, fmt.Errorf("读取响应失败: %v", err)
+	}
+	return aiResponse{
+		body:       body,
+		status:     resp.StatusCode,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}, nil
+}
+
+// backoff 计算第attempt次重试前的等待时间
+// 基础间隔按2的指数增长并加入随机抖动；上一次响应若带有Retry-After则优先使用
+func (c *aiClient) backoff(attempt int, last aiResponse) time.Duration {
+	if last.retryAfter > 0 {
+		return last.retryAfter
+	}
+
+	base := time.Duration(c.cfg.AIRetryBaseMillis) * time.Millisecond
+	delay := base << uint(attempt-1)
+	maxDelay := 10 * time.Second
+	if delay > maxDelay || delay <= 0 {
+		delay = maxDelay
+	}
+	// 抖动范围为[delay/2, delay]
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter 解析Retry-After头中的秒数，无法解析时返回0
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	// 避免服务端给出过长的等待时间导致请求长期挂起
+	if seconds > 30 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// isRetryableStatus 判断状态码是否值得重试（限流或服务端错误）
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// sleepContext 等待指定时间，期间ctx被取消则提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tokenBucket 令牌桶限流器
+// 按固定速率补充令牌，桶容量决定允许的突发请求数
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64 // 每秒补充的令牌数，<=0 表示不限流
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:     rate,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// wait 阻塞直到获得一个令牌或ctx被取消
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		need := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleepContext(ctx, need); err != nil {
+			return err
+		}
+	}
+}
+
+// 熔断器状态
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// circuitBreaker 熔断器
+// 连续失败达到阈值后打开，冷却期内直接拒绝请求；冷却结束后进入半开状态，只放行一个探测请求
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool // 半开状态下是否已有探测请求在进行
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+	}
+}
+
+// allow 判断当前是否允许发起请求
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrAICircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return ErrAICircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// success 记录一次成功调用，关闭熔断器
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// failure 记录一次失败调用，达到阈值或半开探测失败时打开熔断器
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			log.Printf("AI熔断器打开，连续失败%d次，冷却%v", b.failures, b.cooldown)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+	b.probing = false
+}
+
+// release 请求未真正发出（如被调用方取消）时释放半开探测名额，不影响计数
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// currentState 返回熔断器当前状态
+func (b *circuitBreaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return breakerHalfOpen
+	}
+	return b.state
+}
+
+// AIOperationMetrics 单个AI操作的调用统计
+type AIOperationMetrics struct {
+	Calls          int64   `json:"calls"`          // 调用次数（含失败）
+	Successes      int64   `json:"successes"`      // 返回200的次数
+	Failures       int64   `json:"failures"`       // 网络错误或非200响应的次数
+	Retries        int64   `json:"retries"`        // 重试次数
+	Rejected       int64   `json:"rejected"`       // 被熔断器拒绝的次数
+	TotalLatencyMs int64   `json:"totalLatencyMs"` // 累计耗时（毫秒，含重试和限流等待）
+	MaxLatencyMs   int64   `json:"maxLatencyMs"`   // 最大单次耗时（毫秒）
+	AvgLatencyMs   float64 `json:"avgLatencyMs"`   // 平均耗时（毫秒）
+	LastError      string  `json:"lastError,omitempty"`
+	LastCallAt     string  `json:"lastCallAt,omitempty"`
+}
+
+// AIMetrics AI调用统计快照
+type AIMetrics struct {
+	CircuitState string                        `json:"circuitState"` // 熔断器状态：closed、open、half-open
+	Operations   map[string]AIOperationMetrics `json:"operations"`   // 按操作名称分组的统计
+}
+
+// aiMetrics 线程安全的调用统计收集器
+type aiMetrics struct {
+	mu  sync.Mutex
+	ops map[string]*AIOperationMetrics
+}
+
+func newAIMetrics() *aiMetrics {
+	return &aiMetrics{ops: make(map[string]*AIOperationMetrics)}
+}
+
+func (m *aiMetrics) get(operation string) *AIOperationMetrics {
+	op, ok := m.ops[operation]
+	if !ok {
+		op = &AIOperationMetrics{}
+		m.ops[operation] = op
+	}
+	return op
+}
+
+func (m *aiMetrics) record(operation string, latency time.Duration, status int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	op := m.get(operation)
+	op.Calls++
+	ms := latency.Milliseconds()
+	op.TotalLatencyMs += ms
+	if ms > op.MaxLatencyMs {
+		op.MaxLatencyMs = ms
+	}
+	op.LastCallAt = time.Now().Format(time.RFC3339)
+	switch {
+	case err != nil:
+		op.Failures++
+		op.LastError = err.Error()
+	case status != http.StatusOK:
+		op.Failures++
+		op.LastError = fmt.Sprintf("HTTP %d", status)
+	default:
+		op.Successes++
+	}
+}
+
+func (m *aiMetrics) recordRetry(operation string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(operation).Retries++
+}
+
+func (m *aiMetrics) recordRejected(operation string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(operation).Rejected++
+}
+
+func (m *aiMetrics) snapshot() map[string]AIOperationMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[string]AIOperationMetrics, len(m.ops))
+	for name, op := range m.ops {
+		copied := *op
+		if copied.Calls > 0 {
+			copied.AvgLatencyMs = float64(copied.TotalLatencyMs) / float64(copied.Calls)
+		}
+		result[name] = copied
+	}
+	return result
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"image-manager/internal/config"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []string // fail、ok、release或wait（等待冷却结束）
+		want      string
+		allowErr  error
+	}{
+		{"closed below threshold", 3, []string{"fail", "fail"}, breakerClosed, nil},
+		{"opens at threshold", 3, []string{"fail", "fail", "fail"}, breakerOpen, ErrAICircuitOpen},
+		{"success resets failures", 3, []string{"fail", "fail", "ok", "fail", "fail"}, breakerClosed, nil},
+		{"half-open after cooldown", 2, []string{"fail", "fail", "wait"}, breakerHalfOpen, nil},
+		{"probe success closes", 2, []string{"fail", "fail", "wait", "allow", "ok"}, breakerClosed, nil},
+		{"probe failure reopens", 2, []string{"fail", "fail", "wait", "allow", "fail"}, breakerOpen, ErrAICircuitOpen},
+		{"only one probe at a time", 2, []string{"fail", "fail", "wait", "allow"}, breakerHalfOpen, ErrAICircuitOpen},
+		{"released probe can retry", 2, []string{"fail", "fail", "wait", "allow", "release"}, breakerHalfOpen, nil},
+		{"disabled never opens", 0, []string{"fail", "fail", "fail"}, breakerClosed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newCircuitBreaker(tt.threshold, time.Hour)
+			for _, step := range tt.steps {
+				switch step {
+				case "fail":
+					b.failure()
+				case "ok":
+					b.success()
+				case "release":
+					b.release()
+				case "allow":
+					if err := b.allow(); err != nil {
+						t.Fatalf("probe not allowed: %v", err)
+					}
+				case "wait":
+					b.openedAt = time.Now().Add(-2 * time.Hour)
+				}
+			}
+			if got := b.currentState(); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+			if err := b.allow(); !errors.Is(err, tt.allowErr) {
+				t.Errorf("allow() = %v, want %v", err, tt.allowErr)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		elapsed time.Duration // 取完突发令牌后经过的时间
+		want    bool          // 不等待能否再取到令牌
+	}{
+		{"empty after burst", 1, 3, 0, false},
+		{"refills at rate", 2, 3, 600 * time.Millisecond, true},
+		{"partial refill is not enough", 1, 3, 500 * time.Millisecond, false},
+		{"unlimited", 0, 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.rate, tt.burst)
+			for i := 0; i < tt.burst; i++ {
+				if err := b.wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b.last = b.last.Add(-tt.elapsed)
+
+			// 已取消的ctx让需要等待的wait立即返回
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if got := b.wait(ctx) == nil; got != tt.want {
+				t.Errorf("token available = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("capped at capacity", func(t *testing.T) {
+		b := newTokenBucket(100, 2)
+		b.last = b.last.Add(-time.Hour)
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if b.tokens != 1 {
+			t.Errorf("tokens = %v, want 1", b.tokens)
+		}
+	})
+}
+
+func TestAIClientBackoff(t *testing.T) {
+	c := &aiClient{cfg: config.Config{AIRetryBaseMillis: 100}}
+	tests := []struct {
+		name     string
+		attempt  int
+		last     aiResponse
+		min, max time.Duration
+	}{
+		{"first retry", 1, aiResponse{}, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"doubles", 3, aiResponse{}, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"capped", 20, aiResponse{}, 5 * time.Second, 10 * time.Second},
+		{"overflow capped", 80, aiResponse{}, 5 * time.Second, 10 * time.Second},
+		{"retry-after wins", 1, aiResponse{retryAfter: 3 * time.Second}, 3 * time.Second, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := c.backoff(tt.attempt, tt.last); got < tt.min || got > tt.max {
+				t.Errorf("%s: backoff = %v, want [%v, %v]", tt.name, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"5", 5 * time.Second},
+		{" 2 ", 2 * time.Second},
+		{"120", 30 * time.Second},
+		{"0", 0},
+		{"-1", 0},
+		{"", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAIClientRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int // 依次返回的状态码，用完后重复最后一个
+		retries   int
+		threshold int
+		wantCalls int32
+		wantCode  int
+		wantErr   error
+	}{
+		{"success", []int{200}, 2, 0, 1, 200, nil},
+		{"retries 5xx then succeeds", []int{503, 502, 200}, 2, 0, 3, 200, nil},
+		{"gives up after max retries", []int{500}, 2, 0, 3, 500, nil},
+		{"4xx is not retried", []int{400}, 2, 0, 1, 400, nil},
+		{"429 is retried", []int{429, 200}, 1, 0, 2, 200, nil},
+		{"breaker stops retries", []int{500}, 5, 2, 2, 0, ErrAICircuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&calls, 1)) - 1
+				if n >= len(tt.statuses) {
+					n = len(tt.statuses) - 1
+				}
+				w.WriteHeader(tt.statuses[n])
+			}))
+			defer server.Close()
+
+			c := newAIClient(config.Config{
+				AIApiURL:                 server.URL,
+				AITimeoutSeconds:         5,
+				AIMaxRetries:             tt.retries,
+				AIRetryBaseMillis:        1,
+				AIBreakerThreshold:       tt.threshold,
+				AIBreakerCooldownSeconds: 60,
+			})
+			_, status, err := c.do(context.Background(), "test", []byte("{}"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if status != tt.wantCode {
+				t.Errorf("status = %d, want %d", status, tt.wantCode)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			metrics := c.metrics.snapshot()["test"]
+			if metrics.Calls != 1 || metrics.Retries != int64(tt.wantCalls-1)+metrics.Rejected {
+				t.Errorf("metrics = %+v", metrics)
+			}
+		})
+	}
+
+	t.Run("cancel stops waiting", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		c := newAIClient(config.Config{AIApiURL: server.URL, AITimeoutSeconds: 5, AIMaxRetries: 3, AIRetryBaseMillis: 60000})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, _, err := c.do(ctx, "test", []byte("{}")); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
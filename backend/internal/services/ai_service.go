@@ -4,14 +4,16 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,7 +23,9 @@ import (
 // AIService AI服务结构体
 // 提供AI相关的功能，包括图片分析和自然语言查询转换
 type AIService struct {
-	cfg config.Config  // 应用配置信息，包含AI API密钥、URL等
+	cfg        config.Config     // 应用配置信息，包含AI API密钥、URL等
+	client     *aiClient         // 共享的AI API客户端，负责重试、限流和熔断
+	queryCache *queryFilterCache // 查询转换结果缓存
 }
 
 // NewAIService 创建AI服务实例
@@ -30,7 +34,20 @@ type AIService struct {
 // 返回: AIService指针
 func NewAIService(cfg config.Config) *AIService {
 	return &AIService{
-		cfg: cfg,
+		cfg:    cfg,
+		client: newAIClient(cfg),
+		queryCache: newQueryFilterCache(
+			time.Duration(cfg.AIQueryCacheTTLSeconds)*time.Second,
+			cfg.AIQueryCacheSize,
+		),
+	}
+}
+
+// Metrics 返回AI调用统计快照，包括各操作的调用次数、耗时、失败次数和熔断器状态
+func (s *AIService) Metrics() AIMetrics {
+	return AIMetrics{
+		CircuitState: s.client.breaker.currentState(),
+		Operations:   s.client.metrics.snapshot(),
 	}
 }
 
@@ -79,11 +96,12 @@ type AnalyzeImageResponse struct {
 // AnalyzeImage 分析图片并生成标签
 // 调用智谱AI GLM-4 Vision模型分析图片内容，返回标签列表（如风景、人物、动物等）
 // 参数:
+//   - ctx: 请求上下文，上传请求被取消时AI调用随之取消
 //   - imageData: 图片的二进制数据
 //   - mimeType: 图片的MIME类型（如image/jpeg）
 //   - existingTags: 标签库中已有的标签列表，AI会优先从中选择
 // 返回: 标签名称列表和错误信息
-func (s *AIService) AnalyzeImage(imageData []byte, mimeType string, existingTags []string) ([]string, error) {
+func (s *AIService) AnalyzeImage(ctx context.Context, imageData []byte, mimeType string, existingTags []string) ([]string, error) {
 	// 如果AI功能未启用或API密钥为空，返回空标签列表
 	if !s.cfg.AIEnabled {
 		log.Printf("AI功能未启用，跳过图片分析")
@@ -173,39 +191,18 @@ func (s *AIService) AnalyzeImage(imageData []byte, mimeType string, existingTags
 		s.cfg.AIModel, len(content), reqBody.MaxTokens)
 	log.Printf("AI API请求体结构预览: %s", reqPreview)
 
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", s.cfg.AIApiURL, bytes.NewBuffer(jsonData))
+	// 通过共享客户端发送请求（带重试、限流和熔断）
+	respBody, statusCode, err := s.client.do(ctx, "analyze_image", jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.cfg.AIApiKey))
-	log.Printf("AI API请求头已设置，Content-Type: application/json")
-
-	// 创建HTTP客户端并发送请求（设置30秒超时）
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求AI API失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应体
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		return nil, err
 	}
 
 	// 打印完整的原始响应内容（包括所有字段）
-	log.Printf("AI API完整响应内容（状态码: %d）: %s", resp.StatusCode, string(respBody))
+	log.Printf("AI API完整响应内容（状态码: %d）: %s", statusCode, string(respBody))
 
 	// 检查HTTP状态码
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("AI API返回错误状态码 %d: %s", resp.StatusCode, string(respBody))
+	if statusCode != http.StatusOK {
+		log.Printf("AI API返回错误状态码 %d: %s", statusCode, string(respBody))
 		return []string{}, nil  // 如果API调用失败，返回空标签列表，不影响上传流程
 	}
 	
@@ -333,16 +330,235 @@ func (s *AIService) AnalyzeImage(imageData []byte, mimeType string, existingTags
 	return tags, nil
 }
 
+// aiNoText 提示词中约定的"图片中没有文字"的回答
+const aiNoText = "[无文字]"
+
+// ExtractText 使用视觉模型识别图片中的文字（OCR）
+// 与AnalyzeImage不同，失败时返回错误而不是空结果，由调用方决定是否保留之前的识别结果
+// 参数:
+//   - ctx: 请求上下文，用于超时控制
+//   - imageData: 图片的二进制数据
+//   - mimeType: 图片的MIME类型（如image/jpeg）
+// 返回: 按行拼接的文字，没有文字时返回空字符串
+func (s *AIService) ExtractText(ctx context.Context, imageData []byte, mimeType string) (string, error) {
+	if !s.cfg.AIEnabled || s.cfg.AIApiKey == "" {
+		return "", errors.New("AI功能未启用")
+	}
+
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(imageData))
+	prompt := `请识别这张图片中的所有文字，按图片中的阅读顺序逐行原样输出。
+
+输出要求：
+1. 只输出识别出的文字，不要任何说明、前缀、翻译或总结
+2. 保持原文的语言，每行对应图片中的一行文字
+3. 如果图片中没有任何文字，只输出：` + aiNoText
+
+	reqBody := AnalyzeImageRequest{
+		Model: s.cfg.AIModel,
+		Messages: []Message{
+			{
+				Role: "user",
+				Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": prompt},
+					map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": dataURL}},
+				},
+			},
+		},
+		MaxTokens: 2000,
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	respBody, statusCode, err := s.client.do(ctx, "extract_text", jsonData)
+	if err != nil {
+		return "", err
+	}
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("AI API返回错误状态码 %d", statusCode)
+	}
+
+	var aiResp AnalyzeImageResponse
+	if err := json.Unmarshal(respBody, &aiResp); err != nil {
+		return "", fmt.Errorf("解析AI API响应失败: %v", err)
+	}
+	if aiResp.Error != nil {
+		return "", fmt.Errorf("AI API返回错误: %s", aiResp.Error.Message)
+	}
+	if len(aiResp.Choices) == 0 {
+		return "", errors.New("AI API响应中没有内容")
+	}
+
+	// 移除GLM-4v可能附带的<|...|>特殊标记
+	text := regexp.MustCompile(`<\|[^|]*\|>`).ReplaceAllString(aiResp.Choices[0].Message.Content, "")
+	text = strings.TrimSpace(text)
+	if text == aiNoText || text == strings.Trim(aiNoText, "[]") {
+		return "", nil
+	}
+	return text, nil
+}
+
 // ConvertQueryToFilters 将自然语言查询转换为图片搜索过滤器
 // 使用智谱AI GLM-4模型将用户的自然语言描述转换为结构化的搜索条件
+// 转换结果按（工作区、规范化查询、标签库）缓存，同一查询翻页时直接命中缓存；降级结果不缓存
 // 参数:
+//   - ctx: 请求上下文，搜索请求被取消时AI调用随之取消
+//   - workspaceID: 查询所在的工作区ID，用于缓存隔离和失效
 //   - query: 自然语言查询（如"找一些风景照片"、"显示上个月拍的猫的照片"）
 //   - existingTags: 标签库中已有的标签列表，AI会优先从中选择标签
-// 返回: 过滤器映射（包含keyword、tags、start_date等）和错误信息
-func (s *AIService) ConvertQueryToFilters(query string, existingTags []string) (map[string]string, error) {
+// 返回: 过滤器映射（包含keyword、tags、start_date等）、转换诊断信息和错误信息
+func (s *AIService) ConvertQueryToFilters(ctx context.Context, workspaceID uint, query string, existingTags []string) (map[string]string, FilterDiagnostics, error) {
+	key := queryCacheKey(workspaceID, query, existingTags)
+	if filters, diag, ok := s.queryCache.get(key); ok {
+		log.Printf("查询转换命中缓存: %s", query)
+		diag.Source = FilterSourceCache
+		return filters, diag, nil
+	}
+
+	filters, diag, err := s.requestQueryFilters(ctx, query, existingTags)
+	if err != nil {
+		return nil, diag, err
+	}
+	if diag.AIUsed {
+		s.queryCache.set(key, workspaceID, filters, diag)
+	}
+	return filters, diag, nil
+}
+
+// 过滤条件来源
+const (
+	FilterSourceAI       = "ai"       // 本次调用AI转换得到
+	FilterSourceCache    = "cache"    // 命中查询转换缓存（缓存的是此前的AI转换结果）
+	FilterSourceFallback = "fallback" // AI不可用或输出无效，降级为关键词搜索
+	FilterSourceSession  = "session"  // 翻页时复用会话上一轮的过滤条件
+)
+
+// 降级原因
+const (
+	FallbackAIDisabled      = "ai_disabled"      // 未启用AI或未配置API密钥
+	FallbackCircuitOpen     = "circuit_open"     // 熔断器打开，暂停调用AI
+	FallbackHTTPError       = "http_error"       // AI API返回非200状态码
+	FallbackAPIError        = "api_error"        // AI API在响应中返回了错误
+	FallbackInvalidResponse = "invalid_response" // 无法解析AI API的响应结构
+	FallbackEmptyResponse   = "empty_response"   // 响应中没有任何候选结果
+	FallbackInvalidJSON     = "invalid_json"     // 模型输出中没有可解析的JSON对象
+)
+
+// FilterDiagnostics 查询转换的诊断信息，说明返回的过滤条件是怎样得到的
+type FilterDiagnostics struct {
+	Source         string          `json:"source"`                   // 过滤条件来源，见FilterSource*常量
+	AIUsed         bool            `json:"aiUsed"`                   // 过滤条件是否来自AI的转换结果（包括缓存）
+	FallbackReason string          `json:"fallbackReason,omitempty"` // 降级原因，见Fallback*常量
+	FallbackDetail string          `json:"fallbackDetail,omitempty"` // 降级的具体说明（状态码、解析错误等）
+	RawOutput      string          `json:"rawOutput,omitempty"`      // 模型返回的原始文本
+	DroppedKeys    []DroppedFilter `json:"droppedKeys,omitempty"`    // 模型输出中被丢弃的字段
+}
+
+// DroppedFilter 被丢弃的过滤器字段
+type DroppedFilter struct {
+	Key    string `json:"key"`    // 字段名
+	Value  string `json:"value"`  // 模型给出的原始值
+	Reason string `json:"reason"` // 丢弃原因
+}
+
+// fallbackDiagnostics 构建降级诊断信息
+func fallbackDiagnostics(reason, detail, rawOutput string) FilterDiagnostics {
+	return FilterDiagnostics{
+		Source:         FilterSourceFallback,
+		FallbackReason: reason,
+		FallbackDetail: detail,
+		RawOutput:      rawOutput,
+	}
+}
+
+// InvalidateQueryCache 清除指定工作区的查询转换缓存
+// 在工作区标签库发生变化时调用（注册为TagService的变更回调）
+func (s *AIService) InvalidateQueryCache(workspaceID uint) {
+	s.queryCache.invalidateWorkspace(workspaceID)
+}
+
+// ConversationTurn 对话历史中的一条消息
+type ConversationTurn struct {
+	Role    string // user 或 assistant
+	Content string
+}
+
+// RefineQueryFilters 在多轮对话中细化检索条件
+// 将用户的追问（如"只要去年夏天的"、"去掉模糊的"）与上一轮的过滤条件、对话历史一起交给AI，得到修改后的完整过滤条件
+// 除标准过滤器字段外，结果还可能包含：
+//   - scope: "previous" 表示在上一轮结果中继续筛选，"all" 表示在全部图片中重新检索
+//   - exclude_tags: 需要排除的标签（逗号分隔）
+// 参数:
+//   - ctx: 请求上下文
+//   - query: 用户本轮的追问
+//   - previous: 上一轮的过滤条件
+//   - history: 最近的对话历史
+//   - existingTags: 标签库中已有的标签列表
+// 返回: 过滤器映射、转换诊断信息（AIUsed为false时调用方应降级）和错误信息
+func (s *AIService) RefineQueryFilters(ctx context.Context, query string, previous map[string]string, history []ConversationTurn, existingTags []string) (map[string]string, FilterDiagnostics, error) {
+	if !s.cfg.AIEnabled || s.cfg.AIApiKey == "" {
+		return nil, fallbackDiagnostics(FallbackAIDisabled, "", ""), nil
+	}
+
+	previousJSON, err := json.Marshal(previous)
+	if err != nil {
+		return nil, FilterDiagnostics{}, fmt.Errorf("序列化上一轮过滤条件失败: %v", err)
+	}
+
+	historyLines := []string{}
+	for _, turn := range history {
+		speaker := "用户"
+		if turn.Role == "assistant" {
+			speaker = "助手"
+		}
+		historyLines = append(historyLines, fmt.Sprintf("%s：%s", speaker, turn.Content))
+	}
+	if len(historyLines) == 0 {
+		historyLines = append(historyLines, "（无）")
+	}
+
+	prompt := fmt.Sprintf(`**你必须只返回一个有效的JSON对象，不要有任何说明文字、解释或示例。直接输出JSON，不要任何其他内容。**
+
+这是一次多轮对话式图片检索。用户之前已经进行过检索，现在提出了新的要求，请在上一轮检索条件的基础上修改，输出修改后的**完整**检索条件。
+
+之前的对话：
+%s
+
+上一轮检索条件：%s
+
+用户的新要求：%s
+
+转换规则：
+1. 在上一轮检索条件的基础上修改：用户没有要求改变的条件必须原样保留；用户要求去掉的条件要删除；用户追加的条件要加入。
+2. 如果新要求是在上一轮结果中进一步筛选（如"只要其中去年夏天的"、"去掉模糊的"），输出"scope": "previous"；如果用户开始了一个全新的话题，输出"scope": "all"，并且不要保留上一轮的条件。
+3. 如果用户要求排除某类图片（如"去掉模糊的"），把对应的标签放入exclude_tags字段（逗号分隔，必须是标签库中存在的标签）。
+4. 标签必须从已有的标签库中选择，如果查询中的标签不在标签库中，请忽略或使用相近的标签。
+5. **严格要求**：只生成用户明确提到的条件，不要自行推断或添加额外的筛选条件。
+
+除下面列出的字段外，本次还允许使用以下两个字段：
+- scope: 检索范围（字符串，"previous"或"all"）
+- exclude_tags: 要排除的标签（字符串，多个标签用逗号分隔）
+
+已有标签库：`, strings.Join(historyLines, "\n"), string(previousJSON), query)
+
+	if len(existingTags) > 0 {
+		prompt += "\n" + strings.Join(existingTags, "、")
+		prompt += "\n\n请优先从上述标签库中选择标签。"
+	} else {
+		prompt += "\n（暂无已有标签）"
+	}
+	prompt += filterFieldsPrompt
+
+	return s.completeFilterQuery(ctx, "refine_query", prompt, "scope", "exclude_tags")
+}
+
+// requestQueryFilters 调用AI完成查询转换
+// 返回: 过滤器映射、转换诊断信息（AIUsed为false表示已降级为关键词搜索）和错误信息
+func (s *AIService) requestQueryFilters(ctx context.Context, query string, existingTags []string) (map[string]string, FilterDiagnostics, error) {
 	// 如果AI功能未启用或API密钥为空，返回空过滤器
 	if !s.cfg.AIEnabled || s.cfg.AIApiKey == "" {
-		return map[string]string{"keyword": query}, nil  // 降级为关键词搜索
+		return map[string]string{"keyword": query}, fallbackDiagnostics(FallbackAIDisabled, "", ""), nil  // 降级为关键词搜索
 	}
 
 	// 构建提示词，要求AI将自然语言转换为JSON格式的过滤器
@@ -369,31 +585,26 @@ func (s *AIService) ConvertQueryToFilters(query string, existingTags []string) (
 		prompt += "\n（暂无已有标签）"
 	}
 	
-	prompt += `
-
-请返回一个JSON对象，**只能包含以下字段**（只包含用户明确提到的条件，不要添加任何其他字段如background、feature等）：
-- keyword: 关键词（字符串，用于搜索文件名。只有用户明确提到文件名、文件关键词时才生成。注意：即使生成了keyword，也应该尽量同时生成tags）
-- tags: 标签（字符串，多个标签用逗号分隔，如"风景,山"。这些标签会被用于OR查询，且必须是标签库中存在的标签。**优先生成标签**：除非用户明确说"只搜索文件名"，否则应该尽量从查询中提取标签。可以从查询的主题、内容、类型等方面提取相关标签，如果标签库中有多个相关标签可以都生成）
-- start_date: 开始日期（字符串，格式：YYYY-MM-DD，例如"2024-06-15"。只有用户明确提到创建时间、上传时间范围时才生成，必须根据用户查询中的实际日期生成，不要使用固定的默认日期）
-- end_date: 结束日期（字符串，格式：YYYY-MM-DD，例如"2024-12-31"。只有用户明确提到创建时间、上传时间范围时才生成，必须根据用户查询中的实际日期生成，不要使用固定的默认日期）
-- taken_start: 拍摄开始时间（字符串，格式：YYYY-MM-DD HH:MM，例如"2024-06-15 08:00"。只有用户明确提到拍摄时间、拍照时间范围时才生成，必须根据用户查询中的实际时间生成，不要使用固定的默认时间）
-- taken_end: 拍摄结束时间（字符串，格式：YYYY-MM-DD HH:MM，例如"2024-12-31 23:59"。只有用户明确提到拍摄时间、拍照时间范围时才生成，必须根据用户查询中的实际时间生成，不要使用固定的默认时间）
-- width_min: 最小宽度（整数，像素。只有用户明确提到宽度、分辨率、尺寸时才生成）
-- width_max: 最大宽度（整数，像素。只有用户明确提到宽度、分辨率、尺寸时才生成）
-- height_min: 最小高度（整数，像素。只有用户明确提到高度、分辨率、尺寸时才生成）
-- height_max: 最大高度（整数，像素。只有用户明确提到高度、分辨率、尺寸时才生成）
-- size_min: 最小文件大小（数字，单位：MB，可以是小数，如1.5表示1.5MB。只有用户明确提到文件大小、文件体积时才生成）
-- size_max: 最大文件大小（数字，单位：MB，可以是小数，如2.5表示2.5MB。只有用户明确提到文件大小、文件体积时才生成）
-
-**输出格式要求（必须严格遵守）**：
-1. **只输出JSON对象，不要有任何其他文字**（不要说明、不要解释、不要示例）
-2. 只能返回上述字段，绝对不要添加任何其他字段（如background、feature、description等）
-3. **关键**：对于文件大小，日期，宽度，高度等字段，除非用户明确提到这个字段相关的词，否则不要自行推断或添加条件！如果用户没有提到日期时间，绝对不要生成start_date、end_date、taken_start、taken_end字段！
-4. **重要**：日期字段中的示例（如"2024-06-15"）只是格式说明，不要使用这些示例值。必须根据用户查询中提到的实际日期来生成，如果用户没有提到日期，就不要生成这些字段！
-5. 直接输出JSON，格式如：{"tags": "风景"} 或 {"keyword": "test", "tags": "test"}
+	prompt += filterFieldsPrompt
 
-**重要**：你的响应必须是一个有效的JSON对象，从第一个{开始，到最后一个}结束，中间不要有任何其他文字。`
+	filters, diag, err := s.completeFilterQuery(ctx, "convert_query", prompt)
+	if err != nil {
+		return nil, diag, err
+	}
+	if !diag.AIUsed {
+		return map[string]string{"keyword": query}, diag, nil  // 降级为关键词搜索
+	}
+	return filters, diag, nil
+}
 
+// completeFilterQuery 发送过滤器转换提示词并解析AI返回的JSON过滤器
+// 参数:
+//   - ctx: 请求上下文
+//   - operation: 操作名称，用于调用统计
+//   - prompt: 完整的用户提示词
+//   - extraFields: 除标准过滤器字段外额外允许的字段
+// 返回: 过滤器映射、转换诊断信息（AIUsed为false表示调用方应降级）和错误信息（仅网络等不可降级的错误）
+func (s *AIService) completeFilterQuery(ctx context.Context, operation, prompt string, extraFields ...string) (map[string]string, FilterDiagnostics, error) {
 	// 构建API请求
 	// 使用system message明确要求只返回JSON
 	systemPrompt := "你是一个JSON转换工具。你只能返回有效的JSON对象，不要有任何说明文字、解释或示例。直接输出JSON，从{开始，到}结束。"
@@ -425,49 +636,34 @@ func (s *AIService) ConvertQueryToFilters(query string, existingTags []string) (
 	// 序列化请求
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %v", err)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", s.cfg.AIApiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+		return nil, FilterDiagnostics{}, fmt.Errorf("序列化请求失败: %v", err)
 	}
 
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.cfg.AIApiKey))
-
-	// 发送请求
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求AI API失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
+	// 通过共享客户端发送请求（带重试、限流和熔断）
+	respBody, statusCode, err := s.client.do(ctx, operation, jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		// 熔断期间直接降级，避免所有搜索请求都失败
+		if errors.Is(err, ErrAICircuitOpen) {
+			log.Printf("AI熔断中，降级处理: %s", operation)
+			return nil, fallbackDiagnostics(FallbackCircuitOpen, err.Error(), ""), nil
+		}
+		return nil, FilterDiagnostics{}, err
 	}
 
 	// 打印完整的原始响应内容（包括所有字段）
-	log.Printf("AI API完整响应内容（状态码: %d）: %s", resp.StatusCode, string(respBody))
+	log.Printf("AI API完整响应内容（状态码: %d）: %s", statusCode, string(respBody))
 
 	// 检查HTTP状态码
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("AI API返回错误状态码 %d: %s", resp.StatusCode, string(respBody))
-		return map[string]string{"keyword": query}, nil  // 降级为关键词搜索
+	if statusCode != http.StatusOK {
+		log.Printf("AI API返回错误状态码 %d: %s", statusCode, string(respBody))
+		return nil, fallbackDiagnostics(FallbackHTTPError, fmt.Sprintf("状态码 %d", statusCode), string(respBody)), nil
 	}
 
 	// 解析响应
 	var aiResp AnalyzeImageResponse
 	if err := json.Unmarshal(respBody, &aiResp); err != nil {
 		log.Printf("解析AI API响应失败: %v, 原始响应: %s", err, string(respBody))
-		return map[string]string{"keyword": query}, nil
+		return nil, fallbackDiagnostics(FallbackInvalidResponse, err.Error(), string(respBody)), nil
 	}
 
 	// 打印解析后的完整响应结构（用于调试）
@@ -476,13 +672,13 @@ func (s *AIService) ConvertQueryToFilters(query string, existingTags []string) (
 	// 检查错误
 	if aiResp.Error != nil {
 		log.Printf("AI API返回错误字段: %+v, 完整响应: %+v", aiResp.Error, aiResp)
-		return map[string]string{"keyword": query}, nil
+		return nil, fallbackDiagnostics(FallbackAPIError, fmt.Sprintf("%+v", aiResp.Error), string(respBody)), nil
 	}
 
 	// 提取响应内容
 	if len(aiResp.Choices) == 0 {
 		log.Printf("AI API响应中没有Choices字段，完整响应: %+v", aiResp)
-		return map[string]string{"keyword": query}, nil
+		return nil, fallbackDiagnostics(FallbackEmptyResponse, "", string(respBody)), nil
 	}
 
 	contentStr := aiResp.Choices[0].Message.Content
@@ -554,8 +750,8 @@ func (s *AIService) ConvertQueryToFilters(query string, existingTags []string) (
 		} else {
 			log.Printf("解析AI返回的JSON失败: %v, 提取的JSON字符串: %s, 原始内容: %s", err, jsonStr, contentStr)
 		}
-		// 如果JSON解析失败，降级为关键词搜索
-		return map[string]string{"keyword": query}, nil
+		// 如果JSON解析失败，由调用方降级
+		return nil, fallbackDiagnostics(FallbackInvalidJSON, err.Error(), contentStr), nil
 	}
 
 	// 定义允许的过滤器字段列表（只允许这些字段）
@@ -572,43 +768,116 @@ func (s *AIService) ConvertQueryToFilters(query string, existingTags []string) (
 		"height_max":  true, // 最大高度
 		"size_min":    true, // 最小文件大小
 		"size_max":    true, // 最大文件大小
+		"color":       true, // 主色调
+		"quality":     true, // 画质
 	}
+	for _, field := range extraFields {
+		allowedFields[field] = true
+	}
+
+	diag := FilterDiagnostics{Source: FilterSourceAI, AIUsed: true, RawOutput: contentStr}
 
 	// 将interface{}类型的值转换为string类型，并过滤掉不在允许列表中的字段
+	// 被丢弃的字段记录到诊断信息中，方便调用方了解模型输出了哪些无法使用的条件
 	filters := make(map[string]string)
 	for k, v := range rawFilters {
-		// 跳过不在允许列表中的字段（如background、feature等）
-		if !allowedFields[k] {
-			continue
-		}
-		
-		// 跳过空值
-		if v == nil {
-			continue
-		}
-		
 		var strValue string
 		switch val := v.(type) {
+		case nil:
+			strValue = ""
 		case string:
-			strValue = val
+			strValue = strings.TrimSpace(val)
 		case float64:
-			// JSON数字会被解析为float64，转换为整数字符串
-			strValue = fmt.Sprintf("%.0f", val)
-		case int:
-			strValue = fmt.Sprintf("%d", val)
-		case int64:
-			strValue = fmt.Sprintf("%d", val)
+			// JSON数字会被解析为float64，保留小数（文件大小可以是1.5MB）
+			strValue = strconv.FormatFloat(val, 'f', -1, 64)
 		default:
 			// 其他类型也尝试转换为字符串
 			strValue = fmt.Sprintf("%v", val)
 		}
-		
-		// 只有非空字符串才添加到filters中
-		if strValue != "" {
-			filters[k] = strValue
+
+		// 跳过不在允许列表中的字段（如background、feature等）
+		if !allowedFields[k] {
+			diag.DroppedKeys = append(diag.DroppedKeys, DroppedFilter{Key: k, Value: strValue, Reason: "不支持的字段"})
+			continue
+		}
+		// 跳过空值
+		if strValue == "" {
+			diag.DroppedKeys = append(diag.DroppedKeys, DroppedFilter{Key: k, Value: strValue, Reason: "空值"})
+			continue
+		}
+		if reason := validateFilterValue(k, strValue); reason != "" {
+			diag.DroppedKeys = append(diag.DroppedKeys, DroppedFilter{Key: k, Value: strValue, Reason: reason})
+			continue
 		}
+		filters[k] = strValue
 	}
+	// 按字段名排序，保证诊断输出稳定
+	sort.Slice(diag.DroppedKeys, func(i, j int) bool { return diag.DroppedKeys[i].Key < diag.DroppedKeys[j].Key })
 
-	return filters, nil
+	return filters, diag, nil
+}
+
+// validateFilterValue 校验过滤器字段值的格式，返回空字符串表示有效，否则返回无效原因
+func validateFilterValue(key, value string) string {
+	switch key {
+	case "start_date", "end_date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "日期格式无效，应为YYYY-MM-DD"
+		}
+	case "taken_start", "taken_end":
+		if _, err := time.Parse("2006-01-02 15:04", value); err != nil {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return "时间格式无效，应为YYYY-MM-DD HH:MM"
+			}
+		}
+	case "width_min", "width_max", "height_min", "height_max":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return "应为非负整数"
+		}
+	case "size_min", "size_max":
+		if n, err := strconv.ParseFloat(value, 64); err != nil || n <= 0 {
+			return "应为正数（单位MB）"
+		}
+	case "color":
+		if !isKnownColor(value) {
+			return "无法识别的颜色"
+		}
+	case "quality":
+		if !isKnownQuality(value) {
+			return "无法识别的画质条件"
+		}
+	case "scope":
+		if value != "previous" && value != "all" {
+			return "应为previous或all"
+		}
+	}
+	return ""
 }
 
+// filterFieldsPrompt 过滤器字段说明及输出格式要求，单次查询转换和多轮对话细化共用
+const filterFieldsPrompt = `
+
+请返回一个JSON对象，**只能包含以下字段**（只包含用户明确提到的条件，不要添加任何其他字段如background、feature等）：
+- keyword: 关键词（字符串，用于搜索文件名和图片中识别出的文字。只有用户明确提到文件名、文件关键词，或截图、文档中包含的文字时才生成。注意：即使生成了keyword，也应该尽量同时生成tags）
+- tags: 标签（字符串，多个标签用逗号分隔，如"风景,山"。这些标签会被用于OR查询，且必须是标签库中存在的标签。**优先生成标签**：除非用户明确说"只搜索文件名"，否则应该尽量从查询中提取标签。可以从查询的主题、内容、类型等方面提取相关标签，如果标签库中有多个相关标签可以都生成）
+- start_date: 开始日期（字符串，格式：YYYY-MM-DD，例如"2024-06-15"。只有用户明确提到创建时间、上传时间范围时才生成，必须根据用户查询中的实际日期生成，不要使用固定的默认日期）
+- end_date: 结束日期（字符串，格式：YYYY-MM-DD，例如"2024-12-31"。只有用户明确提到创建时间、上传时间范围时才生成，必须根据用户查询中的实际日期生成，不要使用固定的默认日期）
+- taken_start: 拍摄开始时间（字符串，格式：YYYY-MM-DD HH:MM，例如"2024-06-15 08:00"。只有用户明确提到拍摄时间、拍照时间范围时才生成，必须根据用户查询中的实际时间生成，不要使用固定的默认时间）
+- taken_end: 拍摄结束时间（字符串，格式：YYYY-MM-DD HH:MM，例如"2024-12-31 23:59"。只有用户明确提到拍摄时间、拍照时间范围时才生成，必须根据用户查询中的实际时间生成，不要使用固定的默认时间）
+- width_min: 最小宽度（整数，像素。只有用户明确提到宽度、分辨率、尺寸时才生成）
+- width_max: 最大宽度（整数，像素。只有用户明确提到宽度、分辨率、尺寸时才生成）
+- height_min: 最小高度（整数，像素。只有用户明确提到高度、分辨率、尺寸时才生成）
+- height_max: 最大高度（整数，像素。只有用户明确提到高度、分辨率、尺寸时才生成）
+- size_min: 最小文件大小（数字，单位：MB，可以是小数，如1.5表示1.5MB。只有用户明确提到文件大小、文件体积时才生成）
+- size_max: 最大文件大小（数字，单位：MB，可以是小数，如2.5表示2.5MB。只有用户明确提到文件大小、文件体积时才生成）
+- color: 主色调（字符串。只有用户明确提到图片的颜色、色调时才生成。可以是十六进制颜色如"#3366CC"，或颜色名称red、orange、yellow、green、cyan、blue、purple、pink、brown、black、gray、white之一；如果用户要求图片"大部分是"、"以某色为主"，在颜色前加"mostly "，如"mostly blue"）
+- quality: 画质（字符串，多个用逗号分隔表示同时满足。只有用户明确提到模糊、清晰、曝光、过暗、过亮、噪点时才生成。取值只能是blurry（模糊）、sharp（清晰）、underexposed（欠曝/过暗）、overexposed（过曝/过亮）、well_exposed（曝光正常）、noisy（噪点多）、clean（噪点少）之一）
+
+**输出格式要求（必须严格遵守）**：
+1. **只输出JSON对象，不要有任何其他文字**（不要说明、不要解释、不要示例）
+2. 只能返回上述字段，绝对不要添加任何其他字段（如background、feature、description等）
+3. **关键**：对于文件大小，日期，宽度，高度等字段，除非用户明确提到这个字段相关的词，否则不要自行推断或添加条件！如果用户没有提到日期时间，绝对不要生成start_date、end_date、taken_start、taken_end字段！
+4. **重要**：日期字段中的示例（如"2024-06-15"）只是格式说明，不要使用这些示例值。必须根据用户查询中提到的实际日期来生成，如果用户没有提到日期，就不要生成这些字段！
+5. 直接输出JSON，格式如：{"tags": "风景"} 或 {"keyword": "test", "tags": "test"}
+
+**重要**：你的响应必须是一个有效的JSON对象，从第一个{开始，到最后一个}结束，中间不要有任何其他文字。`
@@ -0,0 +1,533 @@
+// Package services 提供业务逻辑层的服务实现
+// album_service.go 实现了相册管理：相册保存在服务端，图片在相册中有序排列，
+// 可以设置封面、描述和每张图片的说明；相册内的图片列表通过ImageService.List的album筛选条件获取
+// 智能相册保存一组筛选条件，每次查看时通过ImageService.List实时计算，只缓存数量和封面
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// smartAlbumCacheTTL 智能相册缓存的数量和封面的有效期，过期后在相册列表中重新计算
+const smartAlbumCacheTTL = 10 * time.Minute
+
+// AlbumService 相册服务结构体
+type AlbumService struct {
+	db     *gorm.DB
+	images *ImageService // 计算相册中的图片列表
+}
+
+// NewAlbumService 创建相册服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - images: 图片服务，相册中的图片和智能相册的筛选结果都通过它的List获取
+//
+// 返回: AlbumService指针
+func NewAlbumService(db *gorm.DB, images *ImageService) *AlbumService {
+	return &AlbumService{db: db, images: images}
+}
+
+// List 获取工作区的相册列表（不含图片），按更新时间倒序，附带图片数量和封面
+// 智能相册使用缓存的数量和封面，缓存过期时重新计算
+func (s *AlbumService) List(m Member) ([]models.Album, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var albums []models.Album
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).Order("updated_at DESC").Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	if len(albums) == 0 {
+		return albums, nil
+	}
+
+	ids := make([]uint, len(albums))
+	for i := range albums {
+		ids[i] = albums[i].ID
+	}
+	// 每个相册的图片数量和第一张图片（没有指定封面时作为封面）
+	var stats []struct {
+		AlbumID uint
+		Count   int
+		FirstID uint
+	}
+	if err := s.db.Model(&models.AlbumItem{}).
+		Select("album_id, COUNT(*) AS count, (SELECT ai.image_id FROM album_items AS ai WHERE ai.album_id = album_items.album_id ORDER BY ai.position, ai.id LIMIT 1) AS first_id").
+		Where("album_id IN ?", ids).
+		Group("album_id").
+		Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	byAlbum := make(map[uint]int, len(stats))
+	for i, stat := range stats {
+		byAlbum[stat.AlbumID] = i
+	}
+	for i := range albums {
+		if albums[i].Kind == models.AlbumSmart {
+			if albums[i].CachedAt == nil || time.Since(*albums[i].CachedAt) > smartAlbumCacheTTL {
+				if err := s.refresh(&albums[i]); err != nil {
+					log.Printf("failed to refresh smart album %d: %v", albums[i].ID, err)
+				}
+			}
+			setSmartStats(&albums[i])
+			continue
+		}
+		if j, ok := byAlbum[albums[i].ID]; ok {
+			albums[i].ImageCount = stats[j].Count
+			albums[i].CoverID = stats[j].FirstID
+		}
+		if albums[i].CoverImageID != nil {
+			albums[i].CoverID = *albums[i].CoverImageID
+		}
+	}
+	return albums, nil
+}
+
+// Get 获取工作区的相册，包含按顺序排列的图片ID和说明
+func (s *AlbumService) Get(m Member, id uint) (*models.Album, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var album models.Album
+	if err := s.db.Where("id = ? AND workspace_id = ?", id, m.WorkspaceID).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		First(&album).Error; err != nil {
+		return nil, errors.New("相册不存在")
+	}
+	if album.Kind == models.AlbumSmart {
+		setSmartStats(&album)
+		return &album, nil
+	}
+	album.ImageCount = len(album.Items)
+	if album.CoverImageID != nil {
+		album.CoverID = *album.CoverImageID
+	} else if len(album.Items) > 0 {
+		album.CoverID = album.Items[0].ImageID
+	}
+	return &album, nil
+}
+
+// Create 创建空相册；提供了筛选条件时创建智能相册，并立即计算一次数量和封面以校验条件
+func (s *AlbumService) Create(m Member, req dto.AlbumRequest) (*models.Album, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("相册名称不能为空")
+	}
+	if req.CoverImageID != nil {
+		return nil, errors.New("新相册不能设置封面")
+	}
+	album := models.Album{UserID: m.UserID, WorkspaceID: m.WorkspaceID, Name: name, Description: strings.TrimSpace(req.Description), Kind: models.AlbumManual}
+	if len(req.Filters) > 0 || strings.TrimSpace(req.Query) != "" {
+		filters, err := smartFilters(req)
+		if err != nil {
+			return nil, err
+		}
+		album.Kind = models.AlbumSmart
+		album.Filters = filters
+		if err := s.evaluate(&album); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.db.Create(&album).Error; err != nil {
+		return nil, err
+	}
+	setSmartStats(&album)
+	return &album, nil
+}
+
+// Update 修改相册的名称、描述和封面，封面必须是相册中的图片
+func (s *AlbumService) Update(m Member, id uint, req dto.AlbumRequest) (*models.Album, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	album, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("相册名称不能为空")
+	}
+	updates := map[string]interface{}{
+		"name":        name,
+		"description": strings.TrimSpace(req.Description),
+	}
+	hasFilters := len(req.Filters) > 0 || strings.TrimSpace(req.Query) != ""
+	if album.Kind == models.AlbumSmart {
+		// 智能相册的封面总是第一张匹配的图片
+		if req.CoverImageID != nil {
+			return nil, errors.New("智能相册不能指定封面")
+		}
+		if hasFilters {
+			filters, err := smartFilters(req)
+			if err != nil {
+				return nil, err
+			}
+			album.Filters = filters
+			if err := s.evaluate(album); err != nil {
+				return nil, err
+			}
+			updates["filters"] = album.Filters
+			updates["cached_count"] = album.CachedCount
+			updates["cached_cover"] = album.CachedCover
+			updates["cached_at"] = album.CachedAt
+		}
+	} else {
+		if hasFilters {
+			return nil, errors.New("普通相册不能设置筛选条件")
+		}
+		if req.CoverImageID != nil && !containsItem(album.Items, *req.CoverImageID) {
+			return nil, errors.New("封面图片不在该相册中")
+		}
+		updates["cover_image_id"] = req.CoverImageID
+	}
+	if err := s.db.Model(&models.Album{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(m, id)
+}
+
+// Images 分页获取相册中的图片：普通相册按相册中的顺序，智能相册按保存的筛选条件实时计算
+// 智能相册获取第一页时顺便更新缓存的数量和封面
+func (s *AlbumService) Images(m Member, id uint, page, pageSize int) ([]models.Image, int64, error) {
+	album, err := s.Get(m, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if album.Kind != models.AlbumSmart {
+		return s.images.List(m, map[string]string{"album": strconv.FormatUint(uint64(id), 10)}, page, pageSize)
+	}
+
+	// 与缓存的数量和封面一致，按相册创建者的身份计算（如favorited指创建者的收藏），
+	// 再为当前用户填充收藏状态
+	images, total, err := s.images.List(viewerOf(album.UserID, album.WorkspaceID), AlbumFilters(album), page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.images.fillActivity(m, images); err != nil {
+		return nil, 0, err
+	}
+	if page == 1 {
+		var cover uint
+		if len(images) > 0 {
+			cover = images[0].ID
+		}
+		now := time.Now()
+		if err := s.saveCache(id, int(total), cover, now); err != nil {
+			log.Printf("failed to cache smart album %d: %v", id, err)
+		}
+	}
+	return images, total, nil
+}
+
+// Refresh 重新计算智能相册的数量和封面
+func (s *AlbumService) Refresh(m Member, id uint) (*models.Album, error) {
+	album, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	if album.Kind != models.AlbumSmart {
+		return album, nil
+	}
+	if err := s.refresh(album); err != nil {
+		return nil, err
+	}
+	setSmartStats(album)
+	return album, nil
+}
+
+// Delete 删除相册，相册中的图片本身不受影响
+func (s *AlbumService) Delete(m Member, id uint) error {
+	if err := m.require(models.RoleEditor); err != nil {
+		return err
+	}
+	if _, err := s.Get(m, id); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.AlbumItem{}, "album_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Album{}, "id = ?", id).Error
+	})
+}
+
+// AddImages 把图片按给定顺序追加到相册末尾，已在相册中的图片保持原位置
+// 返回新加入的图片数量
+func (s *AlbumService) AddImages(m Member, id uint, imageIDs []uint) (int, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return 0, err
+	}
+	album, err := s.Get(m, id)
+	if err != nil {
+		return 0, err
+	}
+	if album.Kind == models.AlbumSmart {
+		return 0, errSmartAlbum
+	}
+	ids := uniqueIDs(imageIDs)
+	var count int64
+	if err := s.db.Model(&models.Image{}).Where("workspace_id = ? AND id IN ?", m.WorkspaceID, ids).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if int(count) != len(ids) {
+		return 0, errors.New("部分图片不存在")
+	}
+
+	position := 0
+	if n := len(album.Items); n > 0 {
+		position = album.Items[n-1].Position + 1
+	}
+	items := make([]models.AlbumItem, 0, len(ids))
+	for _, imageID := range ids {
+		if containsItem(album.Items, imageID) {
+			continue
+		}
+		items = append(items, models.AlbumItem{AlbumID: id, ImageID: imageID, Position: position})
+		position++
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&items).Error; err != nil {
+			return err
+		}
+		return touchAlbum(tx, id)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// RemoveImages 从相册移除图片，移除的是封面时改为使用第一张图片
+// 返回移除的图片数量
+func (s *AlbumService) RemoveImages(m Member, id uint, imageIDs []uint) (int, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return 0, err
+	}
+	album, err := s.Get(m, id)
+	if err != nil {
+		return 0, err
+	}
+	if album.Kind == models.AlbumSmart {
+		return 0, errSmartAlbum
+	}
+	var removed int64
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("album_id = ? AND image_id IN ?", id, imageIDs).Delete(&models.AlbumItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		if album.CoverImageID != nil {
+			if err := tx.Model(&models.Album{}).
+				Where("id = ? AND cover_image_id IN ?", id, imageIDs).
+				Update("cover_image_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		return touchAlbum(tx, id)
+	})
+	return int(removed), err
+}
+
+// Reorder 按给定顺序重新排列相册中的图片，imageIDs必须恰好包含相册中的全部图片
+func (s *AlbumService) Reorder(m Member, id uint, imageIDs []uint) (*models.Album, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	album, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	if album.Kind == models.AlbumSmart {
+		return nil, errSmartAlbum
+	}
+	ids := uniqueIDs(imageIDs)
+	if len(ids) != len(imageIDs) || len(ids) != len(album.Items) {
+		return nil, errors.New("排序必须包含相册中的全部图片且不能重复")
+	}
+	for _, imageID := range ids {
+		if !containsItem(album.Items, imageID) {
+			return nil, errors.New("排序中包含不在相册中的图片")
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for position, imageID := range ids {
+			if err := tx.Model(&models.AlbumItem{}).
+				Where("album_id = ? AND image_id = ?", id, imageID).
+				Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return touchAlbum(tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(m, id)
+}
+
+// SetCaption 设置图片在相册中的说明，为空表示清除
+func (s *AlbumService) SetCaption(m Member, id, imageID uint, caption string) (*models.AlbumItem, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	album, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	if album.Kind == models.AlbumSmart {
+		return nil, errSmartAlbum
+	}
+	var item models.AlbumItem
+	if err := s.db.Where("album_id = ? AND image_id = ?", id, imageID).First(&item).Error; err != nil {
+		return nil, errors.New("图片不在该相册中")
+	}
+	item.Caption = strings.TrimSpace(caption)
+	if err := s.db.Model(&item).Update("caption", item.Caption).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// errSmartAlbum 对智能相册执行普通相册的图片操作
+var errSmartAlbum = errors.New("智能相册的图片由筛选条件决定，不能手动添加、移除或排序")
+
+// AlbumFilters 解析智能相册保存的筛选条件
+func AlbumFilters(album *models.Album) map[string]string {
+	filters := map[string]string{}
+	if album.Filters != "" {
+		_ = json.Unmarshal([]byte(album.Filters), &filters)
+	}
+	return filters
+}
+
+// smartFilters 把请求中的筛选条件整理为保存用的JSON，query中的条件覆盖filters中的同名条件
+// 去掉空值、分页参数和ids：ids是对话式检索上一轮的结果集，保存后会使相册不再随图片库变化
+func smartFilters(req dto.AlbumRequest) (string, error) {
+	filters := map[string]string{}
+	for key, value := range req.Filters {
+		filters[key] = value
+	}
+	if query := strings.TrimPrefix(strings.TrimSpace(req.Query), "?"); query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return "", errors.New("筛选条件格式错误: " + err.Error())
+		}
+		for key := range values {
+			filters[key] = values.Get(key)
+		}
+	}
+	for key, value := range filters {
+		value = strings.TrimSpace(value)
+		if value == "" || key == "ids" || key == "page" || key == "pageSize" {
+			delete(filters, key)
+			continue
+		}
+		filters[key] = value
+	}
+	if len(filters) == 0 {
+		return "", errors.New("智能相册至少需要一个筛选条件")
+	}
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// evaluate 按智能相册的筛选条件计算数量和封面，写入album但不保存；条件无效时返回错误
+func (s *AlbumService) evaluate(album *models.Album) error {
+	images, total, err := s.images.List(viewerOf(album.UserID, album.WorkspaceID), AlbumFilters(album), 1, 1)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	album.CachedCount = int(total)
+	album.CachedCover = 0
+	if len(images) > 0 {
+		album.CachedCover = images[0].ID
+	}
+	album.CachedAt = &now
+	return nil
+}
+
+// refresh 重新计算智能相册的数量和封面并保存
+func (s *AlbumService) refresh(album *models.Album) error {
+	if err := s.evaluate(album); err != nil {
+		return err
+	}
+	return s.saveCache(album.ID, album.CachedCount, album.CachedCover, *album.CachedAt)
+}
+
+// saveCache 保存智能相册的缓存，不改变相册的修改时间
+func (s *AlbumService) saveCache(id uint, count int, cover uint, at time.Time) error {
+	return s.db.Model(&models.Album{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"cached_count": count,
+		"cached_cover": cover,
+		"cached_at":    at,
+	}).Error
+}
+
+// setSmartStats 用缓存填充智能相册的图片数量和封面
+func setSmartStats(album *models.Album) {
+	if album.Kind != models.AlbumSmart {
+		return
+	}
+	album.ImageCount = album.CachedCount
+	album.CoverID = album.CachedCover
+}
+
+// removeImageFromAlbums 在删除图片的事务中把图片移出所有相册，并清除以它为封面的设置
+func removeImageFromAlbums(tx *gorm.DB, imageID uint) error {
+	if err := tx.Delete(&models.AlbumItem{}, "image_id = ?", imageID).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Album{}).Where("cover_image_id = ?", imageID).Update("cover_image_id", nil).Error
+}
+
+// touchAlbum 更新相册的修改时间，使最近编辑的相册排在列表前面
+func touchAlbum(tx *gorm.DB, id uint) error {
+	return tx.Model(&models.Album{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
+}
+
+// containsItem 判断图片是否在相册中
+func containsItem(items []models.AlbumItem, imageID uint) bool {
+	for _, item := range items {
+		if item.ImageID == imageID {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueIDs 去除重复的ID，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	result := make([]uint, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+// Package services 提供业务逻辑层的服务实现
+// annotation_service.go 实现了图片区域标注：在图片上框选一个矩形并附上说明
+// 矩形以原图像素为单位保存，缩略图和缩放后的预览按显示尺寸与原图尺寸的比例换算，不受显示大小影响
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AnnotationService 区域标注服务结构体
+type AnnotationService struct {
+	db *gorm.DB
+}
+
+// NewAnnotationService 创建区域标注服务实例
+func NewAnnotationService(db *gorm.DB) *AnnotationService {
+	return &AnnotationService{db: db}
+}
+
+// List 获取图片的全部区域标注，按创建时间排序
+func (s *AnnotationService) List(m Member, imageID uint) ([]models.ImageAnnotation, error) {
+	if err := checkWorkspaceImage(s.db, m, models.RoleViewer, imageID); err != nil {
+		return nil, err
+	}
+	var annotations []models.ImageAnnotation
+	if err := s.db.Where("image_id = ?", imageID).Order("created_at, id").Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+	userIDs := make([]uint, len(annotations))
+	for i, annotation := range annotations {
+		userIDs[i] = annotation.UserID
+	}
+	names, err := usernames(s.db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range annotations {
+		annotations[i].Username = names[annotations[i].UserID]
+	}
+	return annotations, nil
+}
+
+// Create 在图片上添加区域标注，需要editor及以上角色；矩形必须在原图范围内
+func (s *AnnotationService) Create(m Member, imageID uint, req dto.AnnotationRequest) (*models.ImageAnnotation, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	var img models.Image
+	if err := s.db.Where("id = ? AND workspace_id = ?", imageID, m.WorkspaceID).First(&img).Error; err != nil {
+		return nil, errors.New("图片不存在")
+	}
+	if err := checkRegion(&img, req); err != nil {
+		return nil, err
+	}
+	annotation := models.ImageAnnotation{
+		ImageID:     imageID,
+		WorkspaceID: m.WorkspaceID,
+		UserID:      m.UserID,
+		X:           req.X,
+		Y:           req.Y,
+		Width:       req.Width,
+		Height:      req.Height,
+		Note:        strings.TrimSpace(req.Note),
+	}
+	if err := s.db.Create(&annotation).Error; err != nil {
+		return nil, err
+	}
+	return s.withUsername(&annotation)
+}
+
+// Update 修改标注的矩形和说明；标注者本人或admin及以上角色可以修改
+func (s *AnnotationService) Update(m Member, id uint, req dto.AnnotationRequest) (*models.ImageAnnotation, error) {
+	annotation, err := s.editable(m, id)
+	if err != nil {
+		return nil, err
+	}
+	var img models.Image
+	if err := s.db.First(&img, annotation.ImageID).Error; err != nil {
+		return nil, errors.New("图片不存在")
+	}
+	if err := checkRegion(&img, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(annotation).Updates(map[string]interface{}{
+		"x":      req.X,
+		"y":      req.Y,
+		"width":  req.Width,
+		"height": req.Height,
+		"note":   strings.TrimSpace(req.Note),
+	}).Error; err != nil {
+		return nil, err
+	}
+	annotation.X, annotation.Y, annotation.Width, annotation.Height = req.X, req.Y, req.Width, req.Height
+	annotation.Note = strings.TrimSpace(req.Note)
+	return s.withUsername(annotation)
+}
+
+// Delete 删除标注；标注者本人或admin及以上角色可以删除
+func (s *AnnotationService) Delete(m Member, id uint) error {
+	annotation, err := s.editable(m, id)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(annotation).Error
+}
+
+// editable 获取工作区中当前成员可以修改的标注
+func (s *AnnotationService) editable(m Member, id uint) (*models.ImageAnnotation, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	var annotation models.ImageAnnotation
+	if err := s.db.Where("id = ? AND workspace_id = ?", id, m.WorkspaceID).First(&annotation).Error; err != nil {
+		return nil, errors.New("标注不存在")
+	}
+	if annotation.UserID != m.UserID && !m.Can(models.RoleAdmin) {
+		return nil, ErrForbidden
+	}
+	return &annotation, nil
+}
+
+// withUsername 填充标注者的用户名
+func (s *AnnotationService) withUsername(annotation *models.ImageAnnotation) (*models.ImageAnnotation, error) {
+	names, err := usernames(s.db, []uint{annotation.UserID})
+	if err != nil {
+		return nil, err
+	}
+	annotation.Username = names[annotation.UserID]
+	return annotation, nil
+}
+
+// checkRegion 检查矩形是否在原图范围内；尺寸未知的图片只要求矩形不为空
+func checkRegion(img *models.Image, req dto.AnnotationRequest) error {
+	if req.X < 0 || req.Y < 0 || req.Width <= 0 || req.Height <= 0 {
+		return errors.New("标注区域无效")
+	}
+	if img.Width > 0 && img.Height > 0 && (req.X+req.Width > img.Width || req.Y+req.Height > img.Height) {
+		return errors.New("标注区域超出图片范围")
+	}
+	return nil
+}
+
+// removeImageAnnotations 在删除图片的事务中删除图片的全部区域标注
+func removeImageAnnotations(tx *gorm.DB, imageID uint) error {
+	return tx.Delete(&models.ImageAnnotation{}, "image_id = ?", imageID).Error
+}
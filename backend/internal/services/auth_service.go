@@ -44,7 +44,15 @@ func (s *AuthService) Register(req dto.RegisterRequest) (*models.User, error) {
 		Password: string(hash),
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
+	// 同时创建用户的个人工作区，用户原有的图片库就是这个工作区
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		_, err := createPersonalWorkspace(tx, user)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -76,3 +84,82 @@ func (s *AuthService) Login(req dto.LoginRequest) (string, *models.User, error)
 
 	return tokenString, &user, nil
 }
+
+// FindUser 按用户名或邮箱查找用户，供不经过登录流程的本地入口（如MCP stdio）确定用户身份
+func (s *AuthService) FindUser(identifier string) (*models.User, error) {
+	var user models.User
+	if err := s.db.Where("username = ? OR email = ?", identifier, identifier).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// IsAdmin 判断用户是否为系统管理员，即用户名或邮箱在admins中
+// 系统管理员与工作区角色无关，每个用户都是自己个人工作区的owner
+func (s *AuthService) IsAdmin(userID uint, admins []string) bool {
+	if len(admins) == 0 {
+		return false
+	}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return false
+	}
+	for _, admin := range admins {
+		if admin == user.Username || admin == user.Email {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTokenWindow 媒体令牌的签发周期；同一周期内签发的令牌相同，带令牌的图片地址可以被浏览器缓存
+const mediaTokenWindow = time.Hour
+
+// MediaToken 签发短期媒体令牌，供<img>、<video>等无法携带请求头的标签通过查询参数访问当前工作区的图片文件
+// 令牌记录用户和工作区，用由JWT密钥派生的独立密钥签名，不能当作登录令牌使用；
+// 有效期到下一个周期结束，客户端在过期前重新获取即可
+func (s *AuthService) MediaToken(m Member) (string, time.Time, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Truncate(mediaTokenWindow).Add(2 * mediaTokenWindow)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":      m.UserID,
+		"workspace_id": m.WorkspaceID,
+		"exp":          expiresAt.Unix(),
+	})
+	tokenString, err := token.SignedString(mediaKey(s.jwtSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+// ParseMediaToken 校验媒体令牌，返回签发时的用户ID和工作区ID
+// 之后仍需按工作区成员关系确认用户当前的权限，令牌签发后被移出工作区的用户无法继续访问
+func ParseMediaToken(secret, tokenString string) (uint, uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return mediaKey(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return 0, 0, errors.New("无效的媒体令牌")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, 0, errors.New("无法解析媒体令牌")
+	}
+	userID, ok1 := claims["user_id"].(float64)
+	workspaceID, ok2 := claims["workspace_id"].(float64)
+	if !ok1 || !ok2 {
+		return 0, 0, errors.New("媒体令牌缺少用户信息")
+	}
+	return uint(userID), uint(workspaceID), nil
+}
+
+// mediaKey 媒体令牌的签名密钥，与登录令牌的密钥不同
+func mediaKey(secret string) []byte {
+	return []byte(secret + ":media")
+}
@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestAuthServiceIsAdmin(t *testing.T) {
+	db := newTestDB(t)
+	auth := NewAuthService(db, "secret")
+	alice := newTestMember(t, db, "alice")
+	bob := newTestMember(t, db, "bob")
+
+	tests := []struct {
+		name   string
+		userID uint
+		admins []string
+		want   bool
+	}{
+		{"by username", alice.UserID, []string{"alice"}, true},
+		{"by email", alice.UserID, []string{"root", "alice@example.com"}, true},
+		{"not listed", bob.UserID, []string{"alice"}, false},
+		{"no admins configured", alice.UserID, nil, false},
+		{"unknown user", 999, []string{"alice"}, false},
+	}
+	for _, tt := range tests {
+		if got := auth.IsAdmin(tt.userID, tt.admins); got != tt.want {
+			t.Errorf("%s: IsAdmin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package services 提供业务逻辑层的服务实现
+// comment_service.go 实现了图片评论：工作区的任何成员都可以评论和回复，评论按回复关系组成讨论串
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CommentService 评论服务结构体
+type CommentService struct {
+	db *gorm.DB
+}
+
+// NewCommentService 创建评论服务实例
+func NewCommentService(db *gorm.DB) *CommentService {
+	return &CommentService{db: db}
+}
+
+// List 获取图片的全部评论，按发表时间排序；前端按ParentID组织成讨论串
+func (s *CommentService) List(m Member, imageID uint) ([]models.ImageComment, error) {
+	if err := checkWorkspaceImage(s.db, m, models.RoleViewer, imageID); err != nil {
+		return nil, err
+	}
+	var comments []models.ImageComment
+	if err := s.db.Where("image_id = ?", imageID).Order("created_at, id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	userIDs := make([]uint, len(comments))
+	for i, comment := range comments {
+		userIDs[i] = comment.UserID
+	}
+	names, err := usernames(s.db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		comments[i].Username = names[comments[i].UserID]
+	}
+	return comments, nil
+}
+
+// Create 发表评论，提供parentId时回复同一张图片上的另一条评论
+func (s *CommentService) Create(m Member, imageID uint, req dto.CommentRequest) (*models.ImageComment, error) {
+	if err := checkWorkspaceImage(s.db, m, models.RoleViewer, imageID); err != nil {
+		return nil, err
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return nil, errors.New("评论内容不能为空")
+	}
+	if req.ParentID != nil {
+		var count int64
+		if err := s.db.Model(&models.ImageComment{}).Where("id = ? AND image_id = ?", *req.ParentID, imageID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, errors.New("回复的评论不存在")
+		}
+	}
+	comment := models.ImageComment{
+		ImageID:     imageID,
+		WorkspaceID: m.WorkspaceID,
+		UserID:      m.UserID,
+		ParentID:    req.ParentID,
+		Body:        body,
+	}
+	if err := s.db.Create(&comment).Error; err != nil {
+		return nil, err
+	}
+	return s.withUsername(&comment)
+}
+
+// Update 修改评论内容，只有评论者本人可以修改
+func (s *CommentService) Update(m Member, id uint, req dto.CommentRequest) (*models.ImageComment, error) {
+	comment, err := s.get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != m.UserID {
+		return nil, ErrForbidden
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return nil, errors.New("评论内容不能为空")
+	}
+	if err := s.db.Model(comment).Update("body", body).Error; err != nil {
+		return nil, err
+	}
+	return s.withUsername(comment)
+}
+
+// Delete 删除评论及其所有回复；评论者本人或admin及以上角色可以删除
+func (s *CommentService) Delete(m Member, id uint) error {
+	comment, err := s.get(m, id)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != m.UserID && !m.Can(models.RoleAdmin) {
+		return ErrForbidden
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 逐层收集回复，避免依赖数据库的递归查询
+		ids := []uint{id}
+		for level := []uint{id}; len(level) > 0; {
+			var replies []uint
+			if err := tx.Model(&models.ImageComment{}).Where("parent_id IN ?", level).Pluck("id", &replies).Error; err != nil {
+				return err
+			}
+			ids = append(ids, replies...)
+			level = replies
+		}
+		return tx.Delete(&models.ImageComment{}, "id IN ?", ids).Error
+	})
+}
+
+// get 获取工作区中的一条评论
+func (s *CommentService) get(m Member, id uint) (*models.ImageComment, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var comment models.ImageComment
+	if err := s.db.Where("id = ? AND workspace_id = ?", id, m.WorkspaceID).First(&comment).Error; err != nil {
+		return nil, errors.New("评论不存在")
+	}
+	return &comment, nil
+}
+
+// withUsername 填充评论者的用户名
+func (s *CommentService) withUsername(comment *models.ImageComment) (*models.ImageComment, error) {
+	names, err := usernames(s.db, []uint{comment.UserID})
+	if err != nil {
+		return nil, err
+	}
+	comment.Username = names[comment.UserID]
+	return comment, nil
+}
+
+// removeImageComments 在删除图片的事务中删除图片的全部评论
+func removeImageComments(tx *gorm.DB, imageID uint) error {
+	return tx.Delete(&models.ImageComment{}, "image_id = ?", imageID).Error
+}
+
+// checkWorkspaceImage 确认成员的角色不低于role，且图片在成员的当前工作区中
+func checkWorkspaceImage(db *gorm.DB, m Member, role string, imageID uint) error {
+	if err := m.require(role); err != nil {
+		return err
+	}
+	var count int64
+	if err := db.Model(&models.Image{}).Where("id = ? AND workspace_id = ?", imageID, m.WorkspaceID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("图片不存在")
+	}
+	return nil
+}
+
+// usernames 查询用户ID对应的用户名
+func usernames(db *gorm.DB, userIDs []uint) (map[uint]string, error) {
+	names := make(map[uint]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return names, nil
+	}
+	var users []models.User
+	if err := db.Select("id", "username").Where("id IN ?", uniqueIDs(userIDs)).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	return names, nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+)
+
+func TestActivityFilters(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.Config{StorageDir: t.TempDir()}
+	workspaces := NewWorkspaceService(db, NewAuthService(db, "secret"))
+	images := NewImageService(db, cfg, NewTagService(db), nil)
+	comments := NewCommentService(db)
+	annotations := NewAnnotationService(db)
+	favorites := NewFavoriteService(db)
+
+	alice := newTestMember(t, db, "alice")
+	team := newTestTeam(t, workspaces, alice, map[string]Member{models.RoleEditor: newTestMember(t, db, "bob")})
+	owner, editor := team[models.RoleOwner], team[models.RoleEditor]
+
+	newImage := func() uint {
+		return newTestImage(t, db, owner, models.Image{Width: 100, Height: 100}).ID
+	}
+	commented, annotated, mine, theirs, plain := newImage(), newImage(), newImage(), newImage(), newImage()
+	first, err := comments.Create(editor, commented, dto.CommentRequest{Body: "好看"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.Create(owner, commented, dto.CommentRequest{Body: "谢谢", ParentID: &first.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := comments.Create(owner, plain, dto.CommentRequest{Body: "串楼", ParentID: &first.ID}); err == nil {
+		t.Error("a reply should stay on the parent's image")
+	}
+	if _, err := annotations.Create(editor, annotated, dto.AnnotationRequest{X: 10, Y: 10, Width: 20, Height: 20, Note: "人脸"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := annotations.Create(editor, plain, dto.AnnotationRequest{X: 90, Y: 90, Width: 20, Height: 20}); err == nil {
+		t.Error("an annotation outside the image should be rejected")
+	}
+	if err := favorites.Add(owner, mine); err != nil {
+		t.Fatal(err)
+	}
+	if err := favorites.Add(editor, theirs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		filters map[string]string
+		want    []uint
+	}{
+		{"has comments", map[string]string{"has_comments": "true"}, []uint{commented}},
+		{"no comments", map[string]string{"has_comments": "false"}, []uint{annotated, mine, theirs, plain}},
+		{"has annotations", map[string]string{"has_annotations": "true"}, []uint{annotated}},
+		{"favorited by me", map[string]string{"favorited": "true"}, []uint{mine}},
+		{"not favorited by me", map[string]string{"favorited": "false", "has_comments": "false", "has_annotations": "false"}, []uint{theirs, plain}},
+		{"invalid value is ignored", map[string]string{"has_comments": "maybe"}, []uint{commented, annotated, mine, theirs, plain}},
+		{"empty id list matches nothing", map[string]string{"ids": JoinIDs(nil)}, nil},
+	}
+	for _, tt := range tests {
+		got, err := images.ListIDs(owner, tt.filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !sameIDs(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	list, _, err := images.List(owner, map[string]string{"ids": JoinIDs([]uint{commented, mine})}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range list {
+		switch img.ID {
+		case commented:
+			if img.CommentCount != 2 || img.Favorited {
+				t.Errorf("commented image: %d comments, favorited %v", img.CommentCount, img.Favorited)
+			}
+		case mine:
+			if img.CommentCount != 0 || !img.Favorited {
+				t.Errorf("favorite image: %d comments, favorited %v", img.CommentCount, img.Favorited)
+			}
+		}
+	}
+}
+
+// sameIDs 判断两个ID列表包含的ID是否相同，不考虑顺序
+func sameIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]uint(nil), a...), append([]uint(nil), b...)
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,832 @@
+// Package services 提供业务逻辑层的服务实现
+// edit_service.go 实现了非破坏性编辑：每张图片保存一棵编辑版本树，版本记录相对原图的完整操作列表，
+// 渲染结果按版本缓存在磁盘上；支持撤销/重做、查看历史、切换到任意版本以及恢复原图
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"image-manager/internal/animation"
+	"image-manager/internal/config"
+	"image-manager/internal/editor"
+	"image-manager/internal/models"
+
+	"github.com/disintegration/imaging"
+	"gorm.io/gorm"
+)
+
+// EditHistory 图片的编辑历史
+type EditHistory struct {
+	Versions         []models.ImageVersion `json:"versions"`         // 全部版本，按创建时间排列
+	CurrentVersionID *uint                 `json:"currentVersionId"` // 当前版本，为空表示原图
+	CanUndo          bool                  `json:"canUndo"`          // 是否可以撤销
+	CanRedo          bool                  `json:"canRedo"`          // 是否可以重做
+}
+
+// ErrEditConflict 渲染期间图片的当前版本被其他请求修改，新版本没有保存
+var ErrEditConflict = errors.New("图片已被其他编辑修改，请刷新后重试")
+
+// EditService 编辑服务结构体
+// 同时作为图片后处理器注册到ImageService：图片文件被替换时清空编辑历史，图片删除时清理版本和渲染缓存
+type EditService struct {
+	db         *gorm.DB
+	cfg        config.Config
+	images     *ImageService
+	watermarks *WatermarkService
+	locks      sync.Map // 图片ID -> *sync.Mutex，串行化同一图片的版本切换，不同图片互不影响
+
+	previewMu    sync.Mutex
+	previewBases []previewBase // 最近使用的预览底图，拖动滑块时连续预览无需重复解码原图
+}
+
+// 预览参数
+const (
+	defaultPreviewSize = 1024 // 预览图默认最长边
+	minPreviewSize     = 64
+	maxPreviewSize     = 2048
+	previewCacheSize   = 16 // 缓存的预览底图数量
+)
+
+// previewBase 缩小后的当前版本渲染结果
+type previewBase struct {
+	imageID   uint
+	versionID uint // 0表示原图
+	size      int
+	img       image.Image
+	factor    float64 // 缩小比例（缩小后/原尺寸）
+}
+
+// Preview 预览结果
+type Preview struct {
+	Data   []byte  // JPEG编码的预览图
+	Width  int     // 预览图宽度
+	Height int     // 预览图高度
+	Scale  float64 // 预览图相对全尺寸渲染结果的缩放比例
+}
+
+// NewEditService 创建编辑服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，渲染缓存保存在StorageDir/renders下
+//   - images: 图片服务，用于读取原图和刷新缩略图
+//   - watermarks: 水印服务，用于解析watermark操作引用的预设
+//
+// 返回: EditService指针
+func NewEditService(db *gorm.DB, cfg config.Config, images *ImageService, watermarks *WatermarkService) *EditService {
+	return &EditService{db: db, cfg: cfg, images: images, watermarks: watermarks}
+}
+
+// Name 后处理器名称
+func (s *EditService) Name() string {
+	return "edits"
+}
+
+// Process 图片文件被替换后，原有的操作列表不再适用于新文件，清空编辑历史
+func (s *EditService) Process(img *models.Image, decoded image.Image, data []byte) error {
+	defer s.lock(img.ID)()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.clearVersions(tx, img); err != nil {
+			return err
+		}
+		return tx.Model(&models.Image{}).Where("id = ?", img.ID).Update("edit_version_id", nil).Error
+	})
+}
+
+// Remove 删除图片时清理其编辑版本和渲染缓存，并释放该图片的锁
+func (s *EditService) Remove(tx *gorm.DB, img *models.Image) error {
+	unlock := s.lock(img.ID)
+	defer func() {
+		s.locks.Delete(img.ID)
+		unlock()
+	}()
+	return s.clearVersions(tx, img)
+}
+
+// lock 锁定一张图片的版本切换，返回解锁函数
+func (s *EditService) lock(imageID uint) func() {
+	mu, _ := s.locks.LoadOrStore(imageID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// clearVersions 删除图片的所有版本记录和渲染缓存文件
+func (s *EditService) clearVersions(tx *gorm.DB, img *models.Image) error {
+	s.dropPreviewBases(img.ID)
+	// 缩小的渲染结果包括未编辑时的原图，替换文件后同样失效
+	renditions, _ := filepath.Glob(filepath.Join(s.cfg.StorageDir, "renditions", fmt.Sprintf("%d_*.jpg", img.ID)))
+	for _, path := range renditions {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var ids []uint
+	if err := tx.Model(&models.ImageVersion{}).Where("image_id = ?", img.ID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		// 不同版本的输出格式可能不同，按前缀删除
+		matches, _ := filepath.Glob(filepath.Join(s.cfg.StorageDir, "renders", fmt.Sprintf("%d_%d.*", img.ID, id)))
+		for _, path := range matches {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return tx.Delete(&models.ImageVersion{}, "image_id = ?", img.ID).Error
+}
+
+// Apply 在当前版本的基础上追加编辑操作，生成新版本并设为当前版本
+// 新操作的坐标相对于当前版本的渲染结果；如果当前版本是撤销后的旧版本，新版本成为它的另一个子版本
+// 渲染不加锁，保存时当前版本已被其他请求修改、或图片文件已被替换则返回ErrEditConflict
+func (s *EditService) Apply(m Member, imageID uint, ops []editor.Operation, label string) (*models.ImageVersion, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("缺少编辑操作")
+	}
+	ops, err := s.resolveOps(m.UserID, ops)
+	if err != nil {
+		return nil, err
+	}
+	if err := editor.Validate(ops); err != nil {
+		return nil, err
+	}
+
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEditable(img); err != nil {
+		return nil, err
+	}
+	base, err := s.versionOps(img.EditVersionID)
+	if err != nil {
+		return nil, err
+	}
+	all := append(base, ops...)
+
+	// 替换文件总是写入新路径，同一路径的修改时间用来发现渲染期间被改写的文件
+	before, err := os.Stat(img.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.render(img, all)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedOps, err := json.Marshal(all)
+	if err != nil {
+		return nil, err
+	}
+	if label == "" {
+		label = ops[0].Type
+		if len(ops) > 1 {
+			label = fmt.Sprintf("%s 等%d项操作", ops[0].Type, len(ops))
+		}
+	}
+	version := models.ImageVersion{
+		ImageID:    img.ID,
+		ParentID:   img.EditVersionID,
+		Label:      label,
+		Operations: encodedOps,
+		Width:      r.first.Bounds().Dx(),
+		Height:     r.first.Bounds().Dy(),
+	}
+
+	defer s.lock(img.ID)()
+	if after, err := os.Stat(img.FilePath); err != nil || !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size() {
+		return nil, ErrEditConflict
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		// 只在当前版本和文件仍是渲染时的基础时切换
+		update := tx.Model(&models.Image{}).Where("id = ? AND file_path = ?", img.ID, img.FilePath)
+		if img.EditVersionID == nil {
+			update = update.Where("edit_version_id IS NULL")
+		} else {
+			update = update.Where("edit_version_id = ?", *img.EditVersionID)
+		}
+		result := update.Update("edit_version_id", version.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.writeRender(img, version.ID, r); err != nil {
+		return nil, err
+	}
+	if err := s.refreshThumbnail(img, r); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Copy 在当前版本的基础上执行编辑操作，把结果另存为一张新图片，源图片及其编辑历史保持不变
+// 新图片记录来源图片，继承其标签和EXIF，并生成缩略图、运行后处理器
+func (s *EditService) Copy(m Member, imageID uint, ops []editor.Operation) (*models.Image, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("缺少编辑操作")
+	}
+	ops, err := s.resolveOps(m.UserID, ops)
+	if err != nil {
+		return nil, err
+	}
+	if err := editor.Validate(ops); err != nil {
+		return nil, err
+	}
+
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEditable(img); err != nil {
+		return nil, err
+	}
+	base, err := s.versionOps(img.EditVersionID)
+	if err != nil {
+		return nil, err
+	}
+	all := append(base, ops...)
+
+	r, err := s.render(img, all)
+	if err != nil {
+		return nil, err
+	}
+	return s.images.createDerived(m, img, r.first, r.data, r.out.Format)
+}
+
+// Preview 在当前版本的缩小渲染结果上执行操作列表并返回预览图，不保存任何内容
+// 操作的坐标与Apply一样相对于当前版本的全尺寸渲染结果，预览时按缩小比例换算；动图只预览第一帧
+// 参数:
+//   - size: 预览图最长边，0表示使用默认值
+func (s *EditService) Preview(m Member, imageID uint, ops []editor.Operation, size int) (*Preview, error) {
+	ops, err := s.resolveOps(m.UserID, ops)
+	if err != nil {
+		return nil, err
+	}
+	if err := editor.Validate(ops); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		size = defaultPreviewSize
+	}
+	if size < minPreviewSize || size > maxPreviewSize {
+		return nil, fmt.Errorf("预览尺寸应在%d到%d之间", minPreviewSize, maxPreviewSize)
+	}
+
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEditable(img); err != nil {
+		return nil, err
+	}
+	base, err := s.previewBase(img, size)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := editor.Apply(base.img, editor.ScaleOps(ops, base.factor))
+	if err != nil {
+		return nil, err
+	}
+	// 预览前的操作（如放大）可能使结果超过预览尺寸
+	rendered = imaging.Fit(rendered, size, size, imaging.Linear)
+
+	buff := &bytes.Buffer{}
+	if err := editor.Encode(buff, rendered, editor.Output{Format: editor.FormatJPEG, Quality: 85}); err != nil {
+		return nil, err
+	}
+	return &Preview{
+		Data:   buff.Bytes(),
+		Width:  rendered.Bounds().Dx(),
+		Height: rendered.Bounds().Dy(),
+		Scale:  base.factor,
+	}, nil
+}
+
+// previewBase 获取图片当前版本缩小到指定尺寸的渲染结果，优先使用缓存
+func (s *EditService) previewBase(img *models.Image, size int) (*previewBase, error) {
+	var versionID uint
+	if img.EditVersionID != nil {
+		versionID = *img.EditVersionID
+	}
+
+	s.previewMu.Lock()
+	for i, cached := range s.previewBases {
+		if cached.imageID == img.ID && cached.versionID == versionID && cached.size == size {
+			// 移到末尾，表示最近使用
+			s.previewBases = append(append(s.previewBases[:i:i], s.previewBases[i+1:]...), cached)
+			s.previewMu.Unlock()
+			return &cached, nil
+		}
+	}
+	s.previewMu.Unlock()
+
+	full, err := s.currentRendition(img)
+	if err != nil {
+		return nil, err
+	}
+	small := imaging.Fit(full, size, size, imaging.Lanczos)
+	base := previewBase{
+		imageID:   img.ID,
+		versionID: versionID,
+		size:      size,
+		img:       small,
+		factor:    float64(small.Bounds().Dx()) / float64(full.Bounds().Dx()),
+	}
+
+	s.previewMu.Lock()
+	s.previewBases = append(s.previewBases, base)
+	if len(s.previewBases) > previewCacheSize {
+		s.previewBases = s.previewBases[len(s.previewBases)-previewCacheSize:]
+	}
+	s.previewMu.Unlock()
+	return &base, nil
+}
+
+// dropPreviewBases 丢弃图片的预览底图缓存，原图被替换或图片被删除时调用
+func (s *EditService) dropPreviewBases(imageID uint) {
+	s.previewMu.Lock()
+	defer s.previewMu.Unlock()
+	kept := s.previewBases[:0]
+	for _, base := range s.previewBases {
+		if base.imageID != imageID {
+			kept = append(kept, base)
+		}
+	}
+	s.previewBases = kept
+}
+
+// currentRendition 解码图片当前版本的全尺寸渲染结果，优先读取渲染缓存
+func (s *EditService) currentRendition(img *models.Image) (image.Image, error) {
+	if img.EditVersionID == nil {
+		return s.decodeOriginal(img)
+	}
+	data, _, err := s.renderCurrent(img)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFirstFrame(data)
+}
+
+// resolveOps 把请求中的watermark操作引用的预设替换为参数快照
+// 客户端提交的快照一律忽略，水印只能来自用户自己的预设（快照中包含服务端文件路径）
+func (s *EditService) resolveOps(userID uint, ops []editor.Operation) ([]editor.Operation, error) {
+	resolved := make([]editor.Operation, len(ops))
+	for i, op := range ops {
+		if op.Type == editor.OpWatermark {
+			spec, err := s.watermarks.Spec(userID, op.WatermarkID)
+			if err != nil {
+				return nil, fmt.Errorf("第%d个操作: %v", i+1, err)
+			}
+			op.Watermark = &spec
+		}
+		resolved[i] = op
+	}
+	return resolved, nil
+}
+
+// Export 导出图片当前版本，可以临时叠加水印预设并转换格式，不影响编辑历史
+// 参数:
+//   - watermarkID: 水印预设ID，0表示不加水印
+//   - format: 导出格式（jpeg、png、webp），为空时与当前版本的格式相同
+//
+// 返回: 文件内容、MIME类型、建议的下载文件名和错误信息
+func (s *EditService) Export(m Member, imageID, watermarkID uint, format string) ([]byte, string, string, error) {
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if err := checkEditable(img); err != nil {
+		return nil, "", "", err
+	}
+	ops, err := s.versionOps(img.EditVersionID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	out := editor.OutputOf(ops, img.StoredFilename)
+	if format != "" {
+		if err := (editor.Operation{Type: editor.OpFormat, Format: format}).Validate(); err != nil {
+			return nil, "", "", err
+		}
+		out.Format = format
+	}
+	filename := img.OriginalFilename
+	if len(ops) > 0 || format != "" {
+		filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + out.Format
+	}
+
+	// 不加水印也不转换格式时直接返回当前版本，无需重新编码
+	if watermarkID == 0 && format == "" {
+		data, mimeType, err := s.renderCurrent(img)
+		return data, mimeType, filename, err
+	}
+
+	// 水印和格式转换作为临时操作追加到当前版本的操作列表之后，动图逐帧叠加水印
+	if watermarkID != 0 {
+		mark, err := s.watermarks.Get(m.UserID, watermarkID)
+		if err != nil {
+			return nil, "", "", errors.New("水印不存在")
+		}
+		ops = withWatermark(ops, mark)
+	}
+	if format != "" {
+		ops = append(ops, editor.Operation{Type: editor.OpFormat, Format: format})
+	}
+	r, err := s.render(img, ops)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return r.data, getMimeType(r.out.Format), filename, nil
+}
+
+// Undo 撤销：回到当前版本的父版本（可能是原图）
+func (s *EditService) Undo(m Member, imageID uint) (*models.Image, error) {
+	return s.move(m, imageID, func(img *models.Image) (*uint, error) {
+		if img.EditVersionID == nil {
+			return nil, errors.New("没有可撤销的编辑")
+		}
+		var current models.ImageVersion
+		if err := s.db.First(&current, *img.EditVersionID).Error; err != nil {
+			return nil, err
+		}
+		return current.ParentID, nil
+	})
+}
+
+// Redo 重做：进入当前版本最近创建的子版本
+func (s *EditService) Redo(m Member, imageID uint) (*models.Image, error) {
+	return s.move(m, imageID, func(img *models.Image) (*uint, error) {
+		child, err := s.latestChild(img)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return nil, errors.New("没有可重做的编辑")
+		}
+		return &child.ID, nil
+	})
+}
+
+// Revert 恢复原图，编辑历史保留，之后仍可重做或切换回任意版本
+func (s *EditService) Revert(m Member, imageID uint) (*models.Image, error) {
+	return s.move(m, imageID, func(img *models.Image) (*uint, error) {
+		return nil, nil
+	})
+}
+
+// Checkout 切换到历史中的指定版本
+func (s *EditService) Checkout(m Member, imageID, versionID uint) (*models.Image, error) {
+	return s.move(m, imageID, func(img *models.Image) (*uint, error) {
+		var version models.ImageVersion
+		if err := s.db.Where("id = ? AND image_id = ?", versionID, img.ID).First(&version).Error; err != nil {
+			return nil, errors.New("版本不存在")
+		}
+		return &version.ID, nil
+	})
+}
+
+// move 切换当前版本并刷新缩略图，target根据图片当前状态计算目标版本（nil表示原图）
+func (s *EditService) move(m Member, imageID uint, target func(img *models.Image) (*uint, error)) (*models.Image, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	defer s.lock(imageID)()
+
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, err
+	}
+	versionID, err := target(img)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Image{}).Where("id = ?", img.ID).Update("edit_version_id", versionID).Error; err != nil {
+		return nil, err
+	}
+	img.EditVersionID = versionID
+
+	if img.FrameCount > 1 {
+		// 动图从渲染结果（优先读缓存）重新生成动态缩略图
+		data, _, err := s.renderCurrent(img)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.images.generateThumbnail(img.ID, bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return s.images.Get(m, imageID)
+	}
+	rendered, _, err := s.renderImage(img)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.images.saveThumbnail(img.ID, rendered); err != nil {
+		return nil, err
+	}
+	return s.images.Get(m, imageID)
+}
+
+// History 获取图片的编辑历史
+func (s *EditService) History(m Member, imageID uint) (*EditHistory, error) {
+	img, err := s.images.Get(m, imageID)
+	if err != nil {
+		return nil, err
+	}
+	history := &EditHistory{CurrentVersionID: img.EditVersionID, CanUndo: img.EditVersionID != nil}
+	if err := s.db.Where("image_id = ?", img.ID).Order("id ASC").Find(&history.Versions).Error; err != nil {
+		return nil, err
+	}
+	child, err := s.latestChild(img)
+	if err != nil {
+		return nil, err
+	}
+	history.CanRedo = child != nil
+	return history, nil
+}
+
+// latestChild 返回当前版本最近创建的子版本，当前为原图时返回最近创建的根版本
+func (s *EditService) latestChild(img *models.Image) (*models.ImageVersion, error) {
+	query := s.db.Where("image_id = ?", img.ID)
+	if img.EditVersionID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *img.EditVersionID)
+	}
+	var children []models.ImageVersion
+	if err := query.Order("id DESC").Limit(1).Find(&children).Error; err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return nil, nil
+	}
+	return &children[0], nil
+}
+
+// Render 返回工作区中图片当前版本的渲染结果及其MIME类型，未编辑的图片直接返回原图
+func (s *EditService) Render(m Member, imageID uint) ([]byte, string, error) {
+	img, err := s.images.find(m, imageID)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.renderCurrent(img)
+}
+
+// renderCurrent 返回图片当前版本的渲染结果，优先读取渲染缓存；调用方负责校验访问权限
+func (s *EditService) renderCurrent(img *models.Image) ([]byte, string, error) {
+	if img.EditVersionID == nil {
+		data, err := os.ReadFile(img.FilePath)
+		return data, img.MimeType, err
+	}
+
+	ops, err := s.versionOps(img.EditVersionID)
+	if err != nil {
+		return nil, "", err
+	}
+	out := editor.OutputOf(ops, img.StoredFilename)
+	path := s.renderPath(img, *img.EditVersionID, out)
+	if data, err := os.ReadFile(path); err == nil {
+		return data, getMimeType(out.Format), nil
+	}
+	r, err := s.render(img, ops)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.writeRender(img, *img.EditVersionID, r); err != nil {
+		return nil, "", err
+	}
+	return r.data, getMimeType(out.Format), nil
+}
+
+// Rendition 返回图片当前版本缩小到size以内的JPEG，不放大；结果缓存在StorageDir/renditions下
+// 用于幻灯片等按屏幕尺寸加载的场景，动图只取第一帧
+func (s *EditService) Rendition(imageID uint, size int) ([]byte, error) {
+	img, err := s.images.getRaw(imageID)
+	if err != nil {
+		return nil, err
+	}
+	var versionID uint
+	if img.EditVersionID != nil {
+		versionID = *img.EditVersionID
+	}
+	path := filepath.Join(s.cfg.StorageDir, "renditions", fmt.Sprintf("%d_%d_%d.jpg", img.ID, versionID, size))
+	if data, err := os.ReadFile(path); err == nil {
+		return data, nil
+	}
+
+	full, err := s.currentRendition(img)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := editor.Encode(&buf, imaging.Fit(full, size, size, imaging.Lanczos), editor.Output{Format: editor.FormatJPEG, Quality: 88}); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// watermarked 在图片当前版本上临时叠加水印预设，与导出使用同一组操作，用于带水印的公开分享
+// size大于0时返回缩小到size以内的JPEG用于浏览，否则按当前版本的输出格式返回完整图片，动图逐帧叠加
+// 返回: 文件内容、输出格式和错误信息
+func (s *EditService) watermarked(img *models.Image, mark *models.Watermark, size int) ([]byte, string, error) {
+	ops, err := s.versionOps(img.EditVersionID)
+	if err != nil {
+		return nil, "", err
+	}
+	ops = withWatermark(ops, mark)
+	if size <= 0 {
+		r, err := s.render(img, ops)
+		if err != nil {
+			return nil, "", err
+		}
+		return r.data, r.out.Format, nil
+	}
+
+	// 先在原尺寸上叠加水印再缩小，水印在浏览和下载时的比例一致
+	original, err := s.decodeOriginal(img)
+	if err != nil {
+		return nil, "", err
+	}
+	rendered, err := editor.Apply(original, ops)
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := editor.Encode(&buf, imaging.Fit(rendered, size, size, imaging.Lanczos), editor.Output{Format: editor.FormatJPEG, Quality: 88}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), editor.FormatJPEG, nil
+}
+
+// withWatermark 把水印预设作为临时操作追加到操作列表之后
+func withWatermark(ops []editor.Operation, mark *models.Watermark) []editor.Operation {
+	spec := watermarkSpec(mark)
+	return append(ops, editor.Operation{Type: editor.OpWatermark, WatermarkID: mark.ID, Watermark: &spec})
+}
+
+// checkEditable 视频只以封面帧作为图片内容，编辑封面没有意义；Live Photo可以编辑静态部分
+func checkEditable(img *models.Image) error {
+	if img.MediaType == models.MediaVideo {
+		return errors.New("视频不支持编辑")
+	}
+	return nil
+}
+
+// rendition 按操作列表渲染并编码后的结果
+type rendition struct {
+	data     []byte        // 按输出格式编码后的文件内容
+	first    image.Image   // 渲染结果，动图为第一帧；用于记录尺寸和生成静态缩略图
+	out      editor.Output // 输出格式
+	animated bool          // 是否保留了动画
+}
+
+// render 对原图执行操作列表并按输出格式编码
+// 原图是动图且输出格式为GIF或WebP时逐帧执行操作、保留动画；转换为其他格式或动图过大时只处理第一帧
+func (s *EditService) render(img *models.Image, ops []editor.Operation) (*rendition, error) {
+	out := editor.OutputOf(ops, img.StoredFilename)
+	if img.FrameCount > 1 && (out.Format == editor.FormatGIF || out.Format == editor.FormatWebP) {
+		r, err := s.renderAnimation(img, ops, out)
+		if err != nil && !errors.Is(err, animation.ErrTooLarge) {
+			return nil, err
+		}
+		if r != nil {
+			return r, nil
+		}
+		log.Printf("图片 %d 无法逐帧处理，只保留第一帧: %v", img.ID, err)
+	}
+
+	original, err := s.decodeOriginal(img)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := editor.Apply(original, ops)
+	if err != nil {
+		return nil, err
+	}
+	buff := &bytes.Buffer{}
+	if err := editor.Encode(buff, rendered, out); err != nil {
+		return nil, err
+	}
+	return &rendition{data: buff.Bytes(), first: rendered, out: out}, nil
+}
+
+// renderAnimation 逐帧执行操作列表并编码为动图，原图实际不是动图时返回nil
+func (s *EditService) renderAnimation(img *models.Image, ops []editor.Operation, out editor.Output) (*rendition, error) {
+	data, err := os.ReadFile(img.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	anim, err := animation.Decode(data, editor.FormatFromFilename(img.StoredFilename))
+	if err != nil || anim == nil {
+		return nil, err
+	}
+	mapped, err := anim.Map(func(frame image.Image) (image.Image, error) {
+		return editor.Apply(frame, ops)
+	})
+	if err != nil {
+		return nil, err
+	}
+	buff := &bytes.Buffer{}
+	if err := animation.Encode(buff, mapped, out.Format); err != nil {
+		return nil, err
+	}
+	return &rendition{data: buff.Bytes(), first: mapped.Frames[0], out: out, animated: true}, nil
+}
+
+// refreshThumbnail 用渲染结果刷新缩略图，保留了动画时生成动态缩略图
+func (s *EditService) refreshThumbnail(img *models.Image, r *rendition) error {
+	if r.animated {
+		return s.images.generateThumbnail(img.ID, bytes.NewReader(r.data))
+	}
+	return s.images.saveThumbnail(img.ID, r.first)
+}
+
+// renderImage 渲染图片当前版本的静态结果（动图为第一帧），同时返回使用的操作列表；未编辑时返回解码后的原图
+func (s *EditService) renderImage(img *models.Image) (image.Image, []editor.Operation, error) {
+	original, err := s.decodeOriginal(img)
+	if err != nil {
+		return nil, nil, err
+	}
+	ops, err := s.versionOps(img.EditVersionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rendered, err := editor.Apply(original, ops)
+	return rendered, ops, err
+}
+
+// versionOps 读取版本的完整操作列表，versionID为空时返回空列表
+func (s *EditService) versionOps(versionID *uint) ([]editor.Operation, error) {
+	if versionID == nil {
+		return nil, nil
+	}
+	var version models.ImageVersion
+	if err := s.db.First(&version, *versionID).Error; err != nil {
+		return nil, err
+	}
+	var ops []editor.Operation
+	if err := json.Unmarshal(version.Operations, &ops); err != nil {
+		return nil, fmt.Errorf("版本 %d 的操作列表无效: %v", version.ID, err)
+	}
+	return ops, nil
+}
+
+// decodeOriginal 解码原图，按EXIF方向摆正，与浏览器中看到的方向一致；动图只解码第一帧
+func (s *EditService) decodeOriginal(img *models.Image) (image.Image, error) {
+	decoded, err := imaging.Open(img.FilePath, imaging.AutoOrientation(true))
+	if err == nil || img.FrameCount <= 1 {
+		return decoded, err
+	}
+	// WebP动图无法直接解码，由动图解码器合成第一帧
+	data, readErr := os.ReadFile(img.FilePath)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return decodeFirstFrame(data)
+}
+
+// renderPath 渲染缓存文件路径，扩展名为输出格式
+func (s *EditService) renderPath(img *models.Image, versionID uint, out editor.Output) string {
+	return filepath.Join(s.cfg.StorageDir, "renders", fmt.Sprintf("%d_%d.%s", img.ID, versionID, out.Format))
+}
+
+// writeRender 把编码后的渲染结果写入缓存文件
+func (s *EditService) writeRender(img *models.Image, versionID uint, r *rendition) error {
+	path := s.renderPath(img, versionID, r.out)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, r.data, 0o644)
+}
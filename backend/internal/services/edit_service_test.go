@@ -0,0 +1,236 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"sync"
+	"testing"
+
+	"image-manager/internal/config"
+	"image-manager/internal/editor"
+	"image-manager/internal/models"
+
+	"github.com/disintegration/imaging"
+	"gorm.io/gorm"
+)
+
+// newEditTestImage 创建一张40x20的JPEG图片
+func newEditTestImage(t *testing.T, db *gorm.DB, m Member) *models.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.New(40, 20, color.White), imaging.JPEG); err != nil {
+		t.Fatal(err)
+	}
+	return newTestImage(t, db, m, models.Image{
+		StoredFilename: "100_photo.jpg",
+		FilePath:       writeTestFile(t, "100_photo.jpg", buf.Bytes()),
+		MimeType:       "image/jpeg",
+		Width:          40,
+		Height:         20,
+	})
+}
+
+func TestEditStack(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.Config{StorageDir: t.TempDir(), ThumbnailWidth: 16, ThumbnailHeight: 16}
+	images := NewImageService(db, cfg, NewTagService(db), nil)
+	edits := NewEditService(db, cfg, images, NewWatermarkService(db, cfg))
+	alice := newTestMember(t, db, "alice")
+	img := newEditTestImage(t, db, alice)
+
+	rotate := []editor.Operation{{Type: editor.OpRotate, Angle: 90}}
+	crop := []editor.Operation{{Type: editor.OpCrop, Width: 10, Height: 10}}
+
+	v1, err := edits.Apply(alice, img.ID, rotate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.ParentID != nil || v1.Width != 20 || v1.Height != 40 {
+		t.Errorf("v1 = parent %v, %dx%d; want a root version of 20x40", v1.ParentID, v1.Width, v1.Height)
+	}
+	v2, err := edits.Apply(alice, img.ID, crop, "裁剪")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.ParentID == nil || *v2.ParentID != v1.ID || v2.Label != "裁剪" || v2.Width != 10 {
+		t.Errorf("v2 = %+v, want a 10px child of v1", v2)
+	}
+	ops, err := edits.versionOps(&v2.ID)
+	if err != nil || len(ops) != 2 {
+		t.Fatalf("v2 operations = %v, %v; want rotate and crop", ops, err)
+	}
+
+	current := func() *uint {
+		t.Helper()
+		history, err := edits.History(alice, img.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return history.CurrentVersionID
+	}
+	is := func(got *uint, want *models.ImageVersion) bool {
+		if want == nil {
+			return got == nil
+		}
+		return got != nil && *got == want.ID
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+		want *models.ImageVersion
+	}{
+		{"undo to v1", func() error { _, err := edits.Undo(alice, img.ID); return err }, v1},
+		{"undo to original", func() error { _, err := edits.Undo(alice, img.ID); return err }, nil},
+		{"redo to v1", func() error { _, err := edits.Redo(alice, img.ID); return err }, v1},
+		{"redo to v2", func() error { _, err := edits.Redo(alice, img.ID); return err }, v2},
+		{"revert", func() error { _, err := edits.Revert(alice, img.ID); return err }, nil},
+		{"checkout v2", func() error { _, err := edits.Checkout(alice, img.ID, v2.ID); return err }, v2},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := current(); !is(got, step.want) {
+			t.Fatalf("%s: current version = %v", step.name, got)
+		}
+	}
+	if _, err := edits.Redo(alice, img.ID); err == nil {
+		t.Error("redo past the newest version should fail")
+	}
+
+	// 撤销后再编辑产生分支，重做进入最近创建的分支
+	if _, err := edits.Undo(alice, img.ID); err != nil {
+		t.Fatal(err)
+	}
+	v3, err := edits.Apply(alice, img.ID, []editor.Operation{{Type: editor.OpFlip, Direction: "horizontal"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3.ParentID == nil || *v3.ParentID != v1.ID {
+		t.Errorf("v3 parent = %v, want v1", v3.ParentID)
+	}
+	if _, err := edits.Undo(alice, img.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := edits.Redo(alice, img.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := current(); !is(got, v3) {
+		t.Errorf("redo after branching = %v, want v3", got)
+	}
+	history, err := edits.History(alice, img.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Versions) != 3 || !history.CanUndo || history.CanRedo {
+		t.Errorf("history = %d versions, undo %v, redo %v", len(history.Versions), history.CanUndo, history.CanRedo)
+	}
+
+	// 渲染结果是当前版本：旋转后为20x40
+	data, _, err := edits.Render(alice, img.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := decoded.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Errorf("rendered %dx%d, want 20x40", b.Dx(), b.Dy())
+	}
+}
+
+func TestEditApplyConcurrent(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.Config{StorageDir: t.TempDir(), ThumbnailWidth: 16, ThumbnailHeight: 16}
+	images := NewImageService(db, cfg, NewTagService(db), nil)
+	edits := NewEditService(db, cfg, images, NewWatermarkService(db, cfg))
+	alice := newTestMember(t, db, "alice")
+	img := newEditTestImage(t, db, alice)
+
+	const workers = 6
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = edits.Apply(alice, img.ID, []editor.Operation{{Type: editor.OpRotate, Angle: 90}}, "")
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrEditConflict) {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+
+	// 保存下来的版本必须是一条链：每个版本的父版本都不同，当前版本是链的末端
+	var versions []models.ImageVersion
+	db.Where("image_id = ?", img.ID).Find(&versions)
+	var current models.Image
+	db.First(&current, img.ID)
+	parents := map[uint]bool{}
+	for _, v := range versions {
+		var parent uint
+		if v.ParentID != nil {
+			parent = *v.ParentID
+		}
+		if parents[parent] {
+			t.Errorf("two versions share parent %d; a concurrent edit was lost", parent)
+		}
+		parents[parent] = true
+	}
+	if len(versions) == 0 || current.EditVersionID == nil || parents[*current.EditVersionID] {
+		t.Errorf("current version %v is not the end of the %d-version chain", current.EditVersionID, len(versions))
+	}
+
+	// 删除图片后不再保留它的锁
+	images.AddProcessor(edits)
+	if err := images.Delete(alice, img.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := edits.locks.Load(img.ID); ok {
+		t.Error("the lock of a deleted image should be released")
+	}
+}
+
+func TestEditStackPermissions(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.Config{StorageDir: t.TempDir(), ThumbnailWidth: 16, ThumbnailHeight: 16}
+	images := NewImageService(db, cfg, NewTagService(db), nil)
+	edits := NewEditService(db, cfg, images, NewWatermarkService(db, cfg))
+	alice := newTestMember(t, db, "alice")
+	bob := newTestMember(t, db, "bob")
+	img := newEditTestImage(t, db, alice)
+	video := newTestImage(t, db, alice, models.Image{MediaType: models.MediaVideo})
+	rotate := []editor.Operation{{Type: editor.OpRotate, Angle: 90}}
+
+	viewer := alice
+	viewer.Role = models.RoleViewer
+	tests := []struct {
+		name    string
+		m       Member
+		imageID uint
+		ops     []editor.Operation
+	}{
+		{"viewer", viewer, img.ID, rotate},
+		{"other workspace", bob, img.ID, rotate},
+		{"video", alice, video.ID, rotate},
+		{"no operations", alice, img.ID, nil},
+		{"invalid operation", alice, img.ID, []editor.Operation{{Type: "explode"}}},
+	}
+	for _, tt := range tests {
+		if _, err := edits.Apply(tt.m, tt.imageID, tt.ops, ""); err == nil {
+			t.Errorf("%s: Apply should fail", tt.name)
+		}
+	}
+	if _, err := edits.Undo(viewer, img.ID); err == nil {
+		t.Error("viewer should not undo")
+	}
+	if _, _, err := edits.Render(bob, img.ID); err == nil {
+		t.Error("another workspace should not render the image")
+	}
+}
@@ -0,0 +1,43 @@
+// Package services 提供业务逻辑层的服务实现
+// favorite_service.go 实现了图片收藏：每个用户单独收藏图片，收藏只对本人可见；
+// 收藏的图片列表通过ImageService.List的favorited筛选条件获取
+package services
+
+import (
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FavoriteService 收藏服务结构体
+type FavoriteService struct {
+	db *gorm.DB
+}
+
+// NewFavoriteService 创建收藏服务实例
+func NewFavoriteService(db *gorm.DB) *FavoriteService {
+	return &FavoriteService{db: db}
+}
+
+// Add 收藏工作区中的图片，任何成员都可以收藏；重复收藏不报错
+func (s *FavoriteService) Add(m Member, imageID uint) error {
+	if err := checkWorkspaceImage(s.db, m, models.RoleViewer, imageID); err != nil {
+		return err
+	}
+	favorite := models.ImageFavorite{ImageID: imageID, UserID: m.UserID}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&favorite).Error
+}
+
+// Remove 取消收藏；没有收藏时不报错
+func (s *FavoriteService) Remove(m Member, imageID uint) error {
+	if err := checkWorkspaceImage(s.db, m, models.RoleViewer, imageID); err != nil {
+		return err
+	}
+	return s.db.Delete(&models.ImageFavorite{}, "image_id = ? AND user_id = ?", imageID, m.UserID).Error
+}
+
+// removeImageFavorites 在删除图片的事务中删除所有用户对该图片的收藏
+func removeImageFavorites(tx *gorm.DB, imageID uint) error {
+	return tx.Delete(&models.ImageFavorite{}, "image_id = ?", imageID).Error
+}
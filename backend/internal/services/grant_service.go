@@ -0,0 +1,323 @@
+// Package services 提供业务逻辑层的服务实现
+// grant_service.go 实现了用户之间的分享授权：所有者把选定的图片或一个相册授权给另一个用户，
+// 对方接受后可以浏览；授权为copy时可以把其中的图片导入到自己当前的工作区。导入不再需要对方的账号密码
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GrantService 分享授权服务结构体
+type GrantService struct {
+	db     *gorm.DB
+	auth   *AuthService  // 按用户名或邮箱查找接收者
+	images *ImageService // 列出授权范围内的图片并执行导入
+	albums *AlbumService // 相册授权的相册
+	tags   *TagService   // 导入时为接收者创建标签
+}
+
+// NewGrantService 创建分享授权服务实例
+func NewGrantService(db *gorm.DB, auth *AuthService, images *ImageService, albums *AlbumService, tags *TagService) *GrantService {
+	return &GrantService{db: db, auth: auth, images: images, albums: albums, tags: tags}
+}
+
+// Outgoing 获取用户授权给他人的记录，按创建时间倒序
+func (s *GrantService) Outgoing(userID uint) ([]models.ShareGrant, error) {
+	return s.list("owner_id = ?", userID)
+}
+
+// Incoming 获取他人授权给用户的记录，按创建时间倒序
+func (s *GrantService) Incoming(userID uint) ([]models.ShareGrant, error) {
+	return s.list("recipient_id = ?", userID)
+}
+
+// Create 把当前工作区中的图片或相册授权给他人，等待接收者接受；需要editor及以上角色
+func (s *GrantService) Create(m Member, req dto.GrantRequest) (*models.ShareGrant, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	recipient, err := s.auth.FindUser(strings.TrimSpace(req.Recipient))
+	if err != nil {
+		return nil, err
+	}
+	if recipient.ID == m.UserID {
+		return nil, errors.New("不能授权给自己")
+	}
+	grant := models.ShareGrant{
+		OwnerID:     m.UserID,
+		WorkspaceID: m.WorkspaceID,
+		RecipientID: recipient.ID,
+		Access:      req.Access,
+		Status:      models.GrantPending,
+		Message:     strings.TrimSpace(req.Message),
+	}
+	if grant.Access == "" {
+		grant.Access = models.GrantRead
+	}
+
+	ids := uniqueIDs(req.ImageIDs)
+	switch {
+	case len(ids) > 0 && req.AlbumID != 0:
+		return nil, errors.New("图片和相册只能选择一个")
+	case len(ids) > 0:
+		var count int64
+		if err := s.db.Model(&models.Image{}).Where("workspace_id = ? AND id IN ?", m.WorkspaceID, ids).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if int(count) != len(ids) {
+			return nil, errors.New("部分图片不存在")
+		}
+		grant.Kind = models.GrantImages
+		for _, id := range ids {
+			grant.Images = append(grant.Images, models.ShareGrantImage{ImageID: id})
+		}
+	case req.AlbumID != 0:
+		if _, err := s.albums.Get(m, req.AlbumID); err != nil {
+			return nil, err
+		}
+		grant.Kind, grant.AlbumID = models.GrantAlbum, req.AlbumID
+	default:
+		return nil, errors.New("请选择要授权的图片或相册")
+	}
+
+	if err := s.db.Create(&grant).Error; err != nil {
+		return nil, err
+	}
+	return s.get("id = ?", grant.ID)
+}
+
+// Respond 接收者接受或拒绝授权；拒绝后可以再接受
+func (s *GrantService) Respond(userID, id uint, accept bool) (*models.ShareGrant, error) {
+	grant, err := s.get("id = ? AND recipient_id = ?", id, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := models.GrantDeclined
+	if accept {
+		status = models.GrantAccepted
+	}
+	if err := s.db.Model(&models.ShareGrant{}).Where("id = ?", grant.ID).Updates(map[string]interface{}{
+		"status":       status,
+		"responded_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return s.get("id = ?", grant.ID)
+}
+
+// Delete 所有者撤销授权，或接收者移除授权；已导入的图片不受影响
+func (s *GrantService) Delete(userID, id uint) error {
+	grant, err := s.get("id = ? AND (owner_id = ? OR recipient_id = ?)", id, userID, userID)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.ShareGrantImage{}, "grant_id = ?", grant.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ShareGrant{}, "id = ?", grant.ID).Error
+	})
+}
+
+// Images 接收者分页浏览已接受的授权中的图片；所有者也可以查看自己授权出去的内容
+func (s *GrantService) Images(userID, id uint, page, pageSize int) ([]models.Image, int64, error) {
+	grant, err := s.get("id = ? AND (owner_id = ? OR recipient_id = ?)", id, userID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if grant.RecipientID == userID && grant.Status != models.GrantAccepted {
+		return nil, 0, errors.New("请先接受授权")
+	}
+	filters, err := s.grantFilters(grant)
+	if err != nil {
+		return nil, 0, err
+	}
+	images, total, err := s.images.List(grantSource(grant), filters, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 评论和收藏属于来源工作区内部的讨论，文件在服务器上的存储路径也不向接收者展示
+	for i := range images {
+		images[i].CommentCount, images[i].Favorited = 0, false
+		images[i].FilePath = ""
+	}
+	return images, total, nil
+}
+
+// Import 接收者把授权范围内的图片导入到当前工作区，需要授权为copy，且在当前工作区中是editor及以上角色
+// 不在授权范围内的图片ID被忽略
+func (s *GrantService) Import(m Member, id uint, imageIDs []uint) ([]models.Image, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	grant, err := s.get("id = ? AND recipient_id = ?", id, m.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if grant.Status != models.GrantAccepted {
+		return nil, errors.New("请先接受授权")
+	}
+	if grant.Access != models.GrantCopy {
+		return nil, errors.New("该授权只允许浏览，不能导入")
+	}
+	allowed, err := s.allowed(grant, imageIDs)
+	if err != nil {
+		return nil, err
+	}
+	return s.images.ImportImages(m, grant.WorkspaceID, allowed, s.tags)
+}
+
+// Thumbnail 授权中图片的缩略图，供接收者浏览授权时使用；所有者也可以查看
+func (s *GrantService) Thumbnail(userID, id, imageID uint) (*models.Thumbnail, error) {
+	grant, err := s.get("id = ? AND (owner_id = ? OR recipient_id = ?)", id, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if grant.RecipientID == userID && grant.Status != models.GrantAccepted {
+		return nil, errors.New("请先接受授权")
+	}
+	allowed, err := s.allowed(grant, []uint{imageID})
+	if err != nil {
+		return nil, err
+	}
+	return s.images.GetThumbnail(grantSource(grant), allowed[0])
+}
+
+// allowed 返回imageIDs中在授权范围内、且仍在来源工作区中的图片ID，一张都没有时返回错误
+func (s *GrantService) allowed(grant *models.ShareGrant, imageIDs []uint) ([]uint, error) {
+	ids := uniqueIDs(imageIDs)
+	if grant.Kind == models.GrantImages {
+		ids = intersectIDs(grantedImageIDs(grant), ids)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("所选图片不在授权范围内")
+	}
+	filters, err := s.grantFilters(grant)
+	if err != nil {
+		return nil, err
+	}
+	// 在授权范围内再按所选ID筛选
+	filters["ids"] = JoinIDs(ids)
+	allowed, err := s.images.ListIDs(grantSource(grant), filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("所选图片不在授权范围内")
+	}
+	return allowed, nil
+}
+
+// grantFilters 授权范围对应的图片列表筛选条件，相册被删除后授权随之失效
+func (s *GrantService) grantFilters(grant *models.ShareGrant) (map[string]string, error) {
+	if grant.Kind == models.GrantAlbum {
+		album, err := s.albums.Get(grantSource(grant), grant.AlbumID)
+		if err != nil {
+			return nil, errors.New("授权的相册已被删除")
+		}
+		if album.Kind == models.AlbumSmart {
+			return AlbumFilters(album), nil
+		}
+		return map[string]string{"album": strconv.FormatUint(uint64(album.ID), 10)}, nil
+	}
+	return map[string]string{"ids": JoinIDs(grantedImageIDs(grant))}, nil
+}
+
+// grantSource 以所有者的身份只读访问授权的来源工作区
+func grantSource(grant *models.ShareGrant) Member {
+	return viewerOf(grant.OwnerID, grant.WorkspaceID)
+}
+
+// grantedImageIDs 选定图片的授权中的图片ID
+func grantedImageIDs(grant *models.ShareGrant) []uint {
+	ids := make([]uint, len(grant.Images))
+	for i, item := range grant.Images {
+		ids[i] = item.ImageID
+	}
+	return ids
+}
+
+// list 按条件列出授权并填充用户名和图片数量
+func (s *GrantService) list(query string, args ...interface{}) ([]models.ShareGrant, error) {
+	var grants []models.ShareGrant
+	if err := s.db.Where(query, args...).Preload("Images").Order("created_at DESC").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	if err := s.fill(grants); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// get 按条件获取一条授权
+func (s *GrantService) get(query string, args ...interface{}) (*models.ShareGrant, error) {
+	var grant models.ShareGrant
+	if err := s.db.Where(query, args...).Preload("Images").First(&grant).Error; err != nil {
+		return nil, errors.New("授权不存在")
+	}
+	grants := []models.ShareGrant{grant}
+	if err := s.fill(grants); err != nil {
+		return nil, err
+	}
+	return &grants[0], nil
+}
+
+// fill 填充所有者和接收者的用户名，以及授权范围内的图片数量
+func (s *GrantService) fill(grants []models.ShareGrant) error {
+	var userIDs []uint
+	for _, grant := range grants {
+		userIDs = append(userIDs, grant.OwnerID, grant.RecipientID)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var users []models.User
+	if err := s.db.Select("id", "username").Where("id IN ?", uniqueIDs(userIDs)).Find(&users).Error; err != nil {
+		return err
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	for i := range grants {
+		grants[i].OwnerName = names[grants[i].OwnerID]
+		grants[i].RecipientName = names[grants[i].RecipientID]
+		if grants[i].Kind == models.GrantImages {
+			grants[i].ImageCount = len(grants[i].Images)
+			continue
+		}
+		// 智能相册的数量来自缓存，相册已删除时为0
+		if album, err := s.albums.Get(grantSource(&grants[i]), grants[i].AlbumID); err == nil {
+			grants[i].ImageCount = album.ImageCount
+		}
+	}
+	return nil
+}
+
+// removeImageFromGrants 在删除图片的事务中把图片移出所有授权
+func removeImageFromGrants(tx *gorm.DB, imageID uint) error {
+	return tx.Delete(&models.ShareGrantImage{}, "image_id = ?", imageID).Error
+}
+
+// intersectIDs 返回同时出现在a和b中的ID，保持b的顺序
+func intersectIDs(a, b []uint) []uint {
+	set := make(map[uint]bool, len(a))
+	for _, id := range a {
+		set[id] = true
+	}
+	var result []uint
+	for _, id := range b {
+		if set[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"image-manager/internal/config"
+	"image-manager/internal/models"
+)
+
+func TestGrantImportCopiesMediaAndEdits(t *testing.T) {
+	db := newTestDB(t)
+	storage := t.TempDir()
+	cfg := config.Config{StorageDir: storage}
+	tags := NewTagService(db)
+	images := NewImageService(db, cfg, tags, nil)
+	grants := NewGrantService(db, NewAuthService(db, "secret"), images, NewAlbumService(db, images), tags)
+
+	alice := newTestMember(t, db, "alice")
+	bob := newTestMember(t, db, "bob")
+
+	video := newTestImage(t, db, alice, models.Image{
+		OriginalFilename: "clip.mp4",
+		StoredFilename:   "100_clip.jpg",
+		FilePath:         writeTestFile(t, "100_clip.jpg", []byte("poster")),
+		MimeType:         "image/jpeg",
+		MediaType:        models.MediaVideo,
+		DurationMs:       3000,
+		VideoFilePath:    writeTestFile(t, "100_clip.mp4", []byte("mp4 data")),
+		VideoMimeType:    "video/mp4",
+	})
+	raw := newTestImage(t, db, alice, models.Image{
+		OriginalFilename: "IMG_1.CR2",
+		StoredFilename:   "200_img_1.jpg",
+		FilePath:         writeTestFile(t, "200_img_1.jpg", []byte("working copy")),
+		MimeType:         "image/jpeg",
+		SourceFilePath:   writeTestFile(t, "200_img_1.cr2", []byte("raw data")),
+		SourceMimeType:   "image/x-canon-cr2",
+	})
+	ops := json.RawMessage(`[{"type":"rotate","angle":90}]`)
+	version := models.ImageVersion{ImageID: raw.ID, Label: "rotate", Operations: ops, Width: 30, Height: 40}
+	if err := db.Create(&version).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Model(raw).Update("edit_version_id", version.ID)
+
+	grant := models.ShareGrant{
+		OwnerID:     alice.UserID,
+		WorkspaceID: alice.WorkspaceID,
+		RecipientID: bob.UserID,
+		Kind:        models.GrantImages,
+		Access:      models.GrantCopy,
+		Status:      models.GrantAccepted,
+		Images:      []models.ShareGrantImage{{ImageID: video.ID}, {ImageID: raw.ID}},
+	}
+	if err := db.Create(&grant).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	shared, _, err := grants.Images(bob.UserID, grant.ID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range shared {
+		if img.FilePath != "" {
+			t.Errorf("%s: recipient sees the storage path %q", img.OriginalFilename, img.FilePath)
+		}
+	}
+
+	imported, err := grants.Import(bob, grant.ID, []uint{video.ID, raw.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 2 {
+		t.Fatalf("imported %d images, want 2", len(imported))
+	}
+	byName := map[string]models.Image{}
+	for _, img := range imported {
+		var stored models.Image
+		if err := db.First(&stored, img.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.WorkspaceID != bob.WorkspaceID {
+			t.Errorf("%s: workspace = %d, want %d", stored.OriginalFilename, stored.WorkspaceID, bob.WorkspaceID)
+		}
+		byName[stored.OriginalFilename] = stored
+	}
+
+	// checkCopy 导入后的文件是存储目录中的新文件，内容与源文件相同
+	checkCopy := func(label, path, source, want string) {
+		t.Helper()
+		if path == "" || path == source || !strings.HasPrefix(path, filepath.Join(storage, "originals")) {
+			t.Errorf("%s: path = %q, want a new file under the storage dir", label, path)
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != want {
+			t.Errorf("%s: content = %q, %v; want %q", label, data, err, want)
+		}
+	}
+
+	gotVideo := byName["clip.mp4"]
+	if gotVideo.MediaType != models.MediaVideo || gotVideo.VideoMimeType != "video/mp4" || gotVideo.DurationMs != 3000 {
+		t.Errorf("video metadata = %q %q %d", gotVideo.MediaType, gotVideo.VideoMimeType, gotVideo.DurationMs)
+	}
+	checkCopy("video poster", gotVideo.FilePath, video.FilePath, "poster")
+	checkCopy("video file", gotVideo.VideoFilePath, video.VideoFilePath, "mp4 data")
+	if _, file, err := images.OpenVideo(bob, gotVideo.ID); err != nil {
+		t.Errorf("OpenVideo in the recipient's workspace: %v", err)
+	} else {
+		file.Close()
+	}
+
+	gotRaw := byName["IMG_1.CR2"]
+	if gotRaw.SourceMimeType != "image/x-canon-cr2" {
+		t.Errorf("SourceMimeType = %q", gotRaw.SourceMimeType)
+	}
+	checkCopy("working copy", gotRaw.FilePath, raw.FilePath, "working copy")
+	checkCopy("raw source", gotRaw.SourceFilePath, raw.SourceFilePath, "raw data")
+	if gotRaw.EditVersionID == nil {
+		t.Fatal("edit version was not copied")
+	}
+	var gotVersion models.ImageVersion
+	if err := db.First(&gotVersion, *gotRaw.EditVersionID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if gotVersion.ImageID != gotRaw.ID || gotVersion.ParentID != nil || string(gotVersion.Operations) != string(ops) ||
+		gotVersion.Width != 30 || gotVersion.Height != 40 {
+		t.Errorf("copied version = %+v", gotVersion)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"image-manager/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建内存SQLite数据库并建好全部表
+// ImageText的全文索引是MySQL专有的，单独建表
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库的每个连接都是独立的库
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Workspace{},
+		&models.WorkspaceMember{},
+		&models.Image{},
+		&models.ImageEXIF{},
+		&models.Tag{},
+		&models.ImageTag{},
+		&models.Thumbnail{},
+		&models.SearchSession{},
+		&models.SearchMessage{},
+		&models.Person{},
+		&models.Face{},
+		&models.ImageColor{},
+		&models.ImageQuality{},
+		&models.ImageVersion{},
+		&models.Watermark{},
+		&models.Album{},
+		&models.AlbumItem{},
+		&models.Slideshow{},
+		&models.SlideshowItem{},
+		&models.RenderJob{},
+		&models.ShareLink{},
+		&models.ShareGrant{},
+		&models.ShareGrantImage{},
+		&models.ImageComment{},
+		&models.ImageAnnotation{},
+		&models.ImageFavorite{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE TABLE image_texts (image_id INTEGER PRIMARY KEY, engine TEXT, content TEXT, created_at DATETIME)").Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestMember 创建用户及其个人工作区，返回用户在个人工作区中的身份（owner）
+func newTestMember(t *testing.T, db *gorm.DB, username string) Member {
+	t.Helper()
+	user := models.User{Username: username, Email: username + "@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	workspace, err := NewWorkspaceService(db, nil).Personal(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Member{UserID: user.ID, WorkspaceID: workspace.ID, Role: models.RoleOwner}
+}
+
+// newTestImage 在成员的工作区中创建一张图片，文件内容写入临时目录
+func newTestImage(t *testing.T, db *gorm.DB, m Member, img models.Image) *models.Image {
+	t.Helper()
+	img.UserID, img.WorkspaceID = m.UserID, m.WorkspaceID
+	if img.OriginalFilename == "" {
+		img.OriginalFilename = "photo.jpg"
+	}
+	if img.MediaType == "" {
+		img.MediaType = models.MediaImage
+	}
+	if img.FilePath == "" {
+		img.FilePath = writeTestFile(t, "photo.jpg", []byte("jpeg"))
+	}
+	if err := db.Create(&img).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &img
+}
+
+// writeTestFile 在测试的临时目录中写入文件，返回路径
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,88 @@
+// Package services 提供业务逻辑层的服务实现
+// image_pipeline.go 实现了图片内容写入后的处理流水线
+// EXIF提取和缩略图生成之后，依次运行注册的处理器（如人脸检测），每个处理器失败只记录日志，不影响上传
+package services
+
+import (
+	"image"
+	"log"
+
+	"image-manager/internal/config"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ImageProcessor 图片后处理器
+// 在图片上传、替换或导入后运行，从图片内容中提取派生数据；图片删除时负责清理这些数据
+type ImageProcessor interface {
+	// Name 处理器名称，用于日志
+	Name() string
+	// Process 处理图片。decoded为解码后的图片，data为原始文件内容；
+	// 同一张图片可能被多次处理（如替换文件后），实现需要先清除该图片之前的结果
+	Process(img *models.Image, decoded image.Image, data []byte) error
+	// Remove 在删除图片的事务中清理该图片的派生数据
+	Remove(tx *gorm.DB, img *models.Image) error
+}
+
+// Library 组装好处理流水线的图片库核心服务
+// API服务器和MCP服务器都通过NewLibrary创建，保证两边写入的图片经过相同的处理与清理
+type Library struct {
+	Tags       *TagService
+	AI         *AIService
+	Images     *ImageService
+	People     *PeopleService
+	Watermarks *WatermarkService
+	Edits      *EditService
+}
+
+// NewLibrary 创建图片库核心服务并注册全部后处理器
+// 标签库变化时清理该工作区的AI查询转换缓存
+func NewLibrary(db *gorm.DB, cfg config.Config) *Library {
+	tags := NewTagService(db)
+	ai := NewAIService(cfg)
+	tags.OnChange(ai.InvalidateQueryCache)
+	images := NewImageService(db, cfg, tags, ai)
+	people := NewPeopleService(db, cfg)
+	watermarks := NewWatermarkService(db, cfg)
+	edits := NewEditService(db, cfg, images, watermarks)
+	images.AddProcessor(edits)
+	images.AddProcessor(NewPaletteService(db))
+	images.AddProcessor(NewQualityService(db))
+	images.AddProcessor(people)
+	images.AddProcessor(NewOCRService(db, cfg, ai))
+	return &Library{Tags: tags, AI: ai, Images: images, People: people, Watermarks: watermarks, Edits: edits}
+}
+
+// AddProcessor 注册图片后处理器，按注册顺序执行
+func (s *ImageService) AddProcessor(processor ImageProcessor) {
+	s.processors = append(s.processors, processor)
+}
+
+// runProcessors 对图片运行所有后处理器
+// 图片只解码一次，由所有处理器共享
+func (s *ImageService) runProcessors(img *models.Image, data []byte) {
+	if len(s.processors) == 0 {
+		return
+	}
+	decoded, err := decodeFirstFrame(data)
+	if err != nil {
+		log.Printf("后处理解码图片失败 %d: %v", img.ID, err)
+		return
+	}
+	for _, processor := range s.processors {
+		if err := processor.Process(img, decoded, data); err != nil {
+			log.Printf("图片后处理失败 %s %d: %v", processor.Name(), img.ID, err)
+		}
+	}
+}
+
+// removeProcessed 在删除图片的事务中清理所有后处理器产生的数据
+func (s *ImageService) removeProcessed(tx *gorm.DB, img *models.Image) error {
+	for _, processor := range s.processors {
+		if err := processor.Remove(tx, img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,16 +4,15 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
 	"image/jpeg"
 	_ "image/png"  // 注册 PNG 解码器，用于 image.DecodeConfig 解析PNG格式
 	_ "image/gif"  // 注册 GIF 解码器，用于 image.DecodeConfig 解析GIF格式
 	"io"
 	"log"
-	"math"
 	"mime/multipart"
 	"os"
 	"path/filepath"
@@ -21,12 +20,13 @@ import (
 	"strings"
 	"time"
 
+	"image-manager/internal/animation"
 	"image-manager/internal/config"
-	"image-manager/internal/dto"
+	"image-manager/internal/ingest"
 	"image-manager/internal/models"
+	"image-manager/internal/video"
 
 	"github.com/disintegration/imaging"  // 图片处理库，用于解码、裁剪、生成缩略图等操作
-	"github.com/lucasb-eyer/go-colorful" // 颜色处理库，用于颜色空间转换
 	"github.com/rwcarlsen/goexif/exif"   // EXIF数据解析库，用于提取图片元数据
 	_ "golang.org/x/image/bmp"           // 注册 BMP 解码器，用于 image.DecodeConfig 解析BMP格式
 	_ "golang.org/x/image/tiff"          // 注册 TIFF 解码器，用于 image.DecodeConfig 解析TIFF格式
@@ -42,6 +42,9 @@ type ImageService struct {
 	cfg  config.Config  // 应用配置信息，包含存储路径、缩略图尺寸等
 	tags *TagService    // 标签服务，用于处理图片标签相关的操作
 	ai   *AIService     // AI服务，用于图片分析和自然语言查询转换
+	processors []ImageProcessor // 图片后处理器（如人脸检测），在缩略图生成之后运行
+	converter  *ingest.Converter // 相机格式（HEIC/HEIF、RAW）转换器
+	video      *video.FFmpeg     // 视频信息读取和封面截取，未安装ffmpeg时为空
 }
 
 // NewImageService 创建图片服务实例
@@ -52,99 +55,100 @@ type ImageService struct {
 //   - ai: AI服务实例
 // 返回: ImageService指针
 func NewImageService(db *gorm.DB, cfg config.Config, tags *TagService, ai *AIService) *ImageService {
+	converter := ingest.NewConverter(cfg.HEIFConverterPath, cfg.RAWConverterPath, filepath.Join(cfg.StorageDir, "temp"))
+	if !converter.HEIFSupported() {
+		log.Printf("找不到heif-convert（%s），无法上传HEIC/HEIF图片", cfg.HEIFConverterPath)
+	}
+	if !converter.RAWDecoderAvailable() {
+		log.Printf("找不到dcraw_emu（%s），相机RAW只使用内嵌预览图", cfg.RAWConverterPath)
+	}
+	ffmpeg, err := video.New(cfg.FFmpegPath, cfg.FFprobePath, 2*time.Minute)
+	if err != nil {
+		log.Printf("%v，无法上传视频", err)
+	}
 	return &ImageService{
-		db:   db,
-		cfg:  cfg,
-		tags: tags,
-		ai:   ai,
+		db:        db,
+		cfg:       cfg,
+		tags:      tags,
+		ai:        ai,
+		converter: converter,
+		video:     ffmpeg,
 	}
 }
 
-// Upload 上传图片
-// 处理图片上传的完整流程：验证文件大小、解析图片格式、保存文件、提取EXIF信息、生成缩略图、关联标签
+// Upload 上传图片或视频
+// 处理上传的完整流程：验证文件大小、解析格式、保存文件、提取EXIF信息、生成缩略图、关联标签
 // 参数:
-//   - userID: 上传用户的ID
-//   - fileHeader: 上传的文件头信息，包含文件名、大小等
+//   - ctx: 请求上下文，用于在请求取消时中止格式转换和AI分析
+//   - m: 上传者及目标工作区，需要editor及以上角色
+//   - fileHeader: 上传的文件头信息，包含文件名、大小等；MP4/MOV视频以封面帧作为图片内容
+//   - motion: Live Photo的动态部分（MOV），与fileHeader中的静态图片合并为一项，没有时为nil
 //   - tagNames: 标签名称列表
 //   - useAI: 是否使用AI自动生成标签
 // 返回: 创建的图片模型指针和错误信息
-func (s *ImageService) Upload(userID uint, fileHeader *multipart.FileHeader, tagNames []string, useAI bool) (*models.Image, error) {
-	// 检查文件大小是否超过限制
-	if fileHeader.Size > s.cfg.MaxUploadSize {
-		return nil, errors.New("文件过大")
+func (s *ImageService) Upload(ctx context.Context, m Member, fileHeader *multipart.FileHeader, motion *multipart.FileHeader, tagNames []string, useAI bool) (*models.Image, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
 	}
-
-	// 打开上传的文件
-	src, err := fileHeader.Open()
+	videoMime, err := sniffVideo(fileHeader)
 	if err != nil {
 		return nil, err
 	}
-	defer src.Close()
 
-	// 将文件内容读取到内存缓冲区，便于后续多次使用（EXIF提取、缩略图生成都需要读取文件）
-	buffer := &bytes.Buffer{}
-	if _, err := io.Copy(buffer, src); err != nil {
-		return nil, err
+	// 视频不读入内存，直接写入磁盘后截取封面帧；图片读入内存解析
+	var imageModel *models.Image
+	var file *uploadedFile
+	var info *video.Info
+	if videoMime != "" {
+		if motion != nil {
+			return nil, errors.New("只有图片可以附带Live Photo视频")
+		}
+		imageModel, file, info, err = s.saveVideo(ctx, fileHeader, videoMime)
+	} else {
+		imageModel, file, err = s.saveImage(ctx, fileHeader)
 	}
-
-	// 使用 image.DecodeConfig 解析图片配置信息（仅读取图片头部信息，不加载完整图片到内存）
-	// 这样可以快速获取图片格式、宽度、高度等信息，性能优于完整解码
-	reader := bytes.NewReader(buffer.Bytes())
-	imgCfg, format, err := image.DecodeConfig(reader)
 	if err != nil {
-		return nil, errors.New("无法解析图片，支持的格式：JPEG, PNG, GIF, BMP, TIFF, WebP")
-	}
-
-	// 将解析到的格式字符串转换为标准MIME类型
-	mimeType := getMimeType(format)
-
-	// 生成唯一文件名：使用纳秒时间戳 + 原始文件名（经过清理处理）
-	// 纳秒时间戳确保文件名唯一，避免文件名冲突
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), sanitizeFilename(fileHeader.Filename))
-	destPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
-	// 确保目标目录存在，os.ModePerm 表示目录权限为 0777
-	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
 		return nil, err
 	}
+	imageModel.UserID, imageModel.WorkspaceID = m.UserID, m.WorkspaceID
+	mimeType := imageModel.MimeType
 
-	// 将文件内容写入磁盘，文件权限为 0644（所有者可读写，其他人只读）
-	if err := os.WriteFile(destPath, buffer.Bytes(), 0o644); err != nil {
-		return nil, err
-	}
-
-	// 创建图片记录到数据库
-	imageModel := &models.Image{
-		UserID:           userID,
-		OriginalFilename: fileHeader.Filename,
-		StoredFilename:   filename,
-		FilePath:         destPath,
-		MimeType:         mimeType,
-		FileSize:         fileHeader.Size,
-		Width:            imgCfg.Width,
-		Height:           imgCfg.Height,
+	if motion != nil {
+		if err := s.attachMotion(ctx, imageModel, motion); err != nil {
+			s.removeFiles(imageModel)
+			return nil, err
+		}
 	}
 
 	// 使用GORM的Create方法将记录插入数据库
 	if err := s.db.Create(imageModel).Error; err != nil {
+		s.removeFiles(imageModel)
 		return nil, err
 	}
 
-	// 异步提取并保存EXIF信息（如果失败只记录日志，不影响主流程）
-	// 使用 bytes.NewReader 重新创建reader，因为之前的reader已被读取
-	if err := s.extractAndSaveEXIF(imageModel.ID, bytes.NewReader(buffer.Bytes())); err != nil {
+	// 提取并保存EXIF信息（如果失败只记录日志，不影响主流程）
+	// 相机格式从原始文件中提取，工作副本不含EXIF；视频使用ffprobe读出的元数据
+	if info != nil {
+		if err := s.saveVideoMetadata(imageModel.ID, info); err != nil {
+			log.Printf("failed to save video metadata: %v", err)
+		}
+	} else if err := s.extractAndSaveEXIF(imageModel.ID, bytes.NewReader(file.exif)); err != nil {
 		log.Printf("failed to parse EXIF: %v", err)
 	}
 
 	// 异步生成缩略图（如果失败只记录日志，不影响主流程）
-	if err := s.generateThumbnail(imageModel.ID, bytes.NewReader(buffer.Bytes())); err != nil {
+	if err := s.generateThumbnail(imageModel.ID, bytes.NewReader(file.data)); err != nil {
 		log.Printf("failed to generate thumbnail: %v", err)
 	}
 
+	// 运行后处理器（人脸检测等），失败只记录日志
+	s.runProcessors(imageModel, file.data)
+
 	// 调用AI分析图片并生成标签（如果失败只记录日志，不影响主流程）
 	aiTags := []string{}
 	if useAI && s.ai != nil {
-		// 先获取用户已有的标签库，让AI优先从中选择
-		existingTags, err := s.tags.List(userID)
+		// 先获取工作区已有的标签库，让AI优先从中选择
+		existingTags, err := s.tags.List(m)
 		existingTagNames := []string{}
 		if err == nil {
 			for _, tag := range existingTags {
@@ -153,7 +157,7 @@ func (s *ImageService) Upload(userID uint, fileHeader *multipart.FileHeader, tag
 		}
 		// 调用AI分析图片，传入已有标签库
 		log.Printf("开始调用AI分析图片，已有标签库: %v", existingTagNames)
-		analyzedTags, err := s.ai.AnalyzeImage(buffer.Bytes(), mimeType, existingTagNames)
+		analyzedTags, err := s.ai.AnalyzeImage(ctx, file.data, mimeType, existingTagNames)
 		if err != nil {
 			log.Printf("AI分析图片失败: %v", err)
 		} else {
@@ -180,7 +184,7 @@ func (s *ImageService) Upload(userID uint, fileHeader *multipart.FileHeader, tag
 
 	// 如果有关键词，则关联标签到图片
 	if len(finalTags) > 0 {
-		if err := s.tags.AssignByNames(userID, imageModel.ID, finalTags); err != nil {
+		if err := s.tags.AssignByNames(m, imageModel.ID, finalTags); err != nil {
 			log.Printf("failed to assign tags: %v", err)
 		}
 	}
@@ -251,18 +255,93 @@ func (s *ImageService) extractAndSaveEXIF(imageID uint, reader io.Reader) error
 
 // generateThumbnail 生成图片缩略图
 // 使用imaging库将原图缩放并裁剪到指定尺寸，然后保存到数据库
+// GIF/WebP动图在开启AnimatedThumbs时生成GIF动态缩略图，否则取第一帧
 // 参数:
 //   - imageID: 图片ID
 //   - reader: 图片文件的读取器
 // 返回: 错误信息
 func (s *ImageService) generateThumbnail(imageID uint, reader io.Reader) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if s.cfg.AnimatedThumbs {
+		_, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err == nil {
+			anim, err := animation.Decode(data, format)
+			if err != nil {
+				log.Printf("动图解码失败，使用第一帧生成缩略图 %d: %v", imageID, err)
+			} else if anim != nil {
+				return s.saveAnimatedThumbnail(imageID, anim)
+			}
+		}
+	}
+
 	// 使用imaging库解码图片（支持多种格式：JPEG、PNG、GIF等）
-	// imaging.Decode 会将图片完整加载到内存中
-	img, err := imaging.Decode(reader)
+	// imaging.Decode 会将图片完整加载到内存中，动图只解码第一帧
+	img, err := decodeFirstFrame(data)
 	if err != nil {
 		return err
 	}
+	return s.saveThumbnail(imageID, img)
+}
+
+// maxThumbnailFrames 动态缩略图的最大帧数，帧数更多的动图均匀抽帧
+const maxThumbnailFrames = 40
+
+// saveAnimatedThumbnail 由动图生成GIF动态缩略图并保存
+func (s *ImageService) saveAnimatedThumbnail(imageID uint, anim *animation.Animation) error {
+	thumbs, err := anim.Sample(maxThumbnailFrames).Map(func(frame image.Image) (image.Image, error) {
+		return imaging.Fill(frame, s.cfg.ThumbnailWidth, s.cfg.ThumbnailHeight, imaging.Center, imaging.Lanczos), nil
+	})
+	if err != nil {
+		return err
+	}
+	buff := &bytes.Buffer{}
+	if err := animation.Encode(buff, thumbs, "gif"); err != nil {
+		return err
+	}
+	thumbnail := models.Thumbnail{
+		ImageID:  imageID,
+		Data:     buff.Bytes(),
+		Width:    s.cfg.ThumbnailWidth,
+		Height:   s.cfg.ThumbnailHeight,
+		Size:     buff.Len(),
+		MimeType: "image/gif",
+	}
+	return s.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "image_id"}}, UpdateAll: true}).Create(&thumbnail).Error
+}
+
+// decodeFirstFrame 解码图片，动图只取第一帧；WebP动图的第一帧需要通过动图解码器合成
+func decodeFirstFrame(data []byte) (image.Image, error) {
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if err == nil {
+		return img, nil
+	}
+	if _, format, cfgErr := image.DecodeConfig(bytes.NewReader(data)); cfgErr == nil && format == "webp" {
+		if anim, animErr := animation.Decode(data, format); animErr == nil && anim != nil {
+			return anim.Frames[0], nil
+		}
+	}
+	return nil, err
+}
 
+// setAnimationInfo 记录图片的帧数和动图时长
+func setAnimationInfo(img *models.Image, data []byte, format string) {
+	img.FrameCount, img.DurationMs = 1, 0
+	info, err := animation.Probe(data, format)
+	if err != nil {
+		log.Printf("读取动图帧信息失败: %v", err)
+		return
+	}
+	if info != nil && info.Frames > 1 {
+		img.FrameCount = info.Frames
+		img.DurationMs = int(info.Duration / time.Millisecond)
+	}
+}
+
+// saveThumbnail 由已解码的图片生成缩略图并保存，编辑版本切换时也用它刷新缩略图
+func (s *ImageService) saveThumbnail(imageID uint, img image.Image) error {
 	// 使用imaging.Fill方法生成缩略图
 	// Fill会按比例缩放图片，然后裁剪到指定尺寸，保持图片中心部分
 	// imaging.Center: 裁剪时保持中心对齐
@@ -284,16 +363,83 @@ func (s *ImageService) generateThumbnail(imageID uint, reader io.Reader) error {
 		Height:  s.cfg.ThumbnailHeight,      // 缩略图高度（配置中定义）
 		Size:    buff.Len(),                 // 缩略图文件大小（字节）
 	}
+	thumbnail.MimeType = "image/jpeg" // 静态缩略图统一编码为JPEG
 
-	// 使用OnConflict处理冲突：如果缩略图已存在则更新所有字段
-	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&thumbnail).Error
+	// 使用OnConflict处理冲突：如果该图片的缩略图已存在则更新所有字段
+	return s.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "image_id"}}, UpdateAll: true}).Create(&thumbnail).Error
 }
 
-func (s *ImageService) List(userID uint, filters map[string]string, page, pageSize int) ([]models.Image, int64, error) {
+// List 分页获取工作区中符合筛选条件的图片，任何成员都可以浏览
+func (s *ImageService) List(m Member, filters map[string]string, page, pageSize int) ([]models.Image, int64, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, 0, err
+	}
 	var images []models.Image
 	var total int64
 
-	baseQuery := s.db.Model(&models.Image{}).Where("images.user_id = ?", userID)
+	order, err := listOrder(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	query, err := s.buildListQuery(m, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	if query == nil {
+		return []models.Image{}, 0, nil
+	}
+
+	// 添加Preload
+	query = query.Preload("Thumbnail").Preload("Exif").Preload("Tags").Preload("Colors", orderColorsByRank).Preload("Quality")
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order(order).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&images).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := s.fillActivity(m, images); err != nil {
+		return nil, 0, err
+	}
+
+	return images, total, nil
+}
+
+// ListIDs 返回符合筛选条件的全部图片ID（不分页），排序与List相同
+// 筛选条件与List相同，用于记录对话式检索的结果集、批量删除等场景
+func (s *ImageService) ListIDs(m Member, filters map[string]string) ([]uint, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	order, err := listOrder(filters)
+	if err != nil {
+		return nil, err
+	}
+	query, err := s.buildListQuery(m, filters)
+	if err != nil {
+		return nil, err
+	}
+	if query == nil {
+		return []uint{}, nil
+	}
+
+	var ids []uint
+	if err := query.Order(order).Pluck("images.id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// buildListQuery 根据筛选条件构建图片查询（不含Preload、排序和分页）
+// 返回nil查询表示已确定没有匹配结果
+func (s *ImageService) buildListQuery(m Member, filters map[string]string) (*gorm.DB, error) {
+	workspaceID := m.WorkspaceID
+	baseQuery := s.db.Model(&models.Image{}).Where("images.workspace_id = ?", workspaceID)
+	filters = extractPersonFilter(filters)
 	
 	// 检查是否有keyword
 	hasKeyword := false
@@ -306,11 +452,27 @@ func (s *ImageService) List(userID uint, filters map[string]string, page, pageSi
 	// 检查是否有其他筛选条件（除了keyword）
 	hasOtherFilters := false
 	hasOtherFilters = hasOtherFilters || (filters["start"] != "" || filters["end"] != "")
+	hasOtherFilters = hasOtherFilters || (filters["start_date"] != "" || filters["end_date"] != "")
 	hasOtherFilters = hasOtherFilters || (filters["width_min"] != "" || filters["width_max"] != "")
 	hasOtherFilters = hasOtherFilters || (filters["height_min"] != "" || filters["height_max"] != "")
 	hasOtherFilters = hasOtherFilters || (filters["size_min"] != "" || filters["size_max"] != "")
 	hasOtherFilters = hasOtherFilters || (filters["taken_start"] != "" || filters["taken_end"] != "")
 	hasOtherFilters = hasOtherFilters || (filters["tags"] != "")
+	hasOtherFilters = hasOtherFilters || (filters["color"] != "")
+	hasOtherFilters = hasOtherFilters || hasQualityFilter(filters)
+
+	// 颜色条件无法识别时直接报错，而不是静默返回空结果
+	if colorStr := strings.TrimSpace(filters["color"]); colorStr != "" {
+		if _, err := colorFilterQuery(s.db, colorStr, filters["color_tolerance"], filters["color_ratio"]); err != nil {
+			return nil, err
+		}
+	}
+	// 画质条件同理
+	if hasQualityFilter(filters) {
+		if _, err := qualityFilterQuery(s.db, s.cfg, filters); err != nil {
+			return nil, err
+		}
+	}
 	
 	// 获取keyword_mode，默认为"or"
 	keywordMode := filters["keyword_mode"]
@@ -334,47 +496,47 @@ func (s *ImageService) List(userID uint, filters map[string]string, page, pageSi
 			if hasTagFilter {
 				// 如果包含标签筛选，先获取符合条件的图片ID列表，然后使用ID列表进行最终查询
 				// 这样可以避免GROUP BY对Preload的影响
-				tempQuery := baseQuery.Where("images.original_filename LIKE ?", "%"+keyword+"%")
-				tempQuery = s.buildOtherFiltersQuery(tempQuery, userID, filters)
+				tempQuery := s.whereKeyword(baseQuery, keyword)
+				tempQuery = s.buildOtherFiltersQuery(tempQuery, workspaceID, filters)
 				var imageIDs []uint
 				if err := tempQuery.Pluck("images.id", &imageIDs).Error; err != nil {
-					return nil, 0, err
+					return nil, err
 				}
 				if len(imageIDs) == 0 {
-					return []models.Image{}, 0, nil
+					return nil, nil
 				}
 				// 使用ID列表创建干净的查询，避免GROUP BY等子句影响Preload
-				query = s.db.Model(&models.Image{}).Where("images.user_id = ? AND images.id IN ?", userID, imageIDs)
+				query = s.db.Model(&models.Image{}).Where("images.workspace_id = ? AND images.id IN ?", workspaceID, imageIDs)
 			} else {
 				// 没有标签筛选，可以直接使用buildOtherFiltersQuery的结果
-				query = baseQuery.Where("images.original_filename LIKE ?", "%"+keyword+"%")
-				query = s.buildOtherFiltersQuery(query, userID, filters)
+				query = s.whereKeyword(baseQuery, keyword)
+				query = s.buildOtherFiltersQuery(query, workspaceID, filters)
 			}
 		} else {
 			// OR模式：关键词 OR (其他所有条件的组合)
 			// 使用子查询或分别查询然后合并ID的方式
 			// 构建keyword查询（只包含keyword条件）
 			keywordQuery := s.db.Model(&models.Image{}).
-				Where("images.user_id = ?", userID).
-				Where("images.original_filename LIKE ?", "%"+keyword+"%")
+				Where("images.workspace_id = ?", workspaceID).
+				Scopes(func(q *gorm.DB) *gorm.DB { return s.whereKeyword(q, keyword) })
 			
 			// 构建其他条件查询（作为整体，不包含keyword）
 			otherQuery := s.buildOtherFiltersQuery(
-				s.db.Model(&models.Image{}).Where("images.user_id = ?", userID),
-				userID,
+				s.db.Model(&models.Image{}).Where("images.workspace_id = ?", workspaceID),
+				workspaceID,
 				filters,
 			)
 			
 			// 获取keyword查询的图片ID
 			var keywordImageIDs []uint
 			if err := keywordQuery.Pluck("images.id", &keywordImageIDs).Error; err != nil {
-				return nil, 0, err
+				return nil, err
 			}
 			
 			// 获取其他条件查询的图片ID
 			var otherImageIDs []uint
 			if err := otherQuery.Pluck("images.id", &otherImageIDs).Error; err != nil {
-				return nil, 0, err
+				return nil, err
 			}
 			
 			// 合并去重
@@ -394,17 +556,17 @@ func (s *ImageService) List(userID uint, filters map[string]string, page, pageSi
 			
 			if len(finalImageIDs) == 0 {
 				// 没有匹配的结果
-				return []models.Image{}, 0, nil
+				return nil, nil
 			}
 			
-			// 最终查询：只根据合并后的ID列表查询，不包含任何WHERE条件（除了user_id和id IN）
+			// 最终查询：只根据合并后的ID列表查询，不包含任何WHERE条件（除了workspace_id和id IN）
 			// 创建一个全新的查询，避免之前查询中的JOIN、GROUP BY等子句影响Preload
-			query = s.db.Model(&models.Image{}).Where("images.user_id = ? AND images.id IN ?", userID, finalImageIDs)
+			query = s.db.Model(&models.Image{}).Where("images.workspace_id = ? AND images.id IN ?", workspaceID, finalImageIDs)
 		}
 	} else if hasKeyword {
 		// 只有keyword，没有其他条件
 		// 无论keyword_mode是什么，都只查询keyword匹配的（因为其他条件为空，视为true，但单独的关键词查询应该只返回匹配的）
-		query = baseQuery.Where("images.original_filename LIKE ?", "%"+keyword+"%")
+		query = s.whereKeyword(baseQuery, keyword)
 	} else if hasOtherFilters {
 		// 只有其他条件，没有keyword
 		// 检查是否包含标签筛选（标签筛选会使用GROUP BY，可能影响Preload）
@@ -412,40 +574,212 @@ func (s *ImageService) List(userID uint, filters map[string]string, page, pageSi
 		if hasTagFilter {
 			// 如果包含标签筛选，先获取图片ID列表，然后使用ID列表进行最终查询
 			// 这样可以避免GROUP BY对Preload的影响
-			otherQuery := s.buildOtherFiltersQuery(baseQuery, userID, filters)
+			otherQuery := s.buildOtherFiltersQuery(baseQuery, workspaceID, filters)
 			var otherImageIDs []uint
 			if err := otherQuery.Pluck("images.id", &otherImageIDs).Error; err != nil {
-				return nil, 0, err
+				return nil, err
 			}
 			if len(otherImageIDs) == 0 {
-				return []models.Image{}, 0, nil
+				return nil, nil
 			}
 			// 使用ID列表创建干净的查询，避免GROUP BY等子句影响Preload
-			query = s.db.Model(&models.Image{}).Where("images.user_id = ? AND images.id IN ?", userID, otherImageIDs)
+			query = s.db.Model(&models.Image{}).Where("images.workspace_id = ? AND images.id IN ?", workspaceID, otherImageIDs)
 		} else {
 			// 没有标签筛选，可以直接使用buildOtherFiltersQuery的结果
-			query = s.buildOtherFiltersQuery(baseQuery, userID, filters)
+			query = s.buildOtherFiltersQuery(baseQuery, workspaceID, filters)
 		}
 	} else {
 		// 没有任何筛选条件，返回所有图片
 		query = baseQuery
 	}
-	
-	// 添加Preload
-	query = query.Preload("Thumbnail").Preload("Exif").Preload("Tags")
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	return s.applyRestrictionFilters(query, m, filters), nil
+}
+
+// listOrder 根据 sort/order 筛选参数返回列表排序子句
+// sort 可选 created_at（默认）、sharpness、noise、brightness、position；order 可选 desc（默认）、asc；
+// 按画质排序时尚未计算画质的图片排在最后；position 是图片在album筛选条件指定的相册中的顺序，
+// 指定了相册且未指定sort时默认按它升序排列
+func listOrder(filters map[string]string) (string, error) {
+	field := strings.ToLower(strings.TrimSpace(filters["sort"]))
+	albumStr := strings.TrimSpace(filters["album"])
+	if field == "" && albumStr != "" {
+		field = "position"
+	}
+
+	order := strings.ToLower(strings.TrimSpace(filters["order"]))
+	switch order {
+	case "":
+		order = "DESC"
+		if field == "position" {
+			order = "ASC"
+		}
+	case "asc", "desc":
+		order = strings.ToUpper(order)
+	default:
+		return "", errors.New("order 只能是 asc 或 desc")
 	}
 
-	if err := query.Order("images.created_at DESC").
-		Offset((page - 1) * pageSize).
-		Limit(pageSize).
-		Find(&images).Error; err != nil {
-		return nil, 0, err
+	switch field {
+	case "", "created_at":
+		return "images.created_at " + order, nil
+	case "sharpness", "noise", "brightness":
+		sub := fmt.Sprintf("(SELECT image_qualities.%s FROM image_qualities WHERE image_qualities.image_id = images.id)", field)
+		return fmt.Sprintf("%s IS NULL, %s %s, images.created_at DESC", sub, sub, order), nil
+	case "position":
+		albumID, err := strconv.ParseUint(albumStr, 10, 64)
+		if err != nil {
+			return "", errors.New("按相册顺序排序需要指定album")
+		}
+		sub := fmt.Sprintf("(SELECT album_items.position FROM album_items WHERE album_items.album_id = %d AND album_items.image_id = images.id)", albumID)
+		return fmt.Sprintf("%s %s, images.id %s", sub, order, order), nil
+	default:
+		return "", fmt.Errorf("不支持的排序字段: %s", field)
 	}
+}
 
-	return images, total, nil
+// whereKeyword 关键词条件：匹配文件名，或匹配OCR识别出的图片文字
+func (s *ImageService) whereKeyword(query *gorm.DB, keyword string) *gorm.DB {
+	return query.Where("(images.original_filename LIKE ? OR images.id IN (?))", "%"+keyword+"%", ocrTextQuery(s.db, keyword))
+}
+
+// applyRestrictionFilters 应用限定范围的筛选条件
+// 这些条件与其他条件始终是AND关系，不受keyword_mode影响：
+//   - ids: 只在指定的图片ID中查找（逗号分隔），用于在上一轮检索结果中继续筛选
+//   - exclude_tags: 排除带有任一指定标签的图片（逗号分隔，支持中英文逗号）
+//   - person: 只查找包含指定人物的图片（人物名称或ID，逗号分隔时要求同时包含所有人物）
+//   - media: 只查找指定媒体类型（image、video、live，逗号分隔表示任一）
+//   - album: 只查找指定相册中的图片（相册ID），未指定排序时按相册中的顺序排列
+//   - has_comments: true只查找有评论的图片，false只查找没有评论的图片
+//   - has_annotations: true只查找有区域标注的图片，false只查找没有区域标注的图片
+//   - favorited: true只查找当前用户收藏的图片，false只查找当前用户未收藏的图片
+func (s *ImageService) applyRestrictionFilters(query *gorm.DB, m Member, filters map[string]string) *gorm.DB {
+	workspaceID := m.WorkspaceID
+	if idStr := strings.TrimSpace(filters["ids"]); idStr != "" {
+		ids := parseIDList(idStr)
+		if len(ids) == 0 {
+			return query.Where("1 = 0")
+		}
+		query = query.Where("images.id IN ?", ids)
+	}
+
+	if excludeStr := strings.TrimSpace(filters["exclude_tags"]); excludeStr != "" {
+		if names := parseTagString(excludeStr); len(names) > 0 {
+			query = query.Where("images.id NOT IN (?)", s.db.Table("image_tags").
+				Select("image_tags.image_id").
+				Joins("JOIN tags ON tags.id = image_tags.tag_id").
+				Where("tags.workspace_id = ? AND tags.name IN ?", workspaceID, names))
+		}
+	}
+
+	if personStr := strings.TrimSpace(filters["person"]); personStr != "" {
+		for _, person := range parseTagString(personStr) {
+			sub := s.db.Table("faces").
+				Select("faces.image_id").
+				Joins("JOIN people ON people.id = faces.person_id").
+				Where("people.workspace_id = ?", workspaceID)
+			if id, err := strconv.ParseUint(person, 10, 64); err == nil {
+				sub = sub.Where("people.id = ? OR people.name = ?", id, person)
+			} else {
+				sub = sub.Where("people.name = ?", person)
+			}
+			query = query.Where("images.id IN (?)", sub)
+		}
+	}
+
+	if mediaStr := strings.TrimSpace(filters["media"]); mediaStr != "" {
+		query = query.Where("images.media_type IN ?", parseTagString(mediaStr))
+	}
+
+	if albumStr := strings.TrimSpace(filters["album"]); albumStr != "" {
+		albumID, err := strconv.ParseUint(albumStr, 10, 64)
+		if err != nil {
+			return query.Where("1 = 0")
+		}
+		query = query.Where("images.id IN (?)", s.db.Table("album_items").
+			Select("album_items.image_id").
+			Joins("JOIN albums ON albums.id = album_items.album_id").
+			Where("albums.id = ? AND albums.workspace_id = ?", albumID, workspaceID))
+	}
+
+	if has, ok := parseBoolFilter(filters["has_comments"]); ok {
+		query = whereImageIn(query, has, s.db.Model(&models.ImageComment{}).Select("image_id"))
+	}
+	if has, ok := parseBoolFilter(filters["has_annotations"]); ok {
+		query = whereImageIn(query, has, s.db.Model(&models.ImageAnnotation{}).Select("image_id"))
+	}
+	if favorited, ok := parseBoolFilter(filters["favorited"]); ok {
+		query = whereImageIn(query, favorited, s.db.Model(&models.ImageFavorite{}).Select("image_id").Where("user_id = ?", m.UserID))
+	}
+
+	return query
+}
+
+// parseBoolFilter 解析true/false形式的筛选条件，为空或无法解析时ok为false（不筛选）
+func parseBoolFilter(value string) (b bool, ok bool) {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	return b, err == nil
+}
+
+// whereImageIn in为true时要求图片ID在子查询结果中，否则要求不在其中
+func whereImageIn(query *gorm.DB, in bool, sub *gorm.DB) *gorm.DB {
+	if in {
+		return query.Where("images.id IN (?)", sub)
+	}
+	return query.Where("images.id NOT IN (?)", sub)
+}
+
+// extractPersonFilter 将关键词中的 person:名称 语法提取为person筛选条件
+// 例如 keyword="person:小明 海边" 等价于 person="小明"、keyword="海边"；没有该语法时原样返回filters
+func extractPersonFilter(filters map[string]string) map[string]string {
+	keyword := filters["keyword"]
+	if !strings.Contains(keyword, "person:") {
+		return filters
+	}
+
+	persons := []string{}
+	if existing := strings.TrimSpace(filters["person"]); existing != "" {
+		persons = append(persons, existing)
+	}
+	rest := []string{}
+	for _, field := range strings.Fields(keyword) {
+		if name, ok := strings.CutPrefix(field, "person:"); ok {
+			if name != "" {
+				persons = append(persons, name)
+			}
+			continue
+		}
+		rest = append(rest, field)
+	}
+
+	copied := copyFilters(filters)
+	copied["keyword"] = strings.Join(rest, " ")
+	copied["person"] = strings.Join(persons, ",")
+	return copied
+}
+
+// JoinIDs 把ID列表拼接为ids筛选条件，空列表返回"0"（不匹配任何图片）
+// 空字符串表示不按ID筛选，因此不能直接用strings.Join拼接可能为空的列表
+func JoinIDs(ids []uint) string {
+	if len(ids) == 0 {
+		return "0"
+	}
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseIDList 解析逗号分隔的ID列表，忽略无效项
+func parseIDList(value string) []uint {
+	ids := []uint{}
+	for _, part := range strings.Split(value, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
 }
 
 // parseTagString 解析标签字符串，支持中英文逗号分隔
@@ -469,13 +803,21 @@ func parseTagString(tagStr string) []string {
 }
 
 // buildOtherFiltersQuery 构建除keyword外的其他筛选条件查询
-func (s *ImageService) buildOtherFiltersQuery(baseQuery *gorm.DB, userID uint, filters map[string]string) *gorm.DB {
+func (s *ImageService) buildOtherFiltersQuery(baseQuery *gorm.DB, workspaceID uint, filters map[string]string) *gorm.DB {
 	query := baseQuery
 	
-	if start, ok := filters["start"]; ok && start != "" {
+	// 上传日期范围：start/end 为列表页使用的字段，start_date/end_date 为AI转换结果使用的字段
+	start, end := filters["start"], filters["end"]
+	if start == "" {
+		start = filters["start_date"]
+	}
+	if end == "" {
+		end = filters["end_date"]
+	}
+	if start != "" {
 		query = query.Where("images.created_at >= ?", start)
 	}
-	if end, ok := filters["end"]; ok && end != "" {
+	if end != "" {
 		query = query.Where("images.created_at <= ?", end)
 	}
 
@@ -552,7 +894,7 @@ func (s *ImageService) buildOtherFiltersQuery(baseQuery *gorm.DB, userID uint, f
 		// 过滤：只保留标签库里确实存在的标签
 		if len(tagNames) > 0 {
 			var existingTags []models.Tag
-			s.db.Where("user_id = ? AND name IN ?", userID, tagNames).Find(&existingTags)
+			s.db.Where("workspace_id = ? AND name IN ?", workspaceID, tagNames).Find(&existingTags)
 			validTagNames := []string{}
 			for _, tag := range existingTags {
 				validTagNames = append(validTagNames, tag.Name)
@@ -572,35 +914,106 @@ func (s *ImageService) buildOtherFiltersQuery(baseQuery *gorm.DB, userID uint, f
 					tagAlias := fmt.Sprintf("tags_%d", i)
 					query = query.
 						Joins(fmt.Sprintf("JOIN image_tags AS %s ON images.id = %s.image_id", alias, alias)).
-						Joins(fmt.Sprintf("JOIN tags AS %s ON %s.id = %s.tag_id AND %s.user_id = ? AND %s.name = ?", 
-							tagAlias, tagAlias, alias, tagAlias, tagAlias), userID, tagName)
+						Joins(fmt.Sprintf("JOIN tags AS %s ON %s.id = %s.tag_id AND %s.workspace_id = ? AND %s.name = ?", 
+							tagAlias, tagAlias, alias, tagAlias, tagAlias), workspaceID, tagName)
 				}
 				query = query.Group("images.id").Distinct("images.id")
 			} else {
 				// OR模式：图片有其中任意一个标签即可匹配
 				query = query.
 					Joins("JOIN image_tags ON images.id = image_tags.image_id").
-					Joins("JOIN tags ON tags.id = image_tags.tag_id AND tags.user_id = ?", userID).
+					Joins("JOIN tags ON tags.id = image_tags.tag_id AND tags.workspace_id = ?", workspaceID).
 					Where("tags.name IN ?", validTagNames).
 					Group("images.id").
 					Distinct("images.id")
 			}
 		}
 	}
+
+	// 颜色筛选：匹配调色板中的颜色（十六进制+Lab容差，或颜色名称）
+	if colorStr := strings.TrimSpace(filters["color"]); colorStr != "" {
+		if sub, err := colorFilterQuery(s.db, colorStr, filters["color_tolerance"], filters["color_ratio"]); err == nil {
+			query = query.Where("images.id IN (?)", sub)
+		} else {
+			query = query.Where("1 = 0")
+		}
+	}
+
+	// 画质筛选：模糊、曝光、噪点标记及清晰度/噪点数值范围
+	if hasQualityFilter(filters) {
+		if sub, err := qualityFilterQuery(s.db, s.cfg, filters); err == nil {
+			query = query.Where("images.id IN (?)", sub)
+		} else {
+			query = query.Where("1 = 0")
+		}
+	}
 	
 	return query
 }
 
-func (s *ImageService) Get(userID, imageID uint) (*models.Image, error) {
+// Get 获取工作区中的一张图片，任何成员都可以查看
+func (s *ImageService) Get(m Member, imageID uint) (*models.Image, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	var imageModel models.Image
-	if err := s.db.Preload("Thumbnail").Preload("Exif").Preload("Tags").Where("user_id = ? AND id = ?", userID, imageID).First(&imageModel).Error; err != nil {
+	if err := s.db.Preload("Thumbnail").Preload("Exif").Preload("Tags").Preload("Colors", orderColorsByRank).Preload("Quality").Preload("Text").Where("workspace_id = ? AND id = ?", m.WorkspaceID, imageID).First(&imageModel).Error; err != nil {
+		return nil, err
+	}
+	images := []models.Image{imageModel}
+	if err := s.fillActivity(m, images); err != nil {
 		return nil, err
 	}
-	return &imageModel, nil
+	return &images[0], nil
+}
+
+// fillActivity 填充图片的评论数量，以及当前用户是否已收藏
+func (s *ImageService) fillActivity(m Member, images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(images))
+	for i := range images {
+		ids[i] = images[i].ID
+	}
+	var counts []struct {
+		ImageID uint
+		Count   int
+	}
+	if err := s.db.Model(&models.ImageComment{}).
+		Select("image_id, COUNT(*) AS count").
+		Where("image_id IN ?", ids).
+		Group("image_id").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+	var favorites []uint
+	if err := s.db.Model(&models.ImageFavorite{}).
+		Where("user_id = ? AND image_id IN ?", m.UserID, ids).
+		Pluck("image_id", &favorites).Error; err != nil {
+		return err
+	}
+	commentCounts := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		commentCounts[count.ImageID] = count.Count
+	}
+	favorited := make(map[uint]bool, len(favorites))
+	for _, id := range favorites {
+		favorited[id] = true
+	}
+	for i := range images {
+		images[i].CommentCount = commentCounts[images[i].ID]
+		images[i].Favorited = favorited[images[i].ID]
+	}
+	return nil
 }
 
-func (s *ImageService) Update(userID, imageID uint, fileHeader *multipart.FileHeader) (*models.Image, error) {
-	imageModel, err := s.Get(userID, imageID)
+// Update 替换图片文件，需要editor及以上角色
+func (s *ImageService) Update(m Member, imageID uint, fileHeader *multipart.FileHeader) (*models.Image, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	imageModel, err := s.Get(m, imageID)
 	if err != nil {
 		return nil, err
 	}
@@ -608,6 +1021,9 @@ func (s *ImageService) Update(userID, imageID uint, fileHeader *multipart.FileHe
 	if fileHeader.Size > s.cfg.MaxUploadSize {
 		return nil, errors.New("文件过大")
 	}
+	if videoMime, err := sniffVideo(fileHeader); err != nil || videoMime != "" {
+		return nil, errors.New("替换文件只支持图片")
+	}
 
 	src, err := fileHeader.Open()
 	if err != nil {
@@ -620,29 +1036,21 @@ func (s *ImageService) Update(userID, imageID uint, fileHeader *multipart.FileHe
 		return nil, err
 	}
 
-	// 先使用 image.DecodeConfig 获取格式和尺寸（需要导入相应的解码器）
-	reader := bytes.NewReader(buffer.Bytes())
-	imgCfg, format, err := image.DecodeConfig(reader)
+	// 解析格式和尺寸，HEIC/HEIF和相机RAW转换为JPEG工作副本
+	file, err := s.parseUpload(context.Background(), buffer.Bytes(), fileHeader.Filename)
 	if err != nil {
-		return nil, errors.New("无法解析图片，支持的格式：JPEG, PNG, GIF, BMP, TIFF, WebP")
+		return nil, err
 	}
 	
 	// 标准化 MIME 类型
-	mimeType := getMimeType(format)
+	mimeType := getMimeType(file.format)
 
 	// 删除旧文件
-	if err := os.Remove(imageModel.FilePath); err != nil && !os.IsNotExist(err) {
-		log.Printf("failed to remove old file: %v", err)
-	}
+	s.removeFiles(imageModel)
 
 	// 保存新文件
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), sanitizeFilename(fileHeader.Filename))
-	destPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
-	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
-		return nil, err
-	}
-
-	if err := os.WriteFile(destPath, buffer.Bytes(), 0o644); err != nil {
+	filename, destPath, sourcePath, err := s.saveUpload(file, fileHeader.Filename)
+	if err != nil {
 		return nil, err
 	}
 	
@@ -651,35 +1059,58 @@ func (s *ImageService) Update(userID, imageID uint, fileHeader *multipart.FileHe
 	imageModel.FilePath = destPath
 	imageModel.MimeType = mimeType
 	imageModel.FileSize = fileHeader.Size
-	imageModel.Width = imgCfg.Width
-	imageModel.Height = imgCfg.Height
+	imageModel.Width = file.config.Width
+	imageModel.Height = file.config.Height
+	// 替换为静态图片后不再是视频或Live Photo，原视频文件已随旧文件删除
+	imageModel.MediaType = models.MediaImage
+	imageModel.VideoFilePath, imageModel.VideoMimeType = "", ""
+	setSourceInfo(imageModel, file, sourcePath)
+	setAnimationInfo(imageModel, file.data, file.format)
 
 	if err := s.db.Save(imageModel).Error; err != nil {
 		return nil, err
 	}
 
 	// 更新缩略图
-	if err := s.generateThumbnail(imageModel.ID, bytes.NewReader(buffer.Bytes())); err != nil {
+	if err := s.generateThumbnail(imageModel.ID, bytes.NewReader(file.data)); err != nil {
 		log.Printf("failed to generate thumbnail: %v", err)
 	}
 
 	// 更新 EXIF
-	if err := s.extractAndSaveEXIF(imageModel.ID, bytes.NewReader(buffer.Bytes())); err != nil {
+	if err := s.extractAndSaveEXIF(imageModel.ID, bytes.NewReader(file.exif)); err != nil {
 		log.Printf("failed to parse EXIF: %v", err)
 	}
 
+	// 图片内容已变化，重新运行后处理器
+	s.runProcessors(imageModel, file.data)
+
 	return imageModel, nil
 }
 
-func (s *ImageService) Delete(userID, imageID uint) error {
-	imageModel, err := s.Get(userID, imageID)
+// Delete 删除图片及其所有派生数据；editor只能删除自己上传的图片，admin及以上可以删除任何图片
+func (s *ImageService) Delete(m Member, imageID uint) error {
+	if err := m.require(models.RoleEditor); err != nil {
+		return err
+	}
+	imageModel, err := s.Get(m, imageID)
 	if err != nil {
 		return err
 	}
+	if imageModel.UserID != m.UserID && !m.Can(models.RoleAdmin) {
+		return ErrForbidden
+	}
 
 	if err := os.Remove(imageModel.FilePath); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	for _, path := range []string{imageModel.SourceFilePath, imageModel.VideoFilePath} {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove file: %v", err)
+		}
+	}
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Delete(&models.Thumbnail{}, "image_id = ?", imageID).Error; err != nil {
@@ -691,11 +1122,141 @@ func (s *ImageService) Delete(userID, imageID uint) error {
 		if err := tx.Delete(&models.ImageTag{}, "image_id = ?", imageID).Error; err != nil {
 			return err
 		}
+		if err := removeImageFromAlbums(tx, imageID); err != nil {
+			return err
+		}
+		if err := removeImageFromSlideshows(tx, imageID); err != nil {
+			return err
+		}
+		if err := removeImageFromGrants(tx, imageID); err != nil {
+			return err
+		}
+		if err := removeImageComments(tx, imageID); err != nil {
+			return err
+		}
+		if err := removeImageAnnotations(tx, imageID); err != nil {
+			return err
+		}
+		if err := removeImageFavorites(tx, imageID); err != nil {
+			return err
+		}
+		if err := s.removeProcessed(tx, imageModel); err != nil {
+			return err
+		}
+		// 由该图片编辑另存的副本保留，只断开来源关联
+		if err := tx.Model(&models.Image{}).Where("derived_from_id = ?", imageID).Update("derived_from_id", nil).Error; err != nil {
+			return err
+		}
 		return tx.Delete(&models.Image{}, "id = ?", imageID).Error
 	})
 }
 
-func (s *ImageService) GetThumbnail(imageID uint) (*models.Thumbnail, error) {
+// DeleteBatch 批量删除图片，例如删除按画质筛选出的模糊照片
+// 先确认所有图片都在该工作区中且可以删除，再逐张删除；返回已删除的数量
+func (s *ImageService) DeleteBatch(m Member, imageIDs []uint) (int, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return 0, err
+	}
+	ids := uniqueIDs(imageIDs)
+
+	var count int64
+	if err := s.db.Model(&models.Image{}).Where("workspace_id = ? AND id IN ?", m.WorkspaceID, ids).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if int(count) != len(ids) {
+		return 0, errors.New("部分图片不存在")
+	}
+	if !m.Can(models.RoleAdmin) {
+		// editor只能删除自己上传的图片，有一张不是就整体拒绝，避免只删除一部分
+		if err := s.db.Model(&models.Image{}).Where("user_id = ? AND id IN ?", m.UserID, ids).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if int(count) != len(ids) {
+			return 0, ErrForbidden
+		}
+	}
+
+	for i, id := range ids {
+		if err := s.Delete(m, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// createDerived 把编辑结果保存为一张新图片，记录来源并继承源图片的标签和EXIF
+// 副本的文件已经按方向摆正并重新编码，不再包含EXIF，因此从源图片复制EXIF记录并把方向重置为正常
+// 参数:
+//   - m: 执行编辑的成员，成为副本的上传者
+//   - source: 源图片，需要预加载Tags和Exif
+//   - rendered: 编辑后的图片，动图为第一帧
+//   - data: 按format编码后的文件内容
+//   - format: 编码格式，如jpeg、png、webp
+//
+// 返回: 新建的图片模型指针和错误信息
+func (s *ImageService) createDerived(m Member, source *models.Image, rendered image.Image, data []byte, format string) (*models.Image, error) {
+	base := strings.TrimSuffix(source.OriginalFilename, filepath.Ext(source.OriginalFilename))
+	originalName := fmt.Sprintf("%s_edited.%s", base, format)
+	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), sanitizeFilename(originalName))
+	destPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(destPath, data, 0o644); err != nil {
+		return nil, err
+	}
+
+	sourceID := source.ID
+	derived := &models.Image{
+		UserID:           m.UserID,
+		WorkspaceID:      source.WorkspaceID,
+		OriginalFilename: originalName,
+		StoredFilename:   filename,
+		FilePath:         destPath,
+		MimeType:         getMimeType(format),
+		FileSize:         int64(len(data)),
+		Width:            rendered.Bounds().Dx(),
+		Height:           rendered.Bounds().Dy(),
+		DerivedFromID:    &sourceID,
+	}
+	setAnimationInfo(derived, data, format)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(derived).Error; err != nil {
+			return err
+		}
+		if source.Exif.ID != 0 {
+			exif := source.Exif
+			exif.ID = 0
+			exif.ImageID = derived.ID
+			exif.Orientation = 1
+			if err := tx.Create(&exif).Error; err != nil {
+				return err
+			}
+		}
+		for _, tag := range source.Tags {
+			if err := tx.Create(&models.ImageTag{ImageID: derived.ID, TagID: tag.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(destPath)
+		return nil, err
+	}
+
+	if err := s.generateThumbnail(derived.ID, bytes.NewReader(data)); err != nil {
+		log.Printf("failed to generate thumbnail: %v", err)
+	}
+	s.runProcessors(derived, data)
+	return s.Get(m, derived.ID)
+}
+
+// GetThumbnail 获取工作区中图片的缩略图
+func (s *ImageService) GetThumbnail(m Member, imageID uint) (*models.Thumbnail, error) {
+	if err := checkWorkspaceImage(s.db, m, models.RoleViewer, imageID); err != nil {
+		return nil, err
+	}
 	var thumb models.Thumbnail
 	if err := s.db.Where("image_id = ?", imageID).First(&thumb).Error; err != nil {
 		return nil, err
@@ -703,8 +1264,9 @@ func (s *ImageService) GetThumbnail(imageID uint) (*models.Thumbnail, error) {
 	return &thumb, nil
 }
 
-func (s *ImageService) GetFile(imageID uint) (*models.Image, []byte, error) {
-	imageModel, err := s.GetRaw(imageID)
+// GetFile 读取工作区中图片的工作副本
+func (s *ImageService) GetFile(m Member, imageID uint) (*models.Image, []byte, error) {
+	imageModel, err := s.find(m, imageID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -717,157 +1279,182 @@ func (s *ImageService) GetFile(imageID uint) (*models.Image, []byte, error) {
 	return imageModel, data, nil
 }
 
-func (s *ImageService) GetRaw(imageID uint) (*models.Image, error) {
-	var img models.Image
-	if err := s.db.Where("id = ?", imageID).First(&img).Error; err != nil {
-		return nil, err
-	}
-	return &img, nil
-}
-
-func sanitizeFilename(name string) string {
-	base := filepath.Base(name)
-	lower := strings.ToLower(base)
-	clean := strings.ReplaceAll(lower, " ", "_")
-	return strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
-			return r
-		}
-		return '-'
-	}, clean)
-}
-
-func (s *ImageService) Crop(userID, imageID uint, req dto.CropRequest) (*models.Image, error) {
-	imageModel, err := s.Get(userID, imageID)
+// GetSource 读取工作区中HEIC/HEIF或相机RAW图片的原始文件，普通图片返回错误
+func (s *ImageService) GetSource(m Member, imageID uint) (*models.Image, []byte, error) {
+	imageModel, err := s.find(m, imageID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-
-	file, err := os.Open(imageModel.FilePath)
-	if err != nil {
-		return nil, err
+	if imageModel.SourceFilePath == "" {
+		return nil, nil, errors.New("该图片没有相机原始文件")
 	}
-	defer file.Close()
 
-	img, err := imaging.Decode(file)
+	data, err := os.ReadFile(imageModel.SourceFilePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	cropped := imaging.Crop(img, image.Rect(req.X, req.Y, req.X+req.Width, req.Y+req.Height))
-	filename := fmt.Sprintf("%d_crop_%s", time.Now().UnixNano(), imageModel.StoredFilename)
-	destPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
-
-	if err := imaging.Save(cropped, destPath); err != nil {
-		return nil, err
-	}
+	return imageModel, data, nil
+}
 
-	info, err := os.Stat(destPath)
-	if err != nil {
+// find 获取工作区中的图片，不加载标签等关联数据；读取图片文件之前用它检查访问权限
+func (s *ImageService) find(m Member, imageID uint) (*models.Image, error) {
+	if err := m.require(models.RoleViewer); err != nil {
 		return nil, err
 	}
-
-	newImage := models.Image{
-		UserID:           userID,
-		OriginalFilename: "crop_" + imageModel.OriginalFilename,
-		StoredFilename:   filename,
-		FilePath:         destPath,
-		MimeType:         imageModel.MimeType,
-		FileSize:         info.Size(),
-		Width:            cropped.Bounds().Dx(),
-		Height:           cropped.Bounds().Dy(),
+	var img models.Image
+	if err := s.db.Where("id = ? AND workspace_id = ?", imageID, m.WorkspaceID).First(&img).Error; err != nil {
+		return nil, errors.New("图片不存在")
 	}
+	return &img, nil
+}
 
-	if err := s.db.Create(&newImage).Error; err != nil {
+// getRaw 按ID获取图片，不检查工作区；只供已经校验过访问权限的内部调用方使用
+func (s *ImageService) getRaw(imageID uint) (*models.Image, error) {
+	var img models.Image
+	if err := s.db.Where("id = ?", imageID).First(&img).Error; err != nil {
 		return nil, err
 	}
+	return &img, nil
+}
 
-	return &newImage, nil
+// uploadedFile 上传文件解析后的结果
+type uploadedFile struct {
+	data   []byte         // 工作副本：普通图片为上传的文件本身，相机格式为转换后的JPEG
+	config image.Config   // 工作副本的尺寸
+	format string         // 工作副本的格式，如jpeg、png
+	exif   []byte         // 用于提取EXIF的数据
+	source *ingest.Format // 上传的是HEIC/HEIF或相机RAW时非空
+	raw    []byte         // 上传的原始文件内容
 }
 
-func (s *ImageService) Adjust(userID, imageID uint, req dto.AdjustRequest) (*models.Image, error) {
-	imageModel, err := s.Get(userID, imageID)
-	if err != nil {
-		return nil, err
+// saveImage 读取上传的图片，解析格式和尺寸并写入磁盘，返回尚未入库的图片模型
+func (s *ImageService) saveImage(ctx context.Context, fileHeader *multipart.FileHeader) (*models.Image, *uploadedFile, error) {
+	// 检查文件大小是否超过限制
+	if fileHeader.Size > s.cfg.MaxUploadSize {
+		return nil, nil, errors.New("文件过大")
 	}
 
-	file, err := os.Open(imageModel.FilePath)
+	// 打开上传的文件
+	src, err := fileHeader.Open()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer file.Close()
+	defer src.Close()
 
-	img, err := imaging.Decode(file)
-	if err != nil {
-		return nil, err
+	// 将文件内容读取到内存缓冲区，便于后续多次使用（EXIF提取、缩略图生成都需要读取文件）
+	buffer := &bytes.Buffer{}
+	if _, err := io.Copy(buffer, src); err != nil {
+		return nil, nil, err
 	}
 
-	var adjusted image.Image = imaging.AdjustBrightness(img, float64(req.Brightness)/100)
-	adjusted = imaging.AdjustContrast(adjusted, float64(req.Contrast)/100)
-	adjusted = imaging.AdjustSaturation(adjusted, float64(req.Saturation)/100)
-	adjusted = adjustHue(adjusted, float64(req.Hue))
-
-	filename := fmt.Sprintf("%d_adjust_%s", time.Now().UnixNano(), imageModel.StoredFilename)
-	destPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
-
-	if err := imaging.Save(adjusted, destPath); err != nil {
-		return nil, err
+	// 解析图片格式和尺寸，HEIC/HEIF和相机RAW转换为JPEG工作副本
+	file, err := s.parseUpload(ctx, buffer.Bytes(), fileHeader.Filename)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	info, err := os.Stat(destPath)
+	// 将文件写入磁盘，相机格式同时保存原始文件和工作副本
+	filename, destPath, sourcePath, err := s.saveUpload(file, fileHeader.Filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	newImage := models.Image{
-		UserID:           userID,
-		OriginalFilename: "adjust_" + imageModel.OriginalFilename,
+	imageModel := &models.Image{
+		OriginalFilename: fileHeader.Filename,
 		StoredFilename:   filename,
 		FilePath:         destPath,
-		MimeType:         imageModel.MimeType,
-		FileSize:         info.Size(),
-		Width:            adjusted.Bounds().Dx(),
-		Height:           adjusted.Bounds().Dy(),
+		MimeType:         getMimeType(file.format),
+		FileSize:         fileHeader.Size,
+		Width:            file.config.Width,
+		Height:           file.config.Height,
+		MediaType:        models.MediaImage,
 	}
+	setSourceInfo(imageModel, file, sourcePath)
+	setAnimationInfo(imageModel, file.data, file.format)
+	return imageModel, file, nil
+}
 
-	if err := s.db.Create(&newImage).Error; err != nil {
-		return nil, err
+// parseUpload 解析上传的文件
+// 普通图片使用 image.DecodeConfig 读取格式和尺寸（仅读取图片头部信息，不加载完整图片到内存）；
+// HEIC/HEIF和相机RAW（CR2/NEF/ARW/DNG）转换为JPEG工作副本，EXIF从原始文件中提取
+func (s *ImageService) parseUpload(ctx context.Context, data []byte, filename string) (*uploadedFile, error) {
+	if source := ingest.Detect(data, filename); source != nil {
+		res, err := s.converter.Convert(ctx, data, source)
+		if err != nil {
+			return nil, fmt.Errorf("无法转换%s图片: %v", strings.ToUpper(source.Name), err)
+		}
+		log.Printf("%s图片已转换为JPEG工作副本（来源: %s）", strings.ToUpper(source.Name), res.Source)
+		return &uploadedFile{data: res.JPEG, config: res.Config, format: "jpeg", exif: res.Exif, source: source, raw: data}, nil
 	}
 
-	return &newImage, nil
+	imgCfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("无法解析图片，支持的格式：JPEG, PNG, GIF, BMP, TIFF, WebP, HEIC/HEIF, 相机RAW（CR2/NEF/ARW/DNG）")
+	}
+	return &uploadedFile{data: data, config: imgCfg, format: format, exif: data, raw: data}, nil
 }
 
-func adjustHue(img image.Image, degrees float64) image.Image {
-	if degrees == 0 {
-		return img
+// saveUpload 把上传的文件写入originals目录
+// 文件名使用纳秒时间戳 + 原始文件名（经过清理处理），确保唯一；相机格式另存原始文件，工作副本使用.jpg扩展名
+// 返回: 存储文件名、工作副本路径、原始文件路径（普通图片为空）和错误信息
+func (s *ImageService) saveUpload(file *uploadedFile, originalFilename string) (string, string, string, error) {
+	prefix := time.Now().UnixNano()
+	name := sanitizeFilename(originalFilename)
+	dir := filepath.Join(s.cfg.StorageDir, "originals")
+	// 确保目标目录存在，os.ModePerm 表示目录权限为 0777
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", "", "", err
 	}
 
-	bounds := img.Bounds()
-	dst := image.NewNRGBA(bounds)
+	sourcePath := ""
+	if file.source != nil {
+		sourcePath = filepath.Join(dir, fmt.Sprintf("%d_%s", prefix, name))
+		if err := os.WriteFile(sourcePath, file.raw, 0o644); err != nil {
+			return "", "", "", err
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ".jpg"
+	}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			colorVal, ok := colorful.MakeColor(img.At(x, y))
-			if !ok {
-				continue
-			}
-			h, s, l := colorVal.Hsl()
-			h = math.Mod(h+degrees, 360)
-			if h < 0 {
-				h += 360
-			}
-			newColor := colorful.Hsl(h, s, l)
-			_, _, _, alpha := img.At(x, y).RGBA()
-			dst.Set(x, y, color.NRGBA{
-				R: uint8(newColor.R * 255),
-				G: uint8(newColor.G * 255),
-				B: uint8(newColor.B * 255),
-				A: uint8(alpha >> 8),
-			})
+	filename := fmt.Sprintf("%d_%s", prefix, name)
+	destPath := filepath.Join(dir, filename)
+	// 文件权限为 0644（所有者可读写，其他人只读）
+	if err := os.WriteFile(destPath, file.data, 0o644); err != nil {
+		return "", "", "", err
+	}
+	return filename, destPath, sourcePath, nil
+}
+
+// setSourceInfo 记录相机原始文件的路径和类型，普通图片清空
+func setSourceInfo(img *models.Image, file *uploadedFile, sourcePath string) {
+	img.SourceFilePath, img.SourceMimeType = sourcePath, ""
+	if file.source != nil {
+		img.SourceMimeType = file.source.MimeType
+	}
+}
+
+// removeFiles 删除图片的文件（工作副本、相机原始文件和视频），失败只记录日志
+func (s *ImageService) removeFiles(img *models.Image) {
+	for _, path := range []string{img.FilePath, img.SourceFilePath, img.VideoFilePath} {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove old file: %v", err)
 		}
 	}
+}
 
-	return dst
+func sanitizeFilename(name string) string {
+	base := filepath.Base(name)
+	lower := strings.ToLower(base)
+	clean := strings.ReplaceAll(lower, " ", "_")
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, clean)
 }
 
 // getMimeType 将 imaging 格式转换为标准 MIME 类型
@@ -890,41 +1477,33 @@ func getMimeType(format string) string {
 	}
 }
 
-// GetOtherUserImages 获取其他用户的所有图片（用于导入功能）
-// 参数:
-//   - sourceUserID: 源用户ID
-// 返回: 图片列表和错误信息
-func (s *ImageService) GetOtherUserImages(sourceUserID uint) ([]models.Image, error) {
-	var images []models.Image
-	if err := s.db.Preload("Thumbnail").Preload("Tags").Where("user_id = ?", sourceUserID).Find(&images).Error; err != nil {
-		return nil, err
-	}
-	return images, nil
-}
-
 // ImportImages 导入图片
-// 从源用户复制图片到目标用户，同时复制标签、EXIF和缩略图
+// 从源工作区复制图片到目标工作区，同时复制视频、相机原始文件、当前编辑版本、标签、EXIF和缩略图；
+// 调用方负责确认有权读取这些图片（见GrantService）
 // 参数:
-//   - targetUserID: 目标用户ID（当前用户）
-//   - sourceUserID: 源用户ID（被导入的用户）
+//   - m: 导入者及目标工作区，需要editor及以上角色
+//   - sourceWorkspaceID: 源工作区ID
 //   - imageIDs: 要导入的图片ID列表
 //   - tagService: 标签服务，用于创建和关联标签
 // 返回: 导入的图片列表和错误信息
-func (s *ImageService) ImportImages(targetUserID, sourceUserID uint, imageIDs []uint, tagService *TagService) ([]models.Image, error) {
-	// 1. 获取源用户的所有标签，以便在导入时保留标签颜色
+func (s *ImageService) ImportImages(m Member, sourceWorkspaceID uint, imageIDs []uint, tagService *TagService) ([]models.Image, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	// 1. 获取源工作区的所有标签，以便在导入时保留标签颜色
 	var sourceTags []models.Tag
-	if err := s.db.Where("user_id = ?", sourceUserID).Find(&sourceTags).Error; err != nil {
-		return nil, fmt.Errorf("获取源用户标签失败: %v", err)
+	if err := s.db.Where("workspace_id = ?", sourceWorkspaceID).Find(&sourceTags).Error; err != nil {
+		return nil, fmt.Errorf("获取源工作区标签失败: %v", err)
 	}
 	sourceTagMap := make(map[string]models.Tag)
 	for _, tag := range sourceTags {
 		sourceTagMap[tag.Name] = tag
 	}
 
-	// 2. 获取目标用户现有的标签，找出需要创建的标签
+	// 2. 获取目标工作区现有的标签，找出需要创建的标签
 	var targetTags []models.Tag
-	if err := s.db.Where("user_id = ?", targetUserID).Find(&targetTags).Error; err != nil {
-		return nil, fmt.Errorf("获取目标用户标签失败: %v", err)
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).Find(&targetTags).Error; err != nil {
+		return nil, fmt.Errorf("获取目标工作区标签失败: %v", err)
 	}
 	targetTagMap := make(map[string]models.Tag)
 	for _, tag := range targetTags {
@@ -934,7 +1513,7 @@ func (s *ImageService) ImportImages(targetUserID, sourceUserID uint, imageIDs []
 	// 3. 获取要导入的图片（包含完整信息：Tags, Exif, Thumbnail）
 	var sourceImages []models.Image
 	if err := s.db.Preload("Tags").Preload("Exif").Preload("Thumbnail").
-		Where("user_id = ? AND id IN ?", sourceUserID, imageIDs).Find(&sourceImages).Error; err != nil {
+		Where("workspace_id = ? AND id IN ?", sourceWorkspaceID, imageIDs).Find(&sourceImages).Error; err != nil {
 		return nil, fmt.Errorf("获取源图片失败: %v", err)
 	}
 
@@ -942,15 +1521,16 @@ func (s *ImageService) ImportImages(targetUserID, sourceUserID uint, imageIDs []
 		return []models.Image{}, nil
 	}
 
-	// 4. 创建目标用户缺失的标签（保留源用户的标签颜色）
+	// 4. 创建目标工作区缺失的标签（保留源工作区的标签颜色）
 	for _, sourceImg := range sourceImages {
 		for _, sourceTag := range sourceImg.Tags {
 			if _, exists := targetTagMap[sourceTag.Name]; !exists {
-				// 标签不存在，需要创建，使用源用户的标签颜色
+				// 标签不存在，需要创建，使用源工作区的标签颜色
 				newTag := models.Tag{
-					UserID: targetUserID,
-					Name:   sourceTag.Name,
-					Color:  sourceTag.Color, // 保留源用户的标签颜色
+					UserID:      m.UserID,
+					WorkspaceID: m.WorkspaceID,
+					Name:        sourceTag.Name,
+					Color:       sourceTag.Color, // 保留源工作区的标签颜色
 				}
 				if err := s.db.Create(&newTag).Error; err != nil {
 					log.Printf("创建标签失败 %s: %v", sourceTag.Name, err)
@@ -960,48 +1540,50 @@ func (s *ImageService) ImportImages(targetUserID, sourceUserID uint, imageIDs []
 			}
 		}
 	}
+	tagService.notifyChanged(m.WorkspaceID)
 
 	// 5. 导入每张图片
 	importedImages := []models.Image{}
 	for _, sourceImg := range sourceImages {
-		// 读取源图片文件
+		// 读取源图片文件（视频为封面帧），导入后的后处理需要它
 		fileData, err := os.ReadFile(sourceImg.FilePath)
 		if err != nil {
 			log.Printf("读取源图片文件失败 %s: %v", sourceImg.FilePath, err)
 			continue
 		}
 
-		// 生成新的文件名
-		filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), sanitizeFilename(sourceImg.OriginalFilename))
-		destPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
-		if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
-			log.Printf("创建目录失败: %v", err)
-			continue
-		}
-
-		// 复制文件
-		if err := os.WriteFile(destPath, fileData, 0o644); err != nil {
+		// 复制工作副本、相机原始文件和视频，所有文件使用同一个新的时间戳前缀
+		paths, err := s.copyStoredFiles(sourceImg.FilePath, sourceImg.SourceFilePath, sourceImg.VideoFilePath)
+		if err != nil {
 			log.Printf("复制文件失败: %v", err)
 			continue
 		}
 
-		// 创建新的图片记录（除了CreatedAt和UpdatedAt，其他信息原样保留）
+		// 创建新的图片记录（除了CreatedAt、UpdatedAt和编辑版本，其他信息原样保留）
 		newImage := models.Image{
-			UserID:           targetUserID,
+			UserID:           m.UserID,
+			WorkspaceID:      m.WorkspaceID,
 			OriginalFilename: sourceImg.OriginalFilename,
-			StoredFilename:   filename,
-			FilePath:         destPath,
+			StoredFilename:   filepath.Base(paths[0]),
+			FilePath:         paths[0],
 			MimeType:         sourceImg.MimeType,
+			SourceFilePath:   paths[1],
+			SourceMimeType:   sourceImg.SourceMimeType,
 			FileSize:         sourceImg.FileSize,
 			Width:            sourceImg.Width,
 			Height:           sourceImg.Height,
+			FrameCount:       sourceImg.FrameCount,
+			DurationMs:       sourceImg.DurationMs,
+			MediaType:        sourceImg.MediaType,
+			VideoFilePath:    paths[2],
+			VideoMimeType:    sourceImg.VideoMimeType,
 			// CreatedAt 和 UpdatedAt 会自动设置为当前时间
 		}
 
 		// 保存图片记录
 		if err := s.db.Create(&newImage).Error; err != nil {
 			log.Printf("创建图片记录失败: %v", err)
-			os.Remove(destPath) // 清理已复制的文件
+			s.removeFiles(&newImage) // 清理已复制的文件
 			continue
 		}
 
@@ -1025,19 +1607,101 @@ func (s *ImageService) ImportImages(targetUserID, sourceUserID uint, imageIDs []
 			}
 		}
 
-		// 关联标签（使用目标用户的标签，如果标签不存在则已经创建）
+		// 关联标签（使用目标工作区的标签，如果标签不存在则已经创建）
 		tagNames := []string{}
 		for _, sourceTag := range sourceImg.Tags {
 			tagNames = append(tagNames, sourceTag.Name)
 		}
 		if len(tagNames) > 0 {
-			if err := tagService.AssignByNames(targetUserID, newImage.ID, tagNames); err != nil {
+			if err := tagService.AssignByNames(m, newImage.ID, tagNames); err != nil {
 				log.Printf("关联标签失败: %v", err)
 			}
 		}
 
+		// 人物等派生数据按工作区隔离，需要在目标工作区下重新处理
+		s.runProcessors(&newImage, fileData)
+
+		// 复制当前编辑版本作为新图片唯一的版本，编辑历史不导入；后处理会清空编辑版本，因此放在其后
+		if sourceImg.EditVersionID != nil {
+			if err := s.copyEditVersion(&newImage, *sourceImg.EditVersionID); err != nil {
+				log.Printf("复制编辑版本失败: %v", err)
+			}
+		}
+
 		importedImages = append(importedImages, newImage)
 	}
 
 	return importedImages, nil
 }
+
+// copyStoredFiles 把一张图片的已存储文件复制到originals目录，返回的新路径与参数一一对应，空路径保持为空
+// 新文件使用同一个新的时间戳前缀，前缀之后的部分与原文件相同；任何一个文件复制失败时删除已复制的文件
+func (s *ImageService) copyStoredFiles(paths ...string) ([]string, error) {
+	prefix := time.Now().UnixNano()
+	copied := make([]string, len(paths))
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		_, name, _ := strings.Cut(filepath.Base(path), "_")
+		dest := filepath.Join(s.cfg.StorageDir, "originals", fmt.Sprintf("%d_%s", prefix, name))
+		if err := copyFile(path, dest); err != nil {
+			for _, done := range copied[:i] {
+				if done != "" {
+					os.Remove(done)
+				}
+			}
+			return nil, err
+		}
+		copied[i] = dest
+	}
+	return copied, nil
+}
+
+// copyFile 复制文件，目标已存在时返回错误
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+// copyEditVersion 把源图片的编辑版本复制为图片的根版本并设为当前版本
+// 操作列表相对原图，原图已原样复制，因此可以直接使用；渲染缓存在首次访问时重新生成
+func (s *ImageService) copyEditVersion(img *models.Image, versionID uint) error {
+	var source models.ImageVersion
+	if err := s.db.First(&source, versionID).Error; err != nil {
+		return err
+	}
+	version := models.ImageVersion{
+		ImageID:    img.ID,
+		Label:      source.Label,
+		Operations: source.Operations,
+		Width:      source.Width,
+		Height:     source.Height,
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Image{}).Where("id = ?", img.ID).Update("edit_version_id", version.ID).Error; err != nil {
+			return err
+		}
+		img.EditVersionID = &version.ID
+		return nil
+	})
+}
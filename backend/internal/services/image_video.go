@@ -0,0 +1,197 @@
+// Package services 提供业务逻辑层的服务实现
+// image_video.go 实现了视频和Live Photo的上传：视频文件原样保存，用ffmpeg截取封面帧作为图片内容，
+// 缩略图、人脸检测、AI标签等都基于封面帧；Live Photo的静态图片按普通图片处理，附带的MOV作为动态部分保存
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"image-manager/internal/models"
+	"image-manager/internal/video"
+
+	"gorm.io/gorm/clause"
+)
+
+// sniffVideo 读取上传文件的开头判断是否为MP4/MOV视频，返回视频的MIME类型，不是视频时返回空字符串
+func sniffVideo(fileHeader *multipart.FileHeader) (string, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	head := make([]byte, 16)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return video.Detect(head[:n], fileHeader.Filename), nil
+}
+
+// saveVideo 把上传的视频写入磁盘，读取时长等信息并截取封面帧，返回尚未入库的图片模型
+// 图片模型的FilePath是封面帧（JPEG），视频文件保存在VideoFilePath
+func (s *ImageService) saveVideo(ctx context.Context, fileHeader *multipart.FileHeader, mimeType string) (*models.Image, *uploadedFile, *video.Info, error) {
+	if s.video == nil {
+		return nil, nil, nil, errors.New("服务器未安装ffmpeg，无法上传视频")
+	}
+	if fileHeader.Size > s.cfg.MaxVideoUploadSize {
+		return nil, nil, nil, fmt.Errorf("视频不能超过%dMB", s.cfg.MaxVideoUploadSize>>20)
+	}
+
+	prefix := time.Now().UnixNano()
+	name := sanitizeFilename(fileHeader.Filename)
+	videoPath := filepath.Join(s.cfg.StorageDir, "originals", fmt.Sprintf("%d_%s", prefix, name))
+	if err := writeUpload(fileHeader, videoPath); err != nil {
+		return nil, nil, nil, err
+	}
+
+	info, poster, err := s.probeVideo(ctx, videoPath)
+	if err != nil {
+		os.Remove(videoPath)
+		return nil, nil, nil, err
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(poster))
+	if err != nil {
+		os.Remove(videoPath)
+		return nil, nil, nil, fmt.Errorf("封面帧无效: %v", err)
+	}
+
+	filename := fmt.Sprintf("%d_%s.jpg", prefix, strings.TrimSuffix(name, filepath.Ext(name)))
+	posterPath := filepath.Join(s.cfg.StorageDir, "originals", filename)
+	if err := os.WriteFile(posterPath, poster, 0o644); err != nil {
+		os.Remove(videoPath)
+		return nil, nil, nil, err
+	}
+
+	imageModel := &models.Image{
+		OriginalFilename: fileHeader.Filename,
+		StoredFilename:   filename,
+		FilePath:         posterPath,
+		MimeType:         "image/jpeg",
+		FileSize:         fileHeader.Size,
+		Width:            cfg.Width,
+		Height:           cfg.Height,
+		FrameCount:       1,
+		DurationMs:       int(info.Duration / time.Millisecond),
+		MediaType:        models.MediaVideo,
+		VideoFilePath:    videoPath,
+		VideoMimeType:    mimeType,
+	}
+	return imageModel, &uploadedFile{data: poster, config: cfg, format: "jpeg"}, info, nil
+}
+
+// probeVideo 读取视频信息并截取封面帧
+func (s *ImageService) probeVideo(ctx context.Context, path string) (*video.Info, []byte, error) {
+	info, err := s.video.Probe(ctx, path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无法解析视频: %v", err)
+	}
+	poster, err := s.video.Poster(ctx, path, video.PosterTime(info.Duration))
+	if err != nil {
+		return nil, nil, fmt.Errorf("无法截取视频封面: %v", err)
+	}
+	return info, poster, nil
+}
+
+// attachMotion 把Live Photo的动态部分（MOV）保存到图片旁，图片变为live类型
+// 未安装ffmpeg时仍然保存视频，只是不记录时长
+func (s *ImageService) attachMotion(ctx context.Context, img *models.Image, motion *multipart.FileHeader) error {
+	mimeType, err := sniffVideo(motion)
+	if err != nil {
+		return err
+	}
+	if mimeType == "" {
+		return errors.New("Live Photo的动态部分必须是MOV或MP4视频")
+	}
+	if img.FrameCount > 1 {
+		return errors.New("动图不能附带Live Photo视频")
+	}
+	if motion.Size > s.cfg.MaxVideoUploadSize {
+		return fmt.Errorf("视频不能超过%dMB", s.cfg.MaxVideoUploadSize>>20)
+	}
+
+	// 与静态图片使用相同的时间戳前缀，便于在存储目录中对应
+	prefix, _, _ := strings.Cut(img.StoredFilename, "_")
+	videoPath := filepath.Join(s.cfg.StorageDir, "originals", fmt.Sprintf("%s_%s", prefix, sanitizeFilename(motion.Filename)))
+	if err := writeUpload(motion, videoPath); err != nil {
+		return err
+	}
+	if s.video != nil {
+		info, err := s.video.Probe(ctx, videoPath)
+		if err != nil {
+			os.Remove(videoPath)
+			return fmt.Errorf("无法解析Live Photo视频: %v", err)
+		}
+		img.DurationMs = int(info.Duration / time.Millisecond)
+	}
+
+	img.MediaType = models.MediaLive
+	img.VideoFilePath = videoPath
+	img.VideoMimeType = mimeType
+	return nil
+}
+
+// writeUpload 把上传的文件流式写入磁盘，不读入内存
+func writeUpload(fileHeader *multipart.FileHeader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	src, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return err
+	}
+	return dst.Close()
+}
+
+// saveVideoMetadata 把视频的拍摄时间、设备和位置保存为EXIF记录，与图片共用按拍摄时间、相机筛选的逻辑
+func (s *ImageService) saveVideoMetadata(imageID uint, info *video.Info) error {
+	exifModel := models.ImageEXIF{
+		ImageID:     imageID,
+		CameraMake:  info.Make,
+		CameraModel: info.Model,
+		TakenAt:     info.CreatedAt,
+		Latitude:    info.Latitude,
+		Longitude:   info.Longitude,
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "image_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"camera_make", "camera_model", "taken_at", "latitude", "longitude"}),
+	}).Create(&exifModel).Error
+}
+
+// OpenVideo 打开工作区中视频或Live Photo动态部分的文件，由调用方关闭
+// 返回的文件支持Seek，用于按Range请求分段传输
+func (s *ImageService) OpenVideo(m Member, imageID uint) (*models.Image, *os.File, error) {
+	imageModel, err := s.find(m, imageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if imageModel.VideoFilePath == "" {
+		return nil, nil, errors.New("该图片没有视频")
+	}
+	f, err := os.Open(imageModel.VideoFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return imageModel, f, nil
+}
@@ -0,0 +1,118 @@
+// Package services 提供业务逻辑层的服务实现
+// ocr_service.go 实现了图片文字识别：上传后用可替换的OCR引擎（本地tesseract或视觉大模型）识别截图、文档中的文字，
+// 识别结果建立全文索引，关键词检索时与文件名一起匹配
+package services
+
+import (
+	"context"
+	"image"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"image-manager/internal/config"
+	"image-manager/internal/models"
+	"image-manager/internal/ocr"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ngramTokenSize MySQL ngram全文解析器的默认分词长度，更短的关键词无法使用全文索引
+const ngramTokenSize = 2
+
+// OCRService 文字识别服务结构体
+// 作为图片后处理器注册到ImageService
+type OCRService struct {
+	db      *gorm.DB
+	engine  ocr.Engine // 识别引擎，未配置或不可用时为nil（OCR被禁用）
+	timeout time.Duration
+}
+
+// NewOCRService 创建文字识别服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，OCREngine决定使用的引擎
+//   - ai: AI服务，OCREngine为ai时使用其视觉模型
+//
+// 返回: OCRService指针
+func NewOCRService(db *gorm.DB, cfg config.Config, ai *AIService) *OCRService {
+	s := &OCRService{db: db, timeout: time.Duration(cfg.OCRTimeoutSeconds) * time.Second}
+	switch cfg.OCREngine {
+	case "tesseract":
+		engine, err := ocr.NewTesseract(cfg.OCRTesseractPath, cfg.OCRLanguages, cfg.OCRMinConfidence)
+		if err != nil {
+			log.Printf("OCR已禁用: %v", err)
+		} else {
+			s.engine = engine
+		}
+	case "ai":
+		s.engine = aiOCREngine{ai: ai}
+	case "", "none":
+	default:
+		log.Printf("未知的OCR引擎 %q，OCR已禁用", cfg.OCREngine)
+	}
+	return s
+}
+
+// Name 后处理器名称
+func (s *OCRService) Name() string {
+	return "ocr"
+}
+
+// Process 识别图片中的文字并保存，已有结果时覆盖；没有识别出文字时删除之前的结果
+func (s *OCRService) Process(img *models.Image, decoded image.Image, data []byte) error {
+	if s.engine == nil {
+		return nil
+	}
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	text, err := s.engine.Recognize(ctx, data, img.MimeType)
+	if err != nil {
+		return err
+	}
+	text = ocr.CleanText(text)
+	if text == "" {
+		return s.db.Delete(&models.ImageText{}, "image_id = ?", img.ID).Error
+	}
+
+	record := models.ImageText{ImageID: img.ID, Engine: s.engine.Name(), Content: text}
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error
+}
+
+// Remove 删除图片时清理其识别结果
+func (s *OCRService) Remove(tx *gorm.DB, img *models.Image) error {
+	return tx.Delete(&models.ImageText{}, "image_id = ?", img.ID).Error
+}
+
+// ocrTextQuery 构建按图片文字匹配关键词的子查询，返回图片ID
+// 关键词足够长时使用ngram全文索引做短语匹配，否则退化为LIKE
+func ocrTextQuery(db *gorm.DB, keyword string) *gorm.DB {
+	sub := db.Table("image_texts").Select("image_texts.image_id")
+	phrase := strings.TrimSpace(strings.ReplaceAll(keyword, `"`, " "))
+	if utf8.RuneCountInString(phrase) < ngramTokenSize {
+		return sub.Where("image_texts.content LIKE ?", "%"+keyword+"%")
+	}
+	return sub.Where("MATCH(image_texts.content) AGAINST(? IN BOOLEAN MODE)", `"`+phrase+`"`)
+}
+
+// aiOCREngine 基于AIService视觉模型的OCR引擎
+type aiOCREngine struct {
+	ai *AIService
+}
+
+// Name 引擎名称
+func (e aiOCREngine) Name() string {
+	return "ai"
+}
+
+// Recognize 调用视觉模型识别图片文字
+func (e aiOCREngine) Recognize(ctx context.Context, data []byte, mimeType string) (string, error) {
+	return e.ai.ExtractText(ctx, data, mimeType)
+}
@@ -0,0 +1,364 @@
+// Package services 提供业务逻辑层的服务实现
+// palette_service.go 实现了图片主色调提取和按颜色检索
+// 上传后在CIE Lab空间对缩小后的像素做k-means聚类得到调色板；检索时支持十六进制颜色加Lab容差，
+// 也支持"蓝色"、"mostly blue"这类颜色名称（按LCh色相、彩度和明度范围匹配）
+package services
+
+import (
+	"errors"
+	"image"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"image-manager/internal/models"
+
+	"github.com/disintegration/imaging"
+	"github.com/lucasb-eyer/go-colorful"
+	"gorm.io/gorm"
+)
+
+// 调色板提取参数
+const (
+	paletteSampleSize = 64   // 聚类前将图片缩小到该尺寸以内
+	paletteColors     = 6    // 聚类数量（调色板最多颜色数）
+	paletteIterations = 12   // k-means迭代次数
+	paletteMinRatio   = 0.01 // 占比低于该值的颜色不保存
+)
+
+// 颜色检索默认参数
+const (
+	defaultColorTolerance = 15.0 // 十六进制颜色的默认Lab容差（ΔE76）
+	defaultColorRatio     = 0.05 // 匹配颜色的默认最小总占比
+	mostlyColorRatio      = 0.4  // "mostly"/"主要是"时的最小总占比
+)
+
+// PaletteService 主色调服务结构体
+// 作为图片后处理器注册到ImageService，在图片内容写入后提取调色板
+type PaletteService struct {
+	db *gorm.DB
+}
+
+// NewPaletteService 创建主色调服务实例
+// 参数:
+//   - db: GORM数据库连接
+// 返回: PaletteService指针
+func NewPaletteService(db *gorm.DB) *PaletteService {
+	return &PaletteService{db: db}
+}
+
+// Name 后处理器名称
+func (s *PaletteService) Name() string {
+	return "palette"
+}
+
+// Process 提取图片调色板并替换之前保存的结果
+func (s *PaletteService) Process(img *models.Image, decoded image.Image, data []byte) error {
+	palette := extractPalette(decoded)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.ImageColor{}, "image_id = ?", img.ID).Error; err != nil {
+			return err
+		}
+		if len(palette) == 0 {
+			return nil
+		}
+		for i := range palette {
+			palette[i].ImageID = img.ID
+		}
+		return tx.Create(&palette).Error
+	})
+}
+
+// Remove 删除图片时清理调色板
+func (s *PaletteService) Remove(tx *gorm.DB, img *models.Image) error {
+	return tx.Delete(&models.ImageColor{}, "image_id = ?", img.ID).Error
+}
+
+// orderColorsByRank 预加载调色板时按排名排序
+func orderColorsByRank(db *gorm.DB) *gorm.DB {
+	return db.Order("image_colors.rank ASC")
+}
+
+// labPoint Lab坐标（L为0-100的常规单位）
+type labPoint struct {
+	l, a, b float64
+}
+
+func (p labPoint) dist2(q labPoint) float64 {
+	dl, da, db := p.l-q.l, p.a-q.a, p.b-q.b
+	return dl*dl + da*da + db*db
+}
+
+// extractPalette 在Lab空间用k-means提取调色板，按占比从高到低返回
+// 使用固定随机种子的k-means++初始化，同一张图片每次得到相同的结果
+func extractPalette(img image.Image) []models.ImageColor {
+	small := imaging.Fit(img, paletteSampleSize, paletteSampleSize, imaging.Box)
+	bounds := small.Bounds()
+
+	points := make([]labPoint, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			offset := small.PixOffset(x, y)
+			// 跳过大部分透明的像素
+			if small.Pix[offset+3] < 128 {
+				continue
+			}
+			c := colorful.Color{
+				R: float64(small.Pix[offset]) / 255,
+				G: float64(small.Pix[offset+1]) / 255,
+				B: float64(small.Pix[offset+2]) / 255,
+			}
+			l, a, b := c.Lab()
+			points = append(points, labPoint{l * 100, a * 100, b * 100})
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	k := min(paletteColors, len(points))
+	centers := initCenters(points, k)
+	assign := make([]int, len(points))
+	for iter := 0; iter < paletteIterations; iter++ {
+		for i, p := range points {
+			best, bestDist := 0, math.MaxFloat64
+			for j, c := range centers {
+				if d := p.dist2(c); d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+			assign[i] = best
+		}
+
+		sums := make([]labPoint, k)
+		counts := make([]int, k)
+		for i, p := range points {
+			j := assign[i]
+			sums[j].l += p.l
+			sums[j].a += p.a
+			sums[j].b += p.b
+			counts[j]++
+		}
+		for j := range centers {
+			if counts[j] > 0 {
+				n := float64(counts[j])
+				centers[j] = labPoint{sums[j].l / n, sums[j].a / n, sums[j].b / n}
+			}
+		}
+	}
+
+	counts := make([]int, k)
+	for _, j := range assign {
+		counts[j]++
+	}
+
+	palette := []models.ImageColor{}
+	for j, c := range centers {
+		ratio := float64(counts[j]) / float64(len(points))
+		if ratio < paletteMinRatio {
+			continue
+		}
+		chroma, hue := labToLCh(c.a, c.b)
+		palette = append(palette, models.ImageColor{
+			Hex:    strings.ToUpper(colorful.Lab(c.l/100, c.a/100, c.b/100).Clamped().Hex()),
+			L:      round2(c.l),
+			A:      round2(c.a),
+			B:      round2(c.b),
+			Chroma: round2(chroma),
+			Hue:    round2(hue),
+			Ratio:  round2(ratio*100) / 100,
+		})
+	}
+	sort.SliceStable(palette, func(i, j int) bool { return palette[i].Ratio > palette[j].Ratio })
+	for i := range palette {
+		palette[i].Rank = i
+	}
+	return palette
+}
+
+// initCenters k-means++初始化聚类中心
+func initCenters(points []labPoint, k int) []labPoint {
+	rng := rand.New(rand.NewSource(1))
+	centers := []labPoint{points[rng.Intn(len(points))]}
+	dists := make([]float64, len(points))
+	for len(centers) < k {
+		total := 0.0
+		for i, p := range points {
+			d := math.MaxFloat64
+			for _, c := range centers {
+				d = math.Min(d, p.dist2(c))
+			}
+			dists[i] = d
+			total += d
+		}
+		if total == 0 {
+			// 剩余像素都与已有中心重合（纯色图片）
+			break
+		}
+		target := rng.Float64() * total
+		chosen := len(points) - 1
+		for i, d := range dists {
+			target -= d
+			if target <= 0 {
+				chosen = i
+				break
+			}
+		}
+		centers = append(centers, points[chosen])
+	}
+	return centers
+}
+
+// labToLCh 计算Lab颜色的彩度和色相角（度）
+func labToLCh(a, b float64) (float64, float64) {
+	chroma := math.Hypot(a, b)
+	hue := math.Atan2(b, a) * 180 / math.Pi
+	if hue < 0 {
+		hue += 360
+	}
+	return chroma, hue
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// namedColor 颜色名称对应的LCh范围
+// hueMin > hueMax 表示跨越0度；achromatic为true时忽略色相
+type namedColor struct {
+	hueMin, hueMax       float64
+	lMin, lMax           float64
+	chromaMin, chromaMax float64
+	achromatic           bool
+}
+
+// 有彩色的最低彩度，无彩色（黑白灰）的最高彩度
+const (
+	chromaticMin  = 15.0
+	achromaticMax = 12.0
+)
+
+// namedColors 支持的颜色名称（中英文）
+var namedColors = func() map[string]namedColor {
+	colors := map[string]namedColor{
+		"red":    {hueMin: 15, hueMax: 50, lMin: 0, lMax: 75, chromaMin: chromaticMin, chromaMax: 200},
+		"orange": {hueMin: 50, hueMax: 80, lMin: 55, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"yellow": {hueMin: 80, hueMax: 110, lMin: 0, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"green":  {hueMin: 110, hueMax: 170, lMin: 0, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"cyan":   {hueMin: 170, hueMax: 225, lMin: 0, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"blue":   {hueMin: 225, hueMax: 310, lMin: 0, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"purple": {hueMin: 310, hueMax: 340, lMin: 0, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"pink":   {hueMin: 340, hueMax: 15, lMin: 50, lMax: 100, chromaMin: chromaticMin, chromaMax: 200},
+		"brown":  {hueMin: 20, hueMax: 90, lMin: 15, lMax: 55, chromaMin: chromaticMin, chromaMax: 70},
+		"black":  {lMin: 0, lMax: 25, chromaMin: 0, chromaMax: achromaticMax, achromatic: true},
+		"gray":   {lMin: 25, lMax: 85, chromaMin: 0, chromaMax: achromaticMax, achromatic: true},
+		"white":  {lMin: 85, lMax: 100, chromaMin: 0, chromaMax: achromaticMax, achromatic: true},
+	}
+	aliases := map[string]string{
+		"红": "red", "红色": "red",
+		"橙": "orange", "橙色": "orange", "橘色": "orange",
+		"黄": "yellow", "黄色": "yellow",
+		"绿": "green", "绿色": "green",
+		"青": "cyan", "青色": "cyan",
+		"蓝": "blue", "蓝色": "blue",
+		"紫": "purple", "紫色": "purple", "violet": "purple",
+		"粉": "pink", "粉色": "pink", "粉红": "pink", "粉红色": "pink",
+		"棕": "brown", "棕色": "brown", "褐色": "brown", "咖啡色": "brown",
+		"黑": "black", "黑色": "black",
+		"灰": "gray", "灰色": "gray", "grey": "gray",
+		"白": "white", "白色": "white",
+	}
+	for alias, name := range aliases {
+		colors[alias] = colors[name]
+	}
+	return colors
+}()
+
+var hexColorPattern = regexp.MustCompile(`^#?([0-9a-fA-F]{6}|[0-9a-fA-F]{3})$`)
+
+// parseColorSpec 规范化颜色条件，去掉"mostly "、"主要是"等前缀
+// 返回: 颜色（十六进制或名称）以及是否要求该颜色占据大部分
+func parseColorSpec(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, prefix := range []string{"mostly ", "mostly:", "主要是", "大部分是"} {
+		if rest, ok := strings.CutPrefix(value, prefix); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return value, false
+}
+
+// isKnownColor 判断颜色条件能否识别（十六进制颜色或支持的颜色名称）
+func isKnownColor(value string) bool {
+	spec, _ := parseColorSpec(value)
+	if hexColorPattern.MatchString(spec) {
+		return true
+	}
+	_, ok := namedColors[spec]
+	return ok
+}
+
+// colorFilterQuery 构建按颜色筛选的子查询，返回满足条件的图片ID
+// 参数:
+//   - db: 数据库连接
+//   - value: 颜色条件，可以是十六进制颜色（#3366CC）或颜色名称（blue、蓝色），
+//     前缀"mostly "或"主要是"表示该颜色需要占据图片的大部分
+//   - toleranceStr: 十六进制颜色的Lab容差（ΔE），为空时使用默认值
+//   - ratioStr: 匹配颜色的最小总占比（0-1），为空时使用默认值
+// 返回: 子查询和错误信息（颜色无法识别时返回错误）
+func colorFilterQuery(db *gorm.DB, value, toleranceStr, ratioStr string) (*gorm.DB, error) {
+	value, mostly := parseColorSpec(value)
+	ratio := defaultColorRatio
+	if mostly {
+		ratio = mostlyColorRatio
+	}
+	if ratioStr != "" {
+		parsed, err := strconv.ParseFloat(ratioStr, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return nil, errors.New("颜色占比必须在0到1之间")
+		}
+		ratio = parsed
+	}
+
+	sub := db.Model(&models.ImageColor{}).Select("image_colors.image_id")
+
+	if hexColorPattern.MatchString(value) {
+		hex := strings.TrimPrefix(value, "#")
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		c, err := colorful.Hex("#" + hex)
+		if err != nil {
+			return nil, err
+		}
+		tolerance := defaultColorTolerance
+		if toleranceStr != "" {
+			parsed, err := strconv.ParseFloat(toleranceStr, 64)
+			if err != nil || parsed <= 0 {
+				return nil, errors.New("颜色容差必须为正数")
+			}
+			tolerance = parsed
+		}
+		l, a, b := c.Lab()
+		sub = sub.Where("POW(image_colors.l - ?, 2) + POW(image_colors.a - ?, 2) + POW(image_colors.b - ?, 2) <= ?",
+			l*100, a*100, b*100, tolerance*tolerance)
+	} else if named, ok := namedColors[value]; ok {
+		sub = sub.Where("image_colors.l BETWEEN ? AND ? AND image_colors.chroma BETWEEN ? AND ?",
+			named.lMin, named.lMax, named.chromaMin, named.chromaMax)
+		if !named.achromatic {
+			if named.hueMin <= named.hueMax {
+				sub = sub.Where("image_colors.hue >= ? AND image_colors.hue < ?", named.hueMin, named.hueMax)
+			} else {
+				sub = sub.Where("(image_colors.hue >= ? OR image_colors.hue < ?)", named.hueMin, named.hueMax)
+			}
+		}
+	} else {
+		return nil, errors.New("无法识别的颜色: " + value)
+	}
+
+	// 调色板中所有匹配颜色的占比之和达到阈值
+	return sub.Group("image_colors.image_id").Having("SUM(image_colors.ratio) >= ?", ratio), nil
+}
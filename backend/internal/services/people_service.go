@@ -0,0 +1,351 @@
+// Package services 提供业务逻辑层的服务实现
+// people_service.go 实现了人物子系统：上传后检测人脸、提取特征，并按用户将人脸聚类为人物
+// 人物初始未命名，用户命名后即可在图片列表中使用 person 筛选条件
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"image-manager/internal/config"
+	"image-manager/internal/faces"
+	"image-manager/internal/models"
+
+	"github.com/disintegration/imaging"
+	"gorm.io/gorm"
+)
+
+// coverSize 人物封面（人脸裁剪图）的边长
+const coverSize = 160
+
+// PeopleService 人物服务结构体
+// 作为图片后处理器注册到ImageService，同时提供人物的查询、命名与合并
+type PeopleService struct {
+	db       *gorm.DB
+	cfg      config.Config
+	detector *faces.Detector // 人脸检测器，加载失败时为nil（人脸检测被禁用）
+	mu       sync.Mutex      // 串行化聚类，避免并发上传时同一个人被拆成多个人物
+}
+
+// NewPeopleService 创建人物服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，包含人脸检测开关和阈值
+// 返回: PeopleService指针
+func NewPeopleService(db *gorm.DB, cfg config.Config) *PeopleService {
+	s := &PeopleService{db: db, cfg: cfg}
+	if cfg.FaceDetectionEnabled {
+		detector, err := faces.NewDetector(float32(cfg.FaceMinScore))
+		if err != nil {
+			log.Printf("加载人脸检测模型失败，人脸检测已禁用: %v", err)
+		} else {
+			s.detector = detector
+		}
+	}
+	return s
+}
+
+// Name 后处理器名称
+func (s *PeopleService) Name() string {
+	return "faces"
+}
+
+// Process 检测图片中的人脸并归入人物
+// 每张人脸与该用户已有人物的聚类中心比较，相似度达到阈值时归入最相似的人物，否则新建未命名人物；
+// 同一张图片中的两张人脸不会归入同一个人物
+func (s *PeopleService) Process(img *models.Image, decoded image.Image, data []byte) error {
+	if s.detector == nil {
+		return nil
+	}
+	detections := s.detector.Detect(decoded)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 图片内容可能已被替换，先清除之前的人脸
+		if err := s.removeImageFaces(tx, img.ID); err != nil {
+			return err
+		}
+		if len(detections) == 0 {
+			return nil
+		}
+
+		var people []models.Person
+		if err := tx.Where("workspace_id = ?", img.WorkspaceID).Find(&people).Error; err != nil {
+			return err
+		}
+		centroids := make(map[uint][]float32, len(people))
+		for _, person := range people {
+			if vec, err := faces.Decode(person.Centroid); err == nil && len(vec) == faces.EmbeddingLen {
+				centroids[person.ID] = vec
+			}
+		}
+
+		usedInImage := map[uint]bool{}
+		touched := map[uint]bool{}
+		for _, det := range detections {
+			rect := det.Rect(decoded.Bounds())
+			if rect.Empty() {
+				continue
+			}
+			vec := faces.Embed(decoded, rect)
+
+			// 找到最相似且本图中尚未使用的人物
+			var bestID uint
+			var best float32
+			for personID, centroid := range centroids {
+				if usedInImage[personID] {
+					continue
+				}
+				if sim := faces.Similarity(vec, centroid); sim > best {
+					bestID, best = personID, sim
+				}
+			}
+
+			if bestID == 0 || float64(best) < s.cfg.FaceMatchThreshold {
+				person := models.Person{UserID: img.UserID, WorkspaceID: img.WorkspaceID, Centroid: faces.Encode(vec)}
+				if err := tx.Create(&person).Error; err != nil {
+					return err
+				}
+				bestID = person.ID
+			}
+			usedInImage[bestID] = true
+			touched[bestID] = true
+
+			face := models.Face{
+				ImageID:     img.ID,
+				UserID:      img.UserID,
+				WorkspaceID: img.WorkspaceID,
+				PersonID:    bestID,
+				X:           rect.Min.X,
+				Y:           rect.Min.Y,
+				Width:       rect.Dx(),
+				Height:      rect.Dy(),
+				Score:       det.Score,
+				Embedding:   faces.Encode(vec),
+			}
+			if err := tx.Create(&face).Error; err != nil {
+				return err
+			}
+		}
+
+		for personID := range touched {
+			if err := s.refreshPerson(tx, personID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove 删除图片时清理其人脸，并更新相关人物
+func (s *PeopleService) Remove(tx *gorm.DB, img *models.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeImageFaces(tx, img.ID)
+}
+
+// removeImageFaces 删除图片的所有人脸，并重新计算受影响的人物
+func (s *PeopleService) removeImageFaces(tx *gorm.DB, imageID uint) error {
+	var personIDs []uint
+	if err := tx.Model(&models.Face{}).Where("image_id = ?", imageID).Distinct().Pluck("person_id", &personIDs).Error; err != nil {
+		return err
+	}
+	if len(personIDs) == 0 {
+		return nil
+	}
+	if err := tx.Delete(&models.Face{}, "image_id = ?", imageID).Error; err != nil {
+		return err
+	}
+	for _, personID := range personIDs {
+		if err := s.refreshPerson(tx, personID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshPerson 根据人物当前的全部人脸重新计算聚类中心、人脸数量和封面
+// 人物已没有任何人脸时删除该人物
+func (s *PeopleService) refreshPerson(tx *gorm.DB, personID uint) error {
+	var members []models.Face
+	if err := tx.Where("person_id = ?", personID).Find(&members).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return tx.Delete(&models.Person{}, "id = ?", personID).Error
+	}
+
+	sum := make([]float32, faces.EmbeddingLen)
+	cover := members[0]
+	for _, face := range members {
+		vec, err := faces.Decode(face.Embedding)
+		if err == nil && len(vec) == len(sum) {
+			for i, v := range vec {
+				sum[i] += v
+			}
+		}
+		if face.Score > cover.Score {
+			cover = face
+		}
+	}
+
+	return tx.Model(&models.Person{}).Where("id = ?", personID).Updates(map[string]interface{}{
+		"centroid":      faces.Encode(faces.Normalize(sum)),
+		"face_count":    len(members),
+		"cover_face_id": cover.ID,
+	}).Error
+}
+
+// List 获取工作区的所有人物，已命名的在前，其余按人脸数量从多到少
+func (s *PeopleService) List(m Member) ([]models.Person, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var people []models.Person
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).
+		Order("name = '' ASC, face_count DESC, id ASC").
+		Find(&people).Error; err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// Get 获取人物及其全部人脸（按置信度从高到低）
+func (s *PeopleService) Get(m Member, personID uint) (*models.Person, []models.Face, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, nil, err
+	}
+	var person models.Person
+	if err := s.db.Where("id = ? AND workspace_id = ?", personID, m.WorkspaceID).First(&person).Error; err != nil {
+		return nil, nil, err
+	}
+	var faceList []models.Face
+	if err := s.db.Where("person_id = ?", personID).Order("score DESC").Find(&faceList).Error; err != nil {
+		return nil, nil, err
+	}
+	return &person, faceList, nil
+}
+
+// Rename 为人物命名，名称为空表示取消命名
+// 同一工作区内名称不能重复，同一个人被拆成了两个人物时应使用Merge合并
+func (s *PeopleService) Rename(m Member, personID uint, name string) (*models.Person, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if len([]rune(name)) > 100 {
+		return nil, errors.New("人物名称不能超过100个字符")
+	}
+
+	var person models.Person
+	if err := s.db.Where("id = ? AND workspace_id = ?", personID, m.WorkspaceID).First(&person).Error; err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		var count int64
+		if err := s.db.Model(&models.Person{}).
+			Where("workspace_id = ? AND name = ? AND id <> ?", m.WorkspaceID, name, personID).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errors.New("人物名称已存在，如果是同一个人请合并")
+		}
+	}
+
+	if err := s.db.Model(&person).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+// Merge 将source人物的所有人脸并入target人物并删除source
+// target未命名而source已命名时，合并后的人物沿用source的名称
+func (s *PeopleService) Merge(m Member, targetID, sourceID uint) (*models.Person, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	if targetID == sourceID {
+		return nil, errors.New("不能与自身合并")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var target, source models.Person
+	if err := s.db.Where("id = ? AND workspace_id = ?", targetID, m.WorkspaceID).First(&target).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("id = ? AND workspace_id = ?", sourceID, m.WorkspaceID).First(&source).Error; err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Face{}).Where("person_id = ?", sourceID).Update("person_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Person{}, "id = ?", sourceID).Error; err != nil {
+			return err
+		}
+		if target.Name == "" && source.Name != "" {
+			if err := tx.Model(&models.Person{}).Where("id = ?", targetID).Update("name", source.Name).Error; err != nil {
+				return err
+			}
+		}
+		return s.refreshPerson(tx, targetID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(&target, targetID).Error; err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// Cover 生成工作区中人物的封面：从原图中裁剪封面人脸并编码为JPEG
+func (s *PeopleService) Cover(m Member, personID uint) ([]byte, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var person models.Person
+	if err := s.db.Where("id = ? AND workspace_id = ?", personID, m.WorkspaceID).First(&person).Error; err != nil {
+		return nil, err
+	}
+	var face models.Face
+	if err := s.db.First(&face, person.CoverFaceID).Error; err != nil {
+		return nil, err
+	}
+	var img models.Image
+	if err := s.db.First(&img, face.ImageID).Error; err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(img.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoded, err := imaging.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(face.X, face.Y, face.X+face.Width, face.Y+face.Height)
+	cover := imaging.Fill(imaging.Crop(decoded, rect), coverSize, coverSize, imaging.Center, imaging.Lanczos)
+	buff := &bytes.Buffer{}
+	if err := jpeg.Encode(buff, cover, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
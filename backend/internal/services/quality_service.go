@@ -0,0 +1,237 @@
+// Package services 提供业务逻辑层的服务实现
+// quality_service.go 实现了图片画质检测：上传后计算清晰度（拉普拉斯方差）、曝光（亮度直方图）和噪点估计，
+// 并提供 quality 筛选条件和按画质排序，便于找出并批量删除模糊、曝光失败的照片
+package services
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"image-manager/internal/config"
+	"image-manager/internal/models"
+
+	"github.com/disintegration/imaging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// qualityMaxSide 计算画质前将图片缩小到的最长边，使不同分辨率图片的清晰度可比
+	qualityMaxSide = 1024
+	// shadowClipLevel/highlightClipLevel 视为暗部/高光溢出的灰度阈值
+	shadowClipLevel    = 10
+	highlightClipLevel = 245
+	// noiseEdgeLevel Sobel梯度超过该值的像素视为边缘，不参与噪点估计
+	noiseEdgeLevel = 48
+
+	// 曝光分类
+	ExposureUnder  = "under"
+	ExposureOver   = "over"
+	ExposureNormal = "normal"
+)
+
+// QualityService 画质服务结构体
+// 作为图片后处理器注册到ImageService
+type QualityService struct {
+	db *gorm.DB
+}
+
+// NewQualityService 创建画质服务实例
+// 参数:
+//   - db: GORM数据库连接
+//
+// 返回: QualityService指针
+func NewQualityService(db *gorm.DB) *QualityService {
+	return &QualityService{db: db}
+}
+
+// Name 后处理器名称
+func (s *QualityService) Name() string {
+	return "quality"
+}
+
+// Process 计算图片的画质指标并保存，已有结果时覆盖
+func (s *QualityService) Process(img *models.Image, decoded image.Image, data []byte) error {
+	quality := measureQuality(decoded)
+	quality.ImageID = img.ID
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&quality).Error
+}
+
+// Remove 删除图片时清理其画质指标
+func (s *QualityService) Remove(tx *gorm.DB, img *models.Image) error {
+	return tx.Delete(&models.ImageQuality{}, "image_id = ?", img.ID).Error
+}
+
+// measureQuality 计算图片的清晰度、噪点和曝光指标
+func measureQuality(img image.Image) models.ImageQuality {
+	gray := imaging.Grayscale(imaging.Fit(img, qualityMaxSide, qualityMaxSide, imaging.Linear))
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	at := func(x, y int) float64 {
+		return float64(gray.Pix[y*gray.Stride+x*4])
+	}
+
+	// 亮度直方图：平均亮度和两端溢出比例
+	var sum float64
+	var shadow, highlight int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := at(x, y)
+			sum += v
+			if v <= shadowClipLevel {
+				shadow++
+			} else if v >= highlightClipLevel {
+				highlight++
+			}
+		}
+	}
+	pixels := float64(w * h)
+	quality := models.ImageQuality{}
+	if pixels > 0 {
+		quality.Brightness = round2(sum / pixels)
+		quality.ShadowClip = round2(float64(shadow) / pixels)
+		quality.HighlightClip = round2(float64(highlight) / pixels)
+	}
+	quality.Exposure = classifyExposure(quality.Brightness, quality.ShadowClip, quality.HighlightClip)
+
+	if w < 3 || h < 3 {
+		return quality
+	}
+
+	// 清晰度：4邻域拉普拉斯响应的方差
+	// 噪点：Immerkær快速噪声估计，跳过边缘像素以免纹理被误判为噪点
+	var lapSum, lapSq, noiseSum float64
+	var lapN, noiseN int
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			c := at(x, y)
+			n, s, e, wv := at(x, y-1), at(x, y+1), at(x+1, y), at(x-1, y)
+			nw, ne, sw, se := at(x-1, y-1), at(x+1, y-1), at(x-1, y+1), at(x+1, y+1)
+
+			lap := n + s + e + wv - 4*c
+			lapSum += lap
+			lapSq += lap * lap
+			lapN++
+
+			gx := (ne + 2*e + se) - (nw + 2*wv + sw)
+			gy := (sw + 2*s + se) - (nw + 2*n + ne)
+			if math.Abs(gx)+math.Abs(gy) > noiseEdgeLevel {
+				continue
+			}
+			noiseSum += math.Abs(4*c - 2*(n+s+e+wv) + (nw + ne + sw + se))
+			noiseN++
+		}
+	}
+	mean := lapSum / float64(lapN)
+	quality.Sharpness = round2(lapSq/float64(lapN) - mean*mean)
+	if noiseN > 0 {
+		quality.Noise = round2(math.Sqrt(math.Pi/2) * noiseSum / (6 * float64(noiseN)))
+	}
+	return quality
+}
+
+// classifyExposure 根据平均亮度和溢出比例判断曝光：整体过暗/过亮，或较暗/较亮且大面积溢出
+func classifyExposure(brightness, shadowClip, highlightClip float64) string {
+	switch {
+	case brightness < 50 || (brightness < 90 && shadowClip >= 0.3):
+		return ExposureUnder
+	case brightness > 205 || (brightness > 165 && highlightClip >= 0.3):
+		return ExposureOver
+	default:
+		return ExposureNormal
+	}
+}
+
+// qualityFlags quality筛选条件支持的取值（中英文）
+var qualityFlags = map[string]string{
+	"blurry":       "blurry",
+	"模糊":           "blurry",
+	"sharp":        "sharp",
+	"清晰":           "sharp",
+	"underexposed": "underexposed",
+	"欠曝":           "underexposed",
+	"overexposed":  "overexposed",
+	"过曝":           "overexposed",
+	"well_exposed": "well_exposed",
+	"曝光正常":         "well_exposed",
+	"noisy":        "noisy",
+	"噪点多":          "noisy",
+	"clean":        "clean",
+	"无噪点":          "clean",
+}
+
+// isKnownQuality 判断quality筛选值中的每个标记是否都能识别
+func isKnownQuality(value string) bool {
+	flags := parseTagString(value)
+	for _, raw := range flags {
+		if _, ok := qualityFlags[strings.ToLower(raw)]; !ok {
+			return false
+		}
+	}
+	return len(flags) > 0
+}
+
+// hasQualityFilter 判断筛选条件中是否包含画质条件
+func hasQualityFilter(filters map[string]string) bool {
+	for _, key := range []string{"quality", "sharpness_min", "sharpness_max", "noise_min", "noise_max"} {
+		if strings.TrimSpace(filters[key]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// qualityFilterQuery 构建画质筛选子查询，返回符合条件的图片ID
+// quality 为逗号分隔的画质标记（blurry、sharp、underexposed、overexposed、well_exposed、noisy、clean），多个标记同时满足；
+// sharpness_min/sharpness_max、noise_min/noise_max 为数值范围；
+// 模糊与噪点的判断使用配置中的阈值，尚未计算画质的图片不会匹配
+func qualityFilterQuery(db *gorm.DB, cfg config.Config, filters map[string]string) (*gorm.DB, error) {
+	sub := db.Table("image_qualities").Select("image_qualities.image_id")
+
+	for _, raw := range parseTagString(filters["quality"]) {
+		flag, ok := qualityFlags[strings.ToLower(raw)]
+		if !ok {
+			return nil, fmt.Errorf("无法识别的画质条件: %s", raw)
+		}
+		switch flag {
+		case "blurry":
+			sub = sub.Where("image_qualities.sharpness < ?", cfg.QualityBlurThreshold)
+		case "sharp":
+			sub = sub.Where("image_qualities.sharpness >= ?", cfg.QualityBlurThreshold)
+		case "underexposed":
+			sub = sub.Where("image_qualities.exposure = ?", ExposureUnder)
+		case "overexposed":
+			sub = sub.Where("image_qualities.exposure = ?", ExposureOver)
+		case "well_exposed":
+			sub = sub.Where("image_qualities.exposure = ?", ExposureNormal)
+		case "noisy":
+			sub = sub.Where("image_qualities.noise > ?", cfg.QualityNoiseThreshold)
+		case "clean":
+			sub = sub.Where("image_qualities.noise <= ?", cfg.QualityNoiseThreshold)
+		}
+	}
+
+	ranges := []struct {
+		key  string
+		cond string
+	}{
+		{"sharpness_min", "image_qualities.sharpness >= ?"},
+		{"sharpness_max", "image_qualities.sharpness <= ?"},
+		{"noise_min", "image_qualities.noise >= ?"},
+		{"noise_max", "image_qualities.noise <= ?"},
+	}
+	for _, r := range ranges {
+		str := strings.TrimSpace(filters[r.key])
+		if str == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%s 必须是非负数", r.key)
+		}
+		sub = sub.Where(r.cond, v)
+	}
+	return sub, nil
+}
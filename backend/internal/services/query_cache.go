@@ -0,0 +1,141 @@
+// Package services 提供业务逻辑层的服务实现
+// query_cache.go 实现了自然语言查询到搜索过滤器转换结果的内存缓存
+// 同一工作区翻页浏览同一查询时直接命中缓存，无需再次调用AI
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queryCacheEntry 缓存条目
+type queryCacheEntry struct {
+	workspaceID uint
+	filters     map[string]string
+	diag        FilterDiagnostics
+	expiresAt   time.Time
+	createdAt   time.Time
+}
+
+// queryFilterCache 查询转换结果缓存
+// 缓存键由工作区ID、规范化后的查询和工作区标签库哈希组成：标签库变化后旧条目自然失效，
+// 同时标签变更时会通过invalidateWorkspace主动清理该工作区的条目
+type queryFilterCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]queryCacheEntry
+}
+
+// newQueryFilterCache 创建缓存，ttl<=0 时禁用缓存
+func newQueryFilterCache(ttl time.Duration, maxEntries int) *queryFilterCache {
+	return &queryFilterCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]queryCacheEntry),
+	}
+}
+
+// get 查询缓存，命中时返回过滤器副本（调用方可以放心修改）和当时的转换诊断信息
+func (c *queryFilterCache) get(key string) (map[string]string, FilterDiagnostics, bool) {
+	if c.ttl <= 0 {
+		return nil, FilterDiagnostics{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, FilterDiagnostics{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, FilterDiagnostics{}, false
+	}
+	diag := entry.diag
+	diag.DroppedKeys = append([]DroppedFilter(nil), entry.diag.DroppedKeys...)
+	return copyFilters(entry.filters), diag, true
+}
+
+// set 写入缓存，超过容量时先清理过期条目，仍然不够则淘汰最早写入的条目
+func (c *queryFilterCache) set(key string, workspaceID uint, filters map[string]string, diag FilterDiagnostics) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		for len(c.entries) >= c.maxEntries {
+			oldestKey := ""
+			var oldest time.Time
+			for k, entry := range c.entries {
+				if oldestKey == "" || entry.createdAt.Before(oldest) {
+					oldestKey, oldest = k, entry.createdAt
+				}
+			}
+			delete(c.entries, oldestKey)
+		}
+	}
+
+	c.entries[key] = queryCacheEntry{
+		workspaceID: workspaceID,
+		filters:     copyFilters(filters),
+		diag:        diag,
+		expiresAt:   now.Add(c.ttl),
+		createdAt:   now,
+	}
+}
+
+// invalidateWorkspace 清除指定工作区的所有缓存条目
+func (c *queryFilterCache) invalidateWorkspace(workspaceID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if entry.workspaceID == workspaceID {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// queryCacheKey 生成缓存键：工作区ID + 规范化查询 + 标签库哈希
+func queryCacheKey(workspaceID uint, query string, tagNames []string) string {
+	return strings.Join([]string{
+		strconv.FormatUint(uint64(workspaceID), 10),
+		normalizeQuery(query),
+		tagVocabularyHash(tagNames),
+	}, "|")
+}
+
+// normalizeQuery 规范化查询：统一小写、合并空白，使仅有大小写或空格差异的查询命中同一条缓存
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// tagVocabularyHash 计算标签库的哈希（与标签顺序无关）
+func tagVocabularyHash(tagNames []string) string {
+	sorted := append([]string(nil), tagNames...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// copyFilters 复制过滤器映射
+func copyFilters(filters map[string]string) map[string]string {
+	copied := make(map[string]string, len(filters))
+	for k, v := range filters {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"image-manager/internal/config"
+)
+
+func TestQueryCacheKey(t *testing.T) {
+	base := queryCacheKey(1, "Red 猫", []string{"猫", "狗"})
+	tests := []struct {
+		name  string
+		key   string
+		equal bool
+	}{
+		{"case and spacing", queryCacheKey(1, "  red   猫 ", []string{"猫", "狗"}), true},
+		{"tag order", queryCacheKey(1, "Red 猫", []string{"狗", "猫"}), true},
+		{"other workspace", queryCacheKey(2, "Red 猫", []string{"猫", "狗"}), false},
+		{"tag added", queryCacheKey(1, "Red 猫", []string{"猫", "狗", "鸟"}), false},
+		{"other query", queryCacheKey(1, "猫", []string{"猫", "狗"}), false},
+	}
+	for _, tt := range tests {
+		if (tt.key == base) != tt.equal {
+			t.Errorf("%s: key equal = %v, want %v", tt.name, tt.key == base, tt.equal)
+		}
+	}
+}
+
+func TestQueryFilterCache(t *testing.T) {
+	t.Run("hit returns a copy", func(t *testing.T) {
+		c := newQueryFilterCache(time.Minute, 10)
+		c.set("k", 1, map[string]string{"tags": "猫"}, FilterDiagnostics{AIUsed: true})
+		filters, diag, ok := c.get("k")
+		if !ok || filters["tags"] != "猫" || !diag.AIUsed {
+			t.Fatalf("get = %v, %+v, %v", filters, diag, ok)
+		}
+		filters["tags"] = "狗"
+		if again, _, _ := c.get("k"); again["tags"] != "猫" {
+			t.Error("modifying a hit should not change the cached entry")
+		}
+	})
+
+	t.Run("expired entries miss", func(t *testing.T) {
+		c := newQueryFilterCache(time.Minute, 10)
+		c.set("k", 1, map[string]string{}, FilterDiagnostics{})
+		entry := c.entries["k"]
+		entry.expiresAt = time.Now().Add(-time.Second)
+		c.entries["k"] = entry
+		if _, _, ok := c.get("k"); ok {
+			t.Error("expired entry should miss")
+		}
+		if len(c.entries) != 0 {
+			t.Error("expired entry should be removed on read")
+		}
+	})
+
+	t.Run("evicts the oldest entry when full", func(t *testing.T) {
+		c := newQueryFilterCache(time.Minute, 2)
+		c.set("a", 1, map[string]string{}, FilterDiagnostics{})
+		c.set("b", 1, map[string]string{}, FilterDiagnostics{})
+		entry := c.entries["a"]
+		entry.createdAt = entry.createdAt.Add(-time.Second)
+		c.entries["a"] = entry
+		c.set("c", 1, map[string]string{}, FilterDiagnostics{})
+		if _, _, ok := c.get("a"); ok {
+			t.Error("oldest entry should be evicted")
+		}
+		for _, key := range []string{"b", "c"} {
+			if _, _, ok := c.get(key); !ok {
+				t.Errorf("entry %s should be kept", key)
+			}
+		}
+	})
+
+	t.Run("invalidates one workspace", func(t *testing.T) {
+		c := newQueryFilterCache(time.Minute, 10)
+		c.set("a", 1, map[string]string{}, FilterDiagnostics{})
+		c.set("b", 2, map[string]string{}, FilterDiagnostics{})
+		c.invalidateWorkspace(1)
+		if _, _, ok := c.get("a"); ok {
+			t.Error("workspace 1 entry should be cleared")
+		}
+		if _, _, ok := c.get("b"); !ok {
+			t.Error("workspace 2 entry should be kept")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newQueryFilterCache(0, 10)
+		c.set("k", 1, map[string]string{}, FilterDiagnostics{})
+		if _, _, ok := c.get("k"); ok {
+			t.Error("cache with ttl 0 should never hit")
+		}
+	})
+}
+
+func TestConvertQueryToFiltersCache(t *testing.T) {
+	var calls int32
+	status := int32(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		w.Write([]byte(`{"choices":[{"message":{"content":"{\"tags\":\"猫\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	s := NewAIService(config.Config{
+		AIEnabled:              true,
+		AIApiKey:               "key",
+		AIApiURL:               server.URL,
+		AITimeoutSeconds:       5,
+		AIQueryCacheTTLSeconds: 60,
+		AIQueryCacheSize:       10,
+	})
+	ctx := context.Background()
+	tags := []string{"猫", "狗"}
+
+	tests := []struct {
+		name        string
+		workspaceID uint
+		query       string
+		prepare     func()
+		wantSource  string
+		wantCalls   int32
+	}{
+		{"first query calls AI", 1, "找猫", nil, FilterSourceAI, 1},
+		{"same query hits cache", 1, " 找猫 ", nil, FilterSourceCache, 1},
+		{"other workspace misses", 2, "找猫", nil, FilterSourceAI, 2},
+		{"tag change invalidates", 1, "找猫", func() { s.InvalidateQueryCache(1) }, FilterSourceAI, 3},
+		{"fallback is not cached", 3, "找狗", func() { atomic.StoreInt32(&status, http.StatusBadRequest) }, FilterSourceFallback, 4},
+		{"retried after fallback", 3, "找狗", func() { atomic.StoreInt32(&status, http.StatusOK) }, FilterSourceAI, 5},
+	}
+	for _, tt := range tests {
+		if tt.prepare != nil {
+			tt.prepare()
+		}
+		_, diag, err := s.ConvertQueryToFilters(ctx, tt.workspaceID, tt.query, tags)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if diag.Source != tt.wantSource {
+			t.Errorf("%s: source = %s, want %s", tt.name, diag.Source, tt.wantSource)
+		}
+		if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+			t.Errorf("%s: AI calls = %d, want %d", tt.name, got, tt.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+// Package services 提供业务逻辑层的服务实现
+// render_service.go 实现了把相册或幻灯片导出为MP4视频：任务保存在数据库中，由后台任务队列逐个调用ffmpeg渲染，
+// 渲染过程中更新进度，完成后生成可下载的视频文件
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+	"image-manager/internal/video"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxRenderSlides 一个视频最多包含的图片数量
+	maxRenderSlides = 500
+	// renderFPS 导出视频的帧率
+	renderFPS = 30
+	// renderPrepareShare 准备图片阶段在总进度中的占比，其余为编码阶段
+	renderPrepareShare = 0.1
+	// renderPollInterval 没有新任务通知时检查排队任务的间隔
+	renderPollInterval = time.Minute
+)
+
+// renderWidths 输出高度对应的宽度（16:9），图片按该宽度从RenditionSizes中取渲染结果
+var renderWidths = map[int]int{
+	720:  1280,
+	1080: 1920,
+	2160: 3840,
+}
+
+// RenderService 视频导出服务结构体
+type RenderService struct {
+	db         *gorm.DB
+	cfg        config.Config
+	edits      *EditService      // 按尺寸渲染图片的当前版本
+	albums     *AlbumService     // 相册中的图片，智能相册按筛选条件计算
+	slideshows *SlideshowService // 幻灯片的图片、停留时间、转场和背景音乐
+	ffmpeg     *video.FFmpeg     // 未安装ffmpeg时为空，此时不能创建任务
+
+	wake    chan struct{}               // 有新任务时通知后台任务队列
+	mu      sync.Mutex                  // 保护cancels
+	cancels map[uint]context.CancelFunc // 正在渲染的任务，删除任务时用来中止ffmpeg
+}
+
+// NewRenderService 创建视频导出服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，生成的视频保存在StorageDir/renders下
+//   - edits: 编辑服务，渲染图片的当前版本
+//   - albums: 相册服务
+//   - slideshows: 幻灯片服务
+//
+// 返回: RenderService指针，需要调用Start启动后台任务队列
+func NewRenderService(db *gorm.DB, cfg config.Config, edits *EditService, albums *AlbumService, slideshows *SlideshowService) *RenderService {
+	ffmpeg, err := video.New(cfg.FFmpegPath, cfg.FFprobePath, 2*time.Minute)
+	if err != nil {
+		log.Printf("%v，无法导出视频", err)
+	}
+	return &RenderService{
+		db:         db,
+		cfg:        cfg,
+		edits:      edits,
+		albums:     albums,
+		slideshows: slideshows,
+		ffmpeg:     ffmpeg,
+		wake:       make(chan struct{}, 1),
+		cancels:    make(map[uint]context.CancelFunc),
+	}
+}
+
+// Start 启动后台任务队列，ctx取消时停止
+// 上次退出时正在渲染的任务重新排队；任务一次只渲染一个，ffmpeg本身会用满所有CPU
+func (s *RenderService) Start(ctx context.Context) {
+	if err := s.db.Model(&models.RenderJob{}).
+		Where("status = ?", models.RenderRunning).
+		Updates(map[string]interface{}{"status": models.RenderPending, "progress": 0}).Error; err != nil {
+		log.Printf("重新排队视频导出任务失败: %v", err)
+	}
+	go s.run(ctx)
+}
+
+// List 获取工作区的视频导出任务，按创建时间倒序
+func (s *RenderService) List(m Member) ([]models.RenderJob, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var jobs []models.RenderJob
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Get 获取工作区的视频导出任务，用于查询进度
+func (s *RenderService) Get(m Member, id uint) (*models.RenderJob, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var job models.RenderJob
+	if err := s.db.Where("id = ? AND workspace_id = ?", id, m.WorkspaceID).First(&job).Error; err != nil {
+		return nil, errors.New("导出任务不存在")
+	}
+	return &job, nil
+}
+
+// Create 创建视频导出任务并放入后台任务队列
+// 参数:
+//   - m: 创建任务的成员，需要editor及以上角色
+//   - req: 导出参数，albumId和slideshowId二选一
+//   - audio: 随请求上传的音轨，没有时为nil
+func (s *RenderService) Create(m Member, req dto.RenderRequest, audio *multipart.FileHeader) (*models.RenderJob, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	if s.ffmpeg == nil {
+		return nil, errors.New("服务器未安装ffmpeg，无法导出视频")
+	}
+	job := models.RenderJob{
+		UserID:      m.UserID,
+		WorkspaceID: m.WorkspaceID,
+		Resolution:  req.Resolution,
+		KenBurns:    req.KenBurns == nil || *req.KenBurns,
+		Audio:       req.Audio == nil || *req.Audio,
+		Status:      models.RenderPending,
+	}
+	if job.Resolution == 0 {
+		job.Resolution = 1080
+	}
+	switch {
+	case req.AlbumID != 0 && req.SlideshowID != 0:
+		return nil, errors.New("相册和幻灯片只能选择一个")
+	case req.AlbumID != 0:
+		album, err := s.albums.Get(m, req.AlbumID)
+		if err != nil {
+			return nil, err
+		}
+		job.SourceType, job.SourceID, job.Name = models.RenderSourceAlbum, album.ID, album.Name
+		job.SlideDuration = req.SlideDuration
+	case req.SlideshowID != 0:
+		show, err := s.slideshows.Get(m, req.SlideshowID)
+		if err != nil {
+			return nil, err
+		}
+		job.SourceType, job.SourceID, job.Name = models.RenderSourceSlideshow, show.ID, show.Name
+	default:
+		return nil, errors.New("请选择要导出的相册或幻灯片")
+	}
+
+	if err := s.db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	if audio != nil && job.Audio {
+		path, err := s.saveAudio(job.ID, audio)
+		if err != nil {
+			s.db.Delete(&job)
+			return nil, err
+		}
+		job.AudioPath = path
+		if err := s.db.Model(&job).UpdateColumn("audio_path", path).Error; err != nil {
+			os.Remove(path)
+			s.db.Delete(&job)
+			return nil, err
+		}
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// Open 打开已完成任务的视频文件，由调用方关闭
+func (s *RenderService) Open(m Member, id uint) (*models.RenderJob, *os.File, error) {
+	job, err := s.Get(m, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.RenderDone {
+		return nil, nil, errors.New("视频尚未导出完成")
+	}
+	f, err := os.Open(job.OutputPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, f, nil
+}
+
+// Delete 删除导出任务和生成的视频，正在渲染的任务会被中止
+func (s *RenderService) Delete(m Member, id uint) error {
+	if err := m.require(models.RoleEditor); err != nil {
+		return err
+	}
+	job, err := s.Get(m, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(&models.RenderJob{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+	}
+	s.mu.Unlock()
+	removeRenderFiles(job)
+	return nil
+}
+
+// run 后台任务队列的主循环，按创建顺序逐个渲染排队中的任务
+func (s *RenderService) run(ctx context.Context) {
+	ticker := time.NewTicker(renderPollInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			job, err := s.claim()
+			if err != nil {
+				log.Printf("获取视频导出任务失败: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.process(ctx, job)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim 取出最早的排队任务并标记为渲染中，没有排队任务时返回nil
+// 按状态条件更新，同一任务不会被多个进程同时取出
+func (s *RenderService) claim() (*models.RenderJob, error) {
+	for {
+		var job models.RenderJob
+		err := s.db.Where("status = ?", models.RenderPending).Order("id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		result := s.db.Model(&models.RenderJob{}).
+			Where("id = ? AND status = ?", job.ID, models.RenderPending).
+			Updates(map[string]interface{}{"status": models.RenderRunning, "started_at": now})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status, job.StartedAt = models.RenderRunning, &now
+			return &job, nil
+		}
+	}
+}
+
+// process 渲染一个任务并保存结果，失败时记录原因
+func (s *RenderService) process(ctx context.Context, job *models.RenderJob) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancels[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancels, job.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	output, duration, err := s.render(ctx, job)
+	if ctx.Err() != nil {
+		// 任务被删除或服务退出：删除时文件已清理，退出时任务在下次启动后重新排队
+		os.Remove(output)
+		return
+	}
+	now := time.Now()
+	updates := map[string]interface{}{"finished_at": now, "audio_path": ""}
+	if err != nil {
+		log.Printf("视频导出失败 %d: %v", job.ID, err)
+		os.Remove(output)
+		updates["status"] = models.RenderFailed
+		updates["error"] = err.Error()
+	} else {
+		updates["status"] = models.RenderDone
+		updates["progress"] = 1
+		updates["output_path"] = output
+		updates["duration"] = duration
+		if stat, err := os.Stat(output); err == nil {
+			updates["size"] = stat.Size()
+		}
+	}
+	if job.AudioPath != "" {
+		os.Remove(job.AudioPath)
+	}
+	result := s.db.Model(&models.RenderJob{}).Where("id = ?", job.ID).Updates(updates)
+	if result.Error != nil {
+		log.Printf("保存视频导出结果失败 %d: %v", job.ID, result.Error)
+	}
+	if result.Error == nil && result.RowsAffected == 0 {
+		// 渲染期间任务已被删除
+		os.Remove(output)
+	}
+}
+
+// render 准备图片并调用ffmpeg渲染，返回生成的视频路径和时长
+func (s *RenderService) render(ctx context.Context, job *models.RenderJob) (string, float64, error) {
+	dir := filepath.Join(s.cfg.StorageDir, "renders")
+	output := filepath.Join(dir, fmt.Sprintf("%d.mp4", job.ID))
+	workDir := filepath.Join(dir, fmt.Sprintf("%d_frames", job.ID))
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		return output, 0, err
+	}
+	defer os.RemoveAll(workDir)
+
+	items, audio, err := s.sourceSlides(job)
+	if err != nil {
+		return output, 0, err
+	}
+	width := renderWidths[job.Resolution]
+	slides := make([]video.Slide, 0, len(items))
+	for i, item := range items {
+		if ctx.Err() != nil {
+			return output, 0, ctx.Err()
+		}
+		// 视频只使用封面帧
+		data, err := s.edits.Rendition(item.ImageID, width)
+		if err != nil {
+			log.Printf("视频导出跳过图片 %d: %v", item.ImageID, err)
+			continue
+		}
+		path := filepath.Join(workDir, fmt.Sprintf("%04d.jpg", i))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return output, 0, err
+		}
+		slides = append(slides, video.Slide{Path: path, Duration: item.Duration, Transition: item.Transition})
+		s.saveProgress(job.ID, renderPrepareShare*float64(i+1)/float64(len(items)))
+	}
+	if len(slides) == 0 {
+		return output, 0, errors.New("没有可导出的图片")
+	}
+
+	opts := video.RenderOptions{
+		Width:    width,
+		Height:   job.Resolution,
+		FPS:      renderFPS,
+		KenBurns: job.KenBurns,
+	}
+	if job.Audio {
+		opts.Audio = audio
+	}
+	last := time.Now()
+	duration, err := s.ffmpeg.Render(ctx, slides, opts, output, func(progress float64) {
+		// 进度每秒最多写一次数据库
+		if time.Since(last) < time.Second {
+			return
+		}
+		last = time.Now()
+		s.saveProgress(job.ID, renderPrepareShare+(1-renderPrepareShare)*progress)
+	})
+	return output, duration, err
+}
+
+// sourceSlides 按任务的来源获取要渲染的图片和音轨
+// 相册中的图片使用任务指定的停留时间和淡入淡出；幻灯片使用各自的停留时间、转场和背景音乐，随任务上传的音轨优先
+func (s *RenderService) sourceSlides(job *models.RenderJob) ([]models.SlideshowItem, string, error) {
+	var items []models.SlideshowItem
+	audio := job.AudioPath
+	switch job.SourceType {
+	case models.RenderSourceAlbum:
+		images, _, err := s.albums.Images(viewerOf(job.UserID, job.WorkspaceID), job.SourceID, 1, maxRenderSlides)
+		if err != nil {
+			return nil, "", err
+		}
+		duration := job.SlideDuration
+		if duration == 0 {
+			duration = defaultSlideDuration
+		}
+		for _, img := range images {
+			items = append(items, models.SlideshowItem{ImageID: img.ID, Duration: duration, Transition: "fade"})
+		}
+	case models.RenderSourceSlideshow:
+		show, err := s.slideshows.Get(viewerOf(job.UserID, job.WorkspaceID), job.SourceID)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, item := range show.Items {
+			if item.Duration == 0 {
+				item.Duration = defaultSlideDuration
+			}
+			if item.Transition == "" {
+				item.Transition = show.Transition
+			}
+			items = append(items, item)
+		}
+		if audio == "" {
+			audio = show.MusicPath
+		}
+	default:
+		return nil, "", fmt.Errorf("未知的导出来源: %s", job.SourceType)
+	}
+	if len(items) > maxRenderSlides {
+		items = items[:maxRenderSlides]
+	}
+	return items, audio, nil
+}
+
+// saveProgress 保存任务进度
+func (s *RenderService) saveProgress(id uint, progress float64) {
+	if err := s.db.Model(&models.RenderJob{}).Where("id = ?", id).UpdateColumn("progress", progress).Error; err != nil {
+		log.Printf("保存视频导出进度失败 %d: %v", id, err)
+	}
+}
+
+// saveAudio 保存随任务上传的音轨，格式与幻灯片的背景音乐相同
+func (s *RenderService) saveAudio(jobID uint, fileHeader *multipart.FileHeader) (string, error) {
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if _, ok := musicTypes[ext]; !ok {
+		return "", errors.New("音轨只支持MP3、M4A、AAC、OGG和WAV")
+	}
+	if fileHeader.Size > maxMusicSize {
+		return "", fmt.Errorf("音轨不能超过%dMB", maxMusicSize>>20)
+	}
+	path := filepath.Join(s.cfg.StorageDir, "renders", fmt.Sprintf("%d_audio%s", jobID, ext))
+	if err := writeUpload(fileHeader, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// removeRenderFiles 删除任务生成的视频和上传的音轨
+func removeRenderFiles(job *models.RenderJob) {
+	if job.OutputPath != "" {
+		os.Remove(job.OutputPath)
+	}
+	if job.AudioPath != "" {
+		os.Remove(job.AudioPath)
+	}
+}
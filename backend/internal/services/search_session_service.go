@@ -0,0 +1,172 @@
+// Package services 提供业务逻辑层的服务实现
+// search_session_service.go 实现了对话式检索会话的业务逻辑，包括会话的创建、查询、删除以及每轮对话的记录
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxSessionResults 会话保存的结果集图片ID上限
+// 结果集用于scope=previous的细化检索，超出部分（按列表排序靠后的图片）不再保存，避免宽泛的查询写入过大的记录
+const maxSessionResults = 1000
+
+// SearchSessionService 对话式检索会话服务结构体
+// 在服务端保存多轮检索的消息历史、最近一轮的过滤条件和结果集，使追问可以在上一轮基础上细化
+// 会话属于发起检索的用户，并限定在检索时所在的工作区中
+type SearchSessionService struct {
+	db *gorm.DB // 数据库连接
+}
+
+// NewSearchSessionService 创建会话服务实例
+// 参数:
+//   - db: GORM数据库连接
+// 返回: SearchSessionService指针
+func NewSearchSessionService(db *gorm.DB) *SearchSessionService {
+	return &SearchSessionService{db: db}
+}
+
+// Create 创建新的检索会话
+// 参数:
+//   - m: 发起检索的成员
+//   - title: 会话标题，通常为首轮查询内容
+// 返回: 创建的会话和错误信息
+func (s *SearchSessionService) Create(m Member, title string) (*models.SearchSession, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	runes := []rune(title)
+	if len(runes) > 200 {
+		title = string(runes[:200])
+	}
+	session := &models.SearchSession{
+		UserID:      m.UserID,
+		WorkspaceID: m.WorkspaceID,
+		Title:       title,
+		Filters:     "{}",
+		ResultIDs:   "[]",
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Get 获取会话及其完整消息历史（按时间顺序）
+func (s *SearchSessionService) Get(m Member, sessionID uint) (*models.SearchSession, error) {
+	var session models.SearchSession
+	if err := s.db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order("search_messages.id ASC")
+	}).Where("id = ? AND user_id = ? AND workspace_id = ?", sessionID, m.UserID, m.WorkspaceID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// List 获取用户在当前工作区的所有会话（不含消息），最近活跃的在前
+func (s *SearchSessionService) List(m Member) ([]models.SearchSession, error) {
+	var sessions []models.SearchSession
+	if err := s.db.Where("user_id = ? AND workspace_id = ?", m.UserID, m.WorkspaceID).Order("updated_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete 删除会话及其消息
+func (s *SearchSessionService) Delete(m Member, sessionID uint) error {
+	var session models.SearchSession
+	if err := s.db.Where("id = ? AND user_id = ? AND workspace_id = ?", sessionID, m.UserID, m.WorkspaceID).First(&session).Error; err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.SearchMessage{}, "session_id = ?", session.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&session).Error
+	})
+}
+
+// RecordTurn 记录一轮对话
+// 追加用户查询消息和助手结果消息，并更新会话的最新过滤条件和结果集；结果集最多保存前maxSessionResults张
+// 参数:
+//   - session: 会话
+//   - query: 用户本轮的查询
+//   - filters: 本轮实际用于检索的过滤条件
+//   - resultIDs: 本轮结果集的全部图片ID
+// 返回: 错误信息
+func (s *SearchSessionService) RecordTurn(session *models.SearchSession, query string, filters map[string]string, resultIDs []uint) error {
+	filtersJSON, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	kept := resultIDs
+	if len(kept) > maxSessionResults {
+		kept = kept[:maxSessionResults]
+	}
+	idsJSON, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		messages := []models.SearchMessage{
+			{SessionID: session.ID, Role: "user", Content: query},
+			{
+				SessionID: session.ID,
+				Role:      "assistant",
+				Content:   fmt.Sprintf("找到 %d 张图片", len(resultIDs)),
+				Filters:   string(filtersJSON),
+				Total:     int64(len(resultIDs)),
+			},
+		}
+		if err := tx.Create(&messages).Error; err != nil {
+			return err
+		}
+
+		session.Filters = string(filtersJSON)
+		session.ResultIDs = string(idsJSON)
+		return tx.Model(session).Updates(map[string]interface{}{
+			"filters":    session.Filters,
+			"result_ids": session.ResultIDs,
+		}).Error
+	})
+}
+
+// SessionFilters 解析会话最近一轮的过滤条件
+func SessionFilters(session *models.SearchSession) map[string]string {
+	filters := map[string]string{}
+	if session.Filters != "" {
+		_ = json.Unmarshal([]byte(session.Filters), &filters)
+	}
+	return filters
+}
+
+// SessionResultIDs 解析会话最近一轮的结果集图片ID
+func SessionResultIDs(session *models.SearchSession) []uint {
+	ids := []uint{}
+	if session.ResultIDs != "" {
+		_ = json.Unmarshal([]byte(session.ResultIDs), &ids)
+	}
+	return ids
+}
+
+// SessionHistory 将会话消息转换为对话历史，最多保留最近limit条
+func SessionHistory(session *models.SearchSession, limit int) []ConversationTurn {
+	messages := session.Messages
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	history := make([]ConversationTurn, 0, len(messages))
+	for _, msg := range messages {
+		content := msg.Content
+		if msg.Role == "assistant" && msg.Filters != "" {
+			content = fmt.Sprintf("%s，检索条件：%s", msg.Content, msg.Filters)
+		}
+		history = append(history, ConversationTurn{Role: msg.Role, Content: content})
+	}
+	return history
+}
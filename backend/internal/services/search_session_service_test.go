@@ -0,0 +1,107 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"image-manager/internal/models"
+)
+
+func TestSearchSessionTurns(t *testing.T) {
+	db := newTestDB(t)
+	sessions := NewSearchSessionService(db)
+	alice := newTestMember(t, db, "alice")
+	bob := newTestMember(t, db, "bob")
+
+	session, err := sessions.Create(alice, strings.Repeat("猫", 300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(session.Title)); n != 200 {
+		t.Errorf("title length = %d, want 200", n)
+	}
+	if err := sessions.RecordTurn(session, "找猫", map[string]string{"tags": "猫"}, []uint{3, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.RecordTurn(session, "只要去年的", map[string]string{"tags": "猫", "start_date": "2025-01-01"}, []uint{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sessions.Get(alice, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filters := SessionFilters(got); filters["start_date"] != "2025-01-01" || filters["tags"] != "猫" {
+		t.Errorf("filters = %v, want the latest turn", filters)
+	}
+	if ids := SessionResultIDs(got); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("result IDs = %v, want [1]", ids)
+	}
+
+	// 结果集只保存前maxSessionResults张，助手消息仍记录总数
+	many := make([]uint, maxSessionResults+5)
+	for i := range many {
+		many[i] = uint(i + 1)
+	}
+	if err := sessions.RecordTurn(session, "所有照片", map[string]string{}, many); err != nil {
+		t.Fatal(err)
+	}
+	capped, err := sessions.Get(alice, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := SessionResultIDs(capped); len(ids) != maxSessionResults || ids[0] != 1 {
+		t.Errorf("stored %d result IDs, want the first %d", len(ids), maxSessionResults)
+	}
+	if last := capped.Messages[len(capped.Messages)-1]; last.Total != int64(len(many)) {
+		t.Errorf("assistant total = %d, want %d", last.Total, len(many))
+	}
+
+	history := SessionHistory(got, 0)
+	wantRoles := []string{"user", "assistant", "user", "assistant"}
+	if len(history) != len(wantRoles) {
+		t.Fatalf("history has %d turns, want %d", len(history), len(wantRoles))
+	}
+	for i, role := range wantRoles {
+		if history[i].Role != role {
+			t.Errorf("turn %d role = %s, want %s", i, history[i].Role, role)
+		}
+	}
+	if history[0].Content != "找猫" || !strings.Contains(history[1].Content, `"tags":"猫"`) {
+		t.Errorf("history = %+v", history)
+	}
+	if recent := SessionHistory(got, 2); len(recent) != 2 || recent[0].Content != "只要去年的" {
+		t.Errorf("limited history = %+v, want the last turn", recent)
+	}
+
+	// 会话只属于创建者，且限定在创建时的工作区
+	if _, err := sessions.Get(bob, session.ID); err == nil {
+		t.Error("another user should not see the session")
+	}
+	other := alice
+	other.WorkspaceID = bob.WorkspaceID
+	if _, err := sessions.Get(other, session.ID); err == nil {
+		t.Error("the session should not be visible from another workspace")
+	}
+	if err := sessions.Delete(bob, session.ID); err == nil {
+		t.Error("another user should not delete the session")
+	}
+	if err := sessions.Delete(alice, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	var messages int64
+	db.Model(&models.SearchMessage{}).Where("session_id = ?", session.ID).Count(&messages)
+	if messages != 0 {
+		t.Errorf("%d messages left after deleting the session", messages)
+	}
+}
+
+func TestSessionFiltersTolerateBadJSON(t *testing.T) {
+	session := &models.SearchSession{Filters: "not json", ResultIDs: ""}
+	if filters := SessionFilters(session); len(filters) != 0 {
+		t.Errorf("filters = %v, want empty", filters)
+	}
+	if ids := SessionResultIDs(session); ids == nil || len(ids) != 0 {
+		t.Errorf("result IDs = %v, want empty non-nil slice", ids)
+	}
+}
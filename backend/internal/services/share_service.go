@@ -0,0 +1,511 @@
+// Package services 提供业务逻辑层的服务实现
+// share_service.go 实现了公开分享链接：分享单张图片、带有指定标签的图片或一个相册，
+// 可以设置过期时间、访问密码和是否允许下载；访客只能通过令牌访问分享范围内的图片
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShareNotFound 分享不存在或已被撤销
+	ErrShareNotFound = errors.New("分享不存在或已被撤销")
+	// ErrShareExpired 分享已过期
+	ErrShareExpired = errors.New("分享已过期")
+	// ErrSharePassword 分享需要密码，或访问凭证不正确
+	ErrSharePassword = errors.New("需要输入访问密码")
+	// ErrShareDownload 分享不允许下载
+	ErrShareDownload = errors.New("该分享不允许下载")
+	// ErrShareVideo 带水印的分享不提供视频文件，访客只能看到叠加了水印的封面
+	ErrShareVideo = errors.New("该分享设置了水印，不提供视频播放")
+
+	errShareImage = errors.New("图片不在该分享中")
+)
+
+// ShareService 分享服务结构体
+type ShareService struct {
+	db     *gorm.DB
+	cfg    config.Config
+	images *ImageService // 按筛选条件列出分享范围内的图片
+	albums *AlbumService // 相册分享的相册，智能相册按筛选条件计算
+	edits  *EditService  // 下载图片的当前版本，带水印的分享由它叠加水印
+}
+
+// NewShareService 创建分享服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，JWTSecret用于签发输入密码后的访问凭证
+//   - images: 图片服务
+//   - albums: 相册服务
+//   - edits: 编辑服务
+//
+// 返回: ShareService指针
+func NewShareService(db *gorm.DB, cfg config.Config, images *ImageService, albums *AlbumService, edits *EditService) *ShareService {
+	return &ShareService{db: db, cfg: cfg, images: images, albums: albums, edits: edits}
+}
+
+// List 获取工作区的分享链接，按创建时间倒序
+func (s *ShareService) List(m Member) ([]models.ShareLink, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var links []models.ShareLink
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	for i := range links {
+		links[i].HasPassword = links[i].PasswordHash != ""
+	}
+	return links, nil
+}
+
+// Create 创建分享链接，需要editor及以上角色
+func (s *ShareService) Create(m Member, req dto.ShareRequest) (*models.ShareLink, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	link := models.ShareLink{
+		UserID:        m.UserID,
+		WorkspaceID:   m.WorkspaceID,
+		AllowDownload: req.AllowDownload,
+		ExpiresAt:     req.ExpiresAt,
+	}
+	if link.ExpiresAt != nil && link.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("过期时间必须晚于当前时间")
+	}
+	if req.WatermarkID != 0 {
+		if _, err := s.edits.watermarks.Get(m.UserID, req.WatermarkID); err != nil {
+			return nil, errors.New("水印不存在")
+		}
+		link.WatermarkID = req.WatermarkID
+	}
+
+	tags := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	targets := 0
+	for _, set := range []bool{req.ImageID != 0, len(tags) > 0, req.AlbumID != 0} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return nil, errors.New("请选择要分享的一张图片、一组标签或一个相册")
+	}
+
+	var title string
+	switch {
+	case req.ImageID != 0:
+		img, err := s.images.Get(m, req.ImageID)
+		if err != nil {
+			return nil, errors.New("图片不存在")
+		}
+		link.Kind, link.TargetID, title = models.ShareImage, img.ID, img.OriginalFilename
+	case len(tags) > 0:
+		link.Kind, link.Tags = models.ShareTags, strings.Join(tags, ",")
+		title = strings.Join(tags, "、")
+	default:
+		album, err := s.albums.Get(m, req.AlbumID)
+		if err != nil {
+			return nil, err
+		}
+		link.Kind, link.TargetID, title = models.ShareAlbum, album.ID, album.Name
+	}
+	link.Title = strings.TrimSpace(req.Title)
+	if link.Title == "" {
+		link.Title = title
+	}
+
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = string(hash)
+	}
+	token, err := newPlaybackToken()
+	if err != nil {
+		return nil, err
+	}
+	link.Token = token
+	if err := s.db.Create(&link).Error; err != nil {
+		return nil, err
+	}
+	link.HasPassword = link.PasswordHash != ""
+	return &link, nil
+}
+
+// Revoke 撤销分享链接，之后该链接无法访问；需要editor及以上角色
+func (s *ShareService) Revoke(m Member, id uint) error {
+	if err := m.require(models.RoleEditor); err != nil {
+		return err
+	}
+	result := s.db.Where("id = ? AND workspace_id = ?", id, m.WorkspaceID).Delete(&models.ShareLink{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("分享不存在")
+	}
+	s.removeWatermarked(id)
+	return nil
+}
+
+// SharedView 访客看到的分享内容
+type SharedView struct {
+	Title         string        `json:"title"`
+	Kind          string        `json:"kind"`
+	AllowDownload bool          `json:"allowDownload"`
+	Watermarked   bool          `json:"watermarked"` // 是否叠加了水印，此时视频只能看到封面
+	ExpiresAt     *time.Time    `json:"expiresAt,omitempty"`
+	Total         int64         `json:"total"`
+	Page          int           `json:"page"`
+	PageSize      int           `json:"pageSize"`
+	Items         []SharedImage `json:"items"`
+}
+
+// SharedImage 分享中的一张图片，只包含展示需要的信息，不暴露标签、EXIF和位置等
+// ID只在该分享的接口中有效（见itemID），不是图片在库中的ID
+type SharedImage struct {
+	ID         string `json:"id"`
+	Filename   string `json:"filename"`
+	MediaType  string `json:"mediaType"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	DurationMs int    `json:"durationMs,omitempty"`
+}
+
+// Unlock 校验访问密码，返回访问凭证；之后的请求带上凭证即可访问，修改或撤销分享后凭证失效
+func (s *ShareService) Unlock(token, password string) (string, error) {
+	link, err := s.byToken(token)
+	if err != nil {
+		return "", err
+	}
+	if link.PasswordHash == "" {
+		return "", nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+		return "", errors.New("访问密码错误")
+	}
+	return s.accessKey(link), nil
+}
+
+// View 分页获取分享中的图片，获取第一页时计一次访问
+// 参数key为Unlock返回的访问凭证，不需要密码的分享忽略；每页最多100张，超出范围时取默认的50张
+func (s *ShareService) View(token, key string, page, pageSize int) (*SharedView, error) {
+	link, err := s.open(token, key)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 50
+	}
+	filters, err := s.shareFilters(link)
+	if err != nil {
+		return nil, err
+	}
+	images, total, err := s.images.List(shareSource(link), filters, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if page == 1 {
+		s.db.Model(&models.ShareLink{}).Where("id = ?", link.ID).UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+	}
+
+	view := &SharedView{
+		Title:         link.Title,
+		Kind:          link.Kind,
+		AllowDownload: link.AllowDownload,
+		Watermarked:   link.WatermarkID != 0,
+		ExpiresAt:     link.ExpiresAt,
+		Total:         total,
+		Page:          page,
+		PageSize:      pageSize,
+		Items:         make([]SharedImage, len(images)),
+	}
+	for i, img := range images {
+		view.Items[i] = SharedImage{
+			ID:         s.itemID(link, img.ID),
+			Filename:   img.OriginalFilename,
+			MediaType:  img.MediaType,
+			Width:      img.Width,
+			Height:     img.Height,
+			DurationMs: img.DurationMs,
+		}
+	}
+	return view, nil
+}
+
+// Rendition 获取分享中图片当前版本缩小到size以内的JPEG，用于浏览大图；分享设置了水印时叠加水印
+func (s *ShareService) Rendition(token, key, itemID string, size int) ([]byte, error) {
+	link, img, err := s.image(token, key, itemID, false)
+	if err != nil {
+		return nil, err
+	}
+	if link.WatermarkID != 0 {
+		data, _, err := s.watermarked(link, img, size)
+		return data, err
+	}
+	return s.edits.Rendition(img.ID, size)
+}
+
+// Thumbnail 获取分享中图片的缩略图
+func (s *ShareService) Thumbnail(token, key, itemID string) (*models.Thumbnail, error) {
+	link, img, err := s.image(token, key, itemID, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.images.GetThumbnail(shareSource(link), img.ID)
+}
+
+// Video 打开分享中的视频或Live Photo动态部分的文件，由调用方关闭
+func (s *ShareService) Video(token, key, itemID string) (*models.Image, *os.File, error) {
+	link, img, err := s.image(token, key, itemID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if link.WatermarkID != 0 {
+		return nil, nil, ErrShareVideo
+	}
+	return s.images.OpenVideo(shareSource(link), img.ID)
+}
+
+// Download 获取分享中图片的当前版本用于下载，分享需要允许下载；分享设置了水印时叠加水印
+// 返回: 图片、文件内容、MIME类型和错误信息
+func (s *ShareService) Download(token, key, itemID string) (*models.Image, []byte, string, error) {
+	link, img, err := s.image(token, key, itemID, true)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if link.WatermarkID != 0 {
+		data, format, err := s.watermarked(link, img, 0)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return img, data, getMimeType(format), nil
+	}
+	data, mimeType, err := s.edits.Render(shareSource(link), img.ID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return img, data, mimeType, nil
+}
+
+// watermarked 获取叠加了分享水印的图片，size为0表示下载用的完整图片
+// 结果按分享缓存在StorageDir/shares下，图片换了版本或水印预设被修改后文件名随之变化
+// 水印预设被删除后不再回退到无水印的图片
+// 返回: 文件内容、输出格式和错误信息
+func (s *ShareService) watermarked(link *models.ShareLink, img *models.Image, size int) ([]byte, string, error) {
+	mark, err := s.edits.watermarks.Get(link.UserID, link.WatermarkID)
+	if err != nil {
+		return nil, "", errors.New("分享的水印已被删除")
+	}
+	var versionID uint
+	if img.EditVersionID != nil {
+		versionID = *img.EditVersionID
+	}
+	prefix := filepath.Join(s.cfg.StorageDir, "shares", fmt.Sprintf("%d_%d_%d_%d_%d", link.ID, img.ID, versionID, mark.UpdatedAt.Unix(), size))
+	if matches, _ := filepath.Glob(prefix + ".*"); len(matches) > 0 {
+		if data, err := os.ReadFile(matches[0]); err == nil {
+			return data, strings.TrimPrefix(filepath.Ext(matches[0]), "."), nil
+		}
+	}
+
+	data, format, err := s.edits.watermarked(img, mark, size)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(prefix), 0o755); err != nil {
+		return nil, "", err
+	}
+	if err := os.WriteFile(prefix+"."+format, data, 0o644); err != nil {
+		return nil, "", err
+	}
+	return data, format, nil
+}
+
+// removeWatermarked 删除分享的水印缓存文件
+func (s *ShareService) removeWatermarked(linkID uint) {
+	matches, _ := filepath.Glob(filepath.Join(s.cfg.StorageDir, "shares", fmt.Sprintf("%d_*", linkID)))
+	for _, path := range matches {
+		os.Remove(path)
+	}
+}
+
+// image 校验分享和访问凭证，获取itemID对应的分享范围内的一张图片
+// download为true时要求分享允许下载
+func (s *ShareService) image(token, key, itemID string, download bool) (*models.ShareLink, *models.Image, error) {
+	link, err := s.open(token, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if download && !link.AllowDownload {
+		return nil, nil, ErrShareDownload
+	}
+	imageID, err := s.parseItemID(link, itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if link.Kind == models.ShareImage && imageID != link.TargetID {
+		return nil, nil, errShareImage
+	}
+	filters, err := s.shareFilters(link)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 单张图片的筛选条件已经是ids，其他分享在分享范围内再按ID筛选
+	filters["ids"] = strconv.FormatUint(uint64(imageID), 10)
+	ids, err := s.images.ListIDs(shareSource(link), filters)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil, errShareImage
+	}
+	img, err := s.images.getRaw(imageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return link, img, nil
+}
+
+// open 按令牌获取可访问的分享：未过期，需要密码时校验访问凭证
+func (s *ShareService) open(token, key string) (*models.ShareLink, error) {
+	link, err := s.byToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if link.PasswordHash != "" && !hmac.Equal([]byte(key), []byte(s.accessKey(link))) {
+		return nil, ErrSharePassword
+	}
+	return link, nil
+}
+
+// byToken 按令牌获取未过期的分享
+func (s *ShareService) byToken(token string) (*models.ShareLink, error) {
+	if token == "" {
+		return nil, ErrShareNotFound
+	}
+	var link models.ShareLink
+	if err := s.db.Where("token = ?", token).First(&link).Error; err != nil {
+		return nil, ErrShareNotFound
+	}
+	if link.ExpiresAt != nil && link.ExpiresAt.Before(time.Now()) {
+		return nil, ErrShareExpired
+	}
+	link.HasPassword = link.PasswordHash != ""
+	return &link, nil
+}
+
+// shareFilters 分享范围对应的图片列表筛选条件
+// 相册被删除后分享随之失效
+func (s *ShareService) shareFilters(link *models.ShareLink) (map[string]string, error) {
+	switch link.Kind {
+	case models.ShareImage:
+		return map[string]string{"ids": strconv.FormatUint(uint64(link.TargetID), 10)}, nil
+	case models.ShareTags:
+		return map[string]string{"tags": link.Tags, "tag_mode": "and"}, nil
+	case models.ShareAlbum:
+		album, err := s.albums.Get(shareSource(link), link.TargetID)
+		if err != nil {
+			return nil, ErrShareNotFound
+		}
+		if album.Kind == models.AlbumSmart {
+			return AlbumFilters(album), nil
+		}
+		return map[string]string{"album": strconv.FormatUint(uint64(album.ID), 10)}, nil
+	}
+	return nil, ErrShareNotFound
+}
+
+// shareSource 以创建者的身份只读访问分享所在的工作区
+func shareSource(link *models.ShareLink) Member {
+	return viewerOf(link.UserID, link.WorkspaceID)
+}
+
+// itemID 分享中图片对外的ID：用由分享令牌派生的密钥加密图片ID
+// 访客看不到图片在库中的ID，得到的ID也只能在同一个分享的接口中使用
+func (s *ShareService) itemID(link *models.ShareLink, imageID uint) string {
+	return encryptItemID(s.itemCipher(link), imageID)
+}
+
+// parseItemID 解析itemID得到图片ID
+func (s *ShareService) parseItemID(link *models.ShareLink, itemID string) (uint, error) {
+	imageID, ok := decryptItemID(s.itemCipher(link), itemID)
+	if !ok {
+		return 0, errShareImage
+	}
+	return imageID, nil
+}
+
+// itemCipher 加密分享中图片ID的AES密钥，每个分享不同
+func (s *ShareService) itemCipher(link *models.ShareLink) cipher.Block {
+	return newItemCipher(s.cfg.JWTSecret, "share-item", link.Token)
+}
+
+// newItemCipher 由JWT密钥、用途和公开令牌派生加密图片ID的AES密钥
+// 分享链接和幻灯片播放使用不同的用途，同一个令牌在两处得到的ID也不同
+func newItemCipher(secret, purpose, token string) cipher.Block {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + "\x00" + token))
+	block, err := aes.NewCipher(mac.Sum(nil)[:16])
+	if err != nil {
+		// 密钥长度固定为16字节，不会出错
+		panic(err)
+	}
+	return block
+}
+
+// encryptItemID 加密图片ID，得到公开接口中使用的ID
+func encryptItemID(c cipher.Block, imageID uint) string {
+	block := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(block, uint64(imageID))
+	c.Encrypt(block, block)
+	return base64.RawURLEncoding.EncodeToString(block)
+}
+
+// decryptItemID 解密公开接口中的ID；解密后后半部分不为零说明不是用该密钥加密的ID
+func decryptItemID(c cipher.Block, itemID string) (uint, bool) {
+	block, err := base64.RawURLEncoding.DecodeString(itemID)
+	if err != nil || len(block) != aes.BlockSize {
+		return 0, false
+	}
+	c.Decrypt(block, block)
+	if binary.BigEndian.Uint64(block[8:]) != 0 {
+		return 0, false
+	}
+	return uint(binary.BigEndian.Uint64(block)), true
+}
+
+// accessKey 输入密码后的访问凭证，由令牌和密码哈希签名得到，修改密码后失效
+func (s *ShareService) accessKey(link *models.ShareLink) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.JWTSecret))
+	mac.Write([]byte(link.Token + "\x00" + link.PasswordHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+	"image-manager/internal/watermark"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestShareItemID(t *testing.T) {
+	s := &ShareService{cfg: config.Config{JWTSecret: "test-secret"}}
+	link := &models.ShareLink{Token: "token-a"}
+	other := &models.ShareLink{Token: "token-b"}
+
+	itemID := s.itemID(link, 42)
+	if itemID == "42" || itemID == s.itemID(other, 42) {
+		t.Fatalf("item ID %q should be opaque and differ between links", itemID)
+	}
+	if got, err := s.parseItemID(link, itemID); err != nil || got != 42 {
+		t.Fatalf("parseItemID = %d, %v; want 42", got, err)
+	}
+
+	tests := []struct {
+		name   string
+		link   *models.ShareLink
+		itemID string
+	}{
+		{"other link", other, itemID},
+		{"raw image ID", link, "42"},
+		{"not base64", link, "!!"},
+		{"empty", link, ""},
+	}
+	for _, tt := range tests {
+		if _, err := s.parseItemID(tt.link, tt.itemID); !errors.Is(err, errShareImage) {
+			t.Errorf("%s: err = %v, want errShareImage", tt.name, err)
+		}
+	}
+}
+
+func TestShareWatermark(t *testing.T) {
+	db := newTestDB(t)
+	storage := t.TempDir()
+	cfg := config.Config{StorageDir: storage, JWTSecret: "test-secret"}
+	tags := NewTagService(db)
+	images := NewImageService(db, cfg, tags, nil)
+	watermarks := NewWatermarkService(db, cfg)
+	edits := NewEditService(db, cfg, images, watermarks)
+	shares := NewShareService(db, cfg, images, NewAlbumService(db, images), edits)
+
+	alice := newTestMember(t, db, "alice")
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, imaging.New(200, 100, color.Black), imaging.JPEG); err != nil {
+		t.Fatal(err)
+	}
+	img := newTestImage(t, db, alice, models.Image{
+		StoredFilename: "100_photo.jpg",
+		FilePath:       writeTestFile(t, "100_photo.jpg", buf.Bytes()),
+		MimeType:       "image/jpeg",
+		Width:          200,
+		Height:         100,
+	})
+	mark := models.Watermark{UserID: alice.UserID, Name: "署名", Type: watermark.TypeText, Text: "ALICE",
+		Position: watermark.PositionCenter, Opacity: 1, Scale: 0.8}
+	if err := db.Create(&mark).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := shares.Create(alice, dto.ShareRequest{ImageID: img.ID, WatermarkID: mark.ID + 1}); err == nil {
+		t.Fatal("sharing with another user's watermark should fail")
+	}
+	link, err := shares.Create(alice, dto.ShareRequest{ImageID: img.ID, AllowDownload: true, WatermarkID: mark.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID := shares.itemID(link, img.ID)
+
+	// 黑色原图叠加白色文字后中心附近应出现亮像素
+	data, err := shares.Rendition(link.Token, "", itemID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasBrightPixel(t, data) {
+		t.Error("rendition should carry the watermark")
+	}
+	_, data, mimeType, err := shares.Download(link.Token, "", itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mimeType != "image/jpeg" || !hasBrightPixel(t, data) {
+		t.Errorf("download should be a watermarked JPEG, got %s", mimeType)
+	}
+	cached, _ := filepath.Glob(filepath.Join(storage, "shares", "*"))
+	if len(cached) != 2 {
+		t.Errorf("cached files = %v, want rendition and download", cached)
+	}
+	if _, _, err := shares.Video(link.Token, "", itemID); !errors.Is(err, ErrShareVideo) {
+		t.Errorf("Video err = %v, want ErrShareVideo", err)
+	}
+
+	// 水印预设删除后不回退到无水印的图片
+	if err := watermarks.Delete(alice.UserID, mark.ID); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Join(storage, "shares"))
+	if _, err := shares.Rendition(link.Token, "", itemID, 100); err == nil {
+		t.Error("rendition should fail once the watermark is deleted")
+	}
+
+	if err := shares.Revoke(alice, link.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShareViewPageSize(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.Config{StorageDir: t.TempDir(), JWTSecret: "test-secret"}
+	images := NewImageService(db, cfg, NewTagService(db), nil)
+	edits := NewEditService(db, cfg, images, NewWatermarkService(db, cfg))
+	shares := NewShareService(db, cfg, images, NewAlbumService(db, images), edits)
+
+	alice := newTestMember(t, db, "alice")
+	img := newTestImage(t, db, alice, models.Image{StoredFilename: "photo.jpg"})
+	link, err := shares.Create(alice, dto.ShareRequest{ImageID: img.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		page, pageSize         int
+		wantPage, wantPageSize int
+	}{
+		{1, 20, 1, 20},
+		{1, 100, 1, 100},
+		{1, 100000, 1, 50},
+		{0, 0, 1, 50},
+		{-3, -1, 1, 50},
+	}
+	for _, tt := range tests {
+		view, err := shares.View(link.Token, "", tt.page, tt.pageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if view.Page != tt.wantPage || view.PageSize != tt.wantPageSize || len(view.Items) != 1 {
+			t.Errorf("View(%d, %d) = page %d, size %d, %d items; want %d, %d, 1",
+				tt.page, tt.pageSize, view.Page, view.PageSize, len(view.Items), tt.wantPage, tt.wantPageSize)
+		}
+	}
+}
+
+// hasBrightPixel 解码图片，判断是否存在接近白色的像素
+func hasBrightPixel(t *testing.T, data []byte) bool {
+	t.Helper()
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := decoded.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, g, b, _ := decoded.At(x, y).RGBA(); r > 0xc000 && g > 0xc000 && b > 0xc000 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,506 @@
+// Package services 提供业务逻辑层的服务实现
+// slideshow_service.go 实现了幻灯片管理：图片顺序、停留时间、转场效果和背景音乐保存在服务端，
+// 并通过公开的播放令牌提供播放清单，未登录的设备（如电视浏览器）也可以播放
+package services
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultSlideDuration 未指定停留时间的图片的默认停留秒数
+	defaultSlideDuration = 5
+	// maxMusicSize 背景音乐的最大文件大小
+	maxMusicSize = 30 << 20
+	// playbackBasePath 公开播放接口的路径前缀，播放清单中的地址都以它开头
+	playbackBasePath = "/api/v1/play/"
+)
+
+// RenditionSizes 播放清单提供的渲染尺寸（最长边像素），分别对应720p、1080p和4K屏幕
+var RenditionSizes = []int{1280, 1920, 3840}
+
+// musicTypes 支持的背景音乐格式
+var musicTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".m4a": "audio/mp4",
+	".aac": "audio/aac",
+	".ogg": "audio/ogg",
+	".wav": "audio/wav",
+}
+
+// SlideshowService 幻灯片服务结构体
+type SlideshowService struct {
+	db    *gorm.DB
+	cfg   config.Config
+	edits *EditService // 生成按屏幕尺寸缩小的渲染结果
+}
+
+// NewSlideshowService 创建幻灯片服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，背景音乐保存在StorageDir/music下
+//   - edits: 编辑服务，播放时按尺寸渲染图片的当前版本
+//
+// 返回: SlideshowService指针
+func NewSlideshowService(db *gorm.DB, cfg config.Config, edits *EditService) *SlideshowService {
+	return &SlideshowService{db: db, cfg: cfg, edits: edits}
+}
+
+// List 获取工作区的幻灯片列表，按更新时间倒序
+func (s *SlideshowService) List(m Member) ([]models.Slideshow, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var shows []models.Slideshow
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).
+		Preload("Items", orderSlides).
+		Order("updated_at DESC").
+		Find(&shows).Error; err != nil {
+		return nil, err
+	}
+	return shows, nil
+}
+
+// Get 获取工作区的幻灯片及其图片
+func (s *SlideshowService) Get(m Member, id uint) (*models.Slideshow, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var show models.Slideshow
+	if err := s.db.Where("id = ? AND workspace_id = ?", id, m.WorkspaceID).
+		Preload("Items", orderSlides).
+		First(&show).Error; err != nil {
+		return nil, errors.New("幻灯片不存在")
+	}
+	return &show, nil
+}
+
+// Create 创建幻灯片并生成公开播放令牌
+func (s *SlideshowService) Create(m Member, req dto.SlideshowRequest) (*models.Slideshow, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	token, err := newPlaybackToken()
+	if err != nil {
+		return nil, err
+	}
+	show := models.Slideshow{UserID: m.UserID, WorkspaceID: m.WorkspaceID, Token: token}
+	items, err := s.fill(&show, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&show).Error; err != nil {
+			return err
+		}
+		return createSlides(tx, show.ID, items)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(m, show.ID)
+}
+
+// Update 修改幻灯片的名称、默认转场和图片列表，图片列表整体替换
+func (s *SlideshowService) Update(m Member, id uint, req dto.SlideshowRequest) (*models.Slideshow, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	show, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.fill(show, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Slideshow{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"name":       show.Name,
+			"transition": show.Transition,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.SlideshowItem{}, "slideshow_id = ?", id).Error; err != nil {
+			return err
+		}
+		return createSlides(tx, id, items)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(m, id)
+}
+
+// Delete 删除幻灯片及其背景音乐，图片本身不受影响
+func (s *SlideshowService) Delete(m Member, id uint) error {
+	if err := m.require(models.RoleEditor); err != nil {
+		return err
+	}
+	show, err := s.Get(m, id)
+	if err != nil {
+		return err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.SlideshowItem{}, "slideshow_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Slideshow{}, "id = ?", id).Error
+	})
+	if err != nil {
+		return err
+	}
+	if show.MusicPath != "" {
+		os.Remove(show.MusicPath)
+	}
+	return nil
+}
+
+// SetMusic 上传背景音乐，替换原有的音乐
+func (s *SlideshowService) SetMusic(m Member, id uint, fileHeader *multipart.FileHeader) (*models.Slideshow, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	show, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	mimeType, ok := musicTypes[ext]
+	if !ok {
+		return nil, errors.New("背景音乐只支持MP3、M4A、AAC、OGG和WAV")
+	}
+	if fileHeader.Size > maxMusicSize {
+		return nil, fmt.Errorf("背景音乐不能超过%dMB", maxMusicSize>>20)
+	}
+
+	path := filepath.Join(s.cfg.StorageDir, "music", fmt.Sprintf("%d_%d%s", show.ID, time.Now().UnixNano(), ext))
+	if err := writeUpload(fileHeader, path); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Slideshow{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"music_path":      path,
+		"music_mime_type": mimeType,
+		"music_name":      fileHeader.Filename,
+	}).Error; err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	if show.MusicPath != "" {
+		os.Remove(show.MusicPath)
+	}
+	return s.Get(m, id)
+}
+
+// RemoveMusic 删除背景音乐
+func (s *SlideshowService) RemoveMusic(m Member, id uint) (*models.Slideshow, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	show, err := s.Get(m, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Slideshow{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"music_path":      "",
+		"music_mime_type": "",
+		"music_name":      "",
+	}).Error; err != nil {
+		return nil, err
+	}
+	if show.MusicPath != "" {
+		os.Remove(show.MusicPath)
+	}
+	return s.Get(m, id)
+}
+
+// ResetToken 重新生成公开播放令牌，之前分享的播放链接失效
+func (s *SlideshowService) ResetToken(m Member, id uint) (*models.Slideshow, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
+	if _, err := s.Get(m, id); err != nil {
+		return nil, err
+	}
+	token, err := newPlaybackToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Slideshow{}).Where("id = ?", id).Update("token", token).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(m, id)
+}
+
+// PlaybackManifest 公开播放清单，地址都是无需登录的公开接口
+type PlaybackManifest struct {
+	Name       string          `json:"name"`
+	Transition string          `json:"transition"`         // 默认转场效果
+	MusicURL   string          `json:"musicUrl,omitempty"` // 背景音乐地址，没有背景音乐时为空
+	Sizes      []int           `json:"sizes"`              // 提供的渲染尺寸（最长边像素）
+	Slides     []PlaybackSlide `json:"slides"`             // 按播放顺序排列
+}
+
+// PlaybackSlide 播放清单中的一张图片
+type PlaybackSlide struct {
+	ID         string            `json:"id"`                 // 只在该幻灯片的播放接口中有效，不是图片ID
+	MediaType  string            `json:"mediaType"`          // image、video或live，视频的图片地址是封面帧
+	Duration   float64           `json:"duration"`           // 停留秒数，视频为0时播放完整视频
+	Transition string            `json:"transition"`         // 切换到这张图片时的转场效果
+	Caption    string            `json:"caption,omitempty"`  // 说明文字
+	Width      int               `json:"width"`              // 当前版本的宽度
+	Height     int               `json:"height"`             // 当前版本的高度
+	Src        string            `json:"src"`                // 默认地址（1080p）
+	Renditions map[string]string `json:"renditions"`         // 各尺寸的地址，键为最长边像素；不超过原图尺寸
+	VideoURL   string            `json:"videoUrl,omitempty"` // 视频地址，支持Range请求
+}
+
+// Manifest 按公开播放令牌生成播放清单
+func (s *SlideshowService) Manifest(token string) (*PlaybackManifest, error) {
+	show, err := s.byToken(token)
+	if err != nil {
+		return nil, err
+	}
+	imageIDs := make([]uint, len(show.Items))
+	for i, item := range show.Items {
+		imageIDs[i] = item.ImageID
+	}
+	var images []models.Image
+	if err := s.db.Where("id IN ?", imageIDs).Find(&images).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Image, len(images))
+	var versionIDs []uint
+	for i := range images {
+		byID[images[i].ID] = &images[i]
+		if images[i].EditVersionID != nil {
+			versionIDs = append(versionIDs, *images[i].EditVersionID)
+		}
+	}
+	// 编辑过的图片按当前版本的尺寸
+	var versions []models.ImageVersion
+	if len(versionIDs) > 0 {
+		if err := s.db.Select("id", "width", "height").Where("id IN ?", versionIDs).Find(&versions).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	base := playbackBasePath + show.Token
+	manifest := &PlaybackManifest{
+		Name:       show.Name,
+		Transition: show.Transition,
+		Sizes:      RenditionSizes,
+		Slides:     make([]PlaybackSlide, 0, len(show.Items)),
+	}
+	if show.MusicPath != "" {
+		manifest.MusicURL = base + "/music"
+	}
+	for _, item := range show.Items {
+		img, ok := byID[item.ImageID]
+		if !ok {
+			continue
+		}
+		itemID := s.itemID(show, img.ID)
+		slide := PlaybackSlide{
+			ID:         itemID,
+			MediaType:  img.MediaType,
+			Duration:   item.Duration,
+			Transition: item.Transition,
+			Caption:    item.Caption,
+			Width:      img.Width,
+			Height:     img.Height,
+			Renditions: map[string]string{},
+		}
+		if slide.Transition == "" {
+			slide.Transition = show.Transition
+		}
+		for _, version := range versions {
+			if img.EditVersionID != nil && version.ID == *img.EditVersionID {
+				slide.Width, slide.Height = version.Width, version.Height
+			}
+		}
+		if img.MediaType == models.MediaVideo {
+			slide.VideoURL = fmt.Sprintf("%s/images/%s/video", base, itemID)
+		} else if slide.Duration == 0 {
+			slide.Duration = defaultSlideDuration
+		}
+		// 原图比某个尺寸小时不再提供更大的尺寸，它们的内容相同
+		longest := max(slide.Width, slide.Height)
+		for _, size := range RenditionSizes {
+			url := fmt.Sprintf("%s/images/%s?size=%d", base, itemID, size)
+			slide.Renditions[strconv.Itoa(size)] = url
+			if size <= 1920 || slide.Src == "" {
+				slide.Src = url
+			}
+			if size >= longest {
+				break
+			}
+		}
+		manifest.Slides = append(manifest.Slides, slide)
+	}
+	return manifest, nil
+}
+
+// Rendition 按公开播放令牌返回幻灯片中一张图片缩小到指定尺寸的JPEG
+// itemID为播放清单中的ID，只能获取该幻灯片中的图片，尺寸必须是RenditionSizes之一
+func (s *SlideshowService) Rendition(token, itemID string, size int) ([]byte, error) {
+	if !slices.Contains(RenditionSizes, size) {
+		return nil, fmt.Errorf("不支持的尺寸: %d", size)
+	}
+	_, imageID, err := s.slide(token, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return s.edits.Rendition(imageID, size)
+}
+
+// OpenVideo 按公开播放令牌打开幻灯片中视频的文件，由调用方关闭
+// 只能打开该幻灯片中的视频，返回的文件支持Seek，用于按Range请求分段传输
+func (s *SlideshowService) OpenVideo(token, itemID string) (*models.Image, *os.File, error) {
+	show, imageID, err := s.slide(token, itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 以创建者的身份只读访问幻灯片所在的工作区
+	return s.edits.images.OpenVideo(viewerOf(show.UserID, show.WorkspaceID), imageID)
+}
+
+// slide 按公开播放令牌获取幻灯片，解析itemID并确认图片在其中
+func (s *SlideshowService) slide(token, itemID string) (*models.Slideshow, uint, error) {
+	show, err := s.byToken(token)
+	if err != nil {
+		return nil, 0, err
+	}
+	if imageID, ok := decryptItemID(s.itemCipher(show), itemID); ok {
+		for _, item := range show.Items {
+			if item.ImageID == imageID {
+				return show, imageID, nil
+			}
+		}
+	}
+	return nil, 0, errors.New("图片不在该幻灯片中")
+}
+
+// itemID 幻灯片中图片对外的ID，与分享链接相同，用由播放令牌派生的密钥加密图片ID；重置令牌后旧的ID失效
+func (s *SlideshowService) itemID(show *models.Slideshow, imageID uint) string {
+	return encryptItemID(s.itemCipher(show), imageID)
+}
+
+// itemCipher 加密幻灯片中图片ID的AES密钥，每个播放令牌不同
+func (s *SlideshowService) itemCipher(show *models.Slideshow) cipher.Block {
+	return newItemCipher(s.cfg.JWTSecret, "slide-item", show.Token)
+}
+
+// OpenMusic 按公开播放令牌打开背景音乐文件，由调用方关闭
+func (s *SlideshowService) OpenMusic(token string) (*models.Slideshow, *os.File, error) {
+	show, err := s.byToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if show.MusicPath == "" {
+		return nil, nil, errors.New("该幻灯片没有背景音乐")
+	}
+	f, err := os.Open(show.MusicPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return show, f, nil
+}
+
+// byToken 按公开播放令牌获取幻灯片
+func (s *SlideshowService) byToken(token string) (*models.Slideshow, error) {
+	if token == "" {
+		return nil, errors.New("幻灯片不存在")
+	}
+	var show models.Slideshow
+	if err := s.db.Where("token = ?", token).Preload("Items", orderSlides).First(&show).Error; err != nil {
+		return nil, errors.New("幻灯片不存在")
+	}
+	return &show, nil
+}
+
+// fill 校验请求并填充幻灯片的名称和默认转场，返回按顺序排列的图片（尚未关联幻灯片ID）
+func (s *SlideshowService) fill(show *models.Slideshow, req dto.SlideshowRequest) ([]models.SlideshowItem, error) {
+	show.Name = strings.TrimSpace(req.Name)
+	if show.Name == "" {
+		return nil, errors.New("幻灯片名称不能为空")
+	}
+	show.Transition = req.Transition
+	if show.Transition == "" {
+		show.Transition = "fade"
+	}
+
+	ids := make([]uint, len(req.Items))
+	for i, item := range req.Items {
+		ids[i] = item.ImageID
+	}
+	ids = uniqueIDs(ids)
+	if len(ids) > 0 {
+		var count int64
+		if err := s.db.Model(&models.Image{}).Where("workspace_id = ? AND id IN ?", show.WorkspaceID, ids).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if int(count) != len(ids) {
+			return nil, errors.New("部分图片不存在")
+		}
+	}
+
+	items := make([]models.SlideshowItem, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = models.SlideshowItem{
+			ImageID:    item.ImageID,
+			Position:   i,
+			Duration:   item.Duration,
+			Transition: item.Transition,
+			Caption:    strings.TrimSpace(item.Caption),
+		}
+	}
+	return items, nil
+}
+
+// createSlides 保存幻灯片的图片
+func createSlides(tx *gorm.DB, slideshowID uint, items []models.SlideshowItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].SlideshowID = slideshowID
+	}
+	return tx.Create(&items).Error
+}
+
+// removeImageFromSlideshows 在删除图片的事务中把图片移出所有幻灯片
+func removeImageFromSlideshows(tx *gorm.DB, imageID uint) error {
+	return tx.Delete(&models.SlideshowItem{}, "image_id = ?", imageID).Error
+}
+
+// orderSlides 按播放顺序预加载幻灯片的图片
+func orderSlides(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// newPlaybackToken 生成公开播放令牌
+func newPlaybackToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
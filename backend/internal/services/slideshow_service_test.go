@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"image-manager/internal/config"
+	"image-manager/internal/models"
+)
+
+func TestSlideshowOpenVideo(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMember(t, db, "alice")
+	images := NewImageService(db, config.Config{StorageDir: t.TempDir()}, nil, nil)
+	s := NewSlideshowService(db, config.Config{JWTSecret: "test-secret"}, NewEditService(db, config.Config{}, images, nil))
+
+	video := newTestImage(t, db, m, models.Image{
+		MediaType:     models.MediaVideo,
+		VideoFilePath: writeTestFile(t, "clip.mp4", []byte("mp4 data")),
+		VideoMimeType: "video/mp4",
+	})
+	// 同一工作区中不在幻灯片里的视频
+	other := newTestImage(t, db, m, models.Image{
+		MediaType:     models.MediaVideo,
+		VideoFilePath: writeTestFile(t, "other.mp4", []byte("other")),
+		VideoMimeType: "video/mp4",
+	})
+	show := models.Slideshow{UserID: m.UserID, WorkspaceID: m.WorkspaceID, Name: "夏天", Token: "play-token",
+		Items: []models.SlideshowItem{{ImageID: video.ID}}}
+	if err := db.Create(&show).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := s.Manifest(show.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID := s.itemID(&show, video.ID)
+	if itemID == fmt.Sprint(video.ID) || manifest.Slides[0].ID != itemID {
+		t.Errorf("slide ID = %q, want the opaque item ID %q", manifest.Slides[0].ID, itemID)
+	}
+	if want := "/api/v1/play/play-token/images/" + itemID + "/video"; manifest.Slides[0].VideoURL != want {
+		t.Errorf("VideoURL = %q, want %q", manifest.Slides[0].VideoURL, want)
+	}
+
+	img, file, err := s.OpenVideo(show.Token, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if img.VideoMimeType != "video/mp4" || string(data) != "mp4 data" {
+		t.Errorf("OpenVideo = %q (%s), want the slideshow's video", data, img.VideoMimeType)
+	}
+
+	reset := show
+	reset.Token = "new-token"
+	tests := []struct {
+		name   string
+		token  string
+		itemID string
+	}{
+		{"image not in slideshow", show.Token, s.itemID(&show, other.ID)},
+		{"raw image ID", show.Token, fmt.Sprint(video.ID)},
+		{"item ID of another token", show.Token, s.itemID(&reset, video.ID)},
+		{"wrong token", "nope", itemID},
+		{"empty token", "", itemID},
+	}
+	for _, tt := range tests {
+		if _, f, err := s.OpenVideo(tt.token, tt.itemID); err == nil {
+			f.Close()
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -11,36 +11,64 @@ import (
 )
 
 type TagService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	listeners []func(workspaceID uint) // 标签库变更回调（新增、删除、改名时触发）
 }
 
 func NewTagService(db *gorm.DB) *TagService {
 	return &TagService{db: db}
 }
 
-func (s *TagService) Create(userID uint, req dto.CreateTagRequest) (*models.Tag, error) {
+// OnChange 注册标签库变更回调
+// 工作区的标签库（标签名集合）发生变化时，以该工作区ID调用所有回调，用于清理依赖标签库的缓存
+func (s *TagService) OnChange(listener func(workspaceID uint)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// notifyChanged 通知标签库变更
+func (s *TagService) notifyChanged(workspaceID uint) {
+	for _, listener := range s.listeners {
+		listener(workspaceID)
+	}
+}
+
+// 标签属于工作区：任何成员都可以查看，editor及以上可以创建标签和修改图片的标签，
+// 删除标签会影响所有成员的图片，需要admin及以上角色
+
+func (s *TagService) Create(m Member, req dto.CreateTagRequest) (*models.Tag, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
 	tag := models.Tag{
-		UserID: userID,
-		Name:   req.Name,
-		Color:  req.Color,
+		UserID:      m.UserID,
+		WorkspaceID: m.WorkspaceID,
+		Name:        req.Name,
+		Color:       req.Color,
 	}
 	if err := s.db.Create(&tag).Error; err != nil {
 		return nil, err
 	}
+	s.notifyChanged(m.WorkspaceID)
 	return &tag, nil
 }
 
-func (s *TagService) List(userID uint) ([]models.Tag, error) {
+func (s *TagService) List(m Member) ([]models.Tag, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	var tags []models.Tag
-	if err := s.db.Where("user_id = ?", userID).Find(&tags).Error; err != nil {
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).Find(&tags).Error; err != nil {
 		return nil, err
 	}
 	return tags, nil
 }
 
-func (s *TagService) Assign(imageID, tagID uint, userID uint) error {
+func (s *TagService) Assign(imageID, tagID uint, m Member) error {
+	if err := s.checkImage(m, imageID); err != nil {
+		return err
+	}
 	var tag models.Tag
-	if err := s.db.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+	if err := s.db.Where("id = ? AND workspace_id = ?", tagID, m.WorkspaceID).First(&tag).Error; err != nil {
 		return err
 	}
 
@@ -52,10 +80,13 @@ func (s *TagService) Assign(imageID, tagID uint, userID uint) error {
 	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&association).Error
 }
 
-func (s *TagService) AssignByNames(userID, imageID uint, names []string) error {
+func (s *TagService) AssignByNames(m Member, imageID uint, names []string) error {
 	if len(names) == 0 {
 		return nil
 	}
+	if err := s.checkImage(m, imageID); err != nil {
+		return err
+	}
 
 	// 先对标签名去重
 	uniqueNames := make(map[string]bool)
@@ -70,24 +101,26 @@ func (s *TagService) AssignByNames(userID, imageID uint, names []string) error {
 	for _, name := range deduplicatedNames {
 		var tag models.Tag
 		// 先查找是否存在该标签
-		err := s.db.Where("user_id = ? AND name = ?", userID, name).First(&tag).Error
+		err := s.db.Where("workspace_id = ? AND name = ?", m.WorkspaceID, name).First(&tag).Error
 		if err != nil {
 			// 如果不存在，创建新标签，颜色为空（无色）
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				tag = models.Tag{
-			UserID: userID,
-			Name:   name,
-					Color:  "", // 自动创建的标签颜色为空
+			UserID:      m.UserID,
+			WorkspaceID: m.WorkspaceID,
+			Name:        name,
+					Color:       "", // 自动创建的标签颜色为空
 				}
 				if err := s.db.Create(&tag).Error; err != nil {
 					return err
 		}
+				s.notifyChanged(m.WorkspaceID)
 			} else {
 			return err
 			}
 		}
 		// 如果标签已存在，使用现有的标签（包括其颜色）
-		if err := s.Assign(imageID, tag.ID, userID); err != nil {
+		if err := s.Assign(imageID, tag.ID, m); err != nil {
 			return err
 		}
 	}
@@ -96,32 +129,38 @@ func (s *TagService) AssignByNames(userID, imageID uint, names []string) error {
 	return s.deduplicateImageTags(imageID)
 }
 
-func (s *TagService) AssignBulk(userID, imageID uint, tagIDs []uint) error {
+func (s *TagService) AssignBulk(m Member, imageID uint, tagIDs []uint) error {
 	if len(tagIDs) == 0 {
 		return errors.New("标签不能为空")
 	}
 	for _, tagID := range tagIDs {
-		if err := s.Assign(imageID, tagID, userID); err != nil {
+		if err := s.Assign(imageID, tagID, m); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (s *TagService) Remove(imageID, tagID, userID uint) error {
+func (s *TagService) Remove(imageID, tagID uint, m Member) error {
+	if err := s.checkImage(m, imageID); err != nil {
+		return err
+	}
 	return s.db.Where("image_id = ? AND tag_id = ?", imageID, tagID).Delete(&models.ImageTag{}).Error
 }
 
 // Delete 删除标签
 // 删除标签时，同时删除所有图片与该标签的关联（ImageTag）
 // 参数:
-//   - userID: 用户ID，确保只能删除自己的标签
+//   - m: 执行删除的成员，需要admin及以上角色，只能删除当前工作区的标签
 //   - tagID: 要删除的标签ID
 // 返回: 错误信息
-func (s *TagService) Delete(userID, tagID uint) error {
-	// 先验证标签是否存在且属于该用户
+func (s *TagService) Delete(m Member, tagID uint) error {
+	if err := m.require(models.RoleAdmin); err != nil {
+		return err
+	}
+	// 先验证标签是否存在且属于该工作区
 	var tag models.Tag
-	if err := s.db.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+	if err := s.db.Where("id = ? AND workspace_id = ?", tagID, m.WorkspaceID).First(&tag).Error; err != nil {
 		return err
 	}
 
@@ -134,13 +173,17 @@ func (s *TagService) Delete(userID, tagID uint) error {
 	if err := s.db.Delete(&tag).Error; err != nil {
 		return err
 	}
+	s.notifyChanged(m.WorkspaceID)
 
 	return nil
 }
 
-func (s *TagService) UpdateColor(userID, tagID uint, color string) (*models.Tag, error) {
+func (s *TagService) UpdateColor(m Member, tagID uint, color string) (*models.Tag, error) {
+	if err := m.require(models.RoleEditor); err != nil {
+		return nil, err
+	}
 	var tag models.Tag
-	if err := s.db.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+	if err := s.db.Where("id = ? AND workspace_id = ?", tagID, m.WorkspaceID).First(&tag).Error; err != nil {
 		return nil, err
 	}
 	
@@ -155,27 +198,32 @@ func (s *TagService) UpdateColor(userID, tagID uint, color string) (*models.Tag,
 // UpdateImageTag 修改图片的标签（将旧标签替换为新标签）
 // 如果新标签不存在，使用旧标签的颜色创建新标签
 // 如果新标签已存在，直接使用该标签
-func (s *TagService) UpdateImageTag(userID, imageID uint, oldTagID uint, newTagName string) error {
+func (s *TagService) UpdateImageTag(m Member, imageID uint, oldTagID uint, newTagName string) error {
+	if err := s.checkImage(m, imageID); err != nil {
+		return err
+	}
 	// 获取旧标签信息（包括颜色）
 	var oldTag models.Tag
-	if err := s.db.Where("id = ? AND user_id = ?", oldTagID, userID).First(&oldTag).Error; err != nil {
+	if err := s.db.Where("id = ? AND workspace_id = ?", oldTagID, m.WorkspaceID).First(&oldTag).Error; err != nil {
 		return err
 	}
 
 	// 查找新标签是否存在
 	var newTag models.Tag
-	err := s.db.Where("user_id = ? AND name = ?", userID, newTagName).First(&newTag).Error
+	err := s.db.Where("workspace_id = ? AND name = ?", m.WorkspaceID, newTagName).First(&newTag).Error
 	if err != nil {
 		// 如果新标签不存在，使用旧标签的颜色创建新标签
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			newTag = models.Tag{
-				UserID: userID,
-				Name:   newTagName,
-				Color:  oldTag.Color, // 使用旧标签的颜色
+				UserID:      m.UserID,
+				WorkspaceID: m.WorkspaceID,
+				Name:        newTagName,
+				Color:       oldTag.Color, // 使用旧标签的颜色
 			}
 			if err := s.db.Create(&newTag).Error; err != nil {
 				return err
 			}
+			s.notifyChanged(m.WorkspaceID)
 		} else {
 			return err
 		}
@@ -201,7 +249,10 @@ func (s *TagService) UpdateImageTag(userID, imageID uint, oldTagID uint, newTagN
 
 // AddImageTagByName 通过标签名给图片添加标签
 // 如果标签不存在，创建新标签，颜色为空
-func (s *TagService) AddImageTagByName(userID, imageID uint, tagName string) error {
+func (s *TagService) AddImageTagByName(m Member, imageID uint, tagName string) error {
+	if err := s.checkImage(m, imageID); err != nil {
+		return err
+	}
 	// 检查该图片是否已经有这个标签
 	var existingAssociations []models.ImageTag
 	if err := s.db.Where("image_id = ?", imageID).Find(&existingAssociations).Error; err != nil {
@@ -210,18 +261,20 @@ func (s *TagService) AddImageTagByName(userID, imageID uint, tagName string) err
 
 	// 查找该标签是否存在
 	var tag models.Tag
-	err := s.db.Where("user_id = ? AND name = ?", userID, tagName).First(&tag).Error
+	err := s.db.Where("workspace_id = ? AND name = ?", m.WorkspaceID, tagName).First(&tag).Error
 	if err != nil {
 		// 如果标签不存在，创建新标签，颜色为空
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			tag = models.Tag{
-				UserID: userID,
-				Name:   tagName,
-				Color:  "", // 自动创建的标签颜色为空
+				UserID:      m.UserID,
+				WorkspaceID: m.WorkspaceID,
+				Name:        tagName,
+				Color:       "", // 自动创建的标签颜色为空
 			}
 			if err := s.db.Create(&tag).Error; err != nil {
 				return err
 			}
+			s.notifyChanged(m.WorkspaceID)
 		} else {
 			return err
 		}
@@ -248,6 +301,21 @@ func (s *TagService) AddImageTagByName(userID, imageID uint, tagName string) err
 	return s.deduplicateImageTags(imageID)
 }
 
+// checkImage 确认成员可以修改图片的标签：需要editor及以上角色，且图片在当前工作区中
+func (s *TagService) checkImage(m Member, imageID uint) error {
+	if err := m.require(models.RoleEditor); err != nil {
+		return err
+	}
+	var count int64
+	if err := s.db.Model(&models.Image{}).Where("id = ? AND workspace_id = ?", imageID, m.WorkspaceID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("图片不存在")
+	}
+	return nil
+}
+
 // deduplicateImageTags 清理图片的重复标签关联，确保每个标签只关联一次
 // 保留第一个出现的关联（按ID排序），删除后续重复的关联
 func (s *TagService) deduplicateImageTags(imageID uint) error {
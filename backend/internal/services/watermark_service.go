@@ -0,0 +1,196 @@
+// Package services 提供业务逻辑层的服务实现
+// watermark_service.go 实现了水印预设管理：用户保存文字或PNG标志水印的参数，
+// 编辑时作为watermark操作永久叠加，或在导出、分享时临时叠加
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+	"image-manager/internal/watermark"
+
+	"gorm.io/gorm"
+)
+
+// maxLogoSize 标志图片的最大文件大小
+const maxLogoSize = 2 << 20
+
+// WatermarkService 水印服务结构体
+type WatermarkService struct {
+	db  *gorm.DB
+	cfg config.Config
+}
+
+// NewWatermarkService 创建水印服务实例
+// 参数:
+//   - db: GORM数据库连接
+//   - cfg: 应用配置，标志图片保存在StorageDir/watermarks下，WatermarkFontPath为文字水印字体
+//
+// 返回: WatermarkService指针
+func NewWatermarkService(db *gorm.DB, cfg config.Config) *WatermarkService {
+	if err := watermark.LoadFont(cfg.WatermarkFontPath); err != nil {
+		log.Printf("%v，文字水印使用内置字体", err)
+		watermark.LoadFont("")
+	}
+	return &WatermarkService{db: db, cfg: cfg}
+}
+
+// List 获取用户的水印预设列表
+func (s *WatermarkService) List(userID uint) ([]models.Watermark, error) {
+	var marks []models.Watermark
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&marks).Error; err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// Get 获取用户的单个水印预设
+func (s *WatermarkService) Get(userID, id uint) (*models.Watermark, error) {
+	var mark models.Watermark
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&mark).Error; err != nil {
+		return nil, err
+	}
+	return &mark, nil
+}
+
+// Create 创建水印预设，图片水印必须上传标志文件
+func (s *WatermarkService) Create(userID uint, req dto.WatermarkRequest, logo *multipart.FileHeader) (*models.Watermark, error) {
+	mark := models.Watermark{UserID: userID}
+	if err := s.fill(&mark, req, logo); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(&mark).Error; err != nil {
+		return nil, err
+	}
+	return &mark, nil
+}
+
+// Update 修改水印预设，图片水印未上传新标志时沿用原标志
+// 已提交的编辑版本保存的是提交时的参数快照，不受修改影响
+func (s *WatermarkService) Update(userID, id uint, req dto.WatermarkRequest, logo *multipart.FileHeader) (*models.Watermark, error) {
+	mark, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fill(mark, req, logo); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(mark).Error; err != nil {
+		return nil, err
+	}
+	return mark, nil
+}
+
+// Delete 删除水印预设（软删除），标志文件保留给引用它的编辑版本
+func (s *WatermarkService) Delete(userID, id uint) error {
+	result := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Watermark{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("水印不存在")
+	}
+	return nil
+}
+
+// Logo 读取图片水印的标志文件
+func (s *WatermarkService) Logo(userID, id uint) ([]byte, error) {
+	mark, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if mark.LogoPath == "" {
+		return nil, errors.New("该水印没有标志图片")
+	}
+	return os.ReadFile(mark.LogoPath)
+}
+
+// Spec 把用户的水印预设转换为渲染参数
+func (s *WatermarkService) Spec(userID, id uint) (watermark.Spec, error) {
+	mark, err := s.Get(userID, id)
+	if err != nil {
+		return watermark.Spec{}, errors.New("水印不存在")
+	}
+	return watermarkSpec(mark), nil
+}
+
+// watermarkSpec 水印预设对应的渲染参数
+func watermarkSpec(mark *models.Watermark) watermark.Spec {
+	return watermark.Spec{
+		Type:     mark.Type,
+		Text:     mark.Text,
+		Color:    mark.Color,
+		LogoPath: mark.LogoPath,
+		Position: mark.Position,
+		Opacity:  mark.Opacity,
+		Scale:    mark.Scale,
+		Margin:   mark.Margin,
+	}
+}
+
+// fill 用请求参数填充水印预设并校验，上传了标志文件时保存文件
+func (s *WatermarkService) fill(mark *models.Watermark, req dto.WatermarkRequest, logo *multipart.FileHeader) error {
+	mark.Name = strings.TrimSpace(req.Name)
+	mark.Type = req.Type
+	mark.Text = strings.TrimSpace(req.Text)
+	mark.Color = req.Color
+	mark.Position = req.Position
+	if mark.Position == "" {
+		mark.Position = watermark.PositionBottomRight
+	}
+	mark.Opacity = req.Opacity
+	mark.Scale = req.Scale
+	mark.Margin = req.Margin
+
+	if mark.Type == watermark.TypeText {
+		mark.LogoPath = ""
+	} else if logo != nil {
+		// 旧标志文件可能仍被已提交的编辑版本引用，不删除
+		path, err := s.saveLogo(mark.UserID, logo)
+		if err != nil {
+			return err
+		}
+		mark.LogoPath = path
+	}
+	return watermarkSpec(mark).Validate()
+}
+
+// saveLogo 校验并保存标志图片，只接受PNG以保留透明背景
+func (s *WatermarkService) saveLogo(userID uint, logo *multipart.FileHeader) (string, error) {
+	if logo.Size > maxLogoSize {
+		return "", fmt.Errorf("标志图片不能超过%dMB", maxLogoSize>>20)
+	}
+	src, err := logo.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, maxLogoSize+1))
+	if err != nil {
+		return "", err
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return "", errors.New("标志图片必须是PNG格式")
+	}
+
+	path := filepath.Join(s.cfg.StorageDir, "watermarks", fmt.Sprintf("%d_%d.png", userID, time.Now().UnixNano()))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
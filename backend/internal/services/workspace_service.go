@@ -0,0 +1,405 @@
+// Package services 提供业务逻辑层的服务实现
+// workspace_service.go 实现了工作区和成员管理：图片、标签和相册属于工作区，
+// 每个用户有一个个人工作区，团队工作区的成员按角色（owner、admin、editor、viewer）获得不同权限
+package services
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrForbidden 当前角色没有执行该操作的权限
+var ErrForbidden = errors.New("没有权限执行该操作")
+
+// Member 用户在某个工作区中的身份
+// 由工作区中间件根据请求解析，处理器传给图片、标签等服务；服务按WorkspaceID限定数据范围，按Role检查权限
+type Member struct {
+	UserID      uint   // 执行操作的用户
+	WorkspaceID uint   // 当前工作区
+	Role        string // 在该工作区中的角色，见models.RoleOwner等常量
+}
+
+// roleRanks 角色的权限等级，未知角色为0
+var roleRanks = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleAdmin:  3,
+	models.RoleOwner:  4,
+}
+
+// Can 判断成员的角色是否不低于role
+func (m Member) Can(role string) bool {
+	return roleRanks[m.Role] > 0 && roleRanks[m.Role] >= roleRanks[role]
+}
+
+// require 成员的角色低于role时返回ErrForbidden
+func (m Member) require(role string) error {
+	if !m.Can(role) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// viewerOf 以只读身份访问工作区，供公开分享、分享授权和后台任务等不经过工作区中间件的场景使用
+// 调用方需要自行确认访问是被允许的（如分享仍然有效）
+func viewerOf(userID, workspaceID uint) Member {
+	return Member{UserID: userID, WorkspaceID: workspaceID, Role: models.RoleViewer}
+}
+
+// WorkspaceService 工作区服务结构体
+type WorkspaceService struct {
+	db   *gorm.DB
+	auth *AuthService // 按用户名或邮箱查找要添加的成员
+}
+
+// NewWorkspaceService 创建工作区服务实例
+func NewWorkspaceService(db *gorm.DB, auth *AuthService) *WorkspaceService {
+	return &WorkspaceService{db: db, auth: auth}
+}
+
+// Resolve 解析用户在工作区中的身份；workspaceID为0时使用用户的个人工作区
+// 用户不是该工作区的成员时返回ErrForbidden
+func (s *WorkspaceService) Resolve(userID, workspaceID uint) (Member, error) {
+	if workspaceID == 0 {
+		workspace, err := s.Personal(userID)
+		if err != nil {
+			return Member{}, err
+		}
+		workspaceID = workspace.ID
+	}
+	var member models.WorkspaceMember
+	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Member{}, ErrForbidden
+		}
+		return Member{}, err
+	}
+	return Member{UserID: userID, WorkspaceID: workspaceID, Role: member.Role}, nil
+}
+
+// Personal 获取用户的个人工作区，没有时创建
+func (s *WorkspaceService) Personal(userID uint) (*models.Workspace, error) {
+	var workspace models.Workspace
+	err := s.db.Where("owner_id = ? AND personal = ?", userID, true).First(&workspace).Error
+	if err == nil {
+		return &workspace, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	var created *models.Workspace
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		created, err = createPersonalWorkspace(tx, &user)
+		return err
+	})
+	return created, err
+}
+
+// List 获取用户所在的工作区，个人工作区在前，附带用户的角色和成员数量
+func (s *WorkspaceService) List(userID uint) ([]models.Workspace, error) {
+	var memberships []models.WorkspaceMember
+	if err := s.db.Where("user_id = ?", userID).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		// 个人工作区在第一次访问时创建
+		if _, err := s.Personal(userID); err != nil {
+			return nil, err
+		}
+		return s.List(userID)
+	}
+	roles := make(map[uint]string, len(memberships))
+	ids := make([]uint, len(memberships))
+	for i, membership := range memberships {
+		roles[membership.WorkspaceID] = membership.Role
+		ids[i] = membership.WorkspaceID
+	}
+
+	var workspaces []models.Workspace
+	if err := s.db.Where("id IN ?", ids).Order("personal DESC, name").Find(&workspaces).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		WorkspaceID uint
+		Count       int
+	}
+	if err := s.db.Model(&models.WorkspaceMember{}).
+		Select("workspace_id, COUNT(*) AS count").
+		Where("workspace_id IN ?", ids).
+		Group("workspace_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	byWorkspace := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		byWorkspace[count.WorkspaceID] = count.Count
+	}
+	for i := range workspaces {
+		workspaces[i].Role = roles[workspaces[i].ID]
+		workspaces[i].Members = byWorkspace[workspaces[i].ID]
+	}
+	return workspaces, nil
+}
+
+// Create 创建团队工作区，创建者成为owner
+func (s *WorkspaceService) Create(userID uint, req dto.WorkspaceRequest) (*models.Workspace, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("工作区名称不能为空")
+	}
+	workspace := models.Workspace{Name: name, OwnerID: userID}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: models.RoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	workspace.Role, workspace.Members = models.RoleOwner, 1
+	return &workspace, nil
+}
+
+// Update 重命名工作区，需要admin及以上角色
+func (s *WorkspaceService) Update(m Member, req dto.WorkspaceRequest) (*models.Workspace, error) {
+	if err := m.require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("工作区名称不能为空")
+	}
+	if err := s.db.Model(&models.Workspace{}).Where("id = ?", m.WorkspaceID).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	var workspace models.Workspace
+	if err := s.db.First(&workspace, m.WorkspaceID).Error; err != nil {
+		return nil, err
+	}
+	workspace.Role = m.Role
+	return &workspace, nil
+}
+
+// Delete 删除团队工作区，只有owner可以删除，且工作区中不能还有图片
+// 同时删除工作区的标签、相册、幻灯片、人物、检索会话、视频导出任务、分享链接、授权和成员，
+// 以及幻灯片的背景音乐和导出的视频文件；正在渲染的任务发现记录被删除后自行清理输出
+func (s *WorkspaceService) Delete(m Member) error {
+	if err := m.require(models.RoleOwner); err != nil {
+		return err
+	}
+	workspace, err := s.get(m.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return errors.New("个人工作区不能删除")
+	}
+	var count int64
+	if err := s.db.Model(&models.Image{}).Where("workspace_id = ?", workspace.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("请先删除工作区中的图片")
+	}
+	var shows []models.Slideshow
+	if err := s.db.Where("workspace_id = ?", workspace.ID).Find(&shows).Error; err != nil {
+		return err
+	}
+	var jobs []models.RenderJob
+	if err := s.db.Where("workspace_id = ?", workspace.ID).Find(&jobs).Error; err != nil {
+		return err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 按ID关联到工作区数据的子表先删除
+		if err := tx.Where("session_id IN (?)", tx.Model(&models.SearchSession{}).Select("id").Where("workspace_id = ?", workspace.ID)).
+			Delete(&models.SearchMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("grant_id IN (?)", tx.Model(&models.ShareGrant{}).Select("id").Where("workspace_id = ?", workspace.ID)).
+			Delete(&models.ShareGrantImage{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.Tag{}, &models.Album{}, &models.Slideshow{}, &models.Person{}, &models.SearchSession{},
+			&models.RenderJob{}, &models.ShareLink{}, &models.ShareGrant{}, &models.WorkspaceMember{}} {
+			if err := tx.Where("workspace_id = ?", workspace.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(workspace).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, show := range shows {
+		if show.MusicPath != "" {
+			os.Remove(show.MusicPath)
+		}
+	}
+	for i := range jobs {
+		removeRenderFiles(&jobs[i])
+	}
+	return nil
+}
+
+// Members 获取工作区的成员列表，按加入时间排序（owner最先加入）
+func (s *WorkspaceService) Members(m Member) ([]models.WorkspaceMember, error) {
+	if err := m.require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	var members []models.WorkspaceMember
+	if err := s.db.Where("workspace_id = ?", m.WorkspaceID).Order("created_at, id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	userIDs := make([]uint, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	var users []models.User
+	if err := s.db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+	for i := range members {
+		members[i].Username = names[members[i].UserID]
+	}
+	return members, nil
+}
+
+// AddMember 按用户名或邮箱添加成员，需要admin及以上角色；只有owner可以添加admin
+func (s *WorkspaceService) AddMember(m Member, req dto.WorkspaceMemberRequest) (*models.WorkspaceMember, error) {
+	if err := s.checkAssign(m, req.Role); err != nil {
+		return nil, err
+	}
+	workspace, err := s.get(m.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.Personal {
+		return nil, errors.New("个人工作区不能添加成员，请创建团队工作区")
+	}
+	user, err := s.auth.FindUser(strings.TrimSpace(req.User))
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := s.db.Model(&models.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", workspace.ID, user.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("该用户已经是工作区成员")
+	}
+	member := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: req.Role}
+	if err := s.db.Create(&member).Error; err != nil {
+		return nil, err
+	}
+	member.Username = user.Username
+	return &member, nil
+}
+
+// UpdateMember 修改成员的角色，需要admin及以上角色；owner的角色不能修改，只有owner可以设置或取消admin
+func (s *WorkspaceService) UpdateMember(m Member, userID uint, role string) (*models.WorkspaceMember, error) {
+	if err := s.checkAssign(m, role); err != nil {
+		return nil, err
+	}
+	member, err := s.member(m.WorkspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkManage(m, member); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(member).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// RemoveMember 移除成员，需要admin及以上角色；成员也可以自己退出。owner不能被移除
+// 移除后该成员上传的图片仍然留在工作区中
+func (s *WorkspaceService) RemoveMember(m Member, userID uint) error {
+	member, err := s.member(m.WorkspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == models.RoleOwner {
+		return errors.New("不能移除工作区的所有者")
+	}
+	if userID != m.UserID {
+		if err := m.require(models.RoleAdmin); err != nil {
+			return err
+		}
+		if err := s.checkManage(m, member); err != nil {
+			return err
+		}
+	}
+	return s.db.Delete(member).Error
+}
+
+// checkAssign 检查成员能否授予role：需要admin及以上角色，只有owner可以授予admin
+func (s *WorkspaceService) checkAssign(m Member, role string) error {
+	if err := m.require(models.RoleAdmin); err != nil {
+		return err
+	}
+	if role == models.RoleOwner {
+		return errors.New("不能授予owner角色")
+	}
+	if role == models.RoleAdmin && m.Role != models.RoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// checkManage 检查成员能否修改或移除target：owner不能被修改，admin之间只有owner可以管理
+func (s *WorkspaceService) checkManage(m Member, target *models.WorkspaceMember) error {
+	if target.Role == models.RoleOwner {
+		return errors.New("不能修改工作区的所有者")
+	}
+	if target.Role == models.RoleAdmin && m.Role != models.RoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// get 按ID获取工作区
+func (s *WorkspaceService) get(id uint) (*models.Workspace, error) {
+	var workspace models.Workspace
+	if err := s.db.First(&workspace, id).Error; err != nil {
+		return nil, errors.New("工作区不存在")
+	}
+	return &workspace, nil
+}
+
+// member 获取工作区中的一个成员
+func (s *WorkspaceService) member(workspaceID, userID uint) (*models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		return nil, errors.New("成员不存在")
+	}
+	return &member, nil
+}
+
+// createPersonalWorkspace 为用户创建个人工作区，用户是唯一的成员和owner
+func createPersonalWorkspace(tx *gorm.DB, user *models.User) (*models.Workspace, error) {
+	workspace := models.Workspace{Name: user.Username, Personal: true, OwnerID: user.ID}
+	if err := tx.Create(&workspace).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: models.RoleOwner}).Error; err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
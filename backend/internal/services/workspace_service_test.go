@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"image-manager/internal/config"
+	"image-manager/internal/dto"
+	"image-manager/internal/models"
+)
+
+func TestMemberCan(t *testing.T) {
+	roles := []string{models.RoleViewer, models.RoleEditor, models.RoleAdmin, models.RoleOwner}
+	for i, role := range roles {
+		m := Member{Role: role}
+		for j, required := range roles {
+			if got, want := m.Can(required), i >= j; got != want {
+				t.Errorf("%s.Can(%s) = %v, want %v", role, required, got, want)
+			}
+		}
+	}
+	if (Member{Role: "guest"}).Can(models.RoleViewer) || (Member{}).Can(models.RoleViewer) {
+		t.Error("unknown roles should have no permissions")
+	}
+}
+
+// newTestTeam 创建owner拥有的团队工作区，roles中的用户按对应角色加入，返回各角色在工作区中的身份
+func newTestTeam(t *testing.T, workspaces *WorkspaceService, owner Member, roles map[string]Member) map[string]Member {
+	t.Helper()
+	team, err := workspaces.Create(owner.UserID, dto.WorkspaceRequest{Name: "团队"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerInTeam, err := workspaces.Resolve(owner.UserID, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := map[string]Member{models.RoleOwner: ownerInTeam}
+	for role, user := range roles {
+		var username string
+		workspaces.db.Model(&models.User{}).Where("id = ?", user.UserID).Pluck("username", &username)
+		if _, err := workspaces.AddMember(ownerInTeam, dto.WorkspaceMemberRequest{User: username, Role: role}); err != nil {
+			t.Fatal(err)
+		}
+		if members[role], err = workspaces.Resolve(user.UserID, team.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return members
+}
+
+func TestWorkspaceMembership(t *testing.T) {
+	db := newTestDB(t)
+	auth := NewAuthService(db, "secret")
+	workspaces := NewWorkspaceService(db, auth)
+	alice := newTestMember(t, db, "alice")
+	team := newTestTeam(t, workspaces, alice, map[string]Member{
+		models.RoleAdmin:  newTestMember(t, db, "bob"),
+		models.RoleEditor: newTestMember(t, db, "carol"),
+		models.RoleViewer: newTestMember(t, db, "dave"),
+	})
+	newTestMember(t, db, "erin")
+	outsider := newTestMember(t, db, "frank")
+
+	if _, err := workspaces.Resolve(outsider.UserID, team[models.RoleOwner].WorkspaceID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("outsider Resolve err = %v, want ErrForbidden", err)
+	}
+	if _, err := workspaces.AddMember(alice, dto.WorkspaceMemberRequest{User: "erin", Role: models.RoleViewer}); err == nil {
+		t.Error("personal workspaces should not take members")
+	}
+
+	tests := []struct {
+		name string
+		by   string
+		role string
+		ok   bool
+	}{
+		{"owner adds admin", models.RoleOwner, models.RoleAdmin, true},
+		{"admin adds editor", models.RoleAdmin, models.RoleEditor, true},
+		{"admin cannot add admin", models.RoleAdmin, models.RoleAdmin, false},
+		{"editor cannot add members", models.RoleEditor, models.RoleViewer, false},
+		{"viewer cannot add members", models.RoleViewer, models.RoleViewer, false},
+		{"nobody grants owner", models.RoleOwner, models.RoleOwner, false},
+	}
+	for _, tt := range tests {
+		member, err := workspaces.AddMember(team[tt.by], dto.WorkspaceMemberRequest{User: "erin", Role: tt.role})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err == nil {
+			db.Delete(member)
+		}
+	}
+
+	admin, editor, viewer := team[models.RoleAdmin], team[models.RoleEditor], team[models.RoleViewer]
+	if _, err := workspaces.UpdateMember(admin, team[models.RoleOwner].UserID, models.RoleViewer); err == nil {
+		t.Error("the owner's role should not change")
+	}
+	if _, err := workspaces.UpdateMember(editor, viewer.UserID, models.RoleEditor); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor promoting a viewer: err = %v, want ErrForbidden", err)
+	}
+	if _, err := workspaces.UpdateMember(admin, viewer.UserID, models.RoleEditor); err != nil {
+		t.Errorf("admin promoting a viewer: %v", err)
+	}
+	if err := workspaces.RemoveMember(editor, admin.UserID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor removing an admin: err = %v, want ErrForbidden", err)
+	}
+	if err := workspaces.RemoveMember(editor, editor.UserID); err != nil {
+		t.Errorf("members should be able to leave: %v", err)
+	}
+	if err := workspaces.RemoveMember(admin, team[models.RoleOwner].UserID); err == nil {
+		t.Error("the owner should not be removable")
+	}
+	if err := workspaces.Delete(admin); !errors.Is(err, ErrForbidden) {
+		t.Errorf("admin deleting the workspace: err = %v, want ErrForbidden", err)
+	}
+}
+
+func TestWorkspaceImagePermissions(t *testing.T) {
+	db := newTestDB(t)
+	cfg := config.Config{StorageDir: t.TempDir()}
+	workspaces := NewWorkspaceService(db, NewAuthService(db, "secret"))
+	tags := NewTagService(db)
+	images := NewImageService(db, cfg, tags, nil)
+	alice := newTestMember(t, db, "alice")
+	team := newTestTeam(t, workspaces, alice, map[string]Member{
+		models.RoleAdmin:  newTestMember(t, db, "bob"),
+		models.RoleEditor: newTestMember(t, db, "carol"),
+		models.RoleViewer: newTestMember(t, db, "dave"),
+	})
+	owner, admin, editor, viewer := team[models.RoleOwner], team[models.RoleAdmin], team[models.RoleEditor], team[models.RoleViewer]
+	personal := newTestImage(t, db, alice, models.Image{})
+
+	// 团队工作区中看不到个人工作区的图片
+	if _, err := images.Get(owner, personal.ID); err == nil {
+		t.Error("a personal image should not be visible from the team workspace")
+	}
+	if _, total, err := images.List(viewer, map[string]string{}, 1, 10); err != nil || total != 0 {
+		t.Errorf("team List = %d images, %v; want 0", total, err)
+	}
+
+	ownImage := func(m Member) *models.Image { return newTestImage(t, db, m, models.Image{}) }
+	tests := []struct {
+		name  string
+		by    Member
+		image *models.Image
+		ok    bool
+	}{
+		{"viewer cannot delete", viewer, ownImage(owner), false},
+		{"editor deletes own image", editor, ownImage(editor), true},
+		{"editor cannot delete others'", editor, ownImage(owner), false},
+		{"admin deletes others'", admin, ownImage(editor), true},
+		{"owner deletes others'", owner, ownImage(admin), true},
+		{"personal owner cannot reach team images", alice, ownImage(editor), false},
+	}
+	for _, tt := range tests {
+		if _, err := images.Get(viewer, tt.image.ID); err != nil {
+			t.Errorf("%s: viewer should see team images: %v", tt.name, err)
+		}
+		if err := images.Delete(tt.by, tt.image.ID); (err == nil) != tt.ok {
+			t.Errorf("%s: Delete err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+
+	tag, err := tags.Create(editor, dto.CreateTagRequest{Name: "团建", Color: "#ff0000"})
+	if err != nil {
+		t.Fatalf("editor creating a tag: %v", err)
+	}
+	if _, err := tags.Create(viewer, dto.CreateTagRequest{Name: "x", Color: "#ff0000"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer creating a tag: err = %v, want ErrForbidden", err)
+	}
+	if err := tags.Delete(editor, tag.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor deleting a tag: err = %v, want ErrForbidden", err)
+	}
+	if list, err := tags.List(alice); err != nil || len(list) != 0 {
+		t.Errorf("personal tags = %v, %v; team tags should not leak", list, err)
+	}
+	if err := tags.Delete(admin, tag.ID); err != nil {
+		t.Errorf("admin deleting a tag: %v", err)
+	}
+}
+
+func TestWorkspaceDeleteCascade(t *testing.T) {
+	db := newTestDB(t)
+	workspaces := NewWorkspaceService(db, NewAuthService(db, "secret"))
+	alice := newTestMember(t, db, "alice")
+	bob := newTestMember(t, db, "bob")
+	team := newTestTeam(t, workspaces, alice, nil)[models.RoleOwner]
+	ws := team.WorkspaceID
+
+	music := writeTestFile(t, "music.mp3", []byte("mp3"))
+	output := writeTestFile(t, "1.mp4", []byte("mp4"))
+	album := models.Album{UserID: alice.UserID, WorkspaceID: ws, Name: "相册"}
+	session := models.SearchSession{UserID: alice.UserID, WorkspaceID: ws, Title: "猫", Filters: "{}", ResultIDs: "[]"}
+	kept := models.SearchSession{UserID: alice.UserID, WorkspaceID: alice.WorkspaceID, Title: "狗", Filters: "{}", ResultIDs: "[]"}
+	for _, row := range []interface{}{
+		&album,
+		&session,
+		&kept,
+		&models.Slideshow{UserID: alice.UserID, WorkspaceID: ws, Name: "幻灯片", MusicPath: music},
+		&models.Person{WorkspaceID: ws, Name: "某人"},
+		&models.RenderJob{UserID: alice.UserID, WorkspaceID: ws, Name: "导出", OutputPath: output},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.SearchMessage{SessionID: session.ID, Role: "user", Content: "猫"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	grant := models.ShareGrant{OwnerID: alice.UserID, WorkspaceID: ws, RecipientID: bob.UserID, Kind: models.GrantAlbum, AlbumID: album.ID}
+	if err := db.Create(&grant).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := workspaces.Delete(team); err != nil {
+		t.Fatal(err)
+	}
+	for name, model := range map[string]interface{}{
+		"albums":      &models.Album{},
+		"slideshows":  &models.Slideshow{},
+		"people":      &models.Person{},
+		"sessions":    &models.SearchSession{},
+		"render jobs": &models.RenderJob{},
+		"grants":      &models.ShareGrant{},
+		"members":     &models.WorkspaceMember{},
+	} {
+		var count int64
+		db.Model(model).Where("workspace_id = ?", ws).Count(&count)
+		if count != 0 {
+			t.Errorf("%d %s outlived the workspace", count, name)
+		}
+	}
+	var messages, others int64
+	db.Model(&models.SearchMessage{}).Where("session_id = ?", session.ID).Count(&messages)
+	db.Model(&models.SearchSession{}).Where("id = ?", kept.ID).Count(&others)
+	if messages != 0 || others != 1 {
+		t.Errorf("messages = %d, other workspace sessions = %d; want 0 and 1", messages, others)
+	}
+	for _, path := range []string{music, output} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed with the workspace", filepath.Base(path))
+		}
+	}
+}
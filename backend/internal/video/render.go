@@ -0,0 +1,171 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Slide 渲染视频中的一张图片
+type Slide struct {
+	Path       string  // 图片文件路径（JPEG）
+	Duration   float64 // 停留秒数，包括与下一张的转场时间
+	Transition string  // 切换到这张图片时的转场效果：none、fade、slide或zoom
+}
+
+// RenderOptions 视频渲染参数
+type RenderOptions struct {
+	Width    int    // 输出宽度
+	Height   int    // 输出高度
+	FPS      int    // 帧率
+	KenBurns bool   // 是否添加缓慢的平移缩放效果
+	Audio    string // 音轨文件路径，为空时输出无声视频；音轨比视频短时循环播放
+}
+
+const (
+	// maxTransition 转场的最长时间（秒）
+	maxTransition = 1.0
+	// kenBurnsZoom 平移缩放效果的最大放大倍数
+	kenBurnsZoom = 0.15
+)
+
+// xfadeTransitions 幻灯片转场效果对应的ffmpeg xfade效果
+var xfadeTransitions = map[string]string{
+	"fade":  "fade",
+	"slide": "slideleft",
+	"zoom":  "zoomin",
+}
+
+// Render 把图片渲染成H.264的MP4视频，写入output
+// progress在编码过程中被调用，参数为0到1的进度；返回视频的总时长（秒）
+// 渲染时间与视频长度相关，不使用调用器的超时，由ctx控制取消
+func (f *FFmpeg) Render(ctx context.Context, slides []Slide, opts RenderOptions, output string, progress func(float64)) (float64, error) {
+	if len(slides) == 0 {
+		return 0, errors.New("没有可渲染的图片")
+	}
+	filter, total := renderFilter(slides, opts)
+
+	args := []string{"-v", "error", "-nostats", "-progress", "pipe:1", "-y"}
+	for _, slide := range slides {
+		args = append(args, "-i", slide.Path)
+	}
+	if opts.Audio != "" {
+		args = append(args, "-stream_loop", "-1", "-i", opts.Audio)
+		// 音轨开头淡入、结尾淡出，避免截断时的爆音
+		fadeOut := max(total-2, 0)
+		filter += fmt.Sprintf(";[%d:a]afade=t=in:d=1,afade=t=out:st=%.3f:d=2[a]", len(slides), fadeOut)
+	}
+	args = append(args, "-filter_complex", filter, "-map", "[v]")
+	if opts.Audio != "" {
+		args = append(args, "-map", "[a]", "-c:a", "aac", "-b:a", "192k")
+	}
+	args = append(args,
+		"-c:v", "libx264", "-preset", "medium", "-crf", "20", "-pix_fmt", "yuv420p",
+		"-r", strconv.Itoa(opts.FPS),
+		"-t", strconv.FormatFloat(total, 'f', 3, 64),
+		"-movflags", "+faststart",
+		output,
+	)
+
+	cmd := exec.CommandContext(ctx, f.ffmpeg, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	// -progress 每隔一段时间输出一组 key=value，out_time_us 是已编码的时长（微秒）
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key != "out_time_us" || progress == nil {
+			continue
+		}
+		if us, err := strconv.ParseFloat(value, 64); err == nil && total > 0 {
+			progress(min(us/1e6/total, 1))
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return 0, fmt.Errorf("%s执行失败: %v: %s", filepath.Base(f.ffmpeg), err, strings.TrimSpace(stderr.String()))
+	}
+	return total, nil
+}
+
+// renderFilter 生成渲染用的filter_complex，输出标签为[v]，同时返回视频总时长
+// 每张图片先按比例缩放并加黑边填满画面，再用zoompan生成该图片的全部帧，相邻图片之间用xfade转场
+func renderFilter(slides []Slide, opts RenderOptions) (string, float64) {
+	// zoompan按整数像素移动画面，先放大再缩小可以减轻缓慢平移时的抖动；4K时放大代价太高
+	scale := 2
+	if opts.Height > 1080 {
+		scale = 1
+	}
+	canvasW, canvasH := opts.Width*scale, opts.Height*scale
+
+	var parts []string
+	for i, slide := range slides {
+		frames := max(int(slide.Duration*float64(opts.FPS)+0.5), 1)
+		z, x, y := kenBurns(i, frames, opts.KenBurns)
+		parts = append(parts, fmt.Sprintf(
+			"[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1,"+
+				"zoompan=z='%s':x='%s':y='%s':d=%d:s=%dx%d:fps=%d,format=yuv420p[s%d]",
+			i, canvasW, canvasH, canvasW, canvasH, z, x, y, frames, opts.Width, opts.Height, opts.FPS, i,
+		))
+	}
+
+	total := slides[0].Duration
+	last := "s0"
+	for i := 1; i < len(slides); i++ {
+		duration := transitionDuration(slides[i-1], slides[i], opts.FPS)
+		effect, ok := xfadeTransitions[slides[i].Transition]
+		if !ok {
+			// 无转场时用一帧的淡入淡出代替硬切，保持滤镜链的结构一致
+			effect = "fade"
+		}
+		label := fmt.Sprintf("x%d", i)
+		parts = append(parts, fmt.Sprintf("[%s][s%d]xfade=transition=%s:duration=%.3f:offset=%.3f[%s]",
+			last, i, effect, duration, total-duration, label))
+		total += slides[i].Duration - duration
+		last = label
+	}
+	parts = append(parts, fmt.Sprintf("[%s]null[v]", last))
+	return strings.Join(parts, ";"), total
+}
+
+// transitionDuration 两张图片之间的转场时间，不超过较短一张停留时间的三分之一
+func transitionDuration(prev, next Slide, fps int) float64 {
+	frame := 1 / float64(fps)
+	if _, ok := xfadeTransitions[next.Transition]; !ok {
+		return frame
+	}
+	return max(min(maxTransition, prev.Duration/3, next.Duration/3), frame)
+}
+
+// kenBurns 返回第index张图片的zoompan缩放和位置表达式
+// 依次使用放大、缩小、从左向右平移和从右向左平移，避免连续的图片动作相同；关闭效果时画面静止
+func kenBurns(index, frames int, enabled bool) (string, string, string) {
+	center := "(iw-iw/zoom)/2"
+	centerY := "(ih-ih/zoom)/2"
+	if !enabled {
+		return "1", "0", "0"
+	}
+	progress := fmt.Sprintf("on/%d", frames)
+	switch index % 4 {
+	case 0:
+		return fmt.Sprintf("1+%g*%s", kenBurnsZoom, progress), center, centerY
+	case 1:
+		return fmt.Sprintf("%g-%g*%s", 1+kenBurnsZoom, kenBurnsZoom, progress), center, centerY
+	case 2:
+		return fmt.Sprintf("%g", 1+kenBurnsZoom), fmt.Sprintf("(iw-iw/zoom)*%s", progress), centerY
+	default:
+		return fmt.Sprintf("%g", 1+kenBurnsZoom), fmt.Sprintf("(iw-iw/zoom)*(1-%s)", progress), centerY
+	}
+}
@@ -0,0 +1,247 @@
+// Package video 调用本地ffprobe/ffmpeg读取视频信息和截取封面帧
+// 视频文件原样保存，封面帧作为图片库中的静态图片参与缩略图、人脸检测、编辑等处理
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MIME类型
+const (
+	MimeMP4       = "video/mp4"
+	MimeQuickTime = "video/quicktime"
+)
+
+// extensions 支持的视频扩展名
+var extensions = map[string]string{
+	".mp4": MimeMP4,
+	".m4v": MimeMP4,
+	".mov": MimeQuickTime,
+}
+
+// Detect 根据文件头和扩展名识别MP4/MOV视频，返回MIME类型，不是视频时返回空字符串
+// MP4和MOV都是ISO BMFF结构，HEIC也是，因此ftyp中的品牌必须是视频品牌；
+// 老式QuickTime文件可能没有ftyp，此时按扩展名和第一个盒子的类型判断
+func Detect(head []byte, filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if len(head) < 12 {
+		return ""
+	}
+	switch string(head[4:8]) {
+	case "ftyp":
+		brand := string(head[8:12])
+		if brand == "qt  " {
+			return MimeQuickTime
+		}
+		if videoBrands[brand] {
+			return MimeMP4
+		}
+		if imageBrands[brand] {
+			return ""
+		}
+		// 品牌不认识时只信任视频扩展名
+		return extensions[ext]
+	case "moov", "mdat", "wide", "free", "skip":
+		return extensions[ext]
+	}
+	return ""
+}
+
+// videoBrands MP4的常见主品牌
+var videoBrands = map[string]bool{
+	"isom": true, "iso2": true, "iso4": true, "iso5": true, "iso6": true,
+	"mp41": true, "mp42": true, "avc1": true, "M4V ": true, "M4VP": true,
+	"dash": true, "3gp4": true, "3gp5": true, "3g2a": true,
+}
+
+// imageBrands HEIF/AVIF图片的主品牌，即使扩展名是视频也不当作视频
+var imageBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true, "hevc": true, "hevx": true,
+	"mif1": true, "msf1": true, "avif": true, "avis": true,
+}
+
+// Info 视频信息
+type Info struct {
+	Duration  time.Duration // 时长
+	Width     int           // 显示宽度（已按旋转角度交换宽高）
+	Height    int           // 显示高度
+	CreatedAt *time.Time    // 拍摄时间（creation_time），没有时为空
+	Make      string        // 设备制造商（iPhone等设备写入的QuickTime元数据）
+	Model     string        // 设备型号
+	Latitude  float64       // 拍摄位置（ISO 6709），没有时为0
+	Longitude float64
+}
+
+// FFmpeg 本地ffprobe和ffmpeg命令行
+type FFmpeg struct {
+	ffprobe string // ffprobe可执行文件路径
+	ffmpeg  string // ffmpeg可执行文件路径
+	timeout time.Duration
+}
+
+// New 创建ffmpeg调用器，任一可执行文件不存在时返回错误
+// 参数:
+//   - ffmpegPath: ffmpeg可执行文件名或路径
+//   - ffprobePath: ffprobe可执行文件名或路径
+//   - timeout: 单次调用的超时时间
+func New(ffmpegPath, ffprobePath string, timeout time.Duration) (*FFmpeg, error) {
+	ffmpeg, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("找不到ffmpeg: %v", err)
+	}
+	ffprobe, err := exec.LookPath(ffprobePath)
+	if err != nil {
+		return nil, fmt.Errorf("找不到ffprobe: %v", err)
+	}
+	return &FFmpeg{ffprobe: ffprobe, ffmpeg: ffmpeg, timeout: timeout}, nil
+}
+
+// Path ffmpeg可执行文件路径，供需要直接调用ffmpeg的功能使用
+func (f *FFmpeg) Path() string {
+	return f.ffmpeg
+}
+
+// probeOutput ffprobe -print_format json 输出中用到的字段
+type probeOutput struct {
+	Streams []struct {
+		CodecType    string            `json:"codec_type"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		Tags         map[string]string `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// Probe 读取视频的时长、分辨率、拍摄时间和设备信息
+func (f *FFmpeg) Probe(ctx context.Context, path string) (*Info, error) {
+	out, err := f.run(ctx, f.ffprobe, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	if err != nil {
+		return nil, err
+	}
+	var probe probeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("无法解析ffprobe输出: %v", err)
+	}
+
+	info := &Info{}
+	hasVideo := false
+	for _, stream := range probe.Streams {
+		if stream.CodecType != "video" || stream.Width == 0 {
+			continue
+		}
+		hasVideo = true
+		info.Width, info.Height = stream.Width, stream.Height
+		// 手机竖拍的视频以横向编码，靠旋转元数据显示为竖向
+		rotation := 0.0
+		if r, err := strconv.ParseFloat(stream.Tags["rotate"], 64); err == nil {
+			rotation = r
+		}
+		for _, side := range stream.SideDataList {
+			if side.Rotation != 0 {
+				rotation = side.Rotation
+			}
+		}
+		if int(rotation)%180 != 0 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+		break
+	}
+	if !hasVideo {
+		return nil, errors.New("文件中没有视频流")
+	}
+
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	tags := probe.Format.Tags
+	// Apple设备的拍摄时间带时区，优先使用
+	for _, key := range []string{"com.apple.quicktime.creationdate", "creation_time"} {
+		if t, err := time.Parse(time.RFC3339, tags[key]); err == nil {
+			info.CreatedAt = &t
+			break
+		}
+	}
+	info.Make = tags["com.apple.quicktime.make"]
+	info.Model = tags["com.apple.quicktime.model"]
+	location := tags["com.apple.quicktime.location.ISO6709"]
+	if location == "" {
+		location = tags["location"]
+	}
+	info.Latitude, info.Longitude = parseISO6709(location)
+	return info, nil
+}
+
+// Poster 截取at时刻的一帧作为封面，返回JPEG；ffmpeg默认按旋转元数据摆正画面
+func (f *FFmpeg) Poster(ctx context.Context, path string, at time.Duration) ([]byte, error) {
+	out, err := f.run(ctx, f.ffmpeg,
+		"-v", "error",
+		"-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64),
+		"-i", path,
+		"-frames:v", "1",
+		"-f", "image2", "-c:v", "mjpeg", "-q:v", "2",
+		"pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errors.New("ffmpeg没有输出封面帧")
+	}
+	return out, nil
+}
+
+// PosterTime 选择封面帧的时刻：跳过可能是黑屏的开头，短视频取中间
+func PosterTime(duration time.Duration) time.Duration {
+	if duration >= 2*time.Second {
+		return time.Second
+	}
+	return duration / 2
+}
+
+// run 执行命令并返回标准输出
+func (f *FFmpeg) run(ctx context.Context, path string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s执行失败: %v: %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// iso6709 形如 +31.2304+121.4737+004.000/ 的坐标
+var iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+// parseISO6709 解析ISO 6709格式的经纬度，无法解析时返回0
+func parseISO6709(s string) (float64, float64) {
+	m := iso6709.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0
+	}
+	lat, err1 := strconv.ParseFloat(m[1], 64)
+	lon, err2 := strconv.ParseFloat(m[2], 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0
+	}
+	return lat, lon
+}
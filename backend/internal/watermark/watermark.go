@@ -0,0 +1,314 @@
+// Package watermark 实现水印渲染：在图片上叠加文字或PNG标志
+// 水印的尺寸和边距都按图片尺寸的比例描述，同一套参数在大图和缩小的预览图上效果一致
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+	"github.com/lucasb-eyer/go-colorful"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 水印类型
+const (
+	TypeText  = "text"  // 文字水印
+	TypeImage = "image" // PNG标志水印
+)
+
+// 水印位置
+const (
+	PositionTopLeft     = "top-left"
+	PositionTop         = "top"
+	PositionTopRight    = "top-right"
+	PositionLeft        = "left"
+	PositionCenter      = "center"
+	PositionRight       = "right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottom      = "bottom"
+	PositionBottomRight = "bottom-right"
+	PositionTile        = "tile" // 平铺满整张图片
+)
+
+// MaxTextLength 文字水印的最大长度（字符）
+const MaxTextLength = 100
+
+// Spec 水印参数
+type Spec struct {
+	Type     string  `json:"type"`               // 水印类型：text或image
+	Text     string  `json:"text,omitempty"`     // 文字内容（text）
+	Color    string  `json:"color,omitempty"`    // 文字颜色（#RRGGBB），默认白色
+	LogoPath string  `json:"logoPath,omitempty"` // 标志图片路径（image），由服务端根据预设填写
+	Position string  `json:"position"`           // 位置，默认右下角
+	Opacity  float64 `json:"opacity"`            // 不透明度（0到1）
+	Scale    float64 `json:"scale"`              // 水印宽度占图片宽度的比例（0到1）
+	Margin   float64 `json:"margin"`             // 与图片边缘的距离占图片短边的比例（0到0.5）
+}
+
+// Validate 校验水印参数
+func (s Spec) Validate() error {
+	switch s.Type {
+	case TypeText:
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			return errors.New("文字水印缺少文字内容")
+		}
+		if utf8.RuneCountInString(text) > MaxTextLength {
+			return fmt.Errorf("水印文字不能超过%d个字符", MaxTextLength)
+		}
+		if s.Color != "" {
+			if _, err := colorful.Hex(s.Color); err != nil {
+				return errors.New("水印颜色应为#RRGGBB格式")
+			}
+		}
+	case TypeImage:
+		if s.LogoPath == "" {
+			return errors.New("图片水印缺少标志图片")
+		}
+	default:
+		return errors.New("水印类型应为text或image")
+	}
+	switch s.Position {
+	case "", PositionTopLeft, PositionTop, PositionTopRight, PositionLeft, PositionCenter,
+		PositionRight, PositionBottomLeft, PositionBottom, PositionBottomRight, PositionTile:
+	default:
+		return errors.New("水印位置无效")
+	}
+	if s.Opacity <= 0 || s.Opacity > 1 {
+		return errors.New("水印不透明度应在0到1之间")
+	}
+	if s.Scale <= 0 || s.Scale > 1 {
+		return errors.New("水印大小应在0到1之间")
+	}
+	if s.Margin < 0 || s.Margin > 0.5 {
+		return errors.New("水印边距应在0到0.5之间")
+	}
+	return nil
+}
+
+var (
+	fontMu   sync.RWMutex
+	textFont *opentype.Font
+
+	logoMu    sync.Mutex
+	logoCache = map[string]image.Image{}
+)
+
+// LoadFont 加载文字水印使用的字体文件（TTF/OTF/TTC），path为空时使用内置的Go字体
+// 内置字体不包含中文字形，需要中文水印时应配置一个CJK字体
+func LoadFont(path string) error {
+	var (
+		parsed *opentype.Font
+		err    error
+	)
+	if path == "" {
+		parsed, err = opentype.Parse(goregular.TTF)
+	} else {
+		var data []byte
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		if parsed, err = opentype.Parse(data); err != nil {
+			// 字体集合（.ttc）取第一个字体
+			var collection *opentype.Collection
+			if collection, err = opentype.ParseCollection(data); err == nil {
+				parsed, err = collection.Font(0)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("加载水印字体失败: %v", err)
+	}
+	fontMu.Lock()
+	textFont = parsed
+	fontMu.Unlock()
+	return nil
+}
+
+// currentFont 返回已加载的字体，未加载时使用内置字体
+func currentFont() (*opentype.Font, error) {
+	fontMu.RLock()
+	f := textFont
+	fontMu.RUnlock()
+	if f != nil {
+		return f, nil
+	}
+	if err := LoadFont(""); err != nil {
+		return nil, err
+	}
+	return currentFont()
+}
+
+// Apply 在图片上叠加水印，返回新图片，原图不变
+func Apply(img image.Image, spec Spec) (image.Image, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	width := int(math.Round(float64(bounds.Dx()) * spec.Scale))
+	if width < 1 {
+		width = 1
+	}
+
+	var mark image.Image
+	var err error
+	if spec.Type == TypeImage {
+		mark, err = renderLogo(spec.LogoPath, width)
+	} else {
+		mark, err = renderText(spec, width)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 所有位置的水印先画到同一个透明图层上，再按不透明度整体叠加
+	margin := int(math.Round(float64(min(bounds.Dx(), bounds.Dy())) * spec.Margin))
+	layer := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for _, pt := range placements(layer.Bounds(), mark.Bounds().Size(), spec.Position, margin) {
+		r := mark.Bounds().Sub(mark.Bounds().Min).Add(pt)
+		draw.Draw(layer, r, mark, mark.Bounds().Min, draw.Over)
+	}
+	return imaging.Overlay(imaging.Clone(img), layer, image.Pt(0, 0), spec.Opacity), nil
+}
+
+// placements 计算水印左上角的位置，平铺时返回多个位置
+func placements(bounds image.Rectangle, size image.Point, position string, margin int) []image.Point {
+	w, h := bounds.Dx(), bounds.Dy()
+	left, right := margin, w-size.X-margin
+	top, bottom := margin, h-size.Y-margin
+	centerX, centerY := (w-size.X)/2, (h-size.Y)/2
+
+	switch position {
+	case PositionTopLeft:
+		return []image.Point{{left, top}}
+	case PositionTop:
+		return []image.Point{{centerX, top}}
+	case PositionTopRight:
+		return []image.Point{{right, top}}
+	case PositionLeft:
+		return []image.Point{{left, centerY}}
+	case PositionCenter:
+		return []image.Point{{centerX, centerY}}
+	case PositionRight:
+		return []image.Point{{right, centerY}}
+	case PositionBottomLeft:
+		return []image.Point{{left, bottom}}
+	case PositionBottom:
+		return []image.Point{{centerX, bottom}}
+	case PositionTile:
+		// 水印之间留出与水印等大的间隔，隔行错开半个间距
+		stepX, stepY := size.X*2+margin, size.Y*3+margin
+		var points []image.Point
+		for row, y := 0, margin; y < h; row, y = row+1, y+stepY {
+			offset := 0
+			if row%2 == 1 {
+				offset = stepX / 2
+			}
+			for x := margin - offset; x < w; x += stepX {
+				points = append(points, image.Pt(x, y))
+			}
+		}
+		return points
+	}
+	return []image.Point{{right, bottom}}
+}
+
+// renderText 把文字渲染成宽度约为width的透明背景图片
+func renderText(spec Spec, width int) (image.Image, error) {
+	f, err := currentFont()
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(spec.Text)
+
+	// 先用参考字号测量文字宽度，再按比例换算出目标字号
+	const refSize = 100
+	advance, err := measure(f, text, refSize)
+	if err != nil {
+		return nil, err
+	}
+	if advance <= 0 {
+		return nil, errors.New("水印文字无法渲染")
+	}
+	size := refSize * float64(width) / advance
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+	textWidth := font.MeasureString(face, text).Ceil()
+	if textWidth < 1 || ascent+descent < 1 {
+		return nil, errors.New("水印文字无法渲染")
+	}
+
+	var c color.Color = color.White
+	if spec.Color != "" {
+		if parsed, err := colorful.Hex(spec.Color); err == nil {
+			c = parsed
+		}
+	}
+	// 文字右下方先画一层半透明阴影，在浅色背景上也能看清
+	shadow := max(1, int(size/30))
+	layer := image.NewNRGBA(image.Rect(0, 0, textWidth+shadow, ascent+descent+shadow))
+	drawer := &font.Drawer{Dst: layer, Face: face}
+	drawer.Src = image.NewUniform(color.NRGBA{A: 96})
+	drawer.Dot = fixed.P(shadow, ascent+shadow)
+	drawer.DrawString(text)
+	drawer.Src = image.NewUniform(c)
+	drawer.Dot = fixed.P(0, ascent)
+	drawer.DrawString(text)
+	return layer, nil
+}
+
+// measure 测量文字在指定字号下的宽度（像素）
+func measure(f *opentype.Font, text string, size float64) (float64, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return 0, err
+	}
+	defer face.Close()
+	advance := font.MeasureString(face, text)
+	return float64(advance) / 64, nil
+}
+
+// renderLogo 读取标志图片并缩放到指定宽度，解码结果按路径缓存
+func renderLogo(path string, width int) (image.Image, error) {
+	logoMu.Lock()
+	logo, ok := logoCache[path]
+	logoMu.Unlock()
+	if !ok {
+		var err error
+		if logo, err = imaging.Open(path); err != nil {
+			return nil, fmt.Errorf("读取水印图片失败: %v", err)
+		}
+		logoMu.Lock()
+		logoCache[path] = logo
+		logoMu.Unlock()
+	}
+	if logo.Bounds().Dx() == width {
+		return logo, nil
+	}
+	return imaging.Resize(logo, width, 0, imaging.Lanczos), nil
+}
+
+// Forget 从缓存中移除标志图片，标志文件被替换或删除时调用
+func Forget(path string) {
+	logoMu.Lock()
+	delete(logoCache, path)
+	logoMu.Unlock()
+}
@@ -8,6 +8,10 @@ import TagManagementPage from './pages/TagManagementPage'
 import MCPSearchPage from './pages/MCPSearchPage'
 import SlideshowPage from './pages/SlideshowPage'
 import SlideshowEditPage from './pages/SlideshowEditPage'
+import PlaybackPage from './pages/PlaybackPage'
+import SharesPage from './pages/SharesPage'
+import SharedPage from './pages/SharedPage'
+import WorkspacesPage from './pages/WorkspacesPage'
 import ProtectedRoute from './components/ProtectedRoute'
 import AppLayout from './components/AppLayout'
 import './App.css'
@@ -19,6 +23,9 @@ function App() {
         <Route path="/" element={<Navigate to="/images" />} />
         <Route path="/login" element={<LoginPage />} />
         <Route path="/register" element={<RegisterPage />} />
+        {/* 公开播放和分享，无需登录 */}
+        <Route path="/play/:token" element={<PlaybackPage />} />
+        <Route path="/s/:token" element={<SharedPage />} />
 
         <Route element={<ProtectedRoute />}>
           <Route path="/slideshow" element={<SlideshowPage />} />
@@ -29,6 +36,8 @@ function App() {
             <Route path="/upload" element={<UploadPage />} />
             <Route path="/tags" element={<TagManagementPage />} />
             <Route path="/mcp" element={<MCPSearchPage />} />
+            <Route path="/shares" element={<SharesPage />} />
+            <Route path="/workspaces" element={<WorkspacesPage />} />
           </Route>
         </Route>
       </Routes>
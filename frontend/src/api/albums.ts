@@ -0,0 +1,75 @@
+/**
+ * albums.ts - 相册相关API接口
+ * 相册保存在服务端，跨设备可见；智能相册保存筛选条件，每次查看时实时计算
+ */
+
+import api from './client'
+import type { Album, AlbumItem, ImageMeta, PaginatedResponse } from '../types'
+
+export interface AlbumPayload {
+  name: string
+  description?: string
+  coverImageId?: number | null // 必须是相册中的图片，为空时使用第一张图片；智能相册不能指定
+  filters?: Record<string, string> // 提供filters或query即为智能相册，键与fetchImages的参数相同
+  query?: string // URL查询字符串形式的筛选条件，如 tags=海边&media=video
+}
+
+export const fetchAlbums = async () => {
+  const { data } = await api.get<Album[]>('/albums')
+  return data
+}
+
+export const fetchAlbum = async (albumId: number) => {
+  const { data } = await api.get<Album>(`/albums/${albumId}`)
+  return data
+}
+
+// fetchAlbumImages 普通相册按相册中的顺序，智能相册按筛选条件实时计算
+export const fetchAlbumImages = async (albumId: number, page = 1, pageSize = 20) => {
+  const { data } = await api.get<PaginatedResponse<ImageMeta>>(`/albums/${albumId}/images`, {
+    params: { page, pageSize },
+  })
+  return data
+}
+
+// refreshAlbum 重新计算智能相册的数量和封面
+export const refreshAlbum = async (albumId: number) => {
+  const { data } = await api.post<Album>(`/albums/${albumId}/refresh`)
+  return data
+}
+
+export const createAlbum = async (payload: AlbumPayload) => {
+  const { data } = await api.post<Album>('/albums', payload)
+  return data
+}
+
+export const updateAlbum = async (albumId: number, payload: AlbumPayload) => {
+  const { data } = await api.put<Album>(`/albums/${albumId}`, payload)
+  return data
+}
+
+export const deleteAlbum = async (albumId: number) => {
+  await api.delete(`/albums/${albumId}`)
+}
+
+// addAlbumImages 按顺序追加到相册末尾，已在相册中的图片保持原位置
+export const addAlbumImages = async (albumId: number, imageIds: number[]) => {
+  const { data } = await api.post<{ added: number }>(`/albums/${albumId}/images`, { imageIds })
+  return data
+}
+
+export const removeAlbumImages = async (albumId: number, imageIds: number[]) => {
+  const { data } = await api.post<{ removed: number }>(`/albums/${albumId}/images/remove`, { imageIds })
+  return data
+}
+
+// reorderAlbum imageIds必须包含相册中的全部图片
+export const reorderAlbum = async (albumId: number, imageIds: number[]) => {
+  const { data } = await api.put<Album>(`/albums/${albumId}/order`, { imageIds })
+  return data
+}
+
+export const setAlbumCaption = async (albumId: number, imageId: number, caption: string) => {
+  const { data } = await api.put<AlbumItem>(`/albums/${albumId}/images/${imageId}/caption`, { caption })
+  return data
+}
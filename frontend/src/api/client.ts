@@ -1,10 +1,11 @@
 /**
  * client.ts - Axios HTTP客户端配置
- * 配置API请求的基础URL、请求拦截器（添加认证token）和响应拦截器（处理401错误）
+ * 配置API请求的基础URL、请求拦截器（添加认证token和当前工作区）和响应拦截器（处理401错误）
  */
 
 import axios from 'axios'
 import { useAuthStore } from '../store/authStore'
+import { useWorkspaceStore } from '../store/workspaceStore'
 
 /**
  * 创建axios实例，配置基础URL
@@ -28,6 +29,11 @@ api.interceptors.request.use((config) => {
     // 添加Bearer token到请求头
     config.headers.Authorization = `Bearer ${token}`
   }
+  // 当前工作区，未选择时后端使用个人工作区
+  const workspaceId = useWorkspaceStore.getState().workspaceId
+  if (workspaceId) {
+    config.headers['X-Workspace-ID'] = String(workspaceId)
+  }
   // 调试日志：记录所有API请求
   console.log('[API Client] 发送请求:', {
     method: config.method?.toUpperCase(),
@@ -43,6 +49,7 @@ api.interceptors.request.use((config) => {
  * 响应拦截器：处理HTTP响应
  * - 成功响应：直接返回
  * - 401未授权：清除登录状态
+ * - 403且不再是当前工作区的成员：切回个人工作区
  * - 其他错误：拒绝Promise，由调用方处理
  */
 api.interceptors.response.use(
@@ -53,6 +60,9 @@ api.interceptors.response.use(
       // 调用logout清除本地存储的token和用户信息
       useAuthStore.getState().logout()
     }
+    if (error.response?.status === 403 && error.response?.data?.message === '不是该工作区的成员') {
+      useWorkspaceStore.getState().setWorkspace(null)
+    }
     // 将错误继续抛出，由具体的API调用函数处理
     return Promise.reject(error)
   },
@@ -0,0 +1,63 @@
+/**
+ * comments.ts - 图片评论、区域标注和收藏API接口
+ * 区域标注的坐标和尺寸以原图像素为单位
+ */
+
+import api from './client'
+import type { ImageAnnotation, ImageComment, ImageMeta, PaginatedResponse } from '../types'
+
+export const fetchComments = async (imageId: number | string) => {
+  const { data } = await api.get<ImageComment[]>(`/images/${imageId}/comments`)
+  return data
+}
+
+// createComment parentId为空表示顶层评论，否则回复该评论
+export const createComment = async (imageId: number | string, body: string, parentId?: number) => {
+  const { data } = await api.post<ImageComment>(`/images/${imageId}/comments`, { body, parentId })
+  return data
+}
+
+export const updateComment = async (id: number, body: string) => {
+  const { data } = await api.put<ImageComment>(`/comments/${id}`, { body })
+  return data
+}
+
+// deleteComment 评论的所有回复会一起删除
+export const deleteComment = async (id: number) => {
+  await api.delete(`/comments/${id}`)
+}
+
+export type AnnotationRegion = Pick<ImageAnnotation, 'x' | 'y' | 'width' | 'height' | 'note'>
+
+export const fetchAnnotations = async (imageId: number | string) => {
+  const { data } = await api.get<ImageAnnotation[]>(`/images/${imageId}/annotations`)
+  return data
+}
+
+export const createAnnotation = async (imageId: number | string, region: AnnotationRegion) => {
+  const { data } = await api.post<ImageAnnotation>(`/images/${imageId}/annotations`, region)
+  return data
+}
+
+export const updateAnnotation = async (id: number, region: AnnotationRegion) => {
+  const { data } = await api.put<ImageAnnotation>(`/annotations/${id}`, region)
+  return data
+}
+
+export const deleteAnnotation = async (id: number) => {
+  await api.delete(`/annotations/${id}`)
+}
+
+export const setFavorite = async (imageId: number | string, favorited: boolean) => {
+  if (favorited) {
+    await api.put(`/images/${imageId}/favorite`)
+  } else {
+    await api.delete(`/images/${imageId}/favorite`)
+  }
+}
+
+// fetchFavorites 当前用户在当前工作区中收藏的图片
+export const fetchFavorites = async (page: number, pageSize: number) => {
+  const { data } = await api.get<PaginatedResponse<ImageMeta>>('/favorites', { params: { page, pageSize } })
+  return data
+}
@@ -0,0 +1,116 @@
+/**
+ * edits.ts - 非破坏性编辑相关API接口
+ * 编辑以操作列表的形式保存在服务器上，原图不变；支持撤销/重做、版本历史和恢复原图
+ */
+
+import api from './client'
+import { mediaUrl } from './media'
+import type { EditHistory, EditOperation, ImageMeta, ImageVersion } from '../types'
+
+/**
+ * EditPreview - 编辑预览结果
+ * scale为预览图相对当前版本的缩放比例，前端可据此把预览图上的坐标换算回实际坐标
+ */
+export interface EditPreview {
+  blob: Blob
+  width: number
+  height: number
+  scale: number
+}
+
+/**
+ * previewEdits - 预览编辑效果，在缩小的当前版本上执行操作，不保存任何内容
+ * @param imageId - 图片ID
+ * @param operations - 待预览的编辑操作，坐标相对于当前版本的渲染结果（全尺寸）
+ * @param size - 预览图最长边（可选，默认1024）
+ */
+export const previewEdits = async (imageId: number, operations: EditOperation[], size?: number): Promise<EditPreview> => {
+  const response = await api.post<Blob>(
+    `/images/${imageId}/edits/preview`,
+    { operations, size },
+    { responseType: 'blob' },
+  )
+  return {
+    blob: response.data,
+    width: Number(response.headers['x-preview-width']),
+    height: Number(response.headers['x-preview-height']),
+    scale: Number(response.headers['x-preview-scale']),
+  }
+}
+
+/**
+ * EditMode - 提交方式：replace在原图上生成新版本，copy另存为新图片（继承标签和EXIF）
+ */
+export type EditMode = 'replace' | 'copy'
+
+/**
+ * commitEdits - 提交编辑：在当前版本上追加一组编辑操作
+ * @param imageId - 图片ID
+ * @param operations - 按顺序执行的编辑操作，坐标相对于当前版本的渲染结果
+ * @param label - 版本说明（可选）
+ * @param mode - 提交方式（可选，默认replace）；copy时只返回新图片，不生成版本
+ */
+export const commitEdits = async (imageId: number, operations: EditOperation[], label?: string, mode?: EditMode) => {
+  const { data } = await api.post<{ image: ImageMeta; version?: ImageVersion }>(`/images/${imageId}/edits/commit`, {
+    operations,
+    label,
+    mode,
+  })
+  return data
+}
+
+/**
+ * fetchEditHistory - 获取图片的编辑版本历史
+ */
+export const fetchEditHistory = async (imageId: number) => {
+  const { data } = await api.get<EditHistory>(`/images/${imageId}/versions`)
+  return data
+}
+
+export const undoEdit = async (imageId: number) => {
+  const { data } = await api.post<ImageMeta>(`/images/${imageId}/undo`)
+  return data
+}
+
+export const redoEdit = async (imageId: number) => {
+  const { data } = await api.post<ImageMeta>(`/images/${imageId}/redo`)
+  return data
+}
+
+/**
+ * revertToOriginal - 恢复原图，编辑历史保留
+ */
+export const revertToOriginal = async (imageId: number) => {
+  const { data } = await api.post<ImageMeta>(`/images/${imageId}/revert`)
+  return data
+}
+
+/**
+ * checkoutVersion - 切换到指定的历史版本
+ */
+export const checkoutVersion = async (imageId: number, versionId: number) => {
+  const { data } = await api.post<ImageMeta>(`/images/${imageId}/versions/${versionId}/checkout`)
+  return data
+}
+
+/**
+ * renderedImageUrl - 图片当前版本渲染结果的地址，未编辑时与原图相同
+ */
+export const renderedImageUrl = (imageId: number) => mediaUrl(`/images/${imageId}/rendered`)
+
+/**
+ * exportImage - 下载图片当前版本，可临时叠加水印预设并转换格式，不影响编辑历史
+ * @param imageId - 图片ID
+ * @param options.watermark - 水印预设ID（可选）
+ * @param options.format - 导出格式（可选，默认与当前版本相同）
+ */
+export const exportImage = async (
+  imageId: number,
+  options: { watermark?: number; format?: 'jpeg' | 'png' | 'webp' } = {},
+) => {
+  const { data } = await api.get<Blob>(`/images/${imageId}/export`, {
+    params: options,
+    responseType: 'blob',
+  })
+  return data
+}
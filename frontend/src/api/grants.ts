@@ -0,0 +1,62 @@
+/**
+ * grants.ts - 用户之间的分享授权API接口
+ * 所有者把选定的图片或相册授权给另一个用户；接收者接受后可以浏览，授权为copy时可以导入
+ */
+
+import api from './client'
+import { mediaUrl } from './media'
+import type { ImageMeta, PaginatedResponse, ShareGrant } from '../types'
+
+export interface GrantPayload {
+  recipient: string // 接收者的用户名或邮箱
+  imageIds?: number[]
+  albumId?: number
+  access: 'read' | 'copy'
+  message?: string
+}
+
+export const fetchOutgoingGrants = async () => {
+  const { data } = await api.get<ShareGrant[]>('/grants/outgoing')
+  return data
+}
+
+export const fetchIncomingGrants = async () => {
+  const { data } = await api.get<ShareGrant[]>('/grants/incoming')
+  return data
+}
+
+// createGrant imageIds和albumId二选一
+export const createGrant = async (payload: GrantPayload) => {
+  const { data } = await api.post<ShareGrant>('/grants', payload)
+  return data
+}
+
+// respondGrant 接收者接受或拒绝授权
+export const respondGrant = async (grantId: number, accept: boolean) => {
+  const { data } = await api.post<ShareGrant>(`/grants/${grantId}/${accept ? 'accept' : 'decline'}`)
+  return data
+}
+
+// deleteGrant 所有者撤销授权，或接收者移除授权
+export const deleteGrant = async (grantId: number) => {
+  await api.delete(`/grants/${grantId}`)
+}
+
+export const fetchGrantImages = async (grantId: number, page = 1, pageSize = 100) => {
+  const { data } = await api.get<PaginatedResponse<ImageMeta>>(`/grants/${grantId}/images`, {
+    params: { page, pageSize },
+  })
+  return data
+}
+
+// grantThumbnailUrl 授权中图片的缩略图，图片在授权者的工作区中，不能使用 /images/:id/thumbnail
+export const grantThumbnailUrl = (grantId: number, imageId: number) =>
+  mediaUrl(`/grants/${grantId}/images/${imageId}/thumbnail`)
+
+// importGrantImages 把授权中的图片导入到当前工作区，不在授权范围内的图片被忽略
+export const importGrantImages = async (grantId: number, imageIds: number[]) => {
+  const { data } = await api.post<{ message: string; importedImages: ImageMeta[] }>(`/grants/${grantId}/import`, {
+    imageIds,
+  })
+  return data
+}
@@ -9,7 +9,7 @@ import type { ImageMeta, PaginatedResponse } from '../types'
 /**
  * fetchImages - 获取图片列表（支持分页和筛选）
  * @param params - 查询参数对象，包含分页信息和筛选条件
- *   - keyword: 关键词搜索（匹配文件名）
+ *   - keyword: 关键词搜索（匹配文件名和OCR识别出的图片文字）
  *   - page: 页码（从1开始）
  *   - pageSize: 每页数量
  *   - start/end: 创建时间范围（ISO格式字符串）
@@ -18,6 +18,16 @@ import type { ImageMeta, PaginatedResponse } from '../types'
  *   - size_min/size_max: 文件大小范围（MB，可以是小数，如1.5表示1.5MB）
  *   - taken_start/taken_end: 拍摄时间范围
  *   - tags: 标签筛选（逗号分隔的标签名）
+ *   - person: 人物筛选（人物名称或ID，逗号分隔时要求同时包含）
+ *   - color: 颜色筛选（颜色名称或#RRGGBB，前缀"mostly "表示以该颜色为主）
+ *   - color_tolerance/color_ratio: 颜色容差（Lab色差）和最小占比
+ *   - quality: 画质标记（blurry、sharp、underexposed、overexposed、well_exposed、noisy、clean，逗号分隔时同时满足）
+ *   - sharpness_min/sharpness_max、noise_min/noise_max: 清晰度和噪点范围
+ *   - media: 媒体类型（image、video、live，逗号分隔表示任一）
+ *   - album: 相册ID，只返回该相册中的图片，未指定sort时按相册中的顺序排列
+ *   - has_comments/has_annotations: true只返回有评论/区域标注的图片，false只返回没有的
+ *   - favorited: true只返回当前用户收藏的图片，false只返回未收藏的
+ *   - sort/order: 排序字段（created_at、sharpness、noise、brightness、position）和方向（desc、asc）
  * @returns Promise<PaginatedResponse<ImageMeta>> 分页响应数据，包含图片列表和总数
  */
 export const fetchImages = async (params: Record<string, string | number | undefined>) => {
@@ -32,9 +42,10 @@ export const fetchImages = async (params: Record<string, string | number | undef
  * @param file - 要上传的图片文件对象
  * @param tags - 图片标签名称数组
  * @param useAI - 是否使用AI自动生成标签（默认true）
+ * @param motion - Live Photo的动态部分（MOV），与静态图片合并为一项
  * @returns Promise<ImageMeta> 上传成功后返回的图片元数据
  */
-export const uploadImage = async (file: File, tags: string[], useAI: boolean = true) => {
+export const uploadImage = async (file: File, tags: string[], useAI: boolean = true, motion?: File) => {
   // 创建FormData对象用于multipart/form-data格式的文件上传
   const formData = new FormData()
   // 添加文件到表单数据
@@ -43,6 +54,9 @@ export const uploadImage = async (file: File, tags: string[], useAI: boolean = t
   tags.forEach((tag) => formData.append('tags[]', tag))
   // 添加是否使用AI的标志
   formData.append('use_ai', useAI ? 'true' : 'false')
+  if (motion) {
+    formData.append('motion', motion)
+  }
   // 发送POST请求到 /images/upload 端点
   // api.post 会自动添加认证token（在axios拦截器中处理）
   const { data } = await api.post<ImageMeta>('/images/upload', formData)
@@ -68,6 +82,16 @@ export const deleteImage = async (id: string) => {
   await api.delete(`/images/${id}`)
 }
 
+/**
+ * deleteImages - 批量删除图片（如删除按画质筛选出的模糊照片）
+ * @param imageIds - 要删除的图片ID数组
+ * @returns Promise<number> 已删除的图片数量
+ */
+export const deleteImages = async (imageIds: number[]) => {
+  const { data } = await api.post<{ deleted: number }>('/images/batch-delete', { imageIds })
+  return data.deleted
+}
+
 /**
  * addImageTag - 为图片添加标签
  * 如果标签不存在，会自动创建（颜色为空）
@@ -101,33 +125,3 @@ export const removeImageTag = async (imageId: string, tagId: string) => {
   await api.delete(`/images/${imageId}/tags/${tagId}`)
 }
 
-/**
- * verifyImportAccount - 验证要导入的账户凭据并获取其图片列表
- * @param username - 用户名或邮箱
- * @param password - 密码
- * @returns Promise<{images: ImageMeta[], userId: number}>
- */
-export const verifyImportAccount = async (username: string, password: string) => {
-  const { data } = await api.post<{ images: ImageMeta[]; userId: number }>('/images/import/verify', {
-    username,
-    password,
-  })
-  return data
-}
-
-/**
- * importImages - 导入其他用户的图片
- * @param username - 用户名或邮箱
- * @param password - 密码
- * @param imageIds - 要导入的图片ID列表
- * @returns Promise<{message: string, importedImages: ImageMeta[]}>
- */
-export const importImages = async (username: string, password: string, imageIds: number[]) => {
-  const { data } = await api.post<{ message: string; importedImages: ImageMeta[] }>('/images/import', {
-    username,
-    password,
-    imageIds,
-  })
-  return data
-}
-
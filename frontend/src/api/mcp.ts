@@ -3,6 +3,7 @@
  * 提供对话式图片检索的API接口
  */
 import api from './client'
+import type { Album } from '../types'
 
 /**
  * 对话式图片搜索请求参数
@@ -11,6 +12,30 @@ export interface MCPSearchRequest {
   query: string      // 自然语言查询字符串
   page?: number      // 页码，默认为1
   pageSize?: number  // 每页数量，默认为20
+  sessionId?: number // 会话ID，传入时在该会话上一轮的基础上细化检索；不传则开启新会话
+  keywordMode?: 'and' | 'or' // 关键词与其他条件的关系，默认为or
+  tagMode?: 'and' | 'or'     // 多个标签之间的关系，默认为or
+}
+
+/**
+ * 被丢弃的过滤器字段
+ */
+export interface MCPDroppedFilter {
+  key: string    // 字段名
+  value: string  // 模型给出的原始值
+  reason: string // 丢弃原因
+}
+
+/**
+ * 查询转换诊断信息
+ */
+export interface MCPFilterDiagnostics {
+  source: 'ai' | 'cache' | 'fallback' | 'session' // 过滤条件来源
+  aiUsed: boolean                  // 过滤条件是否来自AI转换结果
+  fallbackReason?: string          // 降级原因，如ai_disabled、circuit_open、invalid_json
+  fallbackDetail?: string          // 降级的具体说明
+  rawOutput?: string               // 模型返回的原始文本
+  droppedKeys?: MCPDroppedFilter[] // 被丢弃的字段
 }
 
 /**
@@ -23,6 +48,8 @@ export interface MCPSearchResponse {
   page: number                     // 当前页码
   pageSize: number                 // 每页数量
   items: any[]                     // 图片列表
+  sessionId: number                // 会话ID（无状态翻页时为0）
+  diagnostics: MCPFilterDiagnostics // 转换诊断信息
 }
 
 /**
@@ -37,3 +64,17 @@ export const mcpSearch = async (request: MCPSearchRequest): Promise<MCPSearchRes
   return response.data
 }
 
+
+/**
+ * 把会话最近一轮检索的过滤条件保存为智能相册
+ * 保存的是条件而不是结果，之后符合条件的新图片也会出现在相册中
+ * 最近一轮限定在上一轮结果中时返回400，错误响应的imageIds为该轮结果，可改为用它们创建普通相册
+ *
+ * @param sessionId 会话ID
+ * @param name 相册名称，不传时使用会话标题
+ * @returns 创建的智能相册
+ */
+export const saveSearchAsAlbum = async (sessionId: number, name?: string, description?: string): Promise<Album> => {
+  const response = await api.post<Album>(`/mcp/sessions/${sessionId}/album`, { name, description })
+  return response.data
+}
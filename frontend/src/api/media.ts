@@ -0,0 +1,22 @@
+/**
+ * media.ts - 媒体令牌和图片文件地址
+ * 图片文件、视频等接口需要登录，<img>、<video>标签无法携带请求头，改为在查询参数mt中携带短期媒体令牌
+ */
+
+import api from './client'
+import { useMediaStore } from '../store/mediaStore'
+
+const baseURL = import.meta.env.VITE_API_BASE_URL ?? '/api/v1'
+
+export const fetchMediaToken = async () => {
+  const { data } = await api.get<{ token: string; expiresAt: string }>('/media-token')
+  return data
+}
+
+// mediaUrl 带媒体令牌的图片文件地址，path为/api/v1之后的部分，如 /images/1/thumbnail
+export const mediaUrl = (path: string) => {
+  const url = `${baseURL}${path}`
+  const token = useMediaStore.getState().token
+  if (!token) return url
+  return `${url}${url.includes('?') ? '&' : '?'}mt=${encodeURIComponent(token)}`
+}
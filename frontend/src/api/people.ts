@@ -0,0 +1,25 @@
+import api from './client'
+import { mediaUrl } from './media'
+import type { Face, Person } from '../types'
+
+export const fetchPeople = async () => {
+  const { data } = await api.get<Person[]>('/people')
+  return data
+}
+
+export const fetchPersonDetail = async (personId: number) => {
+  const { data } = await api.get<{ person: Person; faces: Face[] }>(`/people/${personId}`)
+  return data
+}
+
+export const renamePerson = async (personId: number, name: string) => {
+  const { data } = await api.put<Person>(`/people/${personId}/name`, { name })
+  return data
+}
+
+export const mergePeople = async (targetId: number, sourceId: number) => {
+  const { data } = await api.post<Person>(`/people/${targetId}/merge`, { sourceId })
+  return data
+}
+
+export const personCoverUrl = (personId: number) => mediaUrl(`/people/${personId}/cover`)
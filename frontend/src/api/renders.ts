@@ -0,0 +1,59 @@
+/**
+ * renders.ts - 视频导出相关API接口
+ * 相册或幻灯片在服务端后台渲染为MP4，创建任务后轮询进度，完成后下载
+ */
+
+import api from './client'
+import type { RenderJob } from '../types'
+
+export interface RenderOptions {
+  albumId?: number
+  slideshowId?: number
+  resolution?: 720 | 1080 | 2160 // 默认1080
+  slideDuration?: number // 相册中每张图片的停留秒数，默认5
+  kenBurns?: boolean // 平移缩放效果，默认开启
+  audio?: boolean // 是否带音轨，默认开启
+  music?: File // 音轨文件，不提供时幻灯片使用其背景音乐
+}
+
+/**
+ * createRender - 创建视频导出任务
+ * 带音轨文件时以multipart/form-data上传
+ */
+export const createRender = async ({ music, ...options }: RenderOptions) => {
+  if (!music) {
+    const { data } = await api.post<RenderJob>('/renders', options)
+    return data
+  }
+  const formData = new FormData()
+  Object.entries(options).forEach(([key, value]) => {
+    if (value !== undefined) {
+      formData.append(key, String(value))
+    }
+  })
+  formData.append('music', music)
+  const { data } = await api.post<RenderJob>('/renders', formData)
+  return data
+}
+
+export const fetchRenders = async () => {
+  const { data } = await api.get<RenderJob[]>('/renders')
+  return data
+}
+
+// fetchRender 查询任务状态和进度
+export const fetchRender = async (renderId: number) => {
+  const { data } = await api.get<RenderJob>(`/renders/${renderId}`)
+  return data
+}
+
+// downloadRender 下载导出完成的视频
+export const downloadRender = async (renderId: number) => {
+  const { data } = await api.get<Blob>(`/renders/${renderId}/download`, { responseType: 'blob' })
+  return data
+}
+
+// deleteRender 删除任务和生成的视频，正在渲染的任务会被中止
+export const deleteRender = async (renderId: number) => {
+  await api.delete(`/renders/${renderId}`)
+}
@@ -0,0 +1,76 @@
+/**
+ * shares.ts - 公开分享相关API接口
+ * 分享者管理自己的分享链接；访客接口（/share/:token）无需登录，输入密码后用返回的key访问
+ */
+
+import axios from 'axios'
+import api from './client'
+import type { SharedView, ShareLink } from '../types'
+
+const baseURL = import.meta.env.VITE_API_BASE_URL ?? '/api/v1'
+
+// 访客接口不带登录token，401表示需要密码而不是登录过期，不能触发退出登录
+const publicApi = axios.create({ baseURL })
+
+export interface SharePayload {
+  imageId?: number
+  tags?: string[] // 分享同时带有这些标签的图片
+  albumId?: number
+  title?: string
+  password?: string
+  allowDownload?: boolean
+  watermarkId?: number // 访客浏览和下载时叠加的水印预设
+  expiresAt?: string | null // ISO格式，为空表示永不过期
+}
+
+export const fetchShares = async () => {
+  const { data } = await api.get<ShareLink[]>('/shares')
+  return data
+}
+
+// createShare imageId、tags和albumId三选一
+export const createShare = async (payload: SharePayload) => {
+  const { data } = await api.post<ShareLink>('/shares', payload)
+  return data
+}
+
+// revokeShare 撤销后链接立即失效
+export const revokeShare = async (shareId: number) => {
+  await api.delete(`/shares/${shareId}`)
+}
+
+// shareUrl 发给访客的页面地址
+export const shareUrl = (token: string) => `${window.location.origin}/s/${token}`
+
+/**
+ * fetchSharedView - 访客获取分享内容
+ * 需要密码时接口返回401，响应中passwordRequired为true
+ */
+export const fetchSharedView = async (token: string, key: string | null, page = 1, pageSize = 50) => {
+  const { data } = await publicApi.get<SharedView>(`/share/${token}`, {
+    params: { page, pageSize },
+    headers: key ? { 'X-Share-Key': key } : undefined,
+  })
+  return data
+}
+
+// unlockShare 校验访问密码，返回之后请求使用的key
+export const unlockShare = async (token: string, password: string) => {
+  const { data } = await publicApi.post<{ key: string }>(`/share/${token}/unlock`, { password })
+  return data.key
+}
+
+/**
+ * sharedImageUrl - 分享中图片的地址，供<img>/<video>直接引用，key通过查询参数传递
+ * @param variant - thumbnail缩略图、view缩小后的大图、video视频、download下载当前版本
+ */
+export const sharedImageUrl = (
+  token: string,
+  itemId: string,
+  variant: 'thumbnail' | 'view' | 'video' | 'download',
+  key: string | null,
+) => {
+  const path = variant === 'view' ? '' : `/${variant}`
+  const query = key ? `?key=${encodeURIComponent(key)}` : ''
+  return `${baseURL}/share/${token}/images/${itemId}${path}${query}`
+}
@@ -0,0 +1,70 @@
+/**
+ * slideshows.ts - 幻灯片相关API接口
+ * 幻灯片保存在服务端；公开播放接口（/play/:token）无需登录，可以在电视等设备的浏览器上播放
+ */
+
+import api from './client'
+import type { PlaybackManifest, Slideshow, SlideshowTransition } from '../types'
+
+export interface SlideshowPayload {
+  name: string
+  transition?: SlideshowTransition
+  items: {
+    imageId: number
+    duration: number // 停留秒数，为0时图片默认5秒、视频播放完整
+    transition?: SlideshowTransition | ''
+    caption?: string
+  }[]
+}
+
+export const fetchSlideshows = async () => {
+  const { data } = await api.get<Slideshow[]>('/slideshows')
+  return data
+}
+
+export const fetchSlideshow = async (slideshowId: number) => {
+  const { data } = await api.get<Slideshow>(`/slideshows/${slideshowId}`)
+  return data
+}
+
+export const createSlideshow = async (payload: SlideshowPayload) => {
+  const { data } = await api.post<Slideshow>('/slideshows', payload)
+  return data
+}
+
+// updateSlideshow items整体替换
+export const updateSlideshow = async (slideshowId: number, payload: SlideshowPayload) => {
+  const { data } = await api.put<Slideshow>(`/slideshows/${slideshowId}`, payload)
+  return data
+}
+
+export const deleteSlideshow = async (slideshowId: number) => {
+  await api.delete(`/slideshows/${slideshowId}`)
+}
+
+export const uploadSlideshowMusic = async (slideshowId: number, music: File) => {
+  const form = new FormData()
+  form.append('music', music)
+  const { data } = await api.put<Slideshow>(`/slideshows/${slideshowId}/music`, form)
+  return data
+}
+
+export const removeSlideshowMusic = async (slideshowId: number) => {
+  const { data } = await api.delete<Slideshow>(`/slideshows/${slideshowId}/music`)
+  return data
+}
+
+// resetSlideshowToken 重新生成播放令牌，之前分享的播放链接失效
+export const resetSlideshowToken = async (slideshowId: number) => {
+  const { data } = await api.post<Slideshow>(`/slideshows/${slideshowId}/token`)
+  return data
+}
+
+export const fetchPlaybackManifest = async (token: string) => {
+  const { data } = await api.get<PlaybackManifest>(`/play/${token}`)
+  return data
+}
+
+// playbackUrl 把播放清单中以 /api/v1 开头的地址转换为当前API地址下的地址
+export const playbackUrl = (path: string) =>
+  `${import.meta.env.VITE_API_BASE_URL ?? '/api/v1'}${path.replace(/^\/api\/v1/, '')}`
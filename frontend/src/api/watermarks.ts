@@ -0,0 +1,56 @@
+/**
+ * watermarks.ts - 水印预设相关API接口
+ * 水印可以作为编辑操作（type: 'watermark'）永久叠加，也可以在导出图片时临时叠加
+ */
+
+import api from './client'
+import type { Watermark, WatermarkSpec } from '../types'
+
+export interface WatermarkPayload extends WatermarkSpec {
+  name: string
+  logo?: File // 图片水印的PNG标志，修改时不传则沿用原标志
+}
+
+const toFormData = (payload: WatermarkPayload) => {
+  const form = new FormData()
+  form.append('name', payload.name)
+  form.append('type', payload.type)
+  form.append('text', payload.text ?? '')
+  form.append('color', payload.color ?? '')
+  form.append('position', payload.position)
+  form.append('opacity', String(payload.opacity))
+  form.append('scale', String(payload.scale))
+  form.append('margin', String(payload.margin))
+  if (payload.logo) {
+    form.append('logo', payload.logo)
+  }
+  return form
+}
+
+export const fetchWatermarks = async () => {
+  const { data } = await api.get<Watermark[]>('/watermarks')
+  return data
+}
+
+export const createWatermark = async (payload: WatermarkPayload) => {
+  const { data } = await api.post<Watermark>('/watermarks', toFormData(payload))
+  return data
+}
+
+export const updateWatermark = async (watermarkId: number, payload: WatermarkPayload) => {
+  const { data } = await api.put<Watermark>(`/watermarks/${watermarkId}`, toFormData(payload))
+  return data
+}
+
+export const deleteWatermark = async (watermarkId: number) => {
+  const { data } = await api.delete(`/watermarks/${watermarkId}`)
+  return data
+}
+
+/**
+ * fetchWatermarkLogo - 获取图片水印的标志（需要登录，返回Blob）
+ */
+export const fetchWatermarkLogo = async (watermarkId: number) => {
+  const { data } = await api.get<Blob>(`/watermarks/${watermarkId}/logo`, { responseType: 'blob' })
+  return data
+}
@@ -0,0 +1,48 @@
+/**
+ * workspaces.ts - 工作区和成员管理API接口
+ * 工作区管理接口按路径中的工作区ID鉴权，不受当前工作区影响
+ */
+
+import api from './client'
+import type { Workspace, WorkspaceMember, WorkspaceRole } from '../types'
+
+export const fetchWorkspaces = async () => {
+  const { data } = await api.get<Workspace[]>('/workspaces')
+  return data
+}
+
+export const createWorkspace = async (name: string) => {
+  const { data } = await api.post<Workspace>('/workspaces', { name })
+  return data
+}
+
+export const renameWorkspace = async (workspaceId: number, name: string) => {
+  const { data } = await api.put<Workspace>(`/workspaces/${workspaceId}`, { name })
+  return data
+}
+
+// deleteWorkspace 只有所有者可以删除，工作区中不能还有图片
+export const deleteWorkspace = async (workspaceId: number) => {
+  await api.delete(`/workspaces/${workspaceId}`)
+}
+
+export const fetchWorkspaceMembers = async (workspaceId: number) => {
+  const { data } = await api.get<WorkspaceMember[]>(`/workspaces/${workspaceId}/members`)
+  return data
+}
+
+// addWorkspaceMember user为用户名或邮箱
+export const addWorkspaceMember = async (workspaceId: number, user: string, role: WorkspaceRole) => {
+  const { data } = await api.post<WorkspaceMember>(`/workspaces/${workspaceId}/members`, { user, role })
+  return data
+}
+
+export const updateWorkspaceMember = async (workspaceId: number, userId: number, role: WorkspaceRole) => {
+  const { data } = await api.put<WorkspaceMember>(`/workspaces/${workspaceId}/members/${userId}`, { role })
+  return data
+}
+
+// removeWorkspaceMember userId为当前用户时表示退出工作区
+export const removeWorkspaceMember = async (workspaceId: number, userId: number) => {
+  await api.delete(`/workspaces/${workspaceId}/members/${userId}`)
+}
@@ -0,0 +1,128 @@
+import { useRef, useState } from 'react'
+import type { ImageAnnotation } from '../types'
+import './AnnotationLayer.css'
+
+// Region 原图像素坐标下的矩形
+export interface Region {
+  x: number
+  y: number
+  width: number
+  height: number
+}
+
+interface AnnotationLayerProps {
+  src: string
+  alt: string
+  naturalWidth: number // 原图宽度（像素），标注坐标以原图为准
+  naturalHeight: number
+  annotations: ImageAnnotation[]
+  visible: boolean
+  drawing: boolean // 为true时在图片上拖动框选新的区域
+  activeId?: number | null
+  onSelect?: (annotation: ImageAnnotation) => void
+  onDraw?: (region: Region) => void
+}
+
+// minRegion 框选区域的最小边长（原图像素），更小的拖动视为误触
+const minRegion = 4
+
+/**
+ * AnnotationLayer 带区域标注的图片
+ * 标注以原图像素保存，按原图尺寸的百分比定位，图片以任何大小显示都能对齐；
+ * 框选时把鼠标位置按显示尺寸与原图尺寸的比例换算回原图像素
+ */
+const AnnotationLayer = ({
+  src,
+  alt,
+  naturalWidth,
+  naturalHeight,
+  annotations,
+  visible,
+  drawing,
+  activeId,
+  onSelect,
+  onDraw,
+}: AnnotationLayerProps) => {
+  const imgRef = useRef<HTMLImageElement>(null)
+  const [start, setStart] = useState<{ x: number; y: number } | null>(null)
+  const [draft, setDraft] = useState<Region | null>(null)
+
+  // toImagePoint 把鼠标位置换算为原图像素坐标，并限制在图片范围内
+  const toImagePoint = (event: React.PointerEvent) => {
+    const rect = imgRef.current!.getBoundingClientRect()
+    const scaleX = naturalWidth / rect.width
+    const scaleY = naturalHeight / rect.height
+    return {
+      x: Math.min(Math.max(Math.round((event.clientX - rect.left) * scaleX), 0), naturalWidth),
+      y: Math.min(Math.max(Math.round((event.clientY - rect.top) * scaleY), 0), naturalHeight),
+    }
+  }
+
+  const regionOf = (a: { x: number; y: number }, b: { x: number; y: number }): Region => ({
+    x: Math.min(a.x, b.x),
+    y: Math.min(a.y, b.y),
+    width: Math.abs(a.x - b.x),
+    height: Math.abs(a.y - b.y),
+  })
+
+  const handlePointerDown = (event: React.PointerEvent) => {
+    if (!drawing || !imgRef.current) return
+    event.preventDefault()
+    event.currentTarget.setPointerCapture(event.pointerId)
+    const point = toImagePoint(event)
+    setStart(point)
+    setDraft({ ...point, width: 0, height: 0 })
+  }
+
+  const handlePointerMove = (event: React.PointerEvent) => {
+    if (!start) return
+    setDraft(regionOf(start, toImagePoint(event)))
+  }
+
+  const handlePointerUp = (event: React.PointerEvent) => {
+    if (!start) return
+    const region = regionOf(start, toImagePoint(event))
+    setStart(null)
+    setDraft(null)
+    if (region.width >= minRegion && region.height >= minRegion) {
+      onDraw?.(region)
+    }
+  }
+
+  // 按原图尺寸的百分比定位
+  const boxStyle = (region: Region): React.CSSProperties => ({
+    left: `${(region.x / naturalWidth) * 100}%`,
+    top: `${(region.y / naturalHeight) * 100}%`,
+    width: `${(region.width / naturalWidth) * 100}%`,
+    height: `${(region.height / naturalHeight) * 100}%`,
+  })
+
+  return (
+    <div
+      className={`annotation-stage${drawing ? ' drawing' : ''}`}
+      onPointerDown={handlePointerDown}
+      onPointerMove={handlePointerMove}
+      onPointerUp={handlePointerUp}
+    >
+      <img ref={imgRef} src={src} alt={alt} draggable={false} />
+      {visible &&
+        naturalWidth > 0 &&
+        naturalHeight > 0 &&
+        annotations.map((annotation, index) => (
+          <button
+            key={annotation.id}
+            type="button"
+            className={`annotation-box${annotation.id === activeId ? ' active' : ''}`}
+            style={boxStyle(annotation)}
+            title={annotation.note || undefined}
+            onClick={() => onSelect?.(annotation)}
+          >
+            <span className="annotation-index">{index + 1}</span>
+          </button>
+        ))}
+      {draft && <div className="annotation-box draft" style={boxStyle(draft)} />}
+    </div>
+  )
+}
+
+export default AnnotationLayer
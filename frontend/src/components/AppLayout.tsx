@@ -1,13 +1,60 @@
+import { useEffect, useState } from 'react'
 import { NavLink, Outlet, useNavigate } from 'react-router-dom'
 import { useAuthStore } from '../store/authStore'
+import { useWorkspaceStore } from '../store/workspaceStore'
+import { fetchWorkspaces } from '../api/workspaces'
+import type { Workspace } from '../types'
 import './AppLayout.css'
 
+/**
+ * WorkspaceSwitcher 切换当前工作区
+ * 当前工作区已不在列表中（被移出或工作区被删除）时切回个人工作区
+ */
+const WorkspaceSwitcher = () => {
+  const { workspaceId, setWorkspace } = useWorkspaceStore()
+  const [workspaces, setWorkspaces] = useState<Workspace[]>([])
+
+  useEffect(() => {
+    fetchWorkspaces()
+      .then((list) => {
+        setWorkspaces(list)
+        if (workspaceId && !list.some((workspace) => workspace.id === workspaceId)) {
+          setWorkspace(null)
+        }
+      })
+      .catch(() => setWorkspaces([]))
+  }, [workspaceId, setWorkspace])
+
+  const personal = workspaces.find((workspace) => workspace.personal)
+  const current = workspaceId ?? personal?.id ?? 0
+
+  return (
+    <select
+      className="workspace-switcher"
+      value={current}
+      onChange={(e) => {
+        const id = Number(e.target.value)
+        setWorkspace(id === personal?.id ? null : id)
+      }}
+    >
+      {workspaces.map((workspace) => (
+        <option key={workspace.id} value={workspace.id}>
+          {workspace.personal ? '个人' : workspace.name}
+        </option>
+      ))}
+    </select>
+  )
+}
+
 const AppLayout = () => {
   const navigate = useNavigate()
   const { logout, user } = useAuthStore()
+  const workspaceId = useWorkspaceStore((state) => state.workspaceId)
+  const setWorkspace = useWorkspaceStore((state) => state.setWorkspace)
 
   const handleLogout = () => {
     logout()
+    setWorkspace(null)
     navigate('/login')
   }
 
@@ -18,6 +65,7 @@ const AppLayout = () => {
         <div className="header-top-mobile">
           <div className="logo logo-mobile">Image Manager</div>
           <div className="user-section-mobile">
+            <WorkspaceSwitcher />
             <div className="user-info">
               <span className="username">{user?.username}</span>
             </div>
@@ -29,8 +77,11 @@ const AppLayout = () => {
           <NavLink to="/tags">标签</NavLink>
           <NavLink to="/mcp">AI搜索</NavLink>
           <NavLink to="/slideshow">轮播</NavLink>
+          <NavLink to="/shares">分享</NavLink>
+          <NavLink to="/workspaces">团队</NavLink>
         </nav>
         <div className="user-section user-section-desktop">
+          <WorkspaceSwitcher />
           <div className="user-info">
             <span className="username">{user?.username}</span>
           </div>
@@ -43,12 +94,15 @@ const AppLayout = () => {
             <NavLink to="/tags">标签</NavLink>
             <NavLink to="/mcp">AI搜索</NavLink>
             <NavLink to="/slideshow">轮播</NavLink>
+            <NavLink to="/shares">分享</NavLink>
+            <NavLink to="/workspaces">团队</NavLink>
           </nav>
           <button onClick={handleLogout} className="logout-btn logout-btn-mobile">退出</button>
         </div>
       </header>
       <main className="app-main">
-        <Outlet />
+        {/* 切换工作区后重新挂载页面，重新加载该工作区的数据 */}
+        <Outlet key={workspaceId ?? 0} />
       </main>
     </div>
   )
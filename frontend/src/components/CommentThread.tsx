@@ -0,0 +1,151 @@
+import { useEffect, useState } from 'react'
+import { createComment, deleteComment, fetchComments, updateComment } from '../api/comments'
+import { useAuthStore } from '../store/authStore'
+import type { ImageComment } from '../types'
+import './CommentThread.css'
+
+interface CommentThreadProps {
+  imageId: number
+  onCountChange?: (count: number) => void
+}
+
+/**
+ * CommentThread 图片的评论讨论串
+ * 评论按回复关系缩进显示；只能修改和删除自己的评论，删除评论时其回复一起删除
+ */
+const CommentThread = ({ imageId, onCountChange }: CommentThreadProps) => {
+  const userId = useAuthStore((state) => state.user?.id)
+  const [comments, setComments] = useState<ImageComment[]>([])
+  const [body, setBody] = useState('')
+  const [replyTo, setReplyTo] = useState<ImageComment | null>(null)
+  const [editingId, setEditingId] = useState<number | null>(null)
+  const [editingBody, setEditingBody] = useState('')
+  const [message, setMessage] = useState<string | null>(null)
+
+  const load = async () => {
+    try {
+      const list = await fetchComments(imageId)
+      setComments(list)
+      onCountChange?.(list.length)
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '加载评论失败')
+    }
+  }
+
+  useEffect(() => {
+    setReplyTo(null)
+    setEditingId(null)
+    load()
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [imageId])
+
+  const handleSubmit = async (event: React.FormEvent) => {
+    event.preventDefault()
+    if (!body.trim()) return
+    try {
+      await createComment(imageId, body.trim(), replyTo?.id)
+      setBody('')
+      setReplyTo(null)
+      setMessage(null)
+      load()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '发表失败')
+    }
+  }
+
+  const handleSaveEdit = async () => {
+    if (!editingId || !editingBody.trim()) return
+    try {
+      await updateComment(editingId, editingBody.trim())
+      setEditingId(null)
+      load()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '修改失败')
+    }
+  }
+
+  const handleDelete = async (comment: ImageComment) => {
+    if (!window.confirm('确定删除这条评论吗？它的回复会一起删除。')) return
+    try {
+      await deleteComment(comment.id)
+      load()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '删除失败')
+    }
+  }
+
+  // 按parentId组织成树，回复的父评论已被删除时作为顶层评论显示
+  const ids = new Set(comments.map((comment) => comment.id))
+  const children = (parentId: number | null) =>
+    comments.filter((comment) =>
+      parentId === null ? comment.parentId === null || !ids.has(comment.parentId) : comment.parentId === parentId,
+    )
+
+  const renderComment = (comment: ImageComment, depth: number) => (
+    <li key={comment.id} className="comment-item" style={{ marginLeft: `${Math.min(depth, 4) * 1.25}rem` }}>
+      <div className="comment-meta">
+        <strong>{comment.username || `用户${comment.userId}`}</strong>
+        <span>{new Date(comment.createdAt).toLocaleString()}</span>
+        {comment.updatedAt !== comment.createdAt && <span>（已编辑）</span>}
+      </div>
+      {editingId === comment.id ? (
+        <div className="comment-edit">
+          <textarea value={editingBody} onChange={(e) => setEditingBody(e.target.value)} maxLength={2000} rows={2} />
+          <button type="button" onClick={handleSaveEdit}>保存</button>
+          <button type="button" onClick={() => setEditingId(null)}>取消</button>
+        </div>
+      ) : (
+        <p className="comment-body">{comment.body}</p>
+      )}
+      <div className="comment-actions">
+        <button type="button" onClick={() => setReplyTo(comment)}>回复</button>
+        {comment.userId === userId && (
+          <>
+            <button
+              type="button"
+              onClick={() => {
+                setEditingId(comment.id)
+                setEditingBody(comment.body)
+              }}
+            >
+              修改
+            </button>
+            <button type="button" onClick={() => handleDelete(comment)} className="danger">删除</button>
+          </>
+        )}
+      </div>
+      {children(comment.id).length > 0 && (
+        <ul className="comment-list">{children(comment.id).map((reply) => renderComment(reply, depth + 1))}</ul>
+      )}
+    </li>
+  )
+
+  return (
+    <div className="comment-thread">
+      {message && <div className="comment-message">{message}</div>}
+      {comments.length === 0 ? (
+        <p className="no-comments">暂无评论</p>
+      ) : (
+        <ul className="comment-list">{children(null).map((comment) => renderComment(comment, 0))}</ul>
+      )}
+      <form className="comment-form" onSubmit={handleSubmit}>
+        {replyTo && (
+          <div className="comment-reply-to">
+            回复 {replyTo.username || `用户${replyTo.userId}`}
+            <button type="button" onClick={() => setReplyTo(null)}>取消回复</button>
+          </div>
+        )}
+        <textarea
+          value={body}
+          onChange={(e) => setBody(e.target.value)}
+          maxLength={2000}
+          rows={2}
+          placeholder={replyTo ? '输入回复' : '发表评论'}
+        />
+        <button type="submit" disabled={!body.trim()}>发表</button>
+      </form>
+    </div>
+  )
+}
+
+export default CommentThread
@@ -1,15 +1,22 @@
 import { Link } from 'react-router-dom'
 import { format } from 'date-fns'
+import { mediaUrl } from '../api/media'
 import type { ImageMeta } from '../types'
 import { useSlideshowStore } from '../store/slideshowStore'
 import './ImageCard.css'
 
+// formatDuration 把毫秒格式化为 m:ss
+const formatDuration = (ms?: number) => {
+  const seconds = Math.round((ms ?? 0) / 1000)
+  return `${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')}`
+}
+
 interface Props {
   image: ImageMeta
 }
 
 const ImageCard = ({ image }: Props) => {
-  const thumbnailUrl = `${import.meta.env.VITE_API_BASE_URL ?? '/api/v1'}/images/${image.id}/thumbnail`
+  const thumbnailUrl = mediaUrl(`/images/${image.id}/thumbnail`)
   const addImage = useSlideshowStore((state) => state.addImage)
   const removeImage = useSlideshowStore((state) => state.removeImage)
   const items = useSlideshowStore((state) => state.items)
@@ -29,6 +36,14 @@ const ImageCard = ({ image }: Props) => {
     <Link to={`/images/${image.id}`} className="image-card">
       <div className="image-card-image-wrapper">
         <img src={thumbnailUrl} alt={image.originalFilename} loading="lazy" />
+        {image.mediaType === 'video' && <span className="media-badge">▶ {formatDuration(image.durationMs)}</span>}
+        {image.mediaType === 'live' && <span className="media-badge">LIVE</span>}
+        {(image.favorited || !!image.commentCount) && (
+          <span className="activity-badge">
+            {image.favorited && <span title="已收藏">★</span>}
+            {!!image.commentCount && <span title={`${image.commentCount} 条评论`}>💬 {image.commentCount}</span>}
+          </span>
+        )}
         <button
           className={`slideshow-add-btn ${isInSlideshow ? 'added' : ''}`}
           onClick={handleAddToSlideshow}
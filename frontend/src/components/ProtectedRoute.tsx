@@ -1,15 +1,49 @@
+import { useEffect } from 'react'
 import { Navigate, Outlet } from 'react-router-dom'
+import { fetchMediaToken } from '../api/media'
 import { useAuthStore } from '../store/authStore'
+import { useMediaStore } from '../store/mediaStore'
+import { useWorkspaceStore } from '../store/workspaceStore'
+
+// mediaTokenRefresh 媒体令牌的刷新间隔；令牌至少有效一小时，提前刷新
+const mediaTokenRefresh = 30 * 60 * 1000
 
 const ProtectedRoute = () => {
   const token = useAuthStore((state) => state.token)
+  const workspaceId = useWorkspaceStore((state) => state.workspaceId)
+  const mediaWorkspaceId = useMediaStore((state) => state.workspaceId)
+  // 订阅令牌，刷新后页面重新生成图片地址
+  useMediaStore((state) => state.token)
+
+  // 获取当前工作区的媒体令牌，并定时刷新
+  useEffect(() => {
+    const { clear, setToken } = useMediaStore.getState()
+    clear()
+    if (!token) return
+    let cancelled = false
+    const load = () =>
+      fetchMediaToken()
+        .then((data) => !cancelled && setToken(data.token, workspaceId))
+        // 获取失败时照常显示页面，图片无法加载
+        .catch(() => !cancelled && setToken(null, workspaceId))
+    load()
+    const timer = window.setInterval(load, mediaTokenRefresh)
+    return () => {
+      cancelled = true
+      window.clearInterval(timer)
+    }
+  }, [token, workspaceId])
 
   if (!token) {
     return <Navigate to="/login" replace />
   }
 
+  // 当前工作区的媒体令牌就绪后再显示页面，避免图片先用无效的地址加载
+  if (mediaWorkspaceId !== workspaceId) {
+    return null
+  }
+
   return <Outlet />
 }
 
 export default ProtectedRoute
-
@@ -1,9 +1,16 @@
 import { useEffect, useState, useRef } from 'react'
 import { useNavigate, useParams } from 'react-router-dom'
 import { deleteImage, fetchImageDetail, uploadImage, addImageTag, updateImageTag, removeImageTag } from '../api/images'
-import type { ImageMeta, Tag } from '../types'
+import type { ImageAnnotation, ImageMeta, Tag } from '../types'
 import { useSlideshowStore } from '../store/slideshowStore'
+import { useAuthStore } from '../store/authStore'
 import ImageEditor from '../components/ImageEditor'
+import AnnotationLayer from '../components/AnnotationLayer'
+import type { Region } from '../components/AnnotationLayer'
+import CommentThread from '../components/CommentThread'
+import { renderedImageUrl } from '../api/edits'
+import { mediaUrl } from '../api/media'
+import { createAnnotation, deleteAnnotation, fetchAnnotations, setFavorite, updateAnnotation } from '../api/comments'
 import './ImageDetailPage.css'
 
 const ImageDetailPage = () => {
@@ -17,10 +24,17 @@ const ImageDetailPage = () => {
   const [editingTagId, setEditingTagId] = useState<number | null>(null)
   const [editingTagName, setEditingTagName] = useState('')
   const [newTagName, setNewTagName] = useState('')
+  const [playingLive, setPlayingLive] = useState(false) // 是否正在播放Live Photo的动态部分
   const [tagMessage, setTagMessage] = useState<string | null>(null)
   const [showFullName, setShowFullName] = useState(false)
   const [isNameTruncated, setIsNameTruncated] = useState(false)
   const nameRef = useRef<HTMLHeadingElement>(null)
+  const userId = useAuthStore((state) => state.user?.id)
+  const [annotations, setAnnotations] = useState<ImageAnnotation[]>([])
+  const [showAnnotations, setShowAnnotations] = useState(true)
+  const [drawing, setDrawing] = useState(false) // 是否正在框选新的标注区域
+  const [activeAnnotationId, setActiveAnnotationId] = useState<number | null>(null)
+  const [annotationMessage, setAnnotationMessage] = useState<string | null>(null)
 
   const loadDetail = async () => {
     if (!id) return
@@ -36,8 +50,20 @@ const ImageDetailPage = () => {
     }
   }
 
+  const loadAnnotations = async () => {
+    if (!id) return
+    try {
+      setAnnotations(await fetchAnnotations(id))
+    } catch {
+      setAnnotations([])
+    }
+  }
+
   useEffect(() => {
     loadDetail()
+    loadAnnotations()
+    setDrawing(false)
+    setActiveAnnotationId(null)
     setShowFullName(false) // 切换图片时重置展开状态
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, [id])
@@ -116,6 +142,60 @@ const ImageDetailPage = () => {
     }
   }
 
+  const handleToggleFavorite = async () => {
+    if (!image) return
+    const favorited = !image.favorited
+    try {
+      await setFavorite(image.id, favorited)
+      setImage({ ...image, favorited })
+    } catch (err: any) {
+      alert(err.response?.data?.message ?? '操作失败')
+    }
+  }
+
+  const showAnnotationMessage = (text: string) => {
+    setAnnotationMessage(text)
+    setTimeout(() => setAnnotationMessage(null), 2000)
+  }
+
+  // 框选完成后输入说明并保存，坐标已由AnnotationLayer换算为原图像素
+  const handleDrawAnnotation = async (region: Region) => {
+    if (!id) return
+    const note = window.prompt('标注说明（可以为空）', '')
+    if (note === null) return
+    try {
+      const annotation = await createAnnotation(id, { ...region, note: note.trim() })
+      setDrawing(false)
+      setShowAnnotations(true)
+      setActiveAnnotationId(annotation.id)
+      await loadAnnotations()
+    } catch (err: any) {
+      showAnnotationMessage(err.response?.data?.message ?? '添加标注失败')
+    }
+  }
+
+  const handleEditAnnotation = async (annotation: ImageAnnotation) => {
+    const note = window.prompt('标注说明', annotation.note)
+    if (note === null || note.trim() === annotation.note) return
+    try {
+      const { x, y, width, height } = annotation
+      await updateAnnotation(annotation.id, { x, y, width, height, note: note.trim() })
+      await loadAnnotations()
+    } catch (err: any) {
+      showAnnotationMessage(err.response?.data?.message ?? '修改失败')
+    }
+  }
+
+  const handleDeleteAnnotation = async (annotation: ImageAnnotation) => {
+    if (!confirm('确定删除该标注吗？')) return
+    try {
+      await deleteAnnotation(annotation.id)
+      await loadAnnotations()
+    } catch (err: any) {
+      showAnnotationMessage(err.response?.data?.message ?? '删除失败')
+    }
+  }
+
   const handleEdit = () => {
     setIsEditing(true)
   }
@@ -162,7 +242,17 @@ const ImageDetailPage = () => {
     return <div className="detail-card">{error ?? '图片不存在'}</div>
   }
 
-  const originalUrl = `${import.meta.env.VITE_API_BASE_URL ?? '/api/v1'}/images/${image.id}/original`
+  const originalUrl = mediaUrl(`/images/${image.id}/original`)
+  // HEIC/HEIF和相机RAW的原始文件，页面上显示的是其JPEG工作副本
+  const sourceUrl = mediaUrl(`/images/${image.id}/source`)
+  // 有编辑版本时显示当前版本的渲染结果
+  const displayUrl = image.editVersionId ? renderedImageUrl(image.id) : originalUrl
+  // 视频文件或Live Photo的动态部分，支持拖动进度条
+  const videoUrl = mediaUrl(`/images/${image.id}/video`)
+  const isVideo = image.mediaType === 'video'
+  const isLive = image.mediaType === 'live'
+  // 标注以原图像素为准，显示标注或框选时显示原图而不是编辑后的版本
+  const annotating = drawing || (showAnnotations && annotations.length > 0)
 
   if (isEditing) {
     return (
@@ -204,18 +294,57 @@ const ImageDetailPage = () => {
           >
             退出
           </button>
-          <button onClick={handleEdit} className="btn-edit">编辑</button>
+          {!isVideo && <button onClick={handleEdit} className="btn-edit">编辑</button>}
+          <button onClick={handleToggleFavorite} className={`btn-favorite${image.favorited ? ' active' : ''}`}>
+            {image.favorited ? '★ 已收藏' : '☆ 收藏'}
+          </button>
+          <button onClick={() => navigate(`/shares?imageId=${image.id}`)} className="btn-edit">分享</button>
           <button onClick={handleDelete} className="btn-delete">删除</button>
         </div>
       </header>
 
       <div className="detail-content">
-        <img src={originalUrl} alt={image.originalFilename} />
+        {isVideo || (isLive && playingLive) ? (
+          <video
+            src={videoUrl}
+            poster={displayUrl}
+            controls={isVideo}
+            autoPlay={isLive}
+            playsInline
+            onEnded={() => setPlayingLive(false)}
+          />
+        ) : (
+          <AnnotationLayer
+            src={annotating ? originalUrl : displayUrl}
+            alt={image.originalFilename}
+            naturalWidth={image.width}
+            naturalHeight={image.height}
+            annotations={annotations}
+            visible={showAnnotations}
+            drawing={drawing}
+            activeId={activeAnnotationId}
+            onSelect={(annotation) => setActiveAnnotationId(annotation.id)}
+            onDraw={handleDrawAnnotation}
+          />
+        )}
         <section className="meta-panel">
           <h3>基本信息</h3>
           <ul>
             <li>分辨率：{image.width} x {image.height}</li>
             <li>文件大小：{(image.fileSize / 1024 / 1024).toFixed(2)} MB</li>
+            {(isVideo || isLive) && image.durationMs ? (
+              <li>时长：{(image.durationMs / 1000).toFixed(1)} 秒</li>
+            ) : null}
+            {isLive && (
+              <li>
+                Live Photo：<button type="button" onClick={() => setPlayingLive(true)} disabled={playingLive}>播放</button>
+              </li>
+            )}
+            {image.sourceMimeType && (
+              <li>
+                原始文件：<a href={sourceUrl} download={image.originalFilename}>{image.sourceMimeType}</a>
+              </li>
+            )}
             {image.exif?.cameraModel && <li>相机：{image.exif.cameraModel}</li>}
             {image.exif?.takenAt && <li>拍摄时间：{new Date(image.exif.takenAt).toLocaleString()}</li>}
             {image.exif?.locationName && <li>地点：{image.exif.locationName}</li>}
@@ -264,6 +393,46 @@ const ImageDetailPage = () => {
             />
             <button onClick={handleAddTag} className="btn-add-tag">添加</button>
           </div>
+
+          {!isVideo && (
+            <>
+              <h3>区域标注</h3>
+              {annotationMessage && <div className="tag-message">{annotationMessage}</div>}
+              <div className="annotation-toolbar">
+                <button onClick={() => setDrawing(!drawing)} className={drawing ? 'active' : undefined}>
+                  {drawing ? '取消框选' : '添加标注'}
+                </button>
+                <label>
+                  <input type="checkbox" checked={showAnnotations} onChange={(e) => setShowAnnotations(e.target.checked)} />
+                  显示标注
+                </label>
+              </div>
+              {drawing && <p className="annotation-hint">在图片上拖动框选区域</p>}
+              {image.editVersionId && annotating && <p className="annotation-hint">标注基于原图，显示标注时展示的是原图</p>}
+              <ol className="annotation-list">
+                {annotations.map((annotation) => (
+                  <li
+                    key={annotation.id}
+                    className={annotation.id === activeAnnotationId ? 'active' : undefined}
+                    onClick={() => setActiveAnnotationId(annotation.id)}
+                  >
+                    <span className="annotation-note">{annotation.note || '（无说明）'}</span>
+                    <span className="annotation-author">{annotation.username}</span>
+                    {annotation.userId === userId && (
+                      <span className="annotation-actions">
+                        <button onClick={() => handleEditAnnotation(annotation)} className="btn-edit-small">修改</button>
+                        <button onClick={() => handleDeleteAnnotation(annotation)} className="btn-delete-small">删除</button>
+                      </span>
+                    )}
+                  </li>
+                ))}
+              </ol>
+              {annotations.length === 0 && <p className="no-tags">暂无标注</p>}
+            </>
+          )}
+
+          <h3>评论</h3>
+          <CommentThread imageId={image.id} />
         </section>
       </div>
     </div>
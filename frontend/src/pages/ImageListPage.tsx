@@ -19,6 +19,9 @@ const DEFAULT_FILTERS = {
   size_min_mb: '',
   size_max_mb: '',
   tags: '',
+  has_comments: '', // ''（不限）、'true' 或 'false'
+  has_annotations: '',
+  favorited: '',
   keyword_mode: 'or', // 'and' 或 'or'，表示关键词和其他条件的关系
   tag_mode: 'or',     // 'and' 或 'or'，表示标签之间的关系
 }
@@ -75,6 +78,9 @@ const ImageListPage = () => {
         size_min: filters.size_min_mb || undefined,
         size_max: filters.size_max_mb || undefined,
         tags: filters.tags,
+        has_comments: filters.has_comments || undefined,
+        has_annotations: filters.has_annotations || undefined,
+        favorited: filters.favorited || undefined,
         keyword_mode: filters.keyword_mode,
         tag_mode: filters.tag_mode,
         page: 1,
@@ -196,6 +202,30 @@ const ImageListPage = () => {
               <label>文件大小最大(MB)</label>
               <input type="number" min="0" step="0.1" value={filters.size_max_mb} onChange={(e) => handleChange('size_max_mb', e.target.value)} />
             </div>
+            <div>
+              <label>评论</label>
+              <select value={filters.has_comments} onChange={(e) => handleChange('has_comments', e.target.value)}>
+                <option value="">不限</option>
+                <option value="true">有评论</option>
+                <option value="false">无评论</option>
+              </select>
+            </div>
+            <div>
+              <label>区域标注</label>
+              <select value={filters.has_annotations} onChange={(e) => handleChange('has_annotations', e.target.value)}>
+                <option value="">不限</option>
+                <option value="true">有标注</option>
+                <option value="false">无标注</option>
+              </select>
+            </div>
+            <div>
+              <label>收藏</label>
+              <select value={filters.favorited} onChange={(e) => handleChange('favorited', e.target.value)}>
+                <option value="">不限</option>
+                <option value="true">我收藏的</option>
+                <option value="false">未收藏</option>
+              </select>
+            </div>
             <div className="tag-input-group">
               <label>标签（逗号分隔）</label>
               <div className="input-with-mode">
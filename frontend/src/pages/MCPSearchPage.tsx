@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react'
 import { useNavigate } from 'react-router-dom'
 import { mcpSearch } from '../api/mcp'
+import { createAlbum } from '../api/albums'
 import type { ImageMeta } from '../types'
 import ImageCard from '../components/ImageCard'
 import { useMCPSearchStore } from '../store/mcpSearchStore'
@@ -33,6 +34,7 @@ const MCPSearchPage = () => {
   const [page, setPage] = useState(() => cachedPage || 1)
   const [pageSize] = useState(() => cachedPageSize || 20)
   const [filters, setFilters] = useState<Record<string, string>>(() => cachedFilters || {})
+  const [albumMessage, setAlbumMessage] = useState<string | null>(null)
   
   // 组件挂载时从缓存恢复数据（只在首次挂载时执行）
   useEffect(() => {
@@ -112,6 +114,20 @@ const MCPSearchPage = () => {
     handleSearch(newPage)
   }
 
+  // 把当前的筛选条件保存为智能相册，之后上传的符合条件的图片也会出现在相册中
+  const handleSaveAsAlbum = async () => {
+    const name = window.prompt('智能相册名称', query.trim())
+    if (!name?.trim()) {
+      return
+    }
+    try {
+      const album = await createAlbum({ name: name.trim(), filters })
+      setAlbumMessage(`已保存为智能相册「${album.name}」`)
+    } catch (err: any) {
+      setAlbumMessage(err.response?.data?.message ?? '保存智能相册失败')
+    }
+  }
+
   // 将AI生成的筛选条件转换为ImageListPage的筛选格式，并跳转到图片列表页
   const handleGoToImageList = () => {
     if (Object.keys(filters).length === 0) {
@@ -189,10 +205,16 @@ const MCPSearchPage = () => {
         <div className="mcp-results-info">
           找到 {total} 张图片
           {Object.keys(filters).length > 0 && (
-            <button onClick={handleGoToImageList} className="goto-image-list-btn">
-              在图片库中使用这些条件搜索
-            </button>
+            <>
+              <button onClick={handleGoToImageList} className="goto-image-list-btn">
+                在图片库中使用这些条件搜索
+              </button>
+              <button onClick={handleSaveAsAlbum} className="goto-image-list-btn">
+                保存为智能相册
+              </button>
+            </>
           )}
+          {albumMessage && <span className="album-message">{albumMessage}</span>}
         </div>
       )}
 
@@ -0,0 +1,93 @@
+import { useEffect, useRef, useState } from 'react'
+import { useParams } from 'react-router-dom'
+import { fetchPlaybackManifest, playbackUrl } from '../api/slideshows'
+import type { PlaybackManifest, PlaybackSlide } from '../types'
+import './PlaybackPage.css'
+
+// pickRendition 按屏幕的物理像素选择最接近的尺寸，避免在电视上加载过大或过小的图片
+const pickRendition = (slide: PlaybackSlide) => {
+  const screenSize = Math.max(window.screen.width, window.screen.height) * (window.devicePixelRatio || 1)
+  const sizes = Object.keys(slide.renditions)
+    .map(Number)
+    .sort((a, b) => a - b)
+  const size = sizes.find((s) => s >= screenSize) ?? sizes[sizes.length - 1]
+  return playbackUrl(size ? slide.renditions[String(size)] : slide.src)
+}
+
+/**
+ * 公开播放页面
+ * 通过播放令牌获取服务端保存的幻灯片，无需登录，适合在电视浏览器上全屏播放
+ */
+const PlaybackPage = () => {
+  const { token } = useParams()
+  const [manifest, setManifest] = useState<PlaybackManifest | null>(null)
+  const [error, setError] = useState<string | null>(null)
+  const [currentIndex, setCurrentIndex] = useState(0)
+  const [started, setStarted] = useState(false) // 浏览器要求用户交互后才能播放声音
+  const audioRef = useRef<HTMLAudioElement | null>(null)
+
+  useEffect(() => {
+    if (!token) return
+    fetchPlaybackManifest(token)
+      .then(setManifest)
+      .catch((err) => setError(err.response?.data?.message ?? '幻灯片不存在'))
+  }, [token])
+
+  const slides = manifest?.slides ?? []
+  const current = slides[currentIndex]
+
+  const next = () => setCurrentIndex((prev) => (slides.length ? (prev + 1) % slides.length : 0))
+
+  // 图片按停留时间切换；视频在播放结束时切换，指定了停留时间时按停留时间截断
+  useEffect(() => {
+    if (!started || !current || (current.videoUrl && current.duration === 0)) return
+    const timer = setTimeout(next, current.duration * 1000)
+    return () => clearTimeout(timer)
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [started, currentIndex, manifest])
+
+  // 预加载下一张
+  useEffect(() => {
+    const following = slides[(currentIndex + 1) % (slides.length || 1)]
+    if (following) {
+      new Image().src = pickRendition(following)
+    }
+  }, [currentIndex, slides])
+
+  const handleStart = () => {
+    setStarted(true)
+    audioRef.current?.play().catch(() => undefined)
+    document.documentElement.requestFullscreen?.().catch(() => undefined)
+  }
+
+  if (error) {
+    return <div className="playback-page playback-message">{error}</div>
+  }
+  if (!manifest) {
+    return <div className="playback-page playback-message">加载中...</div>
+  }
+  if (slides.length === 0) {
+    return <div className="playback-page playback-message">幻灯片中没有图片</div>
+  }
+
+  return (
+    <div className="playback-page">
+      {manifest.musicUrl && <audio ref={audioRef} src={playbackUrl(manifest.musicUrl)} loop />}
+      <div key={currentIndex} className={`playback-slide transition-${current.transition}`}>
+        {current.videoUrl && started ? (
+          <video src={playbackUrl(current.videoUrl)} poster={pickRendition(current)} autoPlay muted={!!manifest.musicUrl} onEnded={next} />
+        ) : (
+          <img src={pickRendition(current)} alt="" />
+        )}
+        {current.caption && <div className="playback-caption">{current.caption}</div>}
+      </div>
+      {!started && (
+        <button className="playback-start" onClick={handleStart}>
+          ▶ {manifest.name}
+        </button>
+      )}
+    </div>
+  )
+}
+
+export default PlaybackPage
@@ -0,0 +1,124 @@
+import { useEffect, useState } from 'react'
+import { useParams } from 'react-router-dom'
+import { fetchSharedView, sharedImageUrl, unlockShare } from '../api/shares'
+import type { SharedImage, SharedView } from '../types'
+import './SharedPage.css'
+
+const PAGE_SIZE = 50
+
+/**
+ * 公开分享页面
+ * 访客无需登录即可查看分享的图片；需要密码时先输入密码，凭证保存在sessionStorage中
+ */
+const SharedPage = () => {
+  const { token = '' } = useParams()
+  const storageKey = `share-key:${token}`
+  const [key, setKey] = useState<string | null>(() => sessionStorage.getItem(storageKey))
+  const [view, setView] = useState<SharedView | null>(null)
+  const [page, setPage] = useState(1)
+  const [error, setError] = useState<string | null>(null)
+  const [passwordRequired, setPasswordRequired] = useState(false)
+  const [password, setPassword] = useState('')
+  const [selected, setSelected] = useState<SharedImage | null>(null)
+
+  useEffect(() => {
+    fetchSharedView(token, key, page, PAGE_SIZE)
+      .then((data) => {
+        setView(data)
+        setPasswordRequired(false)
+        setError(null)
+      })
+      .catch((err) => {
+        if (err.response?.data?.passwordRequired) {
+          setPasswordRequired(true)
+          return
+        }
+        setError(err.response?.data?.message ?? '分享不存在')
+      })
+  }, [token, key, page])
+
+  const handleUnlock = async (e: React.FormEvent) => {
+    e.preventDefault()
+    try {
+      const newKey = await unlockShare(token, password)
+      sessionStorage.setItem(storageKey, newKey)
+      setKey(newKey)
+      setError(null)
+    } catch (err: any) {
+      setError(err.response?.data?.message ?? '访问密码错误')
+    }
+  }
+
+  if (passwordRequired) {
+    return (
+      <div className="shared-page">
+        <form className="shared-password" onSubmit={handleUnlock}>
+          <h2>该分享需要访问密码</h2>
+          <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} autoFocus />
+          <button type="submit">查看</button>
+          {error && <div className="shared-error">{error}</div>}
+        </form>
+      </div>
+    )
+  }
+  if (error) {
+    return <div className="shared-page shared-error">{error}</div>
+  }
+  if (!view) {
+    return <div className="shared-page">加载中...</div>
+  }
+
+  const totalPages = Math.max(1, Math.ceil(view.total / view.pageSize))
+
+  return (
+    <div className="shared-page">
+      <header className="shared-header">
+        <h1>{view.title}</h1>
+        <span>
+          共{view.total}项
+          {view.expiresAt && `，${new Date(view.expiresAt).toLocaleString()}前有效`}
+        </span>
+      </header>
+
+      <div className="shared-grid">
+        {view.items.map((item) => (
+          <button key={item.id} className="shared-item" onClick={() => setSelected(item)}>
+            <img src={sharedImageUrl(token, item.id, 'thumbnail', key)} alt={item.filename} loading="lazy" />
+            {item.mediaType !== 'image' && <span className="shared-badge">{item.mediaType === 'video' ? '视频' : 'Live'}</span>}
+          </button>
+        ))}
+      </div>
+
+      {totalPages > 1 && (
+        <div className="shared-pagination">
+          <button disabled={page <= 1} onClick={() => setPage(page - 1)}>上一页</button>
+          <span>{page} / {totalPages}</span>
+          <button disabled={page >= totalPages} onClick={() => setPage(page + 1)}>下一页</button>
+        </div>
+      )}
+
+      {selected && (
+        <div className="shared-viewer" onClick={() => setSelected(null)}>
+          <div className="shared-viewer-content" onClick={(e) => e.stopPropagation()}>
+            {selected.mediaType === 'video' && !view.watermarked ? (
+              <video src={sharedImageUrl(token, selected.id, 'video', key)} controls autoPlay />
+            ) : (
+              <img src={sharedImageUrl(token, selected.id, 'view', key)} alt={selected.filename} />
+            )}
+            <div className="shared-viewer-actions">
+              <span>{selected.filename}</span>
+              {view.allowDownload && selected.mediaType !== 'video' && (
+                <a href={sharedImageUrl(token, selected.id, 'download', key)} download={selected.filename}>
+                  下载
+                </a>
+              )}
+              <button onClick={() => setSelected(null)}>关闭</button>
+            </div>
+          </div>
+        </div>
+      )}
+    </div>
+  )
+}
+
+export default SharedPage
@@ -0,0 +1,301 @@
+import { useEffect, useState } from 'react'
+import { useSearchParams } from 'react-router-dom'
+import { createShare, fetchShares, revokeShare, shareUrl, type SharePayload } from '../api/shares'
+import { createGrant, deleteGrant, fetchOutgoingGrants, type GrantPayload } from '../api/grants'
+import { fetchAlbums } from '../api/albums'
+import { fetchWatermarks } from '../api/watermarks'
+import type { Album, GrantStatus, ShareGrant, ShareKind, ShareLink, Watermark } from '../types'
+import './SharesPage.css'
+
+const KIND_LABELS: Record<ShareKind, string> = {
+  image: '单张图片',
+  tags: '标签',
+  album: '相册',
+}
+
+const GRANT_STATUS_LABELS: Record<GrantStatus, string> = {
+  pending: '等待接受',
+  accepted: '已接受',
+  declined: '已拒绝',
+}
+
+/**
+ * UserGrants 授权给其他用户
+ * 选定的图片或一个相册授权给指定用户，对方在上传页的导入标签中接受后可以浏览，允许复制时可以导入
+ */
+const UserGrants = ({ albums, presetImageId }: { albums: Album[]; presetImageId: number }) => {
+  const [grants, setGrants] = useState<ShareGrant[]>([])
+  const [recipient, setRecipient] = useState('')
+  const [target, setTarget] = useState<'images' | 'album'>('images')
+  const [imageIds, setImageIds] = useState(presetImageId ? String(presetImageId) : '')
+  const [albumId, setAlbumId] = useState(0)
+  const [access, setAccess] = useState<'read' | 'copy'>('read')
+  const [note, setNote] = useState('')
+  const [message, setMessage] = useState<string | null>(null)
+
+  const loadGrants = async () => {
+    setGrants(await fetchOutgoingGrants())
+  }
+
+  useEffect(() => {
+    loadGrants()
+  }, [])
+
+  const handleSubmit = async (event: React.FormEvent) => {
+    event.preventDefault()
+    const payload: GrantPayload = { recipient: recipient.trim(), access, message: note.trim() || undefined }
+    if (target === 'images') {
+      payload.imageIds = imageIds.split(/[,，\s]+/).map(Number).filter((id) => id > 0)
+    } else {
+      payload.albumId = albumId
+    }
+    try {
+      const grant = await createGrant(payload)
+      setMessage(`已授权给 ${grant.recipientName}，等待对方接受`)
+      setNote('')
+      loadGrants()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '授权失败')
+    }
+  }
+
+  const handleRevoke = async (grant: ShareGrant) => {
+    if (!window.confirm(`确定撤销对 ${grant.recipientName} 的授权吗？对方已导入的图片不受影响。`)) {
+      return
+    }
+    try {
+      await deleteGrant(grant.id)
+      setMessage('已撤销')
+      loadGrants()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '撤销失败')
+    }
+  }
+
+  return (
+    <>
+      <h2 className="share-section-title">授权给其他用户</h2>
+      <form className="share-form" onSubmit={handleSubmit}>
+        <input type="text" value={recipient} onChange={(e) => setRecipient(e.target.value)} placeholder="对方的用户名或邮箱" />
+        <select value={target} onChange={(e) => setTarget(e.target.value as 'images' | 'album')}>
+          <option value="images">选定的图片</option>
+          <option value="album">相册</option>
+        </select>
+        {target === 'images' ? (
+          <input type="text" value={imageIds} onChange={(e) => setImageIds(e.target.value)} placeholder="图片ID，逗号分隔" />
+        ) : (
+          <select value={albumId} onChange={(e) => setAlbumId(Number(e.target.value))}>
+            <option value={0}>选择相册</option>
+            {albums.map((album) => (
+              <option key={album.id} value={album.id}>{album.name}</option>
+            ))}
+          </select>
+        )}
+        <select value={access} onChange={(e) => setAccess(e.target.value as 'read' | 'copy')}>
+          <option value="read">仅浏览</option>
+          <option value="copy">允许导入</option>
+        </select>
+        <input type="text" value={note} onChange={(e) => setNote(e.target.value)} maxLength={500} placeholder="附言（可选）" />
+        <button type="submit">授权</button>
+      </form>
+
+      {message && <div className="share-message">{message}</div>}
+
+      <table className="share-table">
+        <thead>
+          <tr>
+            <th>接收者</th>
+            <th>范围</th>
+            <th>权限</th>
+            <th>状态</th>
+            <th />
+          </tr>
+        </thead>
+        <tbody>
+          {grants.map((grant) => (
+            <tr key={grant.id} className={grant.status === 'declined' ? 'expired' : undefined}>
+              <td>{grant.recipientName}</td>
+              <td>
+                {grant.kind === 'album' ? albums.find((album) => album.id === grant.albumId)?.name ?? '相册' : '图片'}
+                （{grant.imageCount} 张）
+              </td>
+              <td>{grant.access === 'copy' ? '允许导入' : '仅浏览'}</td>
+              <td>{GRANT_STATUS_LABELS[grant.status]}</td>
+              <td className="share-actions">
+                <button onClick={() => handleRevoke(grant)} className="danger">撤销</button>
+              </td>
+            </tr>
+          ))}
+          {grants.length === 0 && (
+            <tr>
+              <td colSpan={5}>还没有授权给其他用户</td>
+            </tr>
+          )}
+        </tbody>
+      </table>
+    </>
+  )
+}
+
+/**
+ * 分享管理页面
+ * 创建单张图片、标签或相册的公开分享链接，查看访问次数并撤销；从图片详情页进入时预选该图片
+ * 下方可以把图片或相册授权给其他用户
+ */
+const SharesPage = () => {
+  const [searchParams] = useSearchParams()
+  const presetImageId = Number(searchParams.get('imageId')) || 0
+  const [shares, setShares] = useState<ShareLink[]>([])
+  const [albums, setAlbums] = useState<Album[]>([])
+  const [kind, setKind] = useState<ShareKind>(presetImageId ? 'image' : 'tags')
+  const [imageId, setImageId] = useState(presetImageId)
+  const [tags, setTags] = useState('')
+  const [albumId, setAlbumId] = useState(0)
+  const [title, setTitle] = useState('')
+  const [password, setPassword] = useState('')
+  const [allowDownload, setAllowDownload] = useState(false)
+  const [watermarks, setWatermarks] = useState<Watermark[]>([])
+  const [watermarkId, setWatermarkId] = useState(0)
+  const [expiresAt, setExpiresAt] = useState('')
+  const [message, setMessage] = useState<string | null>(null)
+
+  const loadShares = async () => {
+    setShares(await fetchShares())
+  }
+
+  useEffect(() => {
+    loadShares()
+    fetchAlbums().then(setAlbums).catch(() => setAlbums([]))
+    fetchWatermarks().then(setWatermarks).catch(() => setWatermarks([]))
+  }, [])
+
+  const handleSubmit = async (event: React.FormEvent) => {
+    event.preventDefault()
+    const payload: SharePayload = {
+      title: title.trim() || undefined,
+      password: password || undefined,
+      allowDownload,
+      watermarkId: watermarkId || undefined,
+      expiresAt: expiresAt ? new Date(expiresAt).toISOString() : null,
+    }
+    if (kind === 'image') payload.imageId = imageId
+    if (kind === 'tags') payload.tags = tags.split(/[,，]/).map((t) => t.trim()).filter(Boolean)
+    if (kind === 'album') payload.albumId = albumId
+    try {
+      const link = await createShare(payload)
+      setMessage(`已创建分享链接：${shareUrl(link.token)}`)
+      setTitle('')
+      setPassword('')
+      loadShares()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '创建失败')
+    }
+  }
+
+  const handleCopy = async (link: ShareLink) => {
+    await navigator.clipboard.writeText(shareUrl(link.token))
+    setMessage('链接已复制')
+  }
+
+  const handleRevoke = async (link: ShareLink) => {
+    if (!window.confirm(`确定撤销“${link.title}”的分享吗？撤销后链接立即失效。`)) {
+      return
+    }
+    try {
+      await revokeShare(link.id)
+      setMessage('已撤销')
+      loadShares()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '撤销失败')
+    }
+  }
+
+  return (
+    <div className="shares-page">
+      <form className="share-form" onSubmit={handleSubmit}>
+        <select value={kind} onChange={(e) => setKind(e.target.value as ShareKind)}>
+          {Object.entries(KIND_LABELS).map(([value, label]) => (
+            <option key={value} value={value}>{label}</option>
+          ))}
+        </select>
+        {kind === 'image' && (
+          <input type="number" min={1} value={imageId || ''} onChange={(e) => setImageId(Number(e.target.value))} placeholder="图片ID" />
+        )}
+        {kind === 'tags' && (
+          <input type="text" value={tags} onChange={(e) => setTags(e.target.value)} placeholder="标签，逗号分隔，要求同时包含" />
+        )}
+        {kind === 'album' && (
+          <select value={albumId} onChange={(e) => setAlbumId(Number(e.target.value))}>
+            <option value={0}>选择相册</option>
+            {albums.map((album) => (
+              <option key={album.id} value={album.id}>{album.name}</option>
+            ))}
+          </select>
+        )}
+        <input type="text" value={title} onChange={(e) => setTitle(e.target.value)} maxLength={100} placeholder="标题（可选）" />
+        <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} placeholder="访问密码（可选）" />
+        <label>
+          过期时间
+          <input type="datetime-local" value={expiresAt} onChange={(e) => setExpiresAt(e.target.value)} />
+        </label>
+        <label>
+          <input type="checkbox" checked={allowDownload} onChange={(e) => setAllowDownload(e.target.checked)} />
+          允许下载
+        </label>
+        <select value={watermarkId} onChange={(e) => setWatermarkId(Number(e.target.value))} title="视频只显示加了水印的封面">
+          <option value={0}>不加水印</option>
+          {watermarks.map((mark) => (
+            <option key={mark.id} value={mark.id}>水印：{mark.name}</option>
+          ))}
+        </select>
+        <button type="submit">创建分享</button>
+      </form>
+
+      {message && <div className="share-message">{message}</div>}
+
+      <table className="share-table">
+        <thead>
+          <tr>
+            <th>标题</th>
+            <th>类型</th>
+            <th>访问次数</th>
+            <th>过期时间</th>
+            <th>设置</th>
+            <th />
+          </tr>
+        </thead>
+        <tbody>
+          {shares.map((link) => {
+            const expired = link.expiresAt !== null && new Date(link.expiresAt) < new Date()
+            return (
+              <tr key={link.id} className={expired ? 'expired' : undefined}>
+                <td>{link.title}</td>
+                <td>{KIND_LABELS[link.kind]}</td>
+                <td>{link.viewCount}</td>
+                <td>{link.expiresAt ? `${new Date(link.expiresAt).toLocaleString()}${expired ? '（已过期）' : ''}` : '永不过期'}</td>
+                <td>
+                  {link.hasPassword && '密码 '}
+                  {link.allowDownload && '可下载 '}
+                  {link.watermarkId ? '水印' : null}
+                </td>
+                <td className="share-actions">
+                  <button onClick={() => handleCopy(link)}>复制链接</button>
+                  <button onClick={() => handleRevoke(link)} className="danger">撤销</button>
+                </td>
+              </tr>
+            )
+          })}
+          {shares.length === 0 && (
+            <tr>
+              <td colSpan={6}>还没有分享链接</td>
+            </tr>
+          )}
+        </tbody>
+      </table>
+
+      <UserGrants albums={albums} presetImageId={presetImageId} />
+    </div>
+  )
+}
+
+export default SharesPage
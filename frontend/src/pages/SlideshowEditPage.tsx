@@ -1,6 +1,10 @@
-import { useState } from 'react'
+import { useEffect, useState } from 'react'
 import { useNavigate } from 'react-router-dom'
 import { useSlideshowStore, type SlideshowItem } from '../store/slideshowStore'
+import { createSlideshow, updateSlideshow } from '../api/slideshows'
+import { createRender, downloadRender, fetchRender } from '../api/renders'
+import { mediaUrl } from '../api/media'
+import type { RenderJob } from '../types'
 import './SlideshowEditPage.css'
 
 const SlideshowEditPage = () => {
@@ -10,7 +14,23 @@ const SlideshowEditPage = () => {
   const updateDuration = useSlideshowStore((state) => state.updateDuration)
   const removeImage = useSlideshowStore((state) => state.removeImage)
   const clear = useSlideshowStore((state) => state.clear)
+  const slideshowId = useSlideshowStore((state) => state.slideshowId)
+  const savedName = useSlideshowStore((state) => state.name)
+  const setSaved = useSlideshowStore((state) => state.setSaved)
   const [localItems, setLocalItems] = useState<SlideshowItem[]>(items)
+  const [name, setName] = useState(savedName || '我的幻灯片')
+  const [playUrl, setPlayUrl] = useState<string | null>(null)
+  const [saveMessage, setSaveMessage] = useState<string | null>(null)
+  const [renderJob, setRenderJob] = useState<RenderJob | null>(null)
+
+  // 导出任务在服务端后台渲染，每2秒查询一次进度
+  useEffect(() => {
+    if (!renderJob || renderJob.status === 'done' || renderJob.status === 'failed') return
+    const timer = setTimeout(() => {
+      fetchRender(renderJob.id).then(setRenderJob).catch(() => setRenderJob(null))
+    }, 2000)
+    return () => clearTimeout(timer)
+  }, [renderJob])
 
   const handleDurationChange = (imageId: number, duration: number) => {
     const newItems = localItems.map((item) =>
@@ -46,6 +66,43 @@ const SlideshowEditPage = () => {
     navigate('/slideshow')
   }
 
+  // 保存到服务端后可以在其他设备上播放，播放链接无需登录
+  const handleSaveToServer = async () => {
+    const payload = {
+      name: name.trim() || '我的幻灯片',
+      items: localItems.map((item) => ({ imageId: item.imageId, duration: item.duration })),
+    }
+    try {
+      const saved = slideshowId ? await updateSlideshow(slideshowId, payload) : await createSlideshow(payload)
+      setSaved(saved.id, saved.name)
+      setPlayUrl(`${window.location.origin}/play/${saved.token}`)
+      setSaveMessage('已保存')
+    } catch (err: any) {
+      setSaveMessage(err.response?.data?.message ?? '保存失败')
+    }
+  }
+
+  // 导出服务端保存的版本，未保存的修改不会包含在视频中
+  const handleExport = async () => {
+    if (!slideshowId) return
+    try {
+      setRenderJob(await createRender({ slideshowId }))
+    } catch (err: any) {
+      setSaveMessage(err.response?.data?.message ?? '导出失败')
+    }
+  }
+
+  const handleDownload = async () => {
+    if (!renderJob) return
+    const blob = await downloadRender(renderJob.id)
+    const url = URL.createObjectURL(blob)
+    const link = document.createElement('a')
+    link.href = url
+    link.download = `${renderJob.name}.mp4`
+    link.click()
+    URL.revokeObjectURL(url)
+  }
+
   const handleClear = () => {
     if (confirm('确定清空所有图片吗？')) {
       clear()
@@ -78,6 +135,23 @@ const SlideshowEditPage = () => {
       <div className="edit-header">
         <h2>编辑轮播组</h2>
         <div className="header-actions">
+          <input
+            value={name}
+            onChange={(e) => setName(e.target.value)}
+            maxLength={100}
+            placeholder="幻灯片名称"
+          />
+          <button onClick={handleSaveToServer} className="save-btn">
+            保存到服务器
+          </button>
+          <button
+            onClick={handleExport}
+            className="save-btn"
+            disabled={!slideshowId || renderJob?.status === 'pending' || renderJob?.status === 'running'}
+            title={slideshowId ? '导出服务器上保存的版本' : '请先保存到服务器'}
+          >
+            导出MP4
+          </button>
           <button onClick={handleSave} className="save-btn">
             保存并播放
           </button>
@@ -90,10 +164,37 @@ const SlideshowEditPage = () => {
         </div>
       </div>
 
+      {(saveMessage || playUrl) && (
+        <div className="save-result">
+          {saveMessage}
+          {playUrl && (
+            <>
+              ，播放链接（无需登录）：<a href={playUrl} target="_blank" rel="noreferrer">{playUrl}</a>
+            </>
+          )}
+        </div>
+      )}
+
+      {renderJob && (
+        <div className="save-result">
+          {renderJob.status === 'pending' && '视频导出排队中...'}
+          {renderJob.status === 'running' && `正在导出视频：${Math.round(renderJob.progress * 100)}%`}
+          {renderJob.status === 'failed' && `视频导出失败：${renderJob.error ?? ''}`}
+          {renderJob.status === 'done' && (
+            <>
+              视频已导出（{Math.round(renderJob.duration)}秒，{(renderJob.size / 1024 / 1024).toFixed(1)}MB）
+              <button onClick={handleDownload} className="save-btn">
+                下载
+              </button>
+            </>
+          )}
+        </div>
+      )}
+
       <div className="edit-content">
         <div className="items-list">
           {localItems.map((item, index) => {
-            const thumbnailUrl = `${import.meta.env.VITE_API_BASE_URL ?? '/api/v1'}/images/${item.imageId}/thumbnail`
+            const thumbnailUrl = mediaUrl(`/images/${item.imageId}/thumbnail`)
             return (
               <div key={item.imageId} className="edit-item">
                 <div className="item-thumbnail">
@@ -1,5 +1,6 @@
 import { useEffect, useState, useRef } from 'react'
 import { useNavigate } from 'react-router-dom'
+import { mediaUrl } from '../api/media'
 import { useSlideshowStore } from '../store/slideshowStore'
 import './SlideshowPage.css'
 
@@ -102,7 +103,7 @@ const SlideshowPage = () => {
   }
 
   const currentItem = items[currentIndex]
-  const originalUrl = `${import.meta.env.VITE_API_BASE_URL ?? '/api/v1'}/images/${currentItem.imageId}/original`
+  const originalUrl = mediaUrl(`/images/${currentItem.imageId}/original`)
 
   return (
     <div className="slideshow-page" onClick={handlePageClick}>
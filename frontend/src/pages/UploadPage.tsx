@@ -1,11 +1,37 @@
-import { useState } from 'react'
-import { uploadImage, verifyImportAccount, importImages } from '../api/images'
-import type { ImageMeta } from '../types'
+import { useEffect, useState } from 'react'
+import { uploadImage } from '../api/images'
+import { fetchIncomingGrants, respondGrant, deleteGrant, fetchGrantImages, grantThumbnailUrl, importGrantImages } from '../api/grants'
+import type { ImageMeta, ShareGrant } from '../types'
 import { useImageListStore } from '../store/imageListStore'
 import * as EXIF from 'exif-js'
 import { format } from 'date-fns'
 import './UploadPage.css'
 
+// 后端会转换为JPEG工作副本的相机格式：iPhone的HEIC/HEIF和相机RAW
+const CAMERA_EXTENSIONS = ['.heic', '.heif', '.cr2', '.nef', '.arw', '.dng']
+// 后端支持的视频格式，同名的图片和MOV视为一张Live Photo
+const VIDEO_EXTENSIONS = ['.mp4', '.m4v', '.mov']
+
+const isVideoFile = (file: File) =>
+  file.type.startsWith('video/') || VIDEO_EXTENSIONS.some(ext => file.name.toLowerCase().endsWith(ext))
+
+const baseName = (name: string) => name.replace(/\.[^.]+$/, '').toLowerCase()
+
+// pairLivePhotos 把同名的静态图片和视频合并为一项上传，其余文件单独上传
+const pairLivePhotos = (files: File[]) => {
+  const stills = new Map<string, File>()
+  files.filter(file => !isVideoFile(file)).forEach(file => stills.set(baseName(file.name), file))
+  const motions = new Map<string, File>()
+  files.filter(isVideoFile).forEach(file => {
+    if (stills.has(baseName(file.name))) {
+      motions.set(baseName(file.name), file)
+    }
+  })
+  return files
+    .filter(file => !(isVideoFile(file) && motions.get(baseName(file.name)) === file))
+    .map(file => ({ file, motion: isVideoFile(file) ? undefined : motions.get(baseName(file.name)) }))
+}
+
 const UploadPage = () => {
   const setHasNewImages = useImageListStore((state) => state.setHasNewImages)
   
@@ -21,13 +47,21 @@ const UploadPage = () => {
   
   // 导入相关状态
   const [activeTab, setActiveTab] = useState<'upload' | 'import'>('upload')
-  const [importUsername, setImportUsername] = useState('')
-  const [importPassword, setImportPassword] = useState('')
+  const [grants, setGrants] = useState<ShareGrant[]>([])
+  const [activeGrant, setActiveGrant] = useState<ShareGrant | null>(null)
   const [importImagesList, setImportImagesList] = useState<ImageMeta[]>([])
   const [selectedImageIds, setSelectedImageIds] = useState<Set<number>>(new Set())
   const [importLoading, setImportLoading] = useState(false)
   const [importMessage, setImportMessage] = useState<string | null>(null)
 
+  // 切换到导入页时加载他人授权给自己的图片
+  useEffect(() => {
+    if (activeTab !== 'import') return
+    fetchIncomingGrants()
+      .then(setGrants)
+      .catch((err: any) => setImportMessage(err.response?.data?.message ?? '加载授权失败'))
+  }, [activeTab])
+
   const extractEXIFTags = (file: File): Promise<string[]> => {
     return new Promise((resolve) => {
       EXIF.getData(file as any, function(this: any) {
@@ -53,11 +87,16 @@ const UploadPage = () => {
   const handleFileChange = async (e: React.ChangeEvent<HTMLInputElement>) => {
     const selectedFiles = Array.from(e.target.files || [])
     
-    // 过滤出图片文件
-    const imageFiles = selectedFiles.filter(file => file.type.startsWith('image/'))
+    // 过滤出图片和视频文件，HEIC和相机RAW在部分浏览器中没有MIME类型，按扩展名识别
+    const imageFiles = selectedFiles.filter(
+      file =>
+        file.type.startsWith('image/') ||
+        isVideoFile(file) ||
+        CAMERA_EXTENSIONS.some(ext => file.name.toLowerCase().endsWith(ext))
+    )
     
     if (imageFiles.length === 0) {
-      setMessage('请选择图片文件')
+      setMessage('请选择图片或视频文件')
       // 重置input，允许重新选择
       e.target.value = ''
       return
@@ -102,7 +141,9 @@ const UploadPage = () => {
     }
     setLoading(true)
     setMessage(null)
-    setUploadProgress({ current: 0, total: files.length })
+    // Live Photo的图片和MOV合并为一项
+    const uploads = pairLivePhotos(files)
+    setUploadProgress({ current: 0, total: uploads.length })
     
     try {
       // 解析标签字符串，支持中英文逗号分隔
@@ -120,15 +161,15 @@ const UploadPage = () => {
       let successCount = 0
       let failCount = 0
       
-      for (let i = 0; i < files.length; i++) {
+      for (let i = 0; i < uploads.length; i++) {
         try {
-          await uploadImage(files[i], allTags, useAI)
+          await uploadImage(uploads[i].file, allTags, useAI, uploads[i].motion)
           successCount++
         } catch (err: any) {
-          console.error(`上传文件 ${files[i].name} 失败:`, err)
+          console.error(`上传文件 ${uploads[i].file.name} 失败:`, err)
           failCount++
         }
-        setUploadProgress({ current: i + 1, total: files.length })
+        setUploadProgress({ current: i + 1, total: uploads.length })
       }
       
       if (failCount === 0) {
@@ -153,29 +194,50 @@ const UploadPage = () => {
   }
 
   // 导入相关处理函数
-  const handleVerifyAccount = async (e: React.FormEvent) => {
-    e.preventDefault()
-    if (!importUsername || !importPassword) {
-      setImportMessage('请输入用户名和密码')
-      return
-    }
+  const handleOpenGrant = async (grant: ShareGrant) => {
     setImportLoading(true)
     setImportMessage(null)
     try {
-      const result = await verifyImportAccount(importUsername, importPassword)
-      setImportImagesList(result.images)
-      // 默认选中所有图片
-      setSelectedImageIds(new Set(result.images.map((img) => img.id)))
-      setImportMessage(`找到 ${result.images.length} 张图片`)
+      const result = await fetchGrantImages(grant.id, 1, 500)
+      setActiveGrant(grant)
+      setImportImagesList(result.items)
+      // 可以导入时默认选中所有图片
+      setSelectedImageIds(grant.access === 'copy' ? new Set(result.items.map((img) => img.id)) : new Set())
+      setImportMessage(`找到 ${result.total} 张图片`)
     } catch (err: any) {
-      setImportMessage(err.response?.data?.message ?? '验证失败')
-      setImportImagesList([])
-      setSelectedImageIds(new Set())
+      setImportMessage(err.response?.data?.message ?? '加载图片失败')
     } finally {
       setImportLoading(false)
     }
   }
 
+  const handleRespondGrant = async (grant: ShareGrant, accept: boolean) => {
+    try {
+      const updated = await respondGrant(grant.id, accept)
+      setGrants((prev) => prev.map((item) => (item.id === updated.id ? updated : item)))
+      if (accept) {
+        await handleOpenGrant(updated)
+      }
+    } catch (err: any) {
+      setImportMessage(err.response?.data?.message ?? '操作失败')
+    }
+  }
+
+  const handleRemoveGrant = async (grant: ShareGrant) => {
+    if (!window.confirm(`移除 ${grant.ownerName} 的授权？已导入的图片不受影响`)) return
+    try {
+      await deleteGrant(grant.id)
+      setGrants((prev) => prev.filter((item) => item.id !== grant.id))
+      if (activeGrant?.id === grant.id) {
+        setActiveGrant(null)
+        setImportImagesList([])
+        setSelectedImageIds(new Set())
+      }
+    } catch (err: any) {
+      setImportMessage(err.response?.data?.message ?? '移除失败')
+    }
+  }
+
   const handleToggleImageSelection = (imageId: number) => {
     const newSelected = new Set(selectedImageIds)
     if (newSelected.has(imageId)) {
@@ -195,24 +257,17 @@ const UploadPage = () => {
   }
 
   const handleImport = async () => {
+    if (!activeGrant) return
     if (selectedImageIds.size === 0) {
       setImportMessage('请至少选择一张图片')
       return
     }
-    if (!importUsername || !importPassword) {
-      setImportMessage('请输入用户名和密码')
-      return
-    }
     setImportLoading(true)
     setImportMessage(null)
     try {
-      const result = await importImages(importUsername, importPassword, Array.from(selectedImageIds))
+      const result = await importGrantImages(activeGrant.id, Array.from(selectedImageIds))
       setImportMessage(result.message)
-      // 清空状态
-      setImportImagesList([])
       setSelectedImageIds(new Set())
-      setImportUsername('')
-      setImportPassword('')
       // 标记有新图片导入，当用户切换到图片列表页面时会自动刷新
       setHasNewImages(true)
     } catch (err: any) {
@@ -304,7 +359,7 @@ const UploadPage = () => {
             <input
               key={uploadMode} // 当模式改变时，强制重新渲染input以重置状态
               type="file"
-              accept="image/*"
+              accept={`image/*,video/mp4,video/quicktime,${CAMERA_EXTENSIONS.join(',')},${VIDEO_EXTENSIONS.join(',')}`}
               multiple={uploadMode !== 'single'}
               {...(uploadMode === 'folder' ? { 
                 webkitdirectory: 'true' as any,
@@ -314,8 +369,8 @@ const UploadPage = () => {
             />
             {files.length === 0 ? (
               <span>
-                {uploadMode === 'single' && '点击或拖拽图片至此（最大10MB，支持 JPEG, PNG, GIF, BMP, TIFF, WebP）'}
-                {uploadMode === 'multiple' && '点击选择多张图片或拖拽图片至此'}
+                {uploadMode === 'single' && '点击或拖拽图片至此（最大10MB，支持 JPEG, PNG, GIF, BMP, TIFF, WebP, HEIC, 相机RAW；MP4/MOV视频最大500MB）'}
+                {uploadMode === 'multiple' && '点击选择多张图片或拖拽图片至此（同名的图片和MOV按Live Photo上传）'}
                 {uploadMode === 'folder' && '点击选择文件夹（将上传文件夹下所有图片）'}
               </span>
             ) : (
@@ -395,34 +450,51 @@ const UploadPage = () => {
       {activeTab === 'import' && (
         <div className="import-card">
           <h2>从其他账户导入图片</h2>
+          <p className="import-hint">其他用户在“分享”页把图片或相册授权给你后会显示在这里，接受后即可浏览；授权允许复制时可以导入到当前工作区</p>
           {importMessage && <div className={`upload-message ${importMessage.includes('成功') ? 'success' : ''}`}>{importMessage}</div>}
 
-          <form onSubmit={handleVerifyAccount}>
-            <label>
-              用户名或邮箱
-              <input
-                type="text"
-                value={importUsername}
-                onChange={(e) => setImportUsername(e.target.value)}
-                placeholder="请输入要导入的账户用户名或邮箱"
-              />
-            </label>
-            <label>
-              密码
-              <input
-                type="password"
-                value={importPassword}
-                onChange={(e) => setImportPassword(e.target.value)}
-                placeholder="请输入账户密码"
-              />
-            </label>
-            <button type="submit" disabled={importLoading}>
-              {importLoading ? '验证中...' : '验证并查看图片'}
-            </button>
-          </form>
+          {grants.length === 0 ? (
+            <div className="grant-empty">暂时没有收到授权</div>
+          ) : (
+            <ul className="grant-list">
+              {grants.map((grant) => (
+                <li key={grant.id} className={`grant-item ${activeGrant?.id === grant.id ? 'active' : ''}`}>
+                  <div className="grant-info">
+                    <strong>{grant.ownerName}</strong>
+                    <span>
+                      {grant.kind === 'album' ? '相册' : '图片'} · {grant.imageCount} 张 · {grant.access === 'copy' ? '可导入' : '仅浏览'}
+                    </span>
+                    {grant.message && <span className="grant-message">{grant.message}</span>}
+                  </div>
+                  <div className="grant-actions">
+                    {grant.status === 'accepted' ? (
+                      <button type="button" onClick={() => handleOpenGrant(grant)} disabled={importLoading}>
+                        浏览
+                      </button>
+                    ) : (
+                      <>
+                        <button type="button" onClick={() => handleRespondGrant(grant, true)}>
+                          接受
+                        </button>
+                        {grant.status === 'pending' && (
+                          <button type="button" className="secondary" onClick={() => handleRespondGrant(grant, false)}>
+                            拒绝
+                          </button>
+                        )}
+                      </>
+                    )}
+                    <button type="button" className="secondary" onClick={() => handleRemoveGrant(grant)}>
+                      移除
+                    </button>
+                  </div>
+                </li>
+              ))}
+            </ul>
+          )}
 
           {importImagesList.length > 0 && (
             <div className="import-images-section">
+              {activeGrant?.access === 'copy' && (
               <div className="import-controls">
                 <button
                   type="button"
@@ -443,15 +515,16 @@ const UploadPage = () => {
                   {importLoading ? '导入中...' : '导入选中图片'}
                 </button>
               </div>
+              )}
               <div className="import-image-grid">
                 {importImagesList.map((image) => {
-                  const thumbnailUrl = `${import.meta.env.VITE_API_BASE_URL ?? '/api/v1'}/images/${image.id}/thumbnail`
+                  const thumbnailUrl = grantThumbnailUrl(activeGrant!.id, image.id)
                   const isSelected = selectedImageIds.has(image.id)
                   return (
                     <div
                       key={image.id}
                       className={`import-image-item ${isSelected ? 'selected' : ''}`}
-                      onClick={() => handleToggleImageSelection(image.id)}
+                      onClick={() => activeGrant?.access === 'copy' && handleToggleImageSelection(image.id)}
                     >
                       <div className="import-image-wrapper">
                         <img src={thumbnailUrl} alt={image.originalFilename} />
@@ -0,0 +1,262 @@
+import { useEffect, useState } from 'react'
+import {
+  addWorkspaceMember,
+  createWorkspace,
+  deleteWorkspace,
+  fetchWorkspaceMembers,
+  fetchWorkspaces,
+  removeWorkspaceMember,
+  renameWorkspace,
+  updateWorkspaceMember,
+} from '../api/workspaces'
+import { useAuthStore } from '../store/authStore'
+import { useWorkspaceStore } from '../store/workspaceStore'
+import type { Workspace, WorkspaceMember, WorkspaceRole } from '../types'
+import './WorkspacesPage.css'
+
+const ROLE_LABELS: Record<WorkspaceRole, string> = {
+  owner: '所有者',
+  admin: '管理员',
+  editor: '编辑者',
+  viewer: '查看者',
+}
+
+const ROLE_RANKS: Record<WorkspaceRole, number> = { viewer: 1, editor: 2, admin: 3, owner: 4 }
+
+const can = (role: WorkspaceRole | undefined, required: WorkspaceRole) => !!role && ROLE_RANKS[role] >= ROLE_RANKS[required]
+
+/**
+ * Members 工作区成员管理
+ * 管理员可以添加、移除成员和修改角色；只有所有者可以设置管理员；成员可以自己退出
+ */
+const Members = ({ workspace, onLeave }: { workspace: Workspace; onLeave: () => void }) => {
+  const userId = useAuthStore((state) => state.user?.id)
+  const [members, setMembers] = useState<WorkspaceMember[]>([])
+  const [user, setUser] = useState('')
+  const [role, setRole] = useState<WorkspaceRole>('editor')
+  const [message, setMessage] = useState<string | null>(null)
+  const manage = can(workspace.role, 'admin')
+  // 可以授予的角色：管理员只能授予编辑者和查看者
+  const assignable: WorkspaceRole[] = workspace.role === 'owner' ? ['admin', 'editor', 'viewer'] : ['editor', 'viewer']
+
+  const loadMembers = async () => {
+    setMembers(await fetchWorkspaceMembers(workspace.id))
+  }
+
+  useEffect(() => {
+    loadMembers()
+  }, [workspace.id])
+
+  const handleAdd = async (event: React.FormEvent) => {
+    event.preventDefault()
+    try {
+      const member = await addWorkspaceMember(workspace.id, user.trim(), role)
+      setMessage(`已添加 ${member.username}`)
+      setUser('')
+      loadMembers()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '添加失败')
+    }
+  }
+
+  const handleRole = async (member: WorkspaceMember, next: WorkspaceRole) => {
+    try {
+      await updateWorkspaceMember(workspace.id, member.userId, next)
+      loadMembers()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '修改失败')
+    }
+  }
+
+  const handleRemove = async (member: WorkspaceMember) => {
+    const self = member.userId === userId
+    if (!window.confirm(self ? `确定退出“${workspace.name}”吗？` : `确定移除 ${member.username} 吗？其上传的图片会留在工作区中。`)) {
+      return
+    }
+    try {
+      await removeWorkspaceMember(workspace.id, member.userId)
+      if (self) {
+        onLeave()
+        return
+      }
+      loadMembers()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '移除失败')
+    }
+  }
+
+  // 所有者不能被移除；管理员只能管理编辑者和查看者
+  const manageable = (member: WorkspaceMember) =>
+    member.role !== 'owner' && manage && (workspace.role === 'owner' || member.role !== 'admin')
+
+  return (
+    <>
+      <h2 className="workspace-section-title">“{workspace.name}”的成员</h2>
+      {manage && (
+        <form className="workspace-form" onSubmit={handleAdd}>
+          <input type="text" value={user} onChange={(e) => setUser(e.target.value)} placeholder="用户名或邮箱" />
+          <select value={role} onChange={(e) => setRole(e.target.value as WorkspaceRole)}>
+            {assignable.map((value) => (
+              <option key={value} value={value}>{ROLE_LABELS[value]}</option>
+            ))}
+          </select>
+          <button type="submit">添加成员</button>
+        </form>
+      )}
+
+      {message && <div className="workspace-message">{message}</div>}
+
+      <table className="workspace-table">
+        <thead>
+          <tr>
+            <th>成员</th>
+            <th>角色</th>
+            <th>加入时间</th>
+            <th />
+          </tr>
+        </thead>
+        <tbody>
+          {members.map((member) => (
+            <tr key={member.id}>
+              <td>{member.username}</td>
+              <td>
+                {manageable(member) ? (
+                  <select value={member.role} onChange={(e) => handleRole(member, e.target.value as WorkspaceRole)}>
+                    {assignable.map((value) => (
+                      <option key={value} value={value}>{ROLE_LABELS[value]}</option>
+                    ))}
+                  </select>
+                ) : (
+                  ROLE_LABELS[member.role]
+                )}
+              </td>
+              <td>{new Date(member.createdAt).toLocaleDateString()}</td>
+              <td className="workspace-actions">
+                {(manageable(member) || (member.userId === userId && member.role !== 'owner')) && (
+                  <button onClick={() => handleRemove(member)} className="danger">
+                    {member.userId === userId ? '退出' : '移除'}
+                  </button>
+                )}
+              </td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    </>
+  )
+}
+
+/**
+ * 团队工作区页面
+ * 创建团队工作区，重命名、删除工作区和管理成员；个人工作区只有自己，不能添加成员
+ */
+const WorkspacesPage = () => {
+  const { workspaceId, setWorkspace } = useWorkspaceStore()
+  const [workspaces, setWorkspaces] = useState<Workspace[]>([])
+  const [selectedId, setSelectedId] = useState(0)
+  const [name, setName] = useState('')
+  const [message, setMessage] = useState<string | null>(null)
+
+  const loadWorkspaces = async () => {
+    setWorkspaces(await fetchWorkspaces())
+  }
+
+  useEffect(() => {
+    loadWorkspaces()
+  }, [])
+
+  const handleCreate = async (event: React.FormEvent) => {
+    event.preventDefault()
+    try {
+      const workspace = await createWorkspace(name.trim())
+      setMessage(`已创建“${workspace.name}”`)
+      setName('')
+      setSelectedId(workspace.id)
+      loadWorkspaces()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '创建失败')
+    }
+  }
+
+  const handleRename = async (workspace: Workspace) => {
+    const next = window.prompt('新的工作区名称', workspace.name)?.trim()
+    if (!next || next === workspace.name) {
+      return
+    }
+    try {
+      await renameWorkspace(workspace.id, next)
+      loadWorkspaces()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '重命名失败')
+    }
+  }
+
+  const handleDelete = async (workspace: Workspace) => {
+    if (!window.confirm(`确定删除“${workspace.name}”吗？工作区的标签、相册和幻灯片会一起删除。`)) {
+      return
+    }
+    try {
+      await deleteWorkspace(workspace.id)
+      setMessage('已删除')
+      if (selectedId === workspace.id) setSelectedId(0)
+      if (workspaceId === workspace.id) setWorkspace(null)
+      loadWorkspaces()
+    } catch (err: any) {
+      setMessage(err.response?.data?.message ?? '删除失败')
+    }
+  }
+
+  const handleLeave = () => {
+    if (workspaceId === selectedId) setWorkspace(null)
+    setSelectedId(0)
+    setMessage('已退出工作区')
+    loadWorkspaces()
+  }
+
+  const selected = workspaces.find((workspace) => workspace.id === selectedId && !workspace.personal)
+
+  return (
+    <div className="workspaces-page">
+      <form className="workspace-form" onSubmit={handleCreate}>
+        <input type="text" value={name} onChange={(e) => setName(e.target.value)} maxLength={100} placeholder="团队工作区名称" />
+        <button type="submit">创建工作区</button>
+      </form>
+
+      {message && <div className="workspace-message">{message}</div>}
+
+      <table className="workspace-table">
+        <thead>
+          <tr>
+            <th>工作区</th>
+            <th>我的角色</th>
+            <th>成员数</th>
+            <th />
+          </tr>
+        </thead>
+        <tbody>
+          {workspaces.map((workspace) => (
+            <tr key={workspace.id} className={workspace.id === selectedId ? 'selected' : undefined}>
+              <td>{workspace.personal ? '个人工作区' : workspace.name}</td>
+              <td>{workspace.role ? ROLE_LABELS[workspace.role] : ''}</td>
+              <td>{workspace.memberCount}</td>
+              <td className="workspace-actions">
+                <button onClick={() => setWorkspace(workspace.personal ? null : workspace.id)}>切换到此</button>
+                {!workspace.personal && <button onClick={() => setSelectedId(workspace.id)}>成员</button>}
+                {!workspace.personal && can(workspace.role, 'admin') && (
+                  <button onClick={() => handleRename(workspace)}>重命名</button>
+                )}
+                {!workspace.personal && workspace.role === 'owner' && (
+                  <button onClick={() => handleDelete(workspace)} className="danger">删除</button>
+                )}
+              </td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+
+      {selected && <Members workspace={selected} onLeave={handleLeave} />}
+    </div>
+  )
+}
+
+export default WorkspacesPage
@@ -0,0 +1,17 @@
+import { create } from 'zustand'
+
+// 当前工作区的媒体令牌，<img>、<video>等标签通过查询参数mt携带它访问图片文件
+// 令牌只能访问签发时的工作区，切换工作区或重新登录后重新获取；不持久化
+interface MediaState {
+  token: string | null
+  workspaceId: number | null | undefined // 令牌所属的工作区，null为个人工作区，undefined表示还没有获取
+  setToken: (token: string | null, workspaceId: number | null) => void
+  clear: () => void
+}
+
+export const useMediaStore = create<MediaState>()((set) => ({
+  token: null,
+  workspaceId: undefined,
+  setToken: (token, workspaceId) => set({ token, workspaceId }),
+  clear: () => set({ token: null, workspaceId: undefined }),
+}))
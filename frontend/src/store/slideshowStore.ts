@@ -10,17 +10,22 @@ export interface SlideshowItem {
 
 interface SlideshowState {
   items: SlideshowItem[]
+  slideshowId: number | null // 已保存到服务端的幻灯片ID，再次保存时更新该幻灯片
+  name: string
   addImage: (image: ImageMeta) => void
   removeImage: (imageId: number) => void
   updateOrder: (items: SlideshowItem[]) => void
   updateDuration: (imageId: number, duration: number) => void
   clear: () => void
+  setSaved: (slideshowId: number, name: string) => void
 }
 
 export const useSlideshowStore = create<SlideshowState>()(
   persist(
     (set) => ({
       items: [],
+      slideshowId: null,
+      name: '',
       addImage: (image) =>
         set((state) => {
           // 检查是否已存在
@@ -49,7 +54,8 @@ export const useSlideshowStore = create<SlideshowState>()(
             item.imageId === imageId ? { ...item, duration } : item
           ),
         })),
-      clear: () => set({ items: [] }),
+      clear: () => set({ items: [], slideshowId: null, name: '' }),
+      setSaved: (slideshowId, name) => set({ slideshowId, name }),
     }),
     {
       name: 'image-manager-slideshow',
@@ -0,0 +1,20 @@
+import { create } from 'zustand'
+import { persist } from 'zustand/middleware'
+
+// 当前工作区，API请求通过X-Workspace-ID请求头携带；为null时使用个人工作区
+interface WorkspaceState {
+  workspaceId: number | null
+  setWorkspace: (workspaceId: number | null) => void
+}
+
+export const useWorkspaceStore = create<WorkspaceState>()(
+  persist(
+    (set) => ({
+      workspaceId: null,
+      setWorkspace: (workspaceId) => set({ workspaceId }),
+    }),
+    {
+      name: 'image-manager-workspace',
+    },
+  ),
+)
@@ -14,6 +14,266 @@ export interface Thumbnail {
   imageId: number
   width: number
   height: number
+  mimeType?: string // 动图的缩略图为image/gif
+}
+
+export interface ImageColor {
+  rank: number
+  hex: string
+  l: number
+  a: number
+  b: number
+  chroma: number
+  hue: number
+  ratio: number
+}
+
+export interface ImageQuality {
+  sharpness: number
+  noise: number
+  brightness: number
+  shadowClip: number
+  highlightClip: number
+  exposure: 'under' | 'over' | 'normal'
+  createdAt: string
+}
+
+export interface ImageText {
+  engine: 'tesseract' | 'ai'
+  content: string
+  createdAt: string
+}
+
+export interface EditOperation {
+  type: 'crop' | 'adjust' | 'rotate' | 'flip' | 'resize' | 'sharpen' | 'blur' | 'grayscale' | 'sepia' | 'watermark' | 'format'
+  // crop、resize
+  x?: number
+  y?: number
+  width?: number
+  height?: number
+  mode?: 'fit' | 'fill' | 'stretch'
+  // adjust
+  brightness?: number
+  contrast?: number
+  saturation?: number
+  hue?: number
+  // rotate：顺时针角度，任意角度时用background填充空白
+  angle?: number
+  background?: string
+  // flip
+  direction?: 'horizontal' | 'vertical'
+  // sharpen、blur
+  sigma?: number
+  // watermark：提交时只需watermarkId，服务端返回的版本中带有参数快照watermark
+  watermarkId?: number
+  watermark?: WatermarkSpec
+  // format：输出格式，quality只对JPEG有效
+  format?: 'jpeg' | 'png' | 'webp'
+  quality?: number
+}
+
+export type WatermarkPosition =
+  | 'top-left'
+  | 'top'
+  | 'top-right'
+  | 'left'
+  | 'center'
+  | 'right'
+  | 'bottom-left'
+  | 'bottom'
+  | 'bottom-right'
+  | 'tile'
+
+export interface WatermarkSpec {
+  type: 'text' | 'image'
+  text?: string
+  color?: string
+  position: WatermarkPosition
+  opacity: number // 不透明度（0到1）
+  scale: number // 水印宽度占图片宽度的比例（0到1）
+  margin: number // 与边缘的距离占图片短边的比例
+}
+
+// Watermark 水印预设，图片水印的标志通过 /watermarks/:id/logo 获取
+export interface Watermark extends WatermarkSpec {
+  id: number
+  userId: number
+  name: string
+  createdAt: string
+  updatedAt: string
+}
+
+// Album 相册，图片通过 fetchAlbumImages 获取；普通相册也可以用 fetchImages({ album: id })
+export interface Album {
+  id: number
+  userId: number
+  name: string
+  description: string
+  kind: 'manual' | 'smart' // smart为智能相册，图片由保存的筛选条件实时计算
+  filters?: string // 智能相册的筛选条件（JSON格式）
+  cachedAt?: string // 智能相册数量和封面的计算时间
+  coverImageId: number | null // 指定的封面，为空时使用第一张图片
+  coverId: number // 实际显示的封面图片ID，相册为空时为0
+  imageCount: number
+  items?: AlbumItem[] // 仅相册详情返回
+  createdAt: string
+  updatedAt: string
+}
+
+export interface AlbumItem {
+  id: number
+  albumId: number
+  imageId: number
+  position: number
+  caption: string // 图片在该相册中的说明
+  createdAt: string
+}
+
+export type SlideshowTransition = 'none' | 'fade' | 'slide' | 'zoom'
+
+// Slideshow 服务端保存的幻灯片，token用于无需登录的公开播放（/play/:token）
+export interface Slideshow {
+  id: number
+  userId: number
+  name: string
+  transition: SlideshowTransition // 默认转场效果
+  musicMimeType: string
+  musicName: string // 背景音乐的原始文件名，为空表示没有背景音乐
+  token: string
+  items?: SlideshowSlide[]
+  createdAt: string
+  updatedAt: string
+}
+
+export interface SlideshowSlide {
+  id: number
+  slideshowId: number
+  imageId: number
+  position: number
+  duration: number // 停留秒数，视频为0时播放完整视频
+  transition: SlideshowTransition | '' // 为空时使用幻灯片的默认效果
+  caption: string
+}
+
+// PlaybackManifest 公开播放清单，地址以 /api/v1 开头
+export interface PlaybackManifest {
+  name: string
+  transition: SlideshowTransition
+  musicUrl?: string
+  sizes: number[] // 提供的渲染尺寸（最长边像素）
+  slides: PlaybackSlide[]
+}
+
+export interface PlaybackSlide {
+  id: string // 只在该幻灯片的播放接口中有效，不是图片ID
+  mediaType: 'image' | 'video' | 'live'
+  duration: number
+  transition: SlideshowTransition
+  caption?: string
+  width: number
+  height: number
+  src: string // 默认尺寸（1080p）的地址
+  renditions: Record<string, string> // 键为最长边像素
+  videoUrl?: string
+}
+
+export type RenderStatus = 'pending' | 'running' | 'done' | 'failed'
+
+// RenderJob 相册或幻灯片导出为MP4的后台任务
+export interface RenderJob {
+  id: number
+  sourceType: 'album' | 'slideshow'
+  sourceId: number
+  name: string
+  resolution: 720 | 1080 | 2160
+  slideDuration: number
+  kenBurns: boolean
+  audio: boolean
+  status: RenderStatus
+  progress: number // 0到1
+  error?: string
+  size: number // 生成的文件大小（字节）
+  duration: number // 视频时长（秒）
+  startedAt?: string
+  finishedAt?: string
+  createdAt: string
+}
+
+export type ShareKind = 'image' | 'tags' | 'album'
+
+// ShareLink 公开分享链接，访客通过 /s/:token 无需登录查看
+export interface ShareLink {
+  id: number
+  token: string
+  kind: ShareKind
+  targetId?: number // 图片ID或相册ID
+  tags?: string // 标签分享的标签名，逗号分隔
+  title: string
+  hasPassword: boolean
+  allowDownload: boolean
+  watermarkId?: number // 访客浏览和下载时叠加的水印预设
+  expiresAt: string | null
+  viewCount: number
+  createdAt: string
+}
+
+// SharedView 访客看到的分享内容，不包含标签、EXIF等信息
+export interface SharedView {
+  title: string
+  kind: ShareKind
+  allowDownload: boolean
+  watermarked: boolean // 叠加了水印时视频只能看到封面
+  expiresAt?: string
+  total: number
+  page: number
+  pageSize: number
+  items: SharedImage[]
+}
+
+export interface SharedImage {
+  id: string // 只在该分享的接口中有效，不是图片ID
+  filename: string
+  mediaType: 'image' | 'video' | 'live'
+  width: number
+  height: number
+  durationMs?: number
+}
+
+export type GrantStatus = 'pending' | 'accepted' | 'declined'
+
+// ShareGrant 用户之间的分享授权，接收者接受后可以浏览，access为copy时可以导入
+export interface ShareGrant {
+  id: number
+  ownerId: number
+  recipientId: number
+  ownerName: string
+  recipientName: string
+  kind: 'images' | 'album'
+  albumId?: number
+  access: 'read' | 'copy'
+  status: GrantStatus
+  message: string
+  imageCount: number
+  respondedAt?: string
+  createdAt: string
+}
+
+export interface ImageVersion {
+  id: number
+  imageId: number
+  parentId: number | null
+  label: string
+  operations: EditOperation[]
+  width: number
+  height: number
+  createdAt: string
+}
+
+export interface EditHistory {
+  versions: ImageVersion[]
+  currentVersionId: number | null
+  canUndo: boolean
+  canRedo: boolean
 }
 
 export interface ImageMeta {
@@ -22,12 +282,24 @@ export interface ImageMeta {
   storedFilename: string
   filePath: string
   mimeType: string
+  sourceMimeType?: string // HEIC/HEIF或相机RAW原始文件的类型，mimeType是其JPEG工作副本的类型
   fileSize: number
   width: number
   height: number
+  frameCount?: number // 动图的帧数，静态图片为1
+  durationMs?: number // 动图一次播放的时长，视频和Live Photo的时长（毫秒）
+  mediaType?: 'image' | 'video' | 'live' // 媒体类型：视频的图片内容是封面帧，Live Photo附带一段动态视频
+  videoMimeType?: string // 视频或Live Photo动态部分的类型
   createdAt: string
+  editVersionId?: number | null
+  derivedFromId?: number | null // 由哪张图片编辑另存而来
   tags?: Tag[]
   thumbnail?: Thumbnail
+  colors?: ImageColor[]
+  quality?: ImageQuality
+  text?: ImageText
+  commentCount?: number // 评论数量
+  favorited?: boolean // 当前用户是否已收藏
   exif?: {
     cameraMake?: string
     cameraModel?: string
@@ -36,6 +308,26 @@ export interface ImageMeta {
   }
 }
 
+export interface Person {
+  id: number
+  name: string
+  faceCount: number
+  coverFaceId: number
+  createdAt: string
+  updatedAt: string
+}
+
+export interface Face {
+  id: number
+  imageId: number
+  personId: number
+  x: number
+  y: number
+  width: number
+  height: number
+  score: number
+}
+
 export interface PaginatedResponse<T> {
   total: number
   page: number
@@ -48,3 +340,54 @@ export interface AuthResponse {
   user: User
 }
 
+
+// WorkspaceRole 工作区成员角色，权限依次递增
+export type WorkspaceRole = 'viewer' | 'editor' | 'admin' | 'owner'
+
+// Workspace 工作区，图片、标签和相册都属于工作区；每个用户有一个个人工作区
+export interface Workspace {
+  id: number
+  name: string
+  personal: boolean
+  ownerId: number
+  role?: WorkspaceRole // 当前用户在该工作区中的角色
+  memberCount: number
+  createdAt: string
+  updatedAt: string
+}
+
+export interface WorkspaceMember {
+  id: number
+  workspaceId: number
+  userId: number
+  username: string
+  role: WorkspaceRole
+  createdAt: string
+}
+
+// ImageComment 图片评论，parentId不为空时是对另一条评论的回复
+export interface ImageComment {
+  id: number
+  imageId: number
+  userId: number
+  parentId: number | null
+  username: string
+  body: string
+  createdAt: string
+  updatedAt: string
+}
+
+// ImageAnnotation 图片区域标注，坐标和尺寸以原图像素为单位，显示时按缩放比例换算
+export interface ImageAnnotation {
+  id: number
+  imageId: number
+  userId: number
+  username: string
+  x: number
+  y: number
+  width: number
+  height: number
+  note: string
+  createdAt: string
+  updatedAt: string
+}